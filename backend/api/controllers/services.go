@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"syncer.net/core/services"
+)
+
+// ServicesController lists providers and connects or disconnects the user's accounts
+type ServicesController struct {
+	registry        *services.ServiceRegistry
+	oauth           *services.OAuthManager
+	callbackBaseURL string
+	frontendURL     string
+}
+
+// NewServicesController creates a new services controller. Provider callbacks are served under
+// callbackBaseURL and finish by redirecting the browser to frontendURL.
+func NewServicesController(registry *services.ServiceRegistry, oauth *services.OAuthManager, callbackBaseURL, frontendURL string) *ServicesController {
+	return &ServicesController{
+		registry:        registry,
+		oauth:           oauth,
+		callbackBaseURL: strings.TrimSuffix(callbackBaseURL, "/"),
+		frontendURL:     strings.TrimSuffix(frontendURL, "/"),
+	}
+}
+
+// serviceStatus is a provider together with the user's accounts connected to it
+type serviceStatus struct {
+	services.ServiceInfo
+	Connected   bool                  `json:"connected"`
+	Connections []services.Connection `json:"connections"`
+}
+
+// ListServices - GET /api/services
+// List available providers with the user's connection status
+func (c *ServicesController) ListServices(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	connections, err := c.oauth.ListConnections(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected services"})
+		return
+	}
+
+	connected := make(map[string][]services.Connection)
+	for _, connection := range connections {
+		connected[connection.ServiceName] = append(connected[connection.ServiceName], connection)
+	}
+
+	infos := c.registry.ListServices()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	statuses := make([]serviceStatus, 0, len(infos))
+	for _, info := range infos {
+		status := serviceStatus{ServiceInfo: info, Connections: []services.Connection{}}
+		if accounts, ok := connected[info.Name]; ok {
+			status.Connected = true
+			status.Connections = accounts
+		}
+		statuses = append(statuses, status)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"services":  statuses,
+		"connected": len(connections),
+	})
+}
+
+// ConnectService - POST /api/services/:service/connect
+// Start the OAuth flow for a provider and return the URL to send the user to. An optional label
+// names the account, so a second account of the same provider can be told apart.
+func (c *ServicesController) ConnectService(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Label string `json:"label" binding:"max=100"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	serviceName := ctx.Param("service")
+	if !c.registry.IsServiceRegistered(serviceName) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Unknown service: " + serviceName})
+		return
+	}
+	if !c.registry.IsServiceAvailable(serviceName) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is temporarily unavailable"})
+		return
+	}
+
+	initiation, err := c.oauth.InitiateAuth(serviceName, userID, c.callbackURL(serviceName), strings.TrimSpace(req.Label))
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to start connection",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"auth_url": initiation.AuthURL,
+		"state":    initiation.State,
+	})
+}
+
+// HandleCallback - GET /services/:service/callback
+// Complete the OAuth flow the provider redirected back from, then return the user to the frontend.
+// The pending state identifies the user, so this route needs no session.
+func (c *ServicesController) HandleCallback(ctx *gin.Context) {
+	serviceName := ctx.Param("service")
+
+	if providerError := ctx.Query("error"); providerError != "" {
+		c.redirectToFrontend(ctx, serviceName, "error", providerError)
+		return
+	}
+
+	code, state := ctx.Query("code"), ctx.Query("state")
+	if code == "" || state == "" {
+		c.redirectToFrontend(ctx, serviceName, "error", "missing_code")
+		return
+	}
+
+	result, err := c.oauth.HandleCallback(ctx.Request.Context(), serviceName, code, state)
+	if errors.Is(err, services.ErrServiceUnavailable) {
+		_ = ctx.Error(err)
+		c.redirectToFrontend(ctx, serviceName, "error", "service_unavailable")
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		c.redirectToFrontend(ctx, serviceName, "error", "connection_failed")
+		return
+	}
+
+	if len(result.MissingScopes) > 0 {
+		c.redirectToFrontend(ctx, serviceName, "degraded", "missing_scopes")
+		return
+	}
+	c.redirectToFrontend(ctx, serviceName, "connected", "")
+}
+
+// DisconnectService - DELETE /api/services/:service
+// Remove the user's account on a provider, revoking its tokens and stopping sync work that uses it.
+// Users with several accounts on the provider choose one with the connections route.
+func (c *ServicesController) DisconnectService(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	serviceName := ctx.Param("service")
+	connections, err := c.oauth.ListConnections(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected services"})
+		return
+	}
+
+	var accounts []services.Connection
+	for _, connection := range connections {
+		if connection.ServiceName == serviceName {
+			accounts = append(accounts, connection)
+		}
+	}
+
+	switch len(accounts) {
+	case 0:
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Service not connected"})
+	case 1:
+		c.disconnect(ctx, userID, accounts[0])
+	default:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":       "Several accounts are connected, choose the one to disconnect",
+			"connections": accounts,
+		})
+	}
+}
+
+// DisconnectConnection - DELETE /api/services/:service/connections/:connection
+// Remove one of the user's accounts on a provider
+func (c *ServicesController) DisconnectConnection(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	connection, ok := c.findConnection(ctx, userID)
+	if !ok {
+		return
+	}
+
+	c.disconnect(ctx, userID, *connection)
+}
+
+// RenameConnection - PATCH /api/services/:service/connections/:connection
+// Change the label of one of the user's accounts on a provider
+func (c *ServicesController) RenameConnection(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Label string `json:"label" binding:"max=100"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, ok := c.findConnection(ctx, userID)
+	if !ok {
+		return
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if err := c.oauth.RenameConnection(userID, connection.ID, label); err != nil {
+		if errors.Is(err, services.ErrNotConnected) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename connection"})
+		return
+	}
+
+	connection.Label = label
+	ctx.JSON(http.StatusOK, gin.H{"connection": connection})
+}
+
+// ReconsentConnection - POST /api/services/:service/connections/:connection/reconsent
+// Start an OAuth flow granting the scopes a degraded account is missing and return the URL to
+// send the user to
+func (c *ServicesController) ReconsentConnection(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	connection, ok := c.findConnection(ctx, userID)
+	if !ok {
+		return
+	}
+	if !c.registry.IsServiceAvailable(connection.ServiceName) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is temporarily unavailable"})
+		return
+	}
+
+	initiation, err := c.oauth.InitiateReconsent(userID, connection.ID, c.callbackURL(connection.ServiceName))
+	if err != nil {
+		if errors.Is(err, services.ErrScopesComplete) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Connection already has every permission it needs"})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to start re-consent",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"auth_url":       initiation.AuthURL,
+		"state":          initiation.State,
+		"missing_scopes": connection.MissingScopes,
+	})
+}
+
+// findConnection loads the connection named in the route, answering 404 when the user has no
+// such connection on the route's provider
+func (c *ServicesController) findConnection(ctx *gin.Context, userID string) (*services.Connection, bool) {
+	connection, err := c.oauth.GetConnection(userID, ctx.Param("connection"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotConnected) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+			return nil, false
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connection"})
+		return nil, false
+	}
+
+	if connection.ServiceName != ctx.Param("service") {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+		return nil, false
+	}
+	return connection, true
+}
+
+// disconnect removes a connection and reports the outcome
+func (c *ServicesController) disconnect(ctx *gin.Context, userID string, connection services.Connection) {
+	if err := c.oauth.Disconnect(userID, connection.ID); err != nil {
+		if errors.Is(err, services.ErrNotConnected) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Service not connected"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect service"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":       "Service disconnected",
+		"service":       connection.ServiceName,
+		"connection_id": connection.ID,
+	})
+}
+
+// callbackURL is the redirect URI registered with the provider
+func (c *ServicesController) callbackURL(serviceName string) string {
+	return c.callbackBaseURL + "/services/" + url.PathEscape(serviceName) + "/callback"
+}
+
+// redirectToFrontend sends the browser back to the dashboard with the outcome of the connection
+func (c *ServicesController) redirectToFrontend(ctx *gin.Context, serviceName, status, reason string) {
+	query := url.Values{"service": {serviceName}, "status": {status}}
+	if reason != "" {
+		query.Set("reason", reason)
+	}
+	ctx.Redirect(http.StatusFound, c.frontendURL+"/dashboard?"+query.Encode())
+}
@@ -1,15 +1,18 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 
 	"syncer.net/core/services"
+	"syncer.net/core/stats"
 	"syncer.net/core/sync"
 	"syncer.net/services/music"
 )
@@ -19,14 +22,16 @@ import (
 type SyncController struct {
 	syncEngine *sync.SyncEngine
 	registry   *services.ServiceRegistry
+	stats      *stats.StatsService
 	db         *sqlx.DB
 }
 
 // NewSyncController creates a new sync controller
-func NewSyncController(syncEngine *sync.SyncEngine, registry *services.ServiceRegistry, db *sqlx.DB) *SyncController {
+func NewSyncController(syncEngine *sync.SyncEngine, registry *services.ServiceRegistry, statsService *stats.StatsService, db *sqlx.DB) *SyncController {
 	return &SyncController{
 		syncEngine: syncEngine,
 		registry:   registry,
+		stats:      statsService,
 		db:         db,
 	}
 }
@@ -50,23 +55,28 @@ func (c *SyncController) InitiateManualSync(ctx *gin.Context) {
 		return
 	}
 
+	// Resolve each side of every pair to one of the user's connected accounts
+	if !c.resolveConnections(ctx, userID, req.ServicePairs) {
+		return
+	}
+
 	// Validate each service pair
 	for i, pair := range req.ServicePairs {
-		if pair.SourceService == pair.TargetService {
+		if pair.SourceConnection == pair.TargetConnection {
 			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Pair %d: source and target services must be different", i),
+				"error": fmt.Sprintf("Pair %d: source and target must be different accounts", i),
 			})
 			return
 		}
 
-		// Validate services are available
-		if !c.registry.IsServiceAvailable(pair.SourceService) {
+		// Validate services are registered; jobs for providers with an open circuit are deferred
+		if !c.registry.IsServiceRegistered(pair.SourceService) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error": fmt.Sprintf("Pair %d: source service %s not available", i, pair.SourceService),
 			})
 			return
 		}
-		if !c.registry.IsServiceAvailable(pair.TargetService) {
+		if !c.registry.IsServiceRegistered(pair.TargetService) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error": fmt.Sprintf("Pair %d: target service %s not available", i, pair.TargetService),
 			})
@@ -88,49 +98,14 @@ func (c *SyncController) InitiateManualSync(ctx *gin.Context) {
 			})
 			return
 		}
-	}
 
-	// Check user has all required services connected
-	var requiredServices []string
-	for _, pair := range req.ServicePairs {
-		requiredServices = append(requiredServices, pair.SourceService, pair.TargetService)
-	}
-
-	// Remove duplicates
-	serviceMap := make(map[string]bool)
-	for _, service := range requiredServices {
-		serviceMap[service] = true
-	}
-	uniqueServices := make([]string, 0, len(serviceMap))
-	for service := range serviceMap {
-		uniqueServices = append(uniqueServices, service)
-	}
-
-	var connectedServices []string
-	err := c.db.Select(&connectedServices, `
-		SELECT s.name FROM user_services us
-		JOIN services s ON us.service_id = s.id
-		WHERE us.user_id = $1 AND s.name = ANY($2)
-	`, userID, pq.Array(uniqueServices))
-
-	if err != nil || len(connectedServices) != len(uniqueServices) {
-		missingServices := make([]string, 0)
-		connectedMap := make(map[string]bool)
-		for _, service := range connectedServices {
-			connectedMap[service] = true
-		}
-		for _, service := range uniqueServices {
-			if !connectedMap[service] {
-				missingServices = append(missingServices, service)
-			}
+		// Reject pairs whose providers cannot perform the sync type in this mode
+		if err := c.syncEngine.CheckPair(pair, req.SyncType); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Pair %d: %v", i, err),
+			})
+			return
 		}
-
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error":              "All services in service pairs must be connected",
-			"missing_services":   missingServices,
-			"connected_services": connectedServices,
-		})
-		return
 	}
 
 	// Set default sync options if not provided
@@ -152,7 +127,7 @@ func (c *SyncController) InitiateManualSync(ctx *gin.Context) {
 	}
 
 	// Queue the manual sync
-	if err := c.syncEngine.QueueManualSync(syncReq); err != nil {
+	if err := c.syncEngine.QueueManualSync(ctx.Request.Context(), syncReq); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to queue sync job: %v", err),
 		})
@@ -168,6 +143,24 @@ func (c *SyncController) InitiateManualSync(ctx *gin.Context) {
 	})
 }
 
+// resolveConnections completes the pairs of a request from the user's connections. It responds
+// and returns false when the connections cannot be loaded or a pair matches none of them.
+func (c *SyncController) resolveConnections(ctx *gin.Context, userID string, pairs []sync.ServicePair) bool {
+	connections, err := c.syncEngine.ListConnections(userID)
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connected services"})
+		return false
+	}
+
+	req := sync.SyncJobRequest{ServicePairs: pairs}
+	if err := req.ResolveConnections(connections); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
 // ScheduleAutoSync - POST /api/sync/schedule
 // Schedule automatic background sync
 // Implements project requirement: "automatic in the background"
@@ -190,6 +183,10 @@ func (c *SyncController) ScheduleAutoSync(ctx *gin.Context) {
 		return
 	}
 
+	if !c.resolveConnections(ctx, userID, req.ServicePairs) {
+		return
+	}
+
 	if req.Schedule.Frequency < 10*time.Minute {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error": "Schedule frequency must be at least 10 minute",
@@ -230,27 +227,60 @@ func (c *SyncController) ScheduleAutoSync(ctx *gin.Context) {
 }
 
 // GetSupportedSyncPairs - GET /api/sync/supported-pairs
-// Get supported sync service pairs and modes
+// Get supported sync service pairs, with the modes and sync types their capabilities allow. Pairs
+// of one provider sync between two of the user's accounts, so they need two distinct connections.
 func (c *SyncController) GetSupportedSyncPairs(ctx *gin.Context) {
 	allServices := c.registry.ListServices()
+	modes := []struct {
+		mode        sync.SyncMode
+		description string
+	}{
+		{sync.SyncModeFrom, "One-way sync from source to target"},
+		{sync.SyncModeTo, "One-way sync from target to source"},
+		{sync.SyncModeBidirectional, "Two-way sync in both directions"},
+	}
 
 	var supportedPairs []map[string]any
 	for _, source := range allServices {
 		for _, target := range allServices {
-			if source.Name != target.Name && source.Category == target.Category {
-				supportedPairs = append(supportedPairs, map[string]any{
-					"source_service": source.Name,
-					"source_display": source.DisplayName,
-					"target_service": target.Name,
-					"target_display": target.DisplayName,
-					"category":       source.Category,
-					"supported_modes": []map[string]string{
-						{"mode": string(sync.SyncModeFrom), "description": "One-way sync from source to target"},
-						{"mode": string(sync.SyncModeTo), "description": "One-way sync from target to source"},
-						{"mode": string(sync.SyncModeBidirectional), "description": "Two-way sync in both directions"},
-					},
-				})
+			if source.Category != target.Category {
+				continue
+			}
+
+			var supportedModes []map[string]any
+			for _, m := range modes {
+				pair := sync.ServicePair{SourceService: source.Name, TargetService: target.Name, SyncMode: m.mode}
+				var syncTypes []string
+				for _, syncType := range music.GetSupportedSyncTypes() {
+					if c.syncEngine.CheckPair(pair, syncType) == nil {
+						syncTypes = append(syncTypes, syncType)
+					}
+				}
+				if len(syncTypes) > 0 {
+					supportedModes = append(supportedModes, map[string]any{
+						"mode":        string(m.mode),
+						"description": m.description,
+						"sync_types":  syncTypes,
+					})
+				}
+			}
+
+			// Pairs whose capabilities allow no sync at all are not offered
+			if len(supportedModes) == 0 {
+				continue
 			}
+
+			supportedPairs = append(supportedPairs, map[string]any{
+				"source_service":                source.Name,
+				"source_display":                source.DisplayName,
+				"source_capabilities":           source.Capabilities,
+				"target_service":                target.Name,
+				"target_display":                target.DisplayName,
+				"target_capabilities":           target.Capabilities,
+				"category":                      source.Category,
+				"supported_modes":               supportedModes,
+				"requires_distinct_connections": source.Name == target.Name,
+			})
 		}
 	}
 
@@ -267,6 +297,32 @@ func (c *SyncController) GetSupportedSyncPairs(ctx *gin.Context) {
 	})
 }
 
+// GetProviderStatus - GET /api/sync/providers/status
+// Get the cached health and circuit breaker state of every provider
+func (c *SyncController) GetProviderStatus(ctx *gin.Context) {
+	monitor := c.registry.HealthMonitor()
+	if monitor == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Provider health monitoring is not enabled",
+		})
+		return
+	}
+
+	statuses := monitor.Statuses()
+	available := 0
+	for _, status := range statuses {
+		if status.Available() {
+			available++
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"providers": statuses,
+		"available": available,
+		"total":     len(statuses),
+	})
+}
+
 // GetSyncStatus - GET /api/sync/status
 // Get current sync status and recent jobs
 func (c *SyncController) GetSyncStatus(ctx *gin.Context) {
@@ -348,7 +404,7 @@ func (c *SyncController) GetSyncStatus(ctx *gin.Context) {
 	}
 
 	// Get sync statistics
-	stats, err := c.getSyncStats(userID)
+	stats, err := c.getSyncStats(ctx.Request.Context(), userID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch sync statistics",
@@ -363,7 +419,7 @@ func (c *SyncController) GetSyncStatus(ctx *gin.Context) {
 }
 
 // GetUserSchedules - GET /api/sync/schedules
-// Get user's automatic sync schedules
+// Get user's automatic sync schedules, including adaptive next run and reasons
 func (c *SyncController) GetUserSchedules(ctx *gin.Context) {
 	userID := ctx.GetString("user_id")
 	if userID == "" {
@@ -373,10 +429,13 @@ func (c *SyncController) GetUserSchedules(ctx *gin.Context) {
 
 	var schedules []map[string]any
 	rows, err := c.db.Query(`
-		SELECT sync_, enabled, next_run, created_at, updated_at
+		SELECT id, sync_type, enabled, next_run,
+		       COALESCE((schedule_data->'schedule'->>'frequency')::bigint, 0) AS frequency_ns,
+		       effective_frequency_ms, adjustment_reason, disabled_reason, disabled_at,
+		       created_at, updated_at
 		FROM sync_schedules
 		WHERE user_id = $1
-		ORDER BY sync_
+		ORDER BY sync_type
 	`, userID)
 
 	if err != nil {
@@ -389,25 +448,55 @@ func (c *SyncController) GetUserSchedules(ctx *gin.Context) {
 
 	for rows.Next() {
 		var schedule struct {
-			SyncType  string    `db:"sync_"`
-			Enabled   bool      `db:"enabled"`
-			NextRun   time.Time `db:"next_run"`
-			CreatedAt time.Time `db:"created_at"`
-			UpdatedAt time.Time `db:"updated_at"`
+			ID                   string     `db:"id"`
+			SyncType             string     `db:"sync_type"`
+			Enabled              bool       `db:"enabled"`
+			NextRun              time.Time  `db:"next_run"`
+			FrequencyNs          int64      `db:"frequency_ns"`
+			EffectiveFrequencyMs *int64     `db:"effective_frequency_ms"`
+			AdjustmentReason     *string    `db:"adjustment_reason"`
+			DisabledReason       *string    `db:"disabled_reason"`
+			DisabledAt           *time.Time `db:"disabled_at"`
+			CreatedAt            time.Time  `db:"created_at"`
+			UpdatedAt            time.Time  `db:"updated_at"`
 		}
 
-		if err := rows.Scan(&schedule.SyncType, &schedule.Enabled, &schedule.NextRun,
+		if err := rows.Scan(&schedule.ID, &schedule.SyncType, &schedule.Enabled, &schedule.NextRun,
+			&schedule.FrequencyNs, &schedule.EffectiveFrequencyMs, &schedule.AdjustmentReason,
+			&schedule.DisabledReason, &schedule.DisabledAt,
 			&schedule.CreatedAt, &schedule.UpdatedAt); err != nil {
 			continue
 		}
 
-		schedules = append(schedules, map[string]any{
-			"sync_":      schedule.SyncType,
-			"enabled":    schedule.Enabled,
-			"next_run":   schedule.NextRun,
-			"created_at": schedule.CreatedAt,
-			"updated_at": schedule.UpdatedAt,
-		})
+		frequency := time.Duration(schedule.FrequencyNs)
+		effectiveFrequency := frequency
+		if schedule.EffectiveFrequencyMs != nil {
+			effectiveFrequency = time.Duration(*schedule.EffectiveFrequencyMs) * time.Millisecond
+		}
+
+		scheduleMap := map[string]any{
+			"id":                  schedule.ID,
+			"sync_type":           schedule.SyncType,
+			"enabled":             schedule.Enabled,
+			"next_run":            schedule.NextRun,
+			"frequency":           frequency.String(),
+			"effective_frequency": effectiveFrequency.String(),
+			"adjusted":            effectiveFrequency != frequency,
+			"created_at":          schedule.CreatedAt,
+			"updated_at":          schedule.UpdatedAt,
+		}
+
+		if schedule.AdjustmentReason != nil {
+			scheduleMap["adjustment_reason"] = *schedule.AdjustmentReason
+		}
+		if schedule.DisabledReason != nil {
+			scheduleMap["disabled_reason"] = *schedule.DisabledReason
+		}
+		if schedule.DisabledAt != nil {
+			scheduleMap["disabled_at"] = *schedule.DisabledAt
+		}
+
+		schedules = append(schedules, scheduleMap)
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
@@ -415,6 +504,193 @@ func (c *SyncController) GetUserSchedules(ctx *gin.Context) {
 	})
 }
 
+// GetScheduleHistory - GET /api/sync/schedules/:syncType/history
+// Get recent runs, success rate, averages and last error for a schedule
+func (c *SyncController) GetScheduleHistory(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	syncType := ctx.Param("syncType")
+	if syncType == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Sync type is required"})
+		return
+	}
+
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	var scheduleID string
+	err = c.db.Get(&scheduleID, `
+		SELECT id FROM sync_schedules
+		WHERE user_id = $1 AND sync_type = $2
+	`, userID, syncType)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	history, err := c.syncEngine.GetScheduleHistory(scheduleID, time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch schedule history",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"sync_type": syncType,
+		"history":   history,
+	})
+}
+
+// GetJobTimeline - GET /api/sync/jobs/:jobId/timeline
+// Page through the logged phases, pair directions and item errors of a sync job
+func (c *SyncController) GetJobTimeline(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	jobID := ctx.Param("jobId")
+	if jobID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Job ID is required"})
+		return
+	}
+
+	level := sync.LogLevel(ctx.Query("level"))
+	switch level {
+	case "", sync.LogLevelDebug, sync.LogLevelInfo, sync.LogLevelWarning, sync.LogLevelError:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "level must be one of debug, info, warning, error"})
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "offset must be zero or positive"})
+		return
+	}
+
+	var exists bool
+	err = c.db.Get(&exists, `
+		SELECT EXISTS(SELECT 1 FROM sync_jobs WHERE id = $1 AND user_id = $2)
+	`, jobID, userID)
+	if err != nil || !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Sync job not found"})
+		return
+	}
+
+	timeline, err := c.syncEngine.GetJobTimeline(jobID, level, limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch job timeline",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, timeline)
+}
+
+// GetUserStats - GET /api/sync/stats?from=YYYY-MM-DD&to=YYYY-MM-DD
+// Get the user's daily, per-provider and per-pair sync statistics over a date range
+func (c *SyncController) GetUserStats(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	from, to, ok := parseStatsRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := c.stats.GetUserStats(ctx.Request.Context(), userID, from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch sync statistics",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetGlobalStats - GET /api/sync/stats/global?from=YYYY-MM-DD&to=YYYY-MM-DD
+// Get sync statistics across all users over a date range
+func (c *SyncController) GetGlobalStats(ctx *gin.Context) {
+	from, to, ok := parseStatsRange(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := c.stats.GetGlobalStats(ctx.Request.Context(), from, to)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch sync statistics",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// parseStatsRange reads the inclusive from/to dates, defaulting to the last 30 days.
+// It writes a 400 response and returns false when the range is invalid.
+func parseStatsRange(ctx *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -29)
+
+	if value := ctx.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+
+	if value := ctx.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return time.Time{}, time.Time{}, false
+	}
+
+	if to.Sub(from) > 366*24*time.Hour {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "date range must not exceed 366 days"})
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
 // UpdateSchedule - PUT /api/sync/schedules/:syncType
 // Update an existing schedule
 func (c *SyncController) UpdateSchedule(ctx *gin.Context) {
@@ -440,32 +716,47 @@ func (c *SyncController) UpdateSchedule(ctx *gin.Context) {
 		return
 	}
 
-	// Update the schedule in database
+	// Update the schedule in database. Each column is assigned once, since enabling and changing
+	// the frequency both touch effective_frequency_ms and schedule_data.
 	updates := []string{}
 	args := []any{}
 	argIndex := 1
+	scheduleData := "schedule_data"
+
+	if req.Frequency != nil && *req.Frequency < 10*time.Minute {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Frequency must be at least 10 minute",
+		})
+		return
+	}
 
 	if req.Enabled != nil {
 		updates = append(updates, fmt.Sprintf("enabled = $%d", argIndex))
 		args = append(args, *req.Enabled)
 		argIndex++
-	}
 
-	if req.Frequency != nil {
-		if *req.Frequency < 10*time.Minute {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "Frequency must be at least 10 minute",
-			})
-			return
+		// Re-enabling clears any automatic disable and restarts the adaptive history
+		if *req.Enabled {
+			updates = append(updates,
+				"disabled_reason = NULL",
+				"disabled_at = NULL",
+			)
+			if req.Frequency == nil {
+				updates = append(updates, "effective_frequency_ms = NULL")
+			}
+			scheduleData = fmt.Sprintf("jsonb_set(%s, '{schedule,enabled_at}', to_jsonb(NOW()))", scheduleData)
 		}
+	}
 
-		// When frequency changes, update next_run too
-		updates = append(updates, fmt.Sprintf("next_run = NOW() + $%d", argIndex))
-		args = append(args, *req.Frequency)
-		argIndex++
-
-		// We would also need to update the schedule_data JSON, but for simplicity
-		// we'll just update the next_run time here
+	if req.Frequency != nil {
+		// When frequency changes, update next_run and reset any adaptive backoff
+		updates = append(updates,
+			fmt.Sprintf("next_run = NOW() + ($%d * INTERVAL '1 millisecond')", argIndex),
+			fmt.Sprintf("effective_frequency_ms = $%d", argIndex),
+		)
+		scheduleData = fmt.Sprintf("jsonb_set(%s, '{schedule,frequency}', to_jsonb($%d::bigint))", scheduleData, argIndex+1)
+		args = append(args, req.Frequency.Milliseconds(), int64(*req.Frequency))
+		argIndex += 2
 	}
 
 	if len(updates) == 0 {
@@ -475,31 +766,47 @@ func (c *SyncController) UpdateSchedule(ctx *gin.Context) {
 		return
 	}
 
+	if (req.Enabled != nil && *req.Enabled) || req.Frequency != nil {
+		updates = append(updates, "adjustment_reason = NULL")
+	}
+	if scheduleData != "schedule_data" {
+		updates = append(updates, "schedule_data = "+scheduleData)
+	}
 	updates = append(updates, "updated_at = NOW()")
 	args = append(args, userID, syncType)
 
 	query := fmt.Sprintf(`
 		UPDATE sync_schedules 
 		SET %s 
-		WHERE user_id = $%d AND sync_ = $%d
-	`, fmt.Sprintf(updates[0], argIndex, argIndex+1), argIndex, argIndex+1)
+		WHERE user_id = $%d AND sync_type = $%d
+		RETURNING id
+	`, strings.Join(updates, ", "), argIndex, argIndex+1)
 
-	result, err := c.db.Exec(query, args...)
-	if err != nil {
+	var scheduleIDs []string
+	if err := c.db.Select(&scheduleIDs, query, args...); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update schedule",
 		})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if len(scheduleIDs) == 0 {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": "Schedule not found",
 		})
 		return
 	}
 
+	// The scheduler keeps its own copy of the schedule; reload it so the change applies now
+	for _, scheduleID := range scheduleIDs {
+		if err := c.syncEngine.ReloadSchedule(scheduleID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Schedule updated but failed to reload it",
+			})
+			return
+		}
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Schedule updated successfully",
 		"sync_":   syncType,
@@ -547,50 +854,22 @@ func (c *SyncController) DeleteSchedule(ctx *gin.Context) {
 	})
 }
 
-// Helper function to get sync statistics
-func (c *SyncController) getSyncStats(userID string) (map[string]any, error) {
-	var stats struct {
-		TotalJobs      int `db:"total_jobs"`
-		SuccessfulJobs int `db:"successful_jobs"`
-		FailedJobs     int `db:"failed_jobs"`
-		TotalSynced    int `db:"total_synced"`
-	}
-
-	err := c.db.Get(&stats, `
-		SELECT 
-			COUNT(*) as total_jobs,
-			COUNT(CASE WHEN status = 'completed' THEN 1 END) as successful_jobs,
-			COUNT(CASE WHEN status = 'failed' THEN 1 END) as failed_jobs,
-			COALESCE(SUM(items_synced), 0) as total_synced
-		FROM sync_jobs 
-		WHERE user_id = $1
-	`, userID)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Get last sync time
-	var lastSync *time.Time
-	err = c.db.Get(&lastSync, `
-		SELECT MAX(finished_at) 
-		FROM sync_jobs 
-		WHERE user_id = $1 AND status = 'completed'
-	`, userID)
-
+// Helper function to get sync statistics from the daily rollups
+func (c *SyncController) getSyncStats(ctx context.Context, userID string) (map[string]any, error) {
+	summary, err := c.stats.GetUserSummary(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	result := map[string]any{
-		"total_jobs":      stats.TotalJobs,
-		"successful_jobs": stats.SuccessfulJobs,
-		"failed_jobs":     stats.FailedJobs,
-		"total_synced":    stats.TotalSynced,
+		"total_jobs":      summary.Totals.JobsTotal,
+		"successful_jobs": summary.Totals.JobsSucceeded,
+		"failed_jobs":     summary.Totals.JobsFailed,
+		"total_synced":    summary.Totals.ItemsSynced,
 	}
 
-	if lastSync != nil {
-		result["last_sync"] = *lastSync
+	if summary.LastSyncAt != nil {
+		result["last_sync"] = *summary.LastSyncAt
 	}
 
 	return result, nil
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -10,13 +18,45 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"syncer.net/api/auth"
+	"syncer.net/api/controllers"
 	"syncer.net/api/middlewares"
 	coreAuth "syncer.net/core/auth"
 	"syncer.net/core/email"
+	"syncer.net/core/logging"
+	"syncer.net/core/metrics"
+	"syncer.net/core/security"
+	coreServices "syncer.net/core/services"
+	"syncer.net/core/stats"
+	"syncer.net/core/tracing"
+	"syncer.net/services"
+	"syncer.net/services/music"
 	"syncer.net/utils"
 )
 
+const (
+	// providersConfigPollInterval is how often the providers config file is checked for changes
+	providersConfigPollInterval = 30 * time.Second
+
+	// defaultSyncWorkers is the sync engine's worker count unless SYNC_WORKERS overrides it
+	defaultSyncWorkers = 4
+)
+
 func main() {
+	// Route both slog and the standard log package through the structured, redacting handler
+	slog.SetDefault(logging.New(logging.ConfigFromEnv()))
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+
 	db, err := sqlx.Open("postgres", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -32,6 +72,53 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Providers come from PROVIDERS_CONFIG and are reloaded when the file changes or on SIGHUP
+	registry := coreServices.NewServiceRegistry(db, slog.Default())
+	if err := services.InitializeServices(ctx, registry, os.Getenv("PROVIDERS_CONFIG"), slog.Default()); err != nil {
+		log.Fatalf("Failed to initialize service providers: %v", err)
+	}
+	go registry.WatchConfig(ctx, providersConfigPollInterval)
+	go reloadProvidersOnSignal(ctx, registry)
+
+	// Probe providers in the background so jobs and connect flows skip those that are down
+	healthMonitor := coreServices.NewHealthMonitor(registry, coreServices.DefaultHealthMonitorConfig(), slog.Default())
+	go healthMonitor.Start(ctx)
+
+	tokenEncryption, err := newTokenEncryption(ctx)
+	if err != nil {
+		log.Fatalf("Failed to set up token encryption: %v", err)
+	}
+	// Once the re-encryption job has bound every stored token, unbound ones are refused
+	if required, _ := strconv.ParseBool(os.Getenv("TOKEN_REQUIRE_BINDING")); required {
+		tokenEncryption.RequireBinding()
+	}
+	oauthManager, err := coreServices.NewOAuthManager(registry, db, tokenEncryption, slog.Default())
+	if err != nil {
+		log.Fatalf("Failed to create OAuth manager: %v", err)
+	}
+
+	// Move stored tokens to the current key so rotated-out keys can be retired
+	tokenReencryptor := coreServices.NewTokenReencryptor(oauthManager, coreServices.DefaultTokenReencryptorConfig(), slog.Default())
+	go tokenReencryptor.Start(ctx)
+
+	syncWorkers := defaultSyncWorkers
+	if value, err := strconv.Atoi(os.Getenv("SYNC_WORKERS")); err == nil && value > 0 {
+		syncWorkers = value
+	}
+	syncEngine, err := music.CreateMusicSyncEngine(registry, oauthManager, db, syncWorkers, slog.Default())
+	if err != nil {
+		log.Fatalf("Failed to create sync engine: %v", err)
+	}
+	if err := syncEngine.Start(ctx); err != nil {
+		log.Fatalf("Failed to start sync engine: %v", err)
+	}
+
+	statsService := stats.NewStatsService(db, 0, slog.Default())
+	go statsService.Start(ctx)
+
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		log.Fatal("JWT_SECRET environment variable is required")
@@ -42,6 +129,11 @@ func main() {
 
 	emailService := email.NewEmailService()
 
+	// Refresh tokens ahead of expiry and email users whose connections need them to sign in again
+	connectionMaintainer := coreServices.NewConnectionMaintainer(oauthManager, emailService,
+		coreServices.DefaultConnectionMaintainerConfig(), slog.Default())
+	go connectionMaintainer.Start(ctx)
+
 	googleOauthConfig := &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
@@ -50,6 +142,7 @@ func main() {
 		Endpoint:     google.Endpoint,
 	}
 	router := gin.Default()
+	router.Use(middlewares.TracingMiddleware())
 	router.Use(middlewares.DBMiddleware(db))
 	router.Use(middlewares.SecurityHeadersMiddleware())
 	router.Use(middlewares.CORSMiddleware())
@@ -58,6 +151,9 @@ func main() {
 		c.JSON(200, gin.H{"status": "healthy"})
 	})
 
+	// Prometheus metrics for the sync engine and providers
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// CSRF token endpoint
 	router.GET("/csrf-token", middlewares.CSRFTokenEndpoint())
 
@@ -91,24 +187,162 @@ func main() {
 	protectedRoutes.Use(middlewares.AuthMiddleware(jwtService, sessionService))
 	protectedRoutes.Use(middlewares.CSRFMiddleware())
 
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// Providers redirect back to the API, which then returns the user to the frontend
+	callbackBaseURL := os.Getenv("SERVICES_CALLBACK_BASE_URL")
+	if callbackBaseURL == "" {
+		callbackBaseURL = "http://localhost:" + port
+	}
+	servicesController := controllers.NewServicesController(registry, oauthManager, callbackBaseURL, os.Getenv("FRONTEND_URL"))
+	router.GET("/services/:service/callback", servicesController.HandleCallback)
+
 	serviceRoutes := protectedRoutes.Group("/services")
 	{
-		serviceRoutes.GET("/", func(c *gin.Context) {
-			c.JSON(200, gin.H{"message": "Services endpoint"})
-		})
+		serviceRoutes.GET("", servicesController.ListServices)
+		serviceRoutes.POST("/:service/connect", servicesController.ConnectService)
+		serviceRoutes.DELETE("/:service", servicesController.DisconnectService)
+		serviceRoutes.PATCH("/:service/connections/:connection", servicesController.RenameConnection)
+		serviceRoutes.DELETE("/:service/connections/:connection", servicesController.DisconnectConnection)
+		serviceRoutes.POST("/:service/connections/:connection/reconsent", servicesController.ReconsentConnection)
+	}
+
+	syncController := controllers.NewSyncController(syncEngine, registry, statsService, db)
+	syncRoutes := protectedRoutes.Group("/sync")
+	{
+		syncRoutes.POST("/manual", syncController.InitiateManualSync)
+		syncRoutes.POST("/schedule", syncController.ScheduleAutoSync)
+		syncRoutes.GET("/supported-pairs", syncController.GetSupportedSyncPairs)
+		syncRoutes.GET("/providers/status", syncController.GetProviderStatus)
+		syncRoutes.GET("/status", syncController.GetSyncStatus)
+		syncRoutes.GET("/schedules", syncController.GetUserSchedules)
+		syncRoutes.PUT("/schedules/:syncType", syncController.UpdateSchedule)
+		syncRoutes.DELETE("/schedules/:syncType", syncController.DeleteSchedule)
+		syncRoutes.GET("/schedules/:syncType/history", syncController.GetScheduleHistory)
+		syncRoutes.GET("/jobs/:jobId/timeline", syncController.GetJobTimeline)
+		syncRoutes.GET("/stats", syncController.GetUserStats)
+		syncRoutes.GET("/stats/global", syncController.GetGlobalStats)
 	}
 
 	router.POST("/contact", middlewares.CSRFMiddleware(), func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Contact endpoint"})
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
 	log.Printf("Server starting on port %s", port)
 	if err := router.Run(":" + port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newTokenEncryption sets up token encryption from the environment. TOKEN_KEY_PROVIDER selects
+// envelope encryption with local, vault or kms (an in-memory stub, only allowed with
+// TOKEN_ALLOW_SOFTWARE_KMS) key-encryption keys; without it tokens are encrypted directly with the
+// keyring. TOKEN_PREVIOUS_KEY_PROVIDERS lists providers that only decrypt tokens stored before a
+// switch. The keyring comes from ENCRYPTION_KEYS_FILE or ENCRYPTION_KEYS, with ENCRYPTION_KEY as
+// the legacy single key, and keeps decrypting tokens stored before a provider was configured.
+func newTokenEncryption(ctx context.Context) (*security.TokenEncryption, error) {
+	var keyring *security.Keyring
+	var err error
+	legacy := os.Getenv("ENCRYPTION_KEY")
+	switch {
+	case os.Getenv("ENCRYPTION_KEYS_FILE") != "":
+		keyring, err = security.LoadKeyringFile(os.Getenv("ENCRYPTION_KEYS_FILE"), legacy)
+	case os.Getenv("ENCRYPTION_KEYS") != "" || legacy != "":
+		keyring, err = security.ParseKeyring(os.Getenv("ENCRYPTION_KEYS"), legacy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption keys: %w", err)
+	}
+
+	kind := os.Getenv("TOKEN_KEY_PROVIDER")
+	if kind == "" {
+		if os.Getenv("TOKEN_PREVIOUS_KEY_PROVIDERS") != "" {
+			return nil, fmt.Errorf("TOKEN_PREVIOUS_KEY_PROVIDERS needs TOKEN_KEY_PROVIDER")
+		}
+		if keyring == nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEY, ENCRYPTION_KEYS or ENCRYPTION_KEYS_FILE is required")
+		}
+		return security.NewKeyringEncryption(keyring)
+	}
+
+	provider, err := newKeyProvider(ctx, kind, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []security.KeyProvider
+	for _, name := range strings.Split(os.Getenv("TOKEN_PREVIOUS_KEY_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		// The software KMS loses its keys on restart, so it can never read older tokens
+		if name == "kms" {
+			return nil, fmt.Errorf("the kms key provider cannot be a previous provider")
+		}
+		p, err := newKeyProvider(ctx, name, keyring)
+		if err != nil {
+			return nil, fmt.Errorf("previous key provider %s: %w", name, err)
+		}
+		previous = append(previous, p)
+	}
+
+	return security.NewEnvelopeEncryption(provider, keyring, previous...)
+}
+
+// newKeyProvider creates the key provider named kind from the environment
+func newKeyProvider(ctx context.Context, kind string, keyring *security.Keyring) (security.KeyProvider, error) {
+	switch kind {
+	case "local":
+		if keyring == nil {
+			return nil, fmt.Errorf("the local key provider needs ENCRYPTION_KEYS or ENCRYPTION_KEYS_FILE")
+		}
+		return security.NewLocalKeyProvider(keyring), nil
+	case "vault":
+		vault, err := security.NewVaultTransit(ctx, security.VaultTransitConfig{
+			Address: os.Getenv("VAULT_ADDR"),
+			Token:   os.Getenv("VAULT_TOKEN"),
+			Mount:   os.Getenv("VAULT_TRANSIT_MOUNT"),
+			KeyName: os.Getenv("VAULT_TRANSIT_KEY"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return vault, nil
+	case "kms":
+		if allowed, _ := strconv.ParseBool(os.Getenv("TOKEN_ALLOW_SOFTWARE_KMS")); !allowed {
+			return nil, fmt.Errorf("the kms key provider keeps its keys in memory and is for development only; set TOKEN_ALLOW_SOFTWARE_KMS=true to use it")
+		}
+		slog.Warn("Using the in-memory software KMS; tokens stored now become unreadable after a restart")
+		kms, err := security.NewSoftwareKMS()
+		if err != nil {
+			return nil, err
+		}
+		return kms, nil
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", kind)
+	}
+}
+
+// reloadProvidersOnSignal re-applies the providers config whenever the process receives SIGHUP
+func reloadProvidersOnSignal(ctx context.Context, registry *coreServices.ServiceRegistry) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := registry.ReloadConfig(ctx); err != nil {
+				slog.Error("Failed to reload providers config", "error", err)
+				continue
+			}
+			slog.Info("Reloaded providers config", "count", registry.GetServiceCount())
+		}
+	}
+}
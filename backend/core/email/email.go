@@ -3,6 +3,7 @@ package email
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/resend/resend-go/v2"
@@ -503,3 +504,157 @@ This email was sent to %s.
 
 	return nil
 }
+
+// ReconnectEmailData describes a connection the user must sign in to again
+type ReconnectEmailData struct {
+	Email       string
+	FullName    string
+	ServiceName string // Provider name used in the dashboard link
+	DisplayName string // Provider name shown to the user
+	Label       string // Label telling apart several accounts of the provider, if any
+	Reason      string
+}
+
+// SendReconnectEmail tells a user that a provider connection stopped working and links to the
+// dashboard where they can connect it again
+func (e *EmailService) SendReconnectEmail(ctx context.Context, data ReconnectEmailData) error {
+	baseURL := os.Getenv("FRONTEND_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000"
+	}
+
+	reconnectURL := fmt.Sprintf("%s/dashboard?%s", baseURL, url.Values{
+		"service": {data.ServiceName},
+		"status":  {"needs_reauth"},
+	}.Encode())
+
+	account := data.DisplayName
+	if data.Label != "" {
+		account = fmt.Sprintf("%s (%s)", data.DisplayName, data.Label)
+	}
+
+	htmlContent := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Reconnect %s - Syncer</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+            padding: 40px;
+        }
+        .header {
+            text-align: center;
+            margin-bottom: 30px;
+        }
+        .logo {
+            font-size: 24px;
+            font-weight: bold;
+            color: #4f46e5;
+            margin-bottom: 10px;
+        }
+        .title {
+            font-size: 24px;
+            font-weight: 600;
+            color: #1f2937;
+            margin-bottom: 20px;
+        }
+        .content {
+            font-size: 16px;
+            color: #6b7280;
+            margin-bottom: 30px;
+        }
+        .button {
+            display: inline-block;
+            background: #4f46e5;
+            color: white;
+            padding: 12px 30px;
+            border-radius: 6px;
+            text-decoration: none;
+            font-weight: 600;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #e5e7eb;
+            font-size: 14px;
+            color: #9ca3af;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <div class="logo">Syncer</div>
+            <h1 class="title">Reconnect %s</h1>
+        </div>
+        
+        <div class="content">
+            <p>Hi %s,</p>
+            
+            <p>Syncer can no longer access your %s account: %s.</p>
+            
+            <p>Syncs using this account are paused until you connect it again.</p>
+            
+            <div style="text-align: center; margin: 30px 0;">
+                <a href="%s" class="button">Reconnect Account</a>
+            </div>
+            
+            <p>If the button doesn't work, you can copy and paste this link into your browser:</p>
+            <p style="word-break: break-all; color: #4f46e5;">%s</p>
+        </div>
+        
+        <div class="footer">
+            <p>Best regards,<br>The Syncer Team</p>
+            <p>This email was sent to %s.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, data.DisplayName, data.DisplayName, data.FullName, account, data.Reason, reconnectURL, reconnectURL, data.Email)
+
+	textContent := fmt.Sprintf(`
+Hi %s,
+
+Syncer can no longer access your %s account: %s.
+
+Syncs using this account are paused until you connect it again:
+%s
+
+Best regards,
+The Syncer Team
+
+This email was sent to %s.
+`, data.FullName, account, data.Reason, reconnectURL, data.Email)
+
+	params := &resend.SendEmailRequest{
+		From:    os.Getenv("RESEND_FROM_EMAIL"),
+		To:      []string{data.Email},
+		Subject: fmt.Sprintf("Reconnect your %s account - Syncer", data.DisplayName),
+		Html:    htmlContent,
+		Text:    textContent,
+	}
+
+	if params.From == "" {
+		params.From = "noreply@syncer.net"
+	}
+
+	_, err := e.client.Emails.Send(params)
+	if err != nil {
+		return fmt.Errorf("failed to send reconnect email: %w", err)
+	}
+
+	return nil
+}
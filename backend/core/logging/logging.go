@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys used to correlate log records across components
+const (
+	KeyComponent = "component"
+	KeyJobID     = "job_id"
+	KeyUserID    = "user_id"
+	KeyProvider  = "provider"
+)
+
+// Config controls the level and output format of the logger
+type Config struct {
+	Level  slog.Level
+	Format string // "text" or "json"
+	Output io.Writer
+}
+
+// ConfigFromEnv builds a logging configuration from LOG_LEVEL and LOG_FORMAT
+func ConfigFromEnv() Config {
+	config := Config{
+		Level:  slog.LevelInfo,
+		Format: strings.ToLower(os.Getenv("LOG_FORMAT")),
+		Output: os.Stderr,
+	}
+
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if err := config.Level.UnmarshalText([]byte(level)); err != nil {
+			config.Level = slog.LevelInfo
+		}
+	}
+
+	if config.Format != "json" {
+		config.Format = "text"
+	}
+
+	return config
+}
+
+// New creates a logger that redacts secrets and adds correlation attributes from the context
+func New(config Config) *slog.Logger {
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       config.Level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if config.Format == "json" {
+		handler = slog.NewJSONHandler(config.Output, options)
+	} else {
+		handler = slog.NewTextHandler(config.Output, options)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Component returns the default logger tagged with a component name, or logger when it is set
+func Component(logger *slog.Logger, name string) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(KeyComponent, name)
+}
+
+type contextKey struct{}
+
+// WithAttrs returns a context carrying attributes that are added to every record logged with it
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+
+	record := slog.Record{}
+	record.Add(args...)
+
+	attrs := make([]slog.Attr, 0, len(existing)+record.NumAttrs())
+	attrs = append(attrs, existing...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// contextHandler adds attributes stored with WithAttrs and the active trace to each record. They
+// are added at the top level, outside any group the logger was given.
+type contextHandler struct {
+	slog.Handler
+
+	root   slog.Handler                      // Handler before the first group, when one was given
+	scoped []func(slog.Handler) slog.Handler // Groups and attributes added since, in order
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	var attrs []slog.Attr
+	if stored, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		attrs = append(attrs, stored...)
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		attrs = append(attrs,
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+	if h.root == nil {
+		record.AddAttrs(attrs...)
+		return h.Handler.Handle(ctx, record)
+	}
+
+	handler := h.root.WithAttrs(attrs)
+	for _, scope := range h.scoped {
+		handler = scope(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.root == nil {
+		return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+	}
+	return h.scope(h.Handler.WithAttrs(attrs), func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return h.scope(h.Handler.WithGroup(name), func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// scope returns a handler that repeats the given step when context attributes are added
+func (h *contextHandler) scope(handler slog.Handler, step func(slog.Handler) slog.Handler) *contextHandler {
+	root := h.root
+	if root == nil {
+		root = h.Handler
+	}
+	return &contextHandler{
+		Handler: handler,
+		root:    root,
+		scoped:  append(slices.Clip(h.scoped), step),
+	}
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute, query parameter and header names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"token":         true,
+	"code":          true,
+	"code_verifier": true,
+	"client_secret": true,
+	"secret":        true,
+	"password":      true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"session_token": true,
+}
+
+// IsSensitive reports whether a key names a credential that must be redacted
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// RedactURL returns the URL as a string with credential query parameters and user info masked
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(redacted)
+	}
+
+	if clean.RawQuery != "" {
+		query := clean.Query()
+		for key := range query {
+			if IsSensitive(key) {
+				query.Set(key, redacted)
+			}
+		}
+		clean.RawQuery = query.Encode()
+	}
+
+	return clean.String()
+}
+
+// RedactHeaders returns a copy of the headers with credential values masked
+func RedactHeaders(headers http.Header) http.Header {
+	clean := headers.Clone()
+	for key := range clean {
+		if IsSensitive(key) {
+			clean[key] = []string{redacted}
+		}
+	}
+	return clean
+}
+
+// redactAttr masks sensitive attributes and URLs before they reach the handler
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case *url.URL:
+		return slog.String(attr.Key, RedactURL(value))
+	case string:
+		if u, ok := credentialURL(value); ok {
+			return slog.String(attr.Key, RedactURL(u))
+		}
+	case http.Header:
+		return slog.Any(attr.Key, RedactHeaders(value))
+	}
+
+	return attr
+}
+
+// credentialURL parses a string value that is a URL carrying user info or a credential in its query
+func credentialURL(value string) (*url.URL, bool) {
+	if !strings.Contains(value, "://") && !strings.Contains(value, "?") {
+		return nil, false
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	if u.User != nil {
+		return u, true
+	}
+	for key := range u.Query() {
+		if IsSensitive(key) {
+			return u, true
+		}
+	}
+	return nil, false
+}
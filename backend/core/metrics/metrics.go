@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "syncer"
+
+// Registry holds every collector exposed on the /metrics endpoint
+var Registry = prometheus.NewRegistry()
+
+var (
+	// SyncJobsTotal counts finished sync jobs by trigger, sync type and status
+	SyncJobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "jobs_total",
+		Help:      "Finished sync jobs by trigger (manual/automatic), sync type and status.",
+	}, []string{"trigger", "sync_type", "status"})
+
+	// SyncJobDuration observes end-to-end sync job durations
+	SyncJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "job_duration_seconds",
+		Help:      "Duration of sync jobs across all service pairs.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"trigger", "sync_type"})
+
+	// SyncPairDuration observes the duration of each directional sync between two providers
+	SyncPairDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "pair_duration_seconds",
+		Help:      "Duration of a single sync direction from a source to a target provider.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 12),
+	}, []string{"source", "target", "sync_type"})
+
+	// SyncItemsTotal counts processed items per target provider and outcome
+	SyncItemsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "items_total",
+		Help:      "Items processed by the sync engine by provider, sync type and outcome.",
+	}, []string{"provider", "sync_type", "outcome"})
+
+	// SyncItemDuration observes how long adding a single item to a target provider takes
+	SyncItemDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "item_duration_seconds",
+		Help:      "Duration of adding a single item to a target provider.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"provider", "sync_type"})
+
+	// SyncQueueDepth reports the number of jobs waiting in each engine queue
+	SyncQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "queue_depth",
+		Help:      "Jobs waiting in the sync engine queues.",
+	}, []string{"queue"})
+
+	// SyncWorkersBusy reports workers currently processing a job, per pool
+	SyncWorkersBusy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "workers_busy",
+		Help:      "Workers currently processing a sync job.",
+	}, []string{"pool"})
+
+	// SyncWorkers reports the configured size of each worker pool
+	SyncWorkers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "workers",
+		Help:      "Configured workers per pool.",
+	}, []string{"pool"})
+
+	// ProviderRequestsTotal counts provider HTTP calls by status code
+	ProviderRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests made to providers by method and status code.",
+	}, []string{"provider", "method", "status"})
+
+	// ProviderRequestDuration observes provider HTTP call latency
+	ProviderRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests made to providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "method"})
+
+	// ProviderCircuitOpen reports whether each provider's circuit breaker is open
+	ProviderCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "circuit_open",
+		Help:      "1 while the provider's circuit breaker is open and calls are rejected, 0 otherwise.",
+	}, []string{"provider"})
+
+	// ProviderRateLimitWait observes time spent waiting on a provider's rate limiter
+	ProviderRateLimitWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time spent waiting for a provider rate limiter before a request.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider"})
+
+	// ProviderRateLimited counts provider throttling signals and requests rejected by quotas
+	ProviderRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "provider",
+		Name:      "rate_limited_total",
+		Help:      "Provider rate limit events by reason (429, quota_exhausted, rejected).",
+	}, []string{"provider", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SyncJobsTotal,
+		SyncJobDuration,
+		SyncPairDuration,
+		SyncItemsTotal,
+		SyncItemDuration,
+		SyncQueueDepth,
+		SyncWorkersBusy,
+		SyncWorkers,
+		ProviderRequestsTotal,
+		ProviderRequestDuration,
+		ProviderCircuitOpen,
+		ProviderRateLimitWait,
+		ProviderRateLimited,
+	)
+}
+
+// Handler returns an HTTP handler serving the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveProviderRequest records a provider HTTP call. A status of 0 means the request failed
+// before a response was received.
+func ObserveProviderRequest(provider, method string, status int, duration time.Duration) {
+	statusLabel := "error"
+	if status > 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+
+	ProviderRequestsTotal.WithLabelValues(provider, method, statusLabel).Inc()
+	ProviderRequestDuration.WithLabelValues(provider, method).Observe(duration.Seconds())
+}
@@ -1,92 +1,376 @@
 package security
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"time"
 )
 
-// TokenEncryption provides AES-256 encryption for sensitive tokens
+// TokenEncryption provides AES-256 encryption for sensitive tokens. With a key provider every
+// token gets its own data key, stored wrapped by the provider; otherwise tokens are encrypted
+// directly with the current key of a keyring.
 type TokenEncryption struct {
-	key [32]byte
+	keyring   *Keyring               // Encrypts without a provider and decrypts direct ciphertexts
+	provider  KeyProvider            // Wraps the data keys of new ciphertexts, if set
+	providers map[string]KeyProvider // Unwrap data keys, by provider name
+
+	requireBinding bool // Reject ciphertexts sealed without a binding
+}
+
+// Columns of user_services that hold encrypted tokens
+const (
+	AccessTokenField  = "access_token"
+	RefreshTokenField = "refresh_token"
+)
+
+// ErrUnbound is returned when a ciphertext sealed without a binding is read while bindings are
+// required
+var ErrUnbound = errors.New("ciphertext is not bound to its record")
+
+// Binding ties a ciphertext to the record and field it is stored in. It is sealed as associated
+// data along with the key the ciphertext names, so a ciphertext copied to another record or
+// field, or relabelled with another key, fails to decrypt.
+type Binding struct {
+	RecordID string // user_services id
+	Field    string // Column holding the ciphertext
+}
+
+// associatedData encodes a binding and the key reference of its ciphertext
+func (b Binding) associatedData(keyRef string) []byte {
+	return []byte("syncer.token\x00" + b.RecordID + "\x00" + b.Field + "\x00" + keyRef)
+}
+
+func (b Binding) validate() error {
+	if b.RecordID == "" || b.Field == "" {
+		return fmt.Errorf("binding needs a record id and field")
+	}
+	return nil
+}
+
+// ParseKey decodes a 32-byte encryption key given as base64, hex or 32 raw characters
+func ParseKey(value string) ([32]byte, error) {
+	var key [32]byte
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(decoded) != len(key) {
+		decoded, err = hex.DecodeString(value)
+	}
+	if err != nil || len(decoded) != len(key) {
+		decoded = []byte(value)
+	}
+	if len(decoded) != len(key) {
+		return key, fmt.Errorf("encryption key must be 32 bytes, got %d", len(decoded))
+	}
+
+	copy(key[:], decoded)
+	return key, nil
 }
 
-// NewTokenEncryption creates a new token encryption instance
+// NewTokenEncryption creates a token encryption instance using a single key
 func NewTokenEncryption(key [32]byte) (*TokenEncryption, error) {
-	return &TokenEncryption{key: key}, nil
+	return NewKeyringEncryption(SingleKeyring(key))
+}
+
+// NewKeyringEncryption creates a token encryption instance that encrypts with the keyring's
+// current key and decrypts with any of its keys
+func NewKeyringEncryption(keyring *Keyring) (*TokenEncryption, error) {
+	if keyring == nil {
+		return nil, fmt.Errorf("keyring is required")
+	}
+	return &TokenEncryption{keyring: keyring}, nil
+}
+
+// NewEnvelopeEncryption creates a token encryption instance wrapping a new data key per token with
+// the provider. Previous providers and the keyring, which may be nil, only decrypt tokens stored
+// before the switch to this provider.
+func NewEnvelopeEncryption(provider KeyProvider, keyring *Keyring, previous ...KeyProvider) (*TokenEncryption, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("key provider is required")
+	}
+
+	providers := map[string]KeyProvider{provider.Name(): provider}
+	for _, p := range previous {
+		if _, duplicate := providers[p.Name()]; duplicate {
+			return nil, fmt.Errorf("key provider %s is configured twice", p.Name())
+		}
+		providers[p.Name()] = p
+	}
+
+	return &TokenEncryption{keyring: keyring, provider: provider, providers: providers}, nil
+}
+
+// RequireBinding makes Decrypt reject ciphertexts sealed without a binding. Enable it once the
+// re-encryption job has bound every stored token.
+func (e *TokenEncryption) RequireBinding() {
+	e.requireBinding = true
+}
+
+// CurrentKeyRef names the key new ciphertexts are encrypted under, as provider/key
+func (e *TokenEncryption) CurrentKeyRef() string {
+	if e.provider != nil {
+		return KeyRef(e.provider.Name(), e.provider.CurrentKeyID())
+	}
+	return KeyRef("keyring", strconv.FormatUint(uint64(e.keyring.CurrentKeyID()), 10))
+}
+
+// RefreshKeys has the key provider look up its current key, for providers whose keys are rotated
+// outside this process
+func (e *TokenEncryption) RefreshKeys(ctx context.Context) error {
+	if refresher, ok := e.provider.(interface{ Refresh(context.Context) error }); ok {
+		return refresher.Refresh(ctx)
+	}
+	return nil
 }
 
-// Encrypt encrypts plaintext using AES-256-GCM
-func (e *TokenEncryption) Encrypt(plaintext string) ([]byte, error) {
+// keyRef names the key a ciphertext was encrypted under, or returns an empty string for
+// ciphertexts written before keys were versioned
+func keyRef(ciphertext []byte) string {
+	if env, ok := parseEnvelope(ciphertext); ok {
+		return KeyRef(env.Provider, env.KeyID)
+	}
+	if id, ok := KeyID(ciphertext); ok {
+		return KeyRef("keyring", strconv.FormatUint(uint64(id), 10))
+	}
+	return ""
+}
+
+// Encrypt encrypts plaintext using AES-256-GCM, bound to the record and field it is stored in.
+// The ciphertext names its key, or carries its wrapped data key, so it can still be decrypted
+// after keys are rotated.
+func (e *TokenEncryption) Encrypt(plaintext string, binding Binding) ([]byte, error) {
 	if plaintext == "" {
 		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
+	if err := binding.validate(); err != nil {
+		return nil, err
+	}
 
-	block, err := aes.NewCipher(e.key[:])
+	if e.provider != nil {
+		return e.encryptEnvelope([]byte(plaintext), binding)
+	}
+
+	key, header := e.keyring.currentKey()
+	header[0] |= boundFlag
+	payload, err := seal(key, []byte(plaintext), binding.associatedData(e.CurrentKeyRef()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
+	return append(header, payload...), nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+// encryptEnvelope seals plaintext with a new data key from the provider
+func (e *TokenEncryption) encryptEnvelope(plaintext []byte, binding Binding) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	dataKey, err := e.provider.GenerateDataKey(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("%s: %w", e.provider.Name(), err)
+	}
+	if len(dataKey.Plaintext) != 32 {
+		return nil, fmt.Errorf("%s returned a %d-byte data key", e.provider.Name(), len(dataKey.Plaintext))
+	}
+	if len(dataKey.Wrapped) > 0xFFFF {
+		return nil, fmt.Errorf("%s returned a wrapped data key too long to store", e.provider.Name())
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	ref := KeyRef(e.provider.Name(), dataKey.KeyID)
+	payload, err := seal([32]byte(dataKey.Plaintext), plaintext, binding.associatedData(ref))
+	if err != nil {
+		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return ciphertext, nil
+	return marshalEnvelope(envelope{
+		Provider: e.provider.Name(),
+		KeyID:    dataKey.KeyID,
+		Wrapped:  dataKey.Wrapped,
+		Payload:  payload,
+		Bound:    true,
+	}), nil
 }
 
-// Decrypt decrypts ciphertext using AES-256-GCM
-func (e *TokenEncryption) Decrypt(ciphertext []byte) (string, error) {
+// Decrypt decrypts a ciphertext with the data key it carries, the keyring key it names, or the
+// legacy key for ciphertexts written before keys were versioned. Bound ciphertexts only decrypt
+// with the binding they were sealed with; unbound ones, written before tokens were bound, decrypt
+// with any binding unless bindings are required.
+func (e *TokenEncryption) Decrypt(ciphertext []byte, binding Binding) (string, error) {
 	if len(ciphertext) == 0 {
 		return "", fmt.Errorf("ciphertext cannot be empty")
 	}
 
-	block, err := aes.NewCipher(e.key[:])
+	if env, ok := parseEnvelope(ciphertext); ok {
+		plaintext, err := e.decryptEnvelope(env, binding)
+		// A legacy ciphertext's random nonce may happen to look like an envelope
+		if err == nil || e.keyring == nil || e.keyring.legacy == nil {
+			return plaintext, err
+		}
+	}
+
+	if e.keyring == nil {
+		return "", fmt.Errorf("%w: ciphertext was not encrypted with a key provider and no keyring is configured", ErrUnknownKey)
+	}
+	candidates, err := e.keyring.decryptionKeys(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	for _, candidate := range candidates {
+		var additionalData []byte
+		if candidate.bound {
+			additionalData = binding.associatedData(keyRef(ciphertext))
+		} else if e.requireBinding {
+			if err == nil {
+				err = ErrUnbound
+			}
+			continue
+		}
+
+		var plaintext []byte
+		plaintext, err = openBytes(candidate.key, candidate.payload, additionalData)
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", err
+}
+
+// decryptEnvelope unwraps an envelope's data key with its provider and opens the payload
+func (e *TokenEncryption) decryptEnvelope(env envelope, binding Binding) (string, error) {
+	var additionalData []byte
+	if env.Bound {
+		additionalData = binding.associatedData(KeyRef(env.Provider, env.KeyID))
+	} else if e.requireBinding {
+		return "", ErrUnbound
+	}
+
+	provider, ok := e.providers[env.Provider]
+	if !ok {
+		return "", fmt.Errorf("%w: key provider %s is not configured", ErrUnknownKey, env.Provider)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	dataKey, err := provider.DecryptDataKey(ctx, env.KeyID, env.Wrapped)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", provider.Name(), err)
+	}
+	if len(dataKey) != 32 {
+		return "", fmt.Errorf("%s returned a %d-byte data key", provider.Name(), len(dataKey))
+	}
+
+	plaintext, err := openBytes([32]byte(dataKey), env.Payload, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether a ciphertext was encrypted under a key other than the current one
+// or without a binding
+func (e *TokenEncryption) NeedsRotation(ciphertext []byte) bool {
+	if len(ciphertext) == 0 {
+		return false
+	}
+	return keyRef(ciphertext) != e.CurrentKeyRef() || !isBound(ciphertext)
+}
+
+// isBound reports whether a ciphertext was sealed with a binding
+func isBound(ciphertext []byte) bool {
+	return keyRef(ciphertext) != "" && ciphertext[0]&boundFlag != 0
+}
+
+// Reencrypt decrypts a ciphertext and encrypts it again under the current key, bound to its
+// record and field. Empty ciphertexts and those already bound under the current key are returned
+// unchanged.
+func (e *TokenEncryption) Reencrypt(ciphertext []byte, binding Binding) ([]byte, error) {
+	if !e.NeedsRotation(ciphertext) {
+		return ciphertext, nil
+	}
+
+	plaintext, err := e.Decrypt(ciphertext, binding)
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt(plaintext, binding)
+}
+
+// keyProviderTimeout bounds a call to a key provider to wrap or unwrap one data key
+const keyProviderTimeout = 10 * time.Second
+
+// newGCM creates an AES-256-GCM cipher for a key
+func newGCM(key [32]byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM under a fresh nonce, authenticating the additional
+// data, and returns the nonce followed by the sealed data
+func seal(key [32]byte, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openBytes decrypts a nonce-prefixed AES-256-GCM payload sealed with the additional data
+func openBytes(key [32]byte, payload, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+	if len(payload) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
 	}
 
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	nonce, sealed := payload[:nonceSize], payload[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, sealed, additionalData)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
 
-	return string(plaintext), nil
+	return plaintext, nil
 }
 
-// EncryptTokens encrypts OAuth tokens for secure storage
-func (e *TokenEncryption) EncryptTokens(accessToken, refreshToken string) ([]byte, []byte, error) {
+// EncryptTokens encrypts OAuth tokens for secure storage in a user_services row
+func (e *TokenEncryption) EncryptTokens(userServiceID, accessToken, refreshToken string) ([]byte, []byte, error) {
 	var encryptedAccess, encryptedRefresh []byte
 	var err error
 
 	if accessToken != "" {
-		encryptedAccess, err = e.Encrypt(accessToken)
+		encryptedAccess, err = e.Encrypt(accessToken, Binding{RecordID: userServiceID, Field: AccessTokenField})
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to encrypt access token: %w", err)
 		}
 	}
 
 	if refreshToken != "" {
-		encryptedRefresh, err = e.Encrypt(refreshToken)
+		encryptedRefresh, err = e.Encrypt(refreshToken, Binding{RecordID: userServiceID, Field: RefreshTokenField})
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to encrypt refresh token: %w", err)
 		}
@@ -95,20 +379,20 @@ func (e *TokenEncryption) EncryptTokens(accessToken, refreshToken string) ([]byt
 	return encryptedAccess, encryptedRefresh, nil
 }
 
-// DecryptTokens decrypts OAuth tokens from secure storage
-func (e *TokenEncryption) DecryptTokens(encryptedAccess, encryptedRefresh []byte) (string, string, error) {
+// DecryptTokens decrypts OAuth tokens stored in a user_services row
+func (e *TokenEncryption) DecryptTokens(userServiceID string, encryptedAccess, encryptedRefresh []byte) (string, string, error) {
 	var accessToken, refreshToken string
 	var err error
 
 	if len(encryptedAccess) > 0 {
-		accessToken, err = e.Decrypt(encryptedAccess)
+		accessToken, err = e.Decrypt(encryptedAccess, Binding{RecordID: userServiceID, Field: AccessTokenField})
 		if err != nil {
 			return "", "", fmt.Errorf("failed to decrypt access token: %w", err)
 		}
 	}
 
 	if len(encryptedRefresh) > 0 {
-		refreshToken, err = e.Decrypt(encryptedRefresh)
+		refreshToken, err = e.Decrypt(encryptedRefresh, Binding{RecordID: userServiceID, Field: RefreshTokenField})
 		if err != nil {
 			return "", "", fmt.Errorf("failed to decrypt refresh token: %w", err)
 		}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"errors"
+	"testing"
+)
+
+var testBinding = Binding{RecordID: "5b0c3f4e-8d1a-4f7e-9c2b-6a1d0e8f3b47", Field: AccessTokenField}
+
+func TestBindingRejectsCopiedCiphertexts(t *testing.T) {
+	kms, _ := NewSoftwareKMS()
+	envelope, _ := NewEnvelopeEncryption(kms, nil)
+	direct, _ := NewTokenEncryption(testKey(t, 4))
+
+	for name, encryption := range map[string]*TokenEncryption{"keyring": direct, "envelope": envelope} {
+		t.Run(name, func(t *testing.T) {
+			stored, err := encryption.Encrypt("access-token", testBinding)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if plaintext, err := encryption.Decrypt(stored, testBinding); err != nil || plaintext != "access-token" {
+				t.Fatalf("Decrypt = %q, %v", plaintext, err)
+			}
+
+			otherRow := Binding{RecordID: "0d9e7a52-1c3b-4a8f-b6e2-93f4c1d7a805", Field: AccessTokenField}
+			if _, err := encryption.Decrypt(stored, otherRow); err == nil {
+				t.Error("ciphertext copied to another row decrypted")
+			}
+			otherField := Binding{RecordID: testBinding.RecordID, Field: RefreshTokenField}
+			if _, err := encryption.Decrypt(stored, otherField); err == nil {
+				t.Error("ciphertext copied to another column decrypted")
+			}
+
+			if _, err := encryption.Encrypt("access-token", Binding{Field: AccessTokenField}); err == nil {
+				t.Error("Encrypt accepted a binding without a record id")
+			}
+		})
+	}
+}
+
+func TestBindingRejectsRelabelledKey(t *testing.T) {
+	key := testKey(t, 5)
+	ring, _ := NewKeyring(2, map[uint32][32]byte{1: key, 2: key})
+	encryption, _ := NewKeyringEncryption(ring)
+
+	stored, err := encryption.Encrypt("access-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// Pointing the header at another key id changes the associated data
+	relabelled := append([]byte(nil), stored...)
+	relabelled[keyHeaderSize-1] = 1
+	if _, err := encryption.Decrypt(relabelled, testBinding); err == nil {
+		t.Error("ciphertext with a rewritten key id decrypted")
+	}
+}
+
+func TestUnboundCiphertexts(t *testing.T) {
+	key := testKey(t, 6)
+
+	// Ciphertexts written before binding carry a key header but no associated data
+	_, header := SingleKeyring(key).currentKey()
+	payload, err := seal(key, []byte("refresh-token"), nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	unbound := append(header, payload...)
+
+	encryption, _ := NewTokenEncryption(key)
+	binding := Binding{RecordID: testBinding.RecordID, Field: RefreshTokenField}
+	if plaintext, err := encryption.Decrypt(unbound, binding); err != nil || plaintext != "refresh-token" {
+		t.Fatalf("Decrypt unbound = %q, %v", plaintext, err)
+	}
+	if !encryption.NeedsRotation(unbound) {
+		t.Fatal("unbound ciphertext under the current key does not need rotation")
+	}
+
+	bound, err := encryption.Reencrypt(unbound, binding)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if encryption.NeedsRotation(bound) {
+		t.Error("re-encrypted ciphertext still needs rotation")
+	}
+
+	encryption.RequireBinding()
+	if _, err := encryption.Decrypt(unbound, binding); !errors.Is(err, ErrUnbound) {
+		t.Errorf("Decrypt unbound with bindings required = %v, want ErrUnbound", err)
+	}
+	if plaintext, err := encryption.Decrypt(bound, binding); err != nil || plaintext != "refresh-token" {
+		t.Errorf("Decrypt bound with bindings required = %q, %v", plaintext, err)
+	}
+}
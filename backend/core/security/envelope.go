@@ -0,0 +1,224 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// envelopeFormat marks a ciphertext encrypted with its own data key, which is stored wrapped by
+// a key-encryption key of a KeyProvider
+const envelopeFormat byte = 0x02
+
+// KeyProvider holds the key-encryption keys that wrap the per-record data keys of envelope
+// encryption. Key material of remote providers never leaves them; only data keys do.
+type KeyProvider interface {
+	// Name identifies the provider in stored key references
+	Name() string
+	// CurrentKeyID is the key-encryption key new data keys are wrapped with
+	CurrentKeyID() string
+	// GenerateDataKey returns a new 32-byte data key along with its wrapped form
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	// DecryptDataKey unwraps a data key wrapped by the given key-encryption key
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// DataKey is a data key in plaintext, to encrypt one record, and wrapped, to store with it
+type DataKey struct {
+	KeyID     string // Key-encryption key that wrapped it
+	Plaintext []byte
+	Wrapped   []byte
+}
+
+// KeyRef names a key-encryption key as provider/key, as stored with ciphertexts and in the
+// token_key_id column
+func KeyRef(provider, keyID string) string {
+	return provider + "/" + keyID
+}
+
+// envelope is a parsed envelope ciphertext
+type envelope struct {
+	Provider string
+	KeyID    string
+	Wrapped  []byte
+	Payload  []byte // Nonce followed by the sealed plaintext
+	Bound    bool   // Payload sealed with a binding as associated data
+}
+
+// marshalEnvelope encodes an envelope as the format byte, the length-prefixed key reference and
+// wrapped data key, then the payload
+func marshalEnvelope(e envelope) []byte {
+	ref := KeyRef(e.Provider, e.KeyID)
+	out := make([]byte, 0, 1+1+len(ref)+2+len(e.Wrapped)+len(e.Payload))
+	format := envelopeFormat
+	if e.Bound {
+		format |= boundFlag
+	}
+	out = append(out, format, byte(len(ref)))
+	out = append(out, ref...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.Wrapped)))
+	out = append(out, e.Wrapped...)
+	return append(out, e.Payload...)
+}
+
+// parseEnvelope decodes an envelope ciphertext, reporting false for other formats
+func parseEnvelope(ciphertext []byte) (envelope, bool) {
+	if len(ciphertext) < 2 || ciphertext[0]&^boundFlag != envelopeFormat {
+		return envelope{}, false
+	}
+
+	rest := ciphertext[1:]
+	refLen := int(rest[0])
+	if len(rest) < 1+refLen+2 {
+		return envelope{}, false
+	}
+	provider, keyID, ok := strings.Cut(string(rest[1:1+refLen]), "/")
+	if !ok {
+		return envelope{}, false
+	}
+
+	rest = rest[1+refLen:]
+	wrappedLen := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+wrappedLen {
+		return envelope{}, false
+	}
+
+	return envelope{
+		Provider: provider,
+		KeyID:    keyID,
+		Wrapped:  rest[2 : 2+wrappedLen],
+		Payload:  rest[2+wrappedLen:],
+		Bound:    ciphertext[0]&boundFlag != 0,
+	}, true
+}
+
+// newDataKey returns 32 random bytes for a data key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// wrapDataKey generates a data key and wraps it with AES-256-GCM under a local key
+func wrapDataKey(keyID string, kek [32]byte) (*DataKey, error) {
+	plaintext, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(kek, plaintext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &DataKey{KeyID: keyID, Plaintext: plaintext, Wrapped: wrapped}, nil
+}
+
+// LocalKeyProvider wraps data keys with the keys of a keyring loaded from the environment or a
+// file. Rotating the keyring's current key changes the key new data keys are wrapped with.
+type LocalKeyProvider struct {
+	keyring *Keyring
+}
+
+// NewLocalKeyProvider creates a key provider backed by a keyring
+func NewLocalKeyProvider(keyring *Keyring) *LocalKeyProvider {
+	return &LocalKeyProvider{keyring: keyring}
+}
+
+func (p *LocalKeyProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalKeyProvider) CurrentKeyID() string {
+	return strconv.FormatUint(uint64(p.keyring.CurrentKeyID()), 10)
+}
+
+// GenerateDataKey wraps a new data key with the keyring's current key
+func (p *LocalKeyProvider) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	key, _ := p.keyring.currentKey()
+	return wrapDataKey(p.CurrentKeyID(), key)
+}
+
+// DecryptDataKey unwraps a data key with the keyring key it names
+func (p *LocalKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	id, err := strconv.ParseUint(keyID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	key, ok := p.keyring.keys[uint32(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKey, id)
+	}
+	return openBytes(key, wrapped, nil)
+}
+
+// SoftwareKMS is an in-process stand-in for a key management service. Its master keys are
+// generated in memory and lost on restart, so it suits development and tests only.
+type SoftwareKMS struct {
+	mu      sync.RWMutex
+	keys    map[string][32]byte
+	current string
+}
+
+// NewSoftwareKMS creates a software KMS with a freshly generated master key
+func NewSoftwareKMS() (*SoftwareKMS, error) {
+	kms := &SoftwareKMS{keys: make(map[string][32]byte)}
+	if _, err := kms.Rotate(); err != nil {
+		return nil, err
+	}
+	return kms, nil
+}
+
+// Rotate generates a new master key that wraps data keys from now on, keeping the earlier ones
+// for unwrapping, and returns its id
+func (k *SoftwareKMS) Rotate() (string, error) {
+	var key [32]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return "", fmt.Errorf("failed to generate master key: %w", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := "v" + strconv.Itoa(len(k.keys)+1)
+	k.keys[id] = key
+	k.current = id
+	return id, nil
+}
+
+func (k *SoftwareKMS) Name() string {
+	return "kms"
+}
+
+func (k *SoftwareKMS) CurrentKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// GenerateDataKey wraps a new data key with the current master key
+func (k *SoftwareKMS) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	k.mu.RLock()
+	id, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+
+	return wrapDataKey(id, key)
+}
+
+// DecryptDataKey unwraps a data key with the master key it names
+func (k *SoftwareKMS) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[keyID]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return openBytes(key, wrapped, nil)
+}
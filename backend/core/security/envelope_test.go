@@ -0,0 +1,260 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEnvelopeEncryptionRotatesKMSKeys(t *testing.T) {
+	kms, err := NewSoftwareKMS()
+	if err != nil {
+		t.Fatalf("NewSoftwareKMS: %v", err)
+	}
+	encryption, err := NewEnvelopeEncryption(kms, nil)
+	if err != nil {
+		t.Fatalf("NewEnvelopeEncryption: %v", err)
+	}
+
+	first, err := encryption.Encrypt("access-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	second, _ := encryption.Encrypt("access-token", testBinding)
+	if bytes.Equal(first, second) {
+		t.Error("two encryptions of the same token are identical")
+	}
+	if ref := keyRef(first); ref != "kms/v1" || encryption.CurrentKeyRef() != ref {
+		t.Errorf("key reference = %q, want kms/v1", ref)
+	}
+
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if !encryption.NeedsRotation(first) {
+		t.Fatal("ciphertext under the previous master key does not need rotation")
+	}
+
+	rotated, err := encryption.Reencrypt(first, testBinding)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if ref := keyRef(rotated); ref != "kms/v2" {
+		t.Errorf("re-encrypted key reference = %q, want kms/v2", ref)
+	}
+	for _, ciphertext := range [][]byte{first, rotated} {
+		if plaintext, err := encryption.Decrypt(ciphertext, testBinding); err != nil || plaintext != "access-token" {
+			t.Errorf("Decrypt(%s) = %q, %v", keyRef(ciphertext), plaintext, err)
+		}
+	}
+}
+
+func TestEnvelopeEncryptionReadsKeyringCiphertexts(t *testing.T) {
+	ring, err := NewKeyring(1, map[uint32][32]byte{1: testKey(t, 3)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	direct, _ := NewKeyringEncryption(ring)
+	stored, err := direct.Encrypt("refresh-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	encryption, err := NewEnvelopeEncryption(NewLocalKeyProvider(ring), ring)
+	if err != nil {
+		t.Fatalf("NewEnvelopeEncryption: %v", err)
+	}
+	if plaintext, err := encryption.Decrypt(stored, testBinding); err != nil || plaintext != "refresh-token" {
+		t.Fatalf("Decrypt keyring ciphertext = %q, %v", plaintext, err)
+	}
+	if !encryption.NeedsRotation(stored) {
+		t.Error("keyring ciphertext does not need moving to envelope encryption")
+	}
+
+	migrated, err := encryption.Reencrypt(stored, testBinding)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if ref := keyRef(migrated); ref != "local/1" {
+		t.Errorf("migrated key reference = %q, want local/1", ref)
+	}
+
+	// Without the provider that wrapped it, an envelope cannot be opened
+	kms, _ := NewSoftwareKMS()
+	other, _ := NewEnvelopeEncryption(kms, nil)
+	if _, err := other.Decrypt(migrated, testBinding); err == nil {
+		t.Error("Decrypt succeeded without the provider that wrapped the data key")
+	}
+}
+
+func TestVaultTransit(t *testing.T) {
+	vault := newFakeVault(t)
+
+	provider, err := NewVaultTransit(context.Background(), VaultTransitConfig{
+		Address: vault.server.URL,
+		Token:   "root",
+		KeyName: "syncer-tokens",
+	})
+	if err != nil {
+		t.Fatalf("NewVaultTransit: %v", err)
+	}
+	encryption, _ := NewEnvelopeEncryption(provider, nil)
+
+	stored, err := encryption.Encrypt("access-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if ref := keyRef(stored); ref != "vault/v1" {
+		t.Errorf("key reference = %q, want vault/v1", ref)
+	}
+
+	vault.rotate()
+	if err := encryption.RefreshKeys(context.Background()); err != nil {
+		t.Fatalf("RefreshKeys: %v", err)
+	}
+	rotated, err := encryption.Reencrypt(stored, testBinding)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if ref := keyRef(rotated); ref != "vault/v2" || encryption.CurrentKeyRef() != ref {
+		t.Errorf("key reference after rotation = %q, current %q; want vault/v2", ref, encryption.CurrentKeyRef())
+	}
+	if plaintext, err := encryption.Decrypt(stored, testBinding); err != nil || plaintext != "access-token" {
+		t.Errorf("Decrypt under the previous version = %q, %v", plaintext, err)
+	}
+
+	if _, err := NewVaultTransit(context.Background(), VaultTransitConfig{
+		Address: vault.server.URL, Token: "wrong", KeyName: "syncer-tokens",
+	}); err == nil {
+		t.Error("NewVaultTransit accepted a rejected token")
+	}
+}
+
+// TestVaultDevServer runs against a real Vault, such as one started with `vault server -dev`,
+// when VAULT_ADDR and VAULT_TOKEN are set
+func TestVaultDevServer(t *testing.T) {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		t.Skip("VAULT_ADDR and VAULT_TOKEN are not set")
+	}
+
+	// Enabling the engine fails harmlessly when it is already mounted
+	vaultRequest(t, address, token, "/v1/sys/mounts/transit", map[string]any{"type": "transit"})
+	vaultRequest(t, address, token, "/v1/transit/keys/syncer-test", map[string]any{})
+
+	provider, err := NewVaultTransit(context.Background(), VaultTransitConfig{
+		Address: address, Token: token, KeyName: "syncer-test",
+	})
+	if err != nil {
+		t.Fatalf("NewVaultTransit: %v", err)
+	}
+	encryption, _ := NewEnvelopeEncryption(provider, nil)
+
+	stored, err := encryption.Encrypt("access-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if plaintext, err := encryption.Decrypt(stored, testBinding); err != nil || plaintext != "access-token" {
+		t.Errorf("Decrypt = %q, %v", plaintext, err)
+	}
+}
+
+func vaultRequest(t *testing.T, address, token, path string, body any) {
+	t.Helper()
+
+	encoded, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, strings.TrimRight(address, "/")+path, bytes.NewReader(encoded))
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("vault %s: %v", path, err)
+	}
+	resp.Body.Close()
+}
+
+// fakeVault emulates the transit endpoints VaultTransit calls
+type fakeVault struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	versions [][32]byte
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	vault := &fakeVault{}
+	vault.rotate()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/transit/keys/syncer-tokens", func(w http.ResponseWriter, r *http.Request) {
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+		vault.reply(w, map[string]any{"latest_version": len(vault.versions)})
+	})
+	mux.HandleFunc("POST /v1/transit/datakey/plaintext/syncer-tokens", func(w http.ResponseWriter, r *http.Request) {
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+
+		version := len(vault.versions)
+		dataKey, _ := newDataKey()
+		wrapped, _ := seal(vault.versions[version-1], dataKey, nil)
+		vault.reply(w, map[string]any{
+			"plaintext":   base64.StdEncoding.EncodeToString(dataKey),
+			"ciphertext":  fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(wrapped)),
+			"key_version": version,
+		})
+	})
+	mux.HandleFunc("POST /v1/transit/decrypt/syncer-tokens", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		vault.mu.Lock()
+		defer vault.mu.Unlock()
+
+		parts := strings.SplitN(body.Ciphertext, ":", 3)
+		version, _ := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+		wrapped, _ := base64.StdEncoding.DecodeString(parts[2])
+		dataKey, err := openBytes(vault.versions[version-1], wrapped, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"cipher: message authentication failed"}})
+			return
+		}
+		vault.reply(w, map[string]any{"plaintext": base64.StdEncoding.EncodeToString(dataKey)})
+	})
+
+	vault.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(vault.server.Close)
+	return vault
+}
+
+// rotate adds a key version that wraps new data keys
+func (v *fakeVault) rotate() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, _ := newDataKey()
+	v.versions = append(v.versions, [32]byte(key))
+}
+
+func (v *fakeVault) reply(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// versionedFormat marks a ciphertext that starts with the id of the key it was encrypted with.
+// Ciphertexts written before keys were versioned start directly with their nonce.
+const versionedFormat byte = 0x01
+
+// boundFlag is set on the format byte of ciphertexts sealed with a Binding as associated data
+const boundFlag byte = 0x10
+
+// keyHeaderSize is the format byte followed by a big-endian key id
+const keyHeaderSize = 1 + 4
+
+// ErrUnknownKey is returned when a ciphertext names a key the keyring does not hold
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds every key tokens may be encrypted with. New ciphertexts use the current key;
+// any key in the ring decrypts, so retired keys stay until nothing is encrypted with them.
+type Keyring struct {
+	keys    map[uint32][32]byte
+	current uint32
+	legacy  *[32]byte // Decrypts ciphertexts without a key id, if set
+}
+
+// NewKeyring creates a keyring encrypting with the current key, which must be among keys
+func NewKeyring(current uint32, keys map[uint32][32]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %d is not in the keyring", current)
+	}
+
+	ring := &Keyring{keys: make(map[uint32][32]byte, len(keys)), current: current}
+	for id, key := range keys {
+		ring.keys[id] = key
+	}
+	return ring, nil
+}
+
+// SingleKeyring creates a keyring of one key with id 1, which also decrypts ciphertexts written
+// before keys were versioned
+func SingleKeyring(key [32]byte) *Keyring {
+	ring, _ := NewKeyring(1, map[uint32][32]byte{1: key})
+	ring.legacy = &key
+	return ring
+}
+
+// ParseKeyring builds a keyring from a comma-separated list of id:key entries, keys encoded as
+// ParseKey accepts. The highest id is the current key. The legacy key, when not empty, decrypts
+// ciphertexts written before keys were versioned and is used alone when no entries are given.
+func ParseKeyring(entries, legacy string) (*Keyring, error) {
+	var legacyKey *[32]byte
+	if legacy != "" {
+		key, err := ParseKey(legacy)
+		if err != nil {
+			return nil, fmt.Errorf("legacy key: %w", err)
+		}
+		legacyKey = &key
+	}
+
+	if strings.TrimSpace(entries) == "" {
+		if legacyKey == nil {
+			return nil, fmt.Errorf("no encryption keys configured")
+		}
+		return SingleKeyring(*legacyKey), nil
+	}
+
+	keys := make(map[uint32][32]byte)
+	for _, entry := range strings.Split(entries, ",") {
+		idText, keyText, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("key entry must be id:key")
+		}
+
+		id, err := strconv.ParseUint(idText, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("key id %q must be a positive integer", idText)
+		}
+		if _, duplicate := keys[uint32(id)]; duplicate {
+			return nil, fmt.Errorf("key %d is listed twice", id)
+		}
+
+		key, err := ParseKey(keyText)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", id, err)
+		}
+		keys[uint32(id)] = key
+	}
+
+	ids := make([]uint32, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+
+	ring, err := NewKeyring(slices.Max(ids), keys)
+	if err != nil {
+		return nil, err
+	}
+	ring.legacy = legacyKey
+	return ring, nil
+}
+
+// LoadKeyringFile builds a keyring from a file of id:key entries, one per line or separated by
+// commas. Lines starting with # are ignored.
+func LoadKeyringFile(path, legacy string) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	return ParseKeyring(strings.Join(entries, ","), legacy)
+}
+
+// CurrentKeyID returns the id of the key new ciphertexts are encrypted with
+func (k *Keyring) CurrentKeyID() uint32 {
+	return k.current
+}
+
+// KeyIDs returns the ids of every key in the ring, in ascending order
+func (k *Keyring) KeyIDs() []uint32 {
+	ids := make([]uint32, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// KeyID returns the id of the key a ciphertext was encrypted with. Ciphertexts written before
+// keys were versioned report false.
+func KeyID(ciphertext []byte) (uint32, bool) {
+	if len(ciphertext) < keyHeaderSize || ciphertext[0]&^boundFlag != versionedFormat {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ciphertext[1:keyHeaderSize]), true
+}
+
+// currentKey returns the key new ciphertexts use and the header naming it
+func (k *Keyring) currentKey() ([32]byte, []byte) {
+	header := make([]byte, keyHeaderSize)
+	header[0] = versionedFormat
+	binary.BigEndian.PutUint32(header[1:], k.current)
+	return k.keys[k.current], header
+}
+
+// decryptionKeys returns the candidate keys for a ciphertext with the payload each applies to.
+// A ciphertext that looks versioned may still be a legacy one whose nonce happens to start with
+// the format byte, so the legacy key is tried on the whole ciphertext as well.
+func (k *Keyring) decryptionKeys(ciphertext []byte) ([]candidateKey, error) {
+	var candidates []candidateKey
+	if id, ok := KeyID(ciphertext); ok {
+		if key, found := k.keys[id]; found {
+			candidates = append(candidates, candidateKey{
+				key:     key,
+				payload: ciphertext[keyHeaderSize:],
+				bound:   ciphertext[0]&boundFlag != 0,
+			})
+		} else if k.legacy == nil {
+			return nil, fmt.Errorf("%w %d", ErrUnknownKey, id)
+		}
+	}
+	if k.legacy != nil {
+		candidates = append(candidates, candidateKey{key: *k.legacy, payload: ciphertext})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: ciphertext has no key id and no legacy key is configured", ErrUnknownKey)
+	}
+	return candidates, nil
+}
+
+type candidateKey struct {
+	key     [32]byte
+	payload []byte
+	bound   bool // Sealed with a binding as associated data
+}
@@ -0,0 +1,116 @@
+package security
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func testKey(t *testing.T, fill byte) [32]byte {
+	t.Helper()
+	var key [32]byte
+	for i := range key {
+		key[i] = fill
+	}
+	return key
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+
+	before, err := NewKeyringEncryption(SingleKeyring(oldKey))
+	if err != nil {
+		t.Fatalf("NewKeyringEncryption: %v", err)
+	}
+	stored, err := before.Encrypt("access-token", testBinding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if id, ok := KeyID(stored); !ok || id != 1 {
+		t.Fatalf("KeyID = %d, %v; want key 1", id, ok)
+	}
+
+	ring, err := NewKeyring(2, map[uint32][32]byte{1: oldKey, 2: newKey})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	after, _ := NewKeyringEncryption(ring)
+
+	if plaintext, err := after.Decrypt(stored, testBinding); err != nil || plaintext != "access-token" {
+		t.Fatalf("Decrypt with rotated keyring = %q, %v", plaintext, err)
+	}
+	if !after.NeedsRotation(stored) {
+		t.Error("ciphertext under the retired key does not need rotation")
+	}
+
+	rotated, err := after.Reencrypt(stored, testBinding)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if id, _ := KeyID(rotated); id != 2 || after.NeedsRotation(rotated) {
+		t.Errorf("re-encrypted ciphertext uses key %d, want 2", id)
+	}
+
+	// Once key 1 is removed, only ciphertexts that were migrated still decrypt
+	newOnly, _ := NewKeyring(2, map[uint32][32]byte{2: newKey})
+	final, _ := NewKeyringEncryption(newOnly)
+	if plaintext, err := final.Decrypt(rotated, testBinding); err != nil || plaintext != "access-token" {
+		t.Errorf("Decrypt after retiring key 1 = %q, %v", plaintext, err)
+	}
+	if _, err := final.Decrypt(stored, testBinding); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt under retired key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestDecryptLegacyCiphertext(t *testing.T) {
+	key := testKey(t, 7)
+
+	// Ciphertexts written before keys were versioned are the nonce followed by the sealed data
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatalf("newGCM: %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatal(err)
+	}
+	legacy := gcm.Seal(nonce, nonce, []byte("refresh-token"), nil)
+
+	current := testKey(t, 'b')
+	ring, err := ParseKeyring("2:"+string(current[:]), string(key[:]))
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	encryption, _ := NewKeyringEncryption(ring)
+
+	if plaintext, err := encryption.Decrypt(legacy, testBinding); err != nil || plaintext != "refresh-token" {
+		t.Fatalf("Decrypt legacy = %q, %v", plaintext, err)
+	}
+	if !encryption.NeedsRotation(legacy) {
+		t.Error("legacy ciphertext does not need rotation")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	raw := testKey(t, 'a')
+	key := string(raw[:])
+
+	ring, err := ParseKeyring("1:"+key+", 3:"+key, "")
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	if ring.CurrentKeyID() != 3 {
+		t.Errorf("current key = %d, want the highest id", ring.CurrentKeyID())
+	}
+
+	if ring, err := ParseKeyring("", key); err != nil || ring.CurrentKeyID() != 1 {
+		t.Errorf("ParseKeyring with only a legacy key = %v, %v", ring, err)
+	}
+
+	for _, entries := range []string{"", "1", "0:" + key, "x:" + key, "1:short", "1:" + key + ",1:" + key} {
+		if _, err := ParseKeyring(entries, ""); err == nil {
+			t.Errorf("ParseKeyring(%q) accepted invalid keys", entries)
+		}
+	}
+}
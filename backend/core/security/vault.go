@@ -0,0 +1,186 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultTransitConfig locates a HashiCorp Vault transit key
+type VaultTransitConfig struct {
+	Address    string // Vault server, such as http://127.0.0.1:8200
+	Token      string
+	Mount      string // Path the transit engine is mounted at; defaults to transit
+	KeyName    string
+	HTTPClient *http.Client
+}
+
+// VaultTransit wraps data keys with a Vault transit key. Rotating the key in Vault moves new data
+// keys to its latest version; earlier versions keep unwrapping until their minimum decryption
+// version is raised.
+type VaultTransit struct {
+	config VaultTransitConfig
+	client *http.Client
+
+	mu      sync.RWMutex
+	current int // Latest key version seen
+}
+
+// NewVaultTransit connects to Vault and reads the transit key's latest version
+func NewVaultTransit(ctx context.Context, config VaultTransitConfig) (*VaultTransit, error) {
+	if config.Address == "" || config.Token == "" || config.KeyName == "" {
+		return nil, fmt.Errorf("vault address, token and key name are required")
+	}
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	vault := &VaultTransit{config: config, client: client}
+	if err := vault.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+// Refresh reads the transit key's latest version, picking up rotations made in Vault
+func (v *VaultTransit) Refresh(ctx context.Context) error {
+	var key struct {
+		LatestVersion int `json:"latest_version"`
+	}
+	if err := v.call(ctx, http.MethodGet, "keys", nil, &key); err != nil {
+		return fmt.Errorf("failed to read transit key %s: %w", v.config.KeyName, err)
+	}
+
+	v.mu.Lock()
+	v.current = key.LatestVersion
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *VaultTransit) Name() string {
+	return "vault"
+}
+
+func (v *VaultTransit) CurrentKeyID() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return "v" + strconv.Itoa(v.current)
+}
+
+// GenerateDataKey asks Vault for a new data key wrapped by the latest transit key version
+func (v *VaultTransit) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	var response struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+		KeyVersion int    `json:"key_version"`
+	}
+	if err := v.call(ctx, http.MethodPost, "datakey/plaintext", map[string]any{"bits": 256}, &response); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+
+	// Vault rotations are picked up from the version that wrapped the key
+	version := response.KeyVersion
+	if version == 0 {
+		version = ciphertextVersion(response.Ciphertext)
+	}
+	v.mu.Lock()
+	if version > v.current {
+		v.current = version
+	}
+	v.mu.Unlock()
+
+	return &DataKey{
+		KeyID:     "v" + strconv.Itoa(version),
+		Plaintext: plaintext,
+		Wrapped:   []byte(response.Ciphertext),
+	}, nil
+}
+
+// DecryptDataKey asks Vault to unwrap a data key. The wrapped form names its key version, so
+// the key id is not needed.
+func (v *VaultTransit) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := v.call(ctx, http.MethodPost, "decrypt", map[string]any{"ciphertext": string(wrapped)}, &response); err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// call sends a request to a transit endpoint of the configured key and decodes its data field
+func (v *VaultTransit) call(ctx context.Context, method, endpoint string, body any, out any) error {
+	target := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimRight(v.config.Address, "/"),
+		v.config.Mount, endpoint, url.PathEscape(v.config.KeyName))
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.config.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err != nil && err != io.EOF {
+		return fmt.Errorf("vault returned status %d with an unreadable body: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.Join(payload.Errors, "; "))
+	}
+
+	return json.Unmarshal(payload.Data, out)
+}
+
+// ciphertextVersion reads the key version from a vault:vN: ciphertext
+func ciphertextVersion(ciphertext string) int {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) < 3 || parts[0] != "vault" {
+		return 0
+	}
+	version, _ := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	return version
+}
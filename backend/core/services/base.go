@@ -3,22 +3,42 @@ package services
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"syncer.net/core/logging"
+	"syncer.net/core/metrics"
+	"syncer.net/core/tracing"
 )
 
 // BaseService provides common functionality for all service implementations
 type BaseService struct {
-	name        string
-	displayName string
-	category    ServiceCategory
-	scopes      []string
-	rateLimiter *rate.Limiter
-	httpClient  *http.Client
-	logger      *log.Logger
+	name         string
+	displayName  string
+	category     ServiceCategory
+	scopes       []string
+	capabilities Capabilities
+	scopeNeeds   map[Capability][]string
+	rateLimiter  *RateLimiter
+	clientMu     sync.RWMutex // Guards httpClient and breaker, which are replaced on live services
+	httpClient   *http.Client
+	breaker      *CircuitBreaker
+	logger       *slog.Logger
+	authBaseURL  string
+	apiBaseURL   string
+
+	retry         RetryConfig
+	cache         *ResponseCache
+	errorDecoder  ErrorDecoder
+	hooksMu       sync.RWMutex
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
 }
 
 // BaseServiceConfig contains configuration for creating a base service
@@ -27,17 +47,35 @@ type BaseServiceConfig struct {
 	DisplayName       string
 	Category          ServiceCategory
 	Scopes            []string
+	Capabilities      Capabilities
+	CapabilityScopes  map[Capability][]string // Scopes each capability needs; all are in Scopes
 	RequestsPerSecond int
 	BurstSize         int
 	HTTPTimeout       time.Duration
-	Logger            *log.Logger
+	Logger            *slog.Logger
+	AuthBaseURL       string // Root of the provider's OAuth endpoints
+	APIBaseURL        string // Root of the provider's REST API
+
+	// Windowed quotas, enforced across replicas when the registry shares a Postgres store.
+	// Zero means unlimited.
+	RequestsPerMinute     int
+	RequestsPerHour       int
+	UserRequestsPerMinute int
+	UserRequestsPerHour   int
+	ThrottleScope         RateLimitScope // Scope blocked by 429 responses, app-wide by default
+	MaxRateLimitWait      time.Duration  // Longest a request waits for quota, one minute by default
+
+	Retry             RetryConfig  // Retries of idempotent calls made through Do
+	ResponseCacheSize int          // Catalog responses kept for conditional requests, 500 by default
+	ErrorDecoder      ErrorDecoder // Defaults to DefaultErrorDecoder
 }
 
 // NewBaseService creates a new base service with the given configuration
 func NewBaseService(config BaseServiceConfig) *BaseService {
 	if config.Logger == nil {
-		config.Logger = log.New(log.Writer(), fmt.Sprintf("[%s] ", config.Name), log.LstdFlags)
+		config.Logger = slog.Default()
 	}
+	config.Logger = config.Logger.With(logging.KeyProvider, config.Name)
 
 	if config.RequestsPerSecond == 0 {
 		config.RequestsPerSecond = 5
@@ -51,16 +89,52 @@ func NewBaseService(config BaseServiceConfig) *BaseService {
 		config.HTTPTimeout = 30 * time.Second
 	}
 
+	if config.Retry.MaxRetries == 0 {
+		config.Retry.MaxRetries = 3
+	}
+
+	if config.Retry.BaseDelay == 0 {
+		config.Retry.BaseDelay = 500 * time.Millisecond
+	}
+
+	if config.Retry.MaxDelay == 0 {
+		config.Retry.MaxDelay = 10 * time.Second
+	}
+
+	if config.ResponseCacheSize == 0 {
+		config.ResponseCacheSize = 500
+	}
+
+	if config.ErrorDecoder == nil {
+		config.ErrorDecoder = DefaultErrorDecoder
+	}
+
 	return &BaseService{
-		name:        config.Name,
-		displayName: config.DisplayName,
-		category:    config.Category,
-		scopes:      config.Scopes,
-		rateLimiter: rate.NewLimiter(rate.Every(time.Second/time.Duration(config.RequestsPerSecond)), config.BurstSize),
+		name:         config.Name,
+		displayName:  config.DisplayName,
+		category:     config.Category,
+		scopes:       config.Scopes,
+		capabilities: config.Capabilities,
+		scopeNeeds:   config.CapabilityScopes,
+		rateLimiter: NewRateLimiter(config.Name, RateLimitConfig{
+			RequestsPerSecond:     config.RequestsPerSecond,
+			BurstSize:             config.BurstSize,
+			RequestsPerMinute:     config.RequestsPerMinute,
+			RequestsPerHour:       config.RequestsPerHour,
+			UserRequestsPerMinute: config.UserRequestsPerMinute,
+			UserRequestsPerHour:   config.UserRequestsPerHour,
+			ThrottleScope:         config.ThrottleScope,
+			MaxWait:               config.MaxRateLimitWait,
+		}, config.Logger),
 		httpClient: &http.Client{
 			Timeout: config.HTTPTimeout,
 		},
-		logger: config.Logger,
+		logger:       config.Logger,
+		authBaseURL:  strings.TrimSuffix(config.AuthBaseURL, "/"),
+		apiBaseURL:   strings.TrimSuffix(config.APIBaseURL, "/"),
+		retry:        config.Retry,
+		cache:        NewResponseCache(config.ResponseCacheSize),
+		errorDecoder: config.ErrorDecoder,
 	}
 }
 
@@ -80,22 +154,65 @@ func (b *BaseService) RequiredScopes() []string {
 	return b.scopes
 }
 
+// AuthURL returns the URL of an OAuth endpoint below the configured auth base URL
+func (b *BaseService) AuthURL(path string) string {
+	return b.authBaseURL + path
+}
+
+// APIURL returns the URL of an API endpoint below the configured API base URL
+func (b *BaseService) APIURL(path string) string {
+	return b.apiBaseURL + path
+}
+
+// Capabilities returns the operations the provider integration supports
+func (b *BaseService) Capabilities() Capabilities {
+	return b.capabilities
+}
+
+// CapabilityScopes returns the scopes each capability needs the user to have granted
+func (b *BaseService) CapabilityScopes() map[Capability][]string {
+	return b.scopeNeeds
+}
+
 func (b *BaseService) GetRateLimit() *RateLimit {
+	config := b.rateLimiter.Config()
+
+	resetWindow := time.Second
+	if config.RequestsPerHour > 0 || config.UserRequestsPerHour > 0 {
+		resetWindow = time.Hour
+	} else if config.RequestsPerMinute > 0 || config.UserRequestsPerMinute > 0 {
+		resetWindow = time.Minute
+	}
+
 	return &RateLimit{
-		RequestsPerSecond: int(b.rateLimiter.Limit()),
-		BurstSize:         b.rateLimiter.Burst(),
-		ResetWindow:       time.Second,
+		RequestsPerSecond:     config.RequestsPerSecond,
+		RequestsPerMinute:     config.RequestsPerMinute,
+		RequestsPerHour:       config.RequestsPerHour,
+		UserRequestsPerMinute: config.UserRequestsPerMinute,
+		UserRequestsPerHour:   config.UserRequestsPerHour,
+		BurstSize:             config.BurstSize,
+		ThrottleScope:         config.ThrottleScope,
+		ResetWindow:           resetWindow,
 	}
 }
 
-func (b *BaseService) HealthCheck(ctx context.Context) error {
-	//TODO: Implement health check
-	return nil
+// WaitForRateLimit waits for the app-level rate limit. Requests sent through DoRequest are
+// limited automatically and must not call it again.
+func (b *BaseService) WaitForRateLimit(ctx context.Context) error {
+	return b.waitForRateLimit(ctx, "")
 }
 
-// WaitForRateLimit waits for rate limiting if necessary
-func (b *BaseService) WaitForRateLimit(ctx context.Context) error {
-	return b.rateLimiter.Wait(ctx)
+// waitForRateLimit waits for the app limit and, when userKey is set, the user token's limit
+func (b *BaseService) waitForRateLimit(ctx context.Context, userKey string) error {
+	start := time.Now()
+	err := b.rateLimiter.Wait(ctx, userKey)
+	metrics.ProviderRateLimitWait.WithLabelValues(b.name).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// SetRateLimitStore shares this service's quotas and blocks through the given store
+func (b *BaseService) SetRateLimitStore(store RateLimitStore) {
+	b.rateLimiter.SetStore(store)
 }
 
 // CreateAuthenticatedRequest creates an HTTP request with OAuth authorization
@@ -116,22 +233,105 @@ func (b *BaseService) CreateAuthenticatedRequest(ctx context.Context, method, ur
 	return req, nil
 }
 
-// DoRequest performs an HTTP request with rate limiting
+// DoRequest performs an HTTP request with rate limiting. Requests carrying a user token also
+// count against that user's quota, and the provider's rate limit headers adapt the limiter.
 func (b *BaseService) DoRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
-	if err := b.WaitForRateLimit(ctx); err != nil {
+	userKey := tokenScopeKey(req)
+	if err := b.waitForRateLimit(ctx, userKey); err != nil {
 		return nil, fmt.Errorf("rate limit wait failed: %w", err)
 	}
 
-	resp, err := b.httpClient.Do(req)
+	b.clientMu.RLock()
+	client, breaker := b.httpClient, b.breaker
+	b.clientMu.RUnlock()
+
+	if breaker != nil {
+		if err := breaker.Allow(); err != nil {
+			return nil, fmt.Errorf("%s unavailable: %w", b.name, err)
+		}
+	}
+
+	// Only the path is recorded since provider URLs may carry tokens in the query string
+	ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", b.name, req.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("provider", b.name),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	if err := b.runRequestHooks(req); err != nil {
+		// The provider was never called, so a trial call it was granted is handed back
+		if breaker != nil {
+			breaker.Release()
+		}
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("request hook failed: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
+		metrics.ObserveProviderRequest(b.name, req.Method, 0, time.Since(start))
+		tracing.RecordError(span, err)
+		recordOutcome(breaker, false)
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
+	metrics.ObserveProviderRequest(b.name, req.Method, resp.StatusCode, time.Since(start))
+	recordOutcome(breaker, resp.StatusCode < http.StatusInternalServerError)
+	b.rateLimiter.Observe(ctx, resp, userKey)
+	b.runResponseHooks(req, resp)
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
 
-	b.logger.Printf("HTTP %s %s -> %d", req.Method, req.URL.String(), resp.StatusCode)
+	b.logger.DebugContext(ctx, "Provider HTTP request",
+		"method", req.Method,
+		"url", req.URL,
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+	)
 
 	return resp, nil
 }
 
+// SetCircuitBreaker attaches the breaker that guards this service's HTTP calls
+func (b *BaseService) SetCircuitBreaker(breaker *CircuitBreaker) {
+	b.clientMu.Lock()
+	defer b.clientMu.Unlock()
+
+	b.breaker = breaker
+}
+
+// SetTransport replaces the transport used for provider HTTP calls, e.g. to replay recorded
+// responses in tests
+func (b *BaseService) SetTransport(transport http.RoundTripper) {
+	b.clientMu.Lock()
+	defer b.clientMu.Unlock()
+
+	// Requests in flight keep the client they started with
+	b.httpClient = &http.Client{Timeout: b.httpClient.Timeout, Transport: transport}
+}
+
+// recordOutcome feeds a request outcome to the circuit breaker. Client errors such as 401 or 404
+// count as successes since they say nothing about the provider's availability.
+func recordOutcome(breaker *CircuitBreaker, ok bool) {
+	if breaker == nil {
+		return
+	}
+	if ok {
+		breaker.RecordSuccess()
+	} else {
+		breaker.RecordFailure()
+	}
+}
+
 // ValidateTokens provides a basic token validation
 func (b *BaseService) ValidateTokens(tokens *OAuthTokens) (bool, error) {
 	if tokens == nil {
@@ -181,37 +381,22 @@ func (b *BaseService) CreateSyncError(errorType, message, itemID, context string
 	}
 }
 
+// Logger returns the service's structured logger, tagged with the provider name
+func (b *BaseService) Logger() *slog.Logger {
+	return b.logger
+}
+
 // LogInfo logs an info message
 func (b *BaseService) LogInfo(message string, args ...any) {
-	b.logger.Printf("[INFO] "+message, args...)
+	b.logger.Info(fmt.Sprintf(message, args...))
 }
 
 // LogWarn logs a warning message
 func (b *BaseService) LogWarn(message string, args ...any) {
-	b.logger.Printf("[WARN] "+message, args...)
+	b.logger.Warn(fmt.Sprintf(message, args...))
 }
 
 // LogError logs an error message
 func (b *BaseService) LogError(message string, args ...any) {
-	b.logger.Printf("[ERROR] "+message, args...)
-}
-
-func (b *BaseService) GetAuthURL(state string, redirectURL string) (string, error) {
-	return "", fmt.Errorf("GetAuthURL not implemented for service %s", b.name)
-}
-
-func (b *BaseService) ExchangeCode(code string, redirectURL string) (*OAuthTokens, error) {
-	return nil, fmt.Errorf("ExchangeCode not implemented for service %s", b.name)
-}
-
-func (b *BaseService) RefreshTokens(refreshToken string) (*OAuthTokens, error) {
-	return nil, fmt.Errorf("RefreshTokens not implemented for service %s", b.name)
-}
-
-func (b *BaseService) GetUserData(ctx context.Context, tokens *OAuthTokens, lastSync time.Time) (*UserDataResult, error) {
-	return nil, fmt.Errorf("GetUserData not implemented for service %s", b.name)
-}
-
-func (b *BaseService) GetUserProfile(ctx context.Context, tokens *OAuthTokens) (*UserProfile, error) {
-	return nil, fmt.Errorf("GetUserProfile not implemented for service %s", b.name)
+	b.logger.Error(fmt.Sprintf(message, args...))
 }
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"syncer.net/core/logging"
+)
+
+// ResponseCache keeps recent catalog responses so repeated lookups can be served locally while
+// fresh and revalidated with ETag or Last-Modified conditional requests once stale. The least
+// recently used entry is evicted when the cache is full.
+type ResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type cachedResponse struct {
+	key          string
+	body         []byte
+	etag         string
+	lastModified string
+	expiresAt    time.Time
+}
+
+// NewResponseCache creates a cache holding at most capacity responses
+func NewResponseCache(capacity int) *ResponseCache {
+	return &ResponseCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// fresh reports whether the response may be used without revalidation
+func (c *cachedResponse) fresh() bool {
+	return time.Now().Before(c.expiresAt)
+}
+
+// applyConditions turns the request into a conditional one for the cached representation
+func (c *cachedResponse) applyConditions(req *http.Request) {
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	if c.lastModified != "" {
+		req.Header.Set("If-Modified-Since", c.lastModified)
+	}
+}
+
+// get returns a copy of the cached response for a URL, or nil
+func (rc *ResponseCache) get(u *url.URL) *cachedResponse {
+	if rc == nil {
+		return nil
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	element, exists := rc.entries[cacheKey(u)]
+	if !exists {
+		return nil
+	}
+	rc.order.MoveToFront(element)
+	entry := *element.Value.(*cachedResponse)
+	return &entry
+}
+
+// put stores a successful response when the provider allows caching it
+func (rc *ResponseCache) put(u *url.URL, resp *http.Response, body []byte) {
+	if rc == nil || resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	maxAge, cacheable := cacheLifetime(resp.Header)
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if !cacheable || (maxAge == 0 && etag == "" && lastModified == "") {
+		return
+	}
+
+	entry := &cachedResponse{
+		key:          cacheKey(u),
+		body:         body,
+		etag:         etag,
+		lastModified: lastModified,
+		expiresAt:    time.Now().Add(maxAge),
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if element, exists := rc.entries[entry.key]; exists {
+		element.Value = entry
+		rc.order.MoveToFront(element)
+		return
+	}
+
+	rc.entries[entry.key] = rc.order.PushFront(entry)
+	for rc.order.Len() > rc.capacity {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// revalidate extends a cached response after a 304 Not Modified
+func (rc *ResponseCache) revalidate(u *url.URL, resp *http.Response) {
+	if rc == nil {
+		return
+	}
+	maxAge, _ := cacheLifetime(resp.Header)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if element, exists := rc.entries[cacheKey(u)]; exists {
+		entry := element.Value.(*cachedResponse)
+		entry.expiresAt = time.Now().Add(maxAge)
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			entry.etag = etag
+		}
+	}
+}
+
+// cacheLifetime reads max-age from Cache-Control and whether storing is allowed at all
+func cacheLifetime(header http.Header) (time.Duration, bool) {
+	var maxAge time.Duration
+	noCache := false
+	for directive := range strings.SplitSeq(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		switch {
+		case directive == "no-store":
+			return 0, false
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	return maxAge, true
+}
+
+// cacheKey identifies a cached URL with credential query parameters removed, so catalog
+// responses are shared between users
+func cacheKey(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	for key := range query {
+		if logging.IsSensitive(key) {
+			query.Del(key)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
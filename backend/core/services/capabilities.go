@@ -0,0 +1,35 @@
+package services
+
+import "slices"
+
+// Capability is an operation a provider integration supports
+type Capability string
+
+const (
+	CapabilityReadFavorites  Capability = "read_favorites"  // List the user's liked or saved items
+	CapabilityWriteFavorites Capability = "write_favorites" // Add items to the user's likes
+	CapabilityReadPlaylists  Capability = "read_playlists"  // List playlists and their items
+	CapabilityWritePlaylists Capability = "write_playlists" // Create playlists and add items to them
+	CapabilityDelete         Capability = "delete"          // Remove items from the user's library
+	CapabilitySearchISRC     Capability = "search_isrc"     // Look up tracks by ISRC
+	CapabilityReadHistory    Capability = "read_history"    // List recently played items
+)
+
+// Capabilities is the set of operations a provider declares
+type Capabilities []Capability
+
+// Has reports whether the capability is declared
+func (c Capabilities) Has(capability Capability) bool {
+	return slices.Contains(c, capability)
+}
+
+// Missing returns the required capabilities that are not declared
+func (c Capabilities) Missing(required Capabilities) Capabilities {
+	var missing Capabilities
+	for _, capability := range required {
+		if !c.Has(capability) {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"syncer.net/core/logging"
+)
+
+// ErrNotRecorded is returned by a replaying transport for requests missing from its cassette
+var ErrNotRecorded = errors.New("request not recorded")
+
+// RecordingMode selects whether provider traffic is recorded to or replayed from a cassette
+type RecordingMode string
+
+const (
+	RecordingModeRecord RecordingMode = "record"
+	RecordingModeReplay RecordingMode = "replay"
+)
+
+// RecordingConfig routes a provider's HTTP traffic through a cassette instead of only the live API
+type RecordingConfig struct {
+	Mode     RecordingMode `yaml:"mode" json:"mode"`
+	Cassette string        `yaml:"cassette" json:"cassette"` // JSON file holding the interactions
+}
+
+// Validate checks the mode and cassette path
+func (c RecordingConfig) Validate() error {
+	if c.Mode != RecordingModeRecord && c.Mode != RecordingModeReplay {
+		return fmt.Errorf("recording mode must be %q or %q, got %q", RecordingModeRecord, RecordingModeReplay, c.Mode)
+	}
+	if c.Cassette == "" {
+		return errors.New("recording needs a cassette path")
+	}
+	return nil
+}
+
+// Transport builds the recording or replaying transport for the config
+func (c RecordingConfig) Transport() (http.RoundTripper, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if c.Mode == RecordingModeRecord {
+		return NewRecordingTransport(c.Cassette, http.DefaultTransport)
+	}
+
+	cassette, err := LoadCassette(c.Cassette)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayTransport(cassette), nil
+}
+
+// Interaction is one recorded provider response. Bodies that are not JSON are stored as JSON strings.
+type Interaction struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// Cassette is a set of recorded interactions stored as a JSON file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette, replacing the file atomically
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace cassette: %w", err)
+	}
+	return nil
+}
+
+// cassetteURL is the form a request URL is recorded and matched in. Credential query parameters
+// are masked so recordings never hold tokens and replays match whatever token is sent.
+func cassetteURL(u *url.URL) string {
+	return logging.RedactURL(u)
+}
+
+// recordingFlushDelay is how long a recorder collects interactions before writing its cassette
+const recordingFlushDelay = time.Second
+
+// RecordingTransport forwards requests to the live API and records every response to a cassette.
+// Interactions are appended to the cassette already at the path and written in batches.
+type RecordingTransport struct {
+	next     http.RoundTripper
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+	dirty    bool
+	flush    *time.Timer
+	err      error // Last failed write, reported by the next call
+}
+
+// NewRecordingTransport records through next into the cassette at path, keeping the
+// interactions it already holds
+func NewRecordingTransport(path string, next http.RoundTripper) (*RecordingTransport, error) {
+	t := &RecordingTransport{next: next, path: path}
+
+	existing, err := LoadCassette(path)
+	switch {
+	case err == nil:
+		t.cassette = *existing
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	return t, nil
+}
+
+// RoundTrip performs the request and records its response, with credentials removed from the body
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	contentType := resp.Header.Get("Content-Type")
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         cassetteURL(req.URL),
+		Status:      resp.StatusCode,
+		ContentType: contentType,
+		Body:        redactBody(body, contentType),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	t.dirty = true
+	if t.flush == nil {
+		t.flush = time.AfterFunc(recordingFlushDelay, func() { t.Flush() })
+	}
+	return resp, nil
+}
+
+// Flush writes the interactions recorded since the last write
+func (t *RecordingTransport) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.flush != nil {
+		t.flush.Stop()
+		t.flush = nil
+	}
+	if !t.dirty || t.err != nil {
+		return t.err
+	}
+
+	if err := t.cassette.Save(t.path); err != nil {
+		t.err = err
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+// Close writes any interactions not yet saved
+func (t *RecordingTransport) Close() error {
+	return t.Flush()
+}
+
+// redactBody masks credentials in a JSON or form-encoded body and returns it as raw JSON
+func redactBody(body []byte, contentType string) json.RawMessage {
+	var document any
+	if err := json.Unmarshal(body, &document); err == nil {
+		redacted, _ := json.Marshal(redactJSON(document))
+		return redacted
+	}
+
+	// Token endpoints such as Deezer's answer with a form-encoded body
+	text := string(body)
+	if values, err := url.ParseQuery(strings.TrimSpace(text)); err == nil && !strings.Contains(contentType, "html") {
+		redacted := false
+		for key := range values {
+			if logging.IsSensitive(key) {
+				values.Set(key, "[REDACTED]")
+				redacted = true
+			}
+		}
+		if redacted {
+			text = values.Encode()
+		}
+	}
+
+	encoded, _ := json.Marshal(text)
+	return encoded
+}
+
+// redactJSON masks the values of credential keys anywhere in a decoded JSON document
+func redactJSON(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, nested := range typed {
+			if logging.IsSensitive(key) {
+				typed[key] = "[REDACTED]"
+				continue
+			}
+			typed[key] = redactJSON(nested)
+		}
+	case []any:
+		for i, nested := range typed {
+			typed[i] = redactJSON(nested)
+		}
+	}
+	return value
+}
+
+// ReplayTransport answers requests from a cassette without touching the network. Interactions
+// recorded for the same request are replayed in order, repeating the last one.
+type ReplayTransport struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	played   map[string]int
+}
+
+// NewReplayTransport replays the interactions of a cassette
+func NewReplayTransport(cassette *Cassette) *ReplayTransport {
+	return &ReplayTransport{cassette: cassette, played: make(map[string]int)}
+}
+
+// RoundTrip returns the recorded response for the request, matched on method and URL. Recordings
+// without a query string match the request whatever its query.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requested := cassetteURL(req.URL)
+	withoutQuery := *req.URL
+	withoutQuery.RawQuery = ""
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := req.Method + " " + requested
+	matches := t.matching(req.Method, requested)
+	if len(matches) == 0 {
+		key = req.Method + " " + withoutQuery.String()
+		matches = t.matching(req.Method, withoutQuery.String())
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, requested)
+	}
+
+	index := min(t.played[key], len(matches)-1)
+	t.played[key]++
+	recorded := matches[index]
+
+	body := []byte(recorded.Body)
+	var text string
+	if err := json.Unmarshal(recorded.Body, &text); err == nil {
+		body = []byte(text)
+	}
+
+	contentType := recorded.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	return &http.Response{
+		StatusCode:    recorded.Status,
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// matching returns the interactions recorded for a method and URL. Callers must hold t.mu.
+func (t *ReplayTransport) matching(method, recordedURL string) []Interaction {
+	var matches []Interaction
+	for _, interaction := range t.cassette.Interactions {
+		if interaction.Method == method && interaction.URL == recordedURL {
+			matches = append(matches, interaction)
+		}
+	}
+	return matches
+}
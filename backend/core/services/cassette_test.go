@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"syncer.net/core/services"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string, error) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", url, err)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "live-secret", "expires_in": 3600}`)
+		case "/tracks":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"offset": %q}`, r.URL.Query().Get("offset"))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "access_token=live-secret&expires=3600")
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	// A second recording session appends to the cassette the first one wrote
+	for _, urls := range [][]string{
+		{server.URL + "/token", server.URL + "/tracks?offset=0&access_token=live-secret"},
+		{server.URL + "/tracks?offset=50&access_token=live-secret", server.URL + "/oauth"},
+	} {
+		transport, err := services.NewRecordingTransport(path, http.DefaultTransport)
+		if err != nil {
+			t.Fatalf("NewRecordingTransport: %v", err)
+		}
+		recorder := &http.Client{Transport: transport}
+		for _, url := range urls {
+			if _, _, err := get(t, recorder, url); err != nil {
+				t.Fatalf("recording %s: %v", url, err)
+			}
+		}
+		if err := transport.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	cassette, err := services.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(cassette.Interactions) != 4 {
+		t.Fatalf("cassette holds %d interactions, want 4", len(cassette.Interactions))
+	}
+	for _, interaction := range cassette.Interactions {
+		if strings.Contains(interaction.URL, "live-secret") || strings.Contains(string(interaction.Body), "live-secret") {
+			t.Errorf("recorded a credential: %s %s", interaction.URL, interaction.Body)
+		}
+	}
+
+	replayer := &http.Client{Transport: services.NewReplayTransport(cassette)}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{server.URL + "/tracks?offset=50&access_token=other-token", `{"offset":"50"}`},
+		{server.URL + "/tracks?access_token=other-token&offset=0", `{"offset":"0"}`},
+		{server.URL + "/oauth?code=abc", "access_token=%5BREDACTED%5D&expires=3600"},
+	}
+	for _, tt := range tests {
+		status, body, err := get(t, replayer, tt.url)
+		body = strings.Join(strings.Fields(body), "") // Saved cassettes are indented
+		if err != nil || status != http.StatusOK || body != tt.want {
+			t.Errorf("replaying %s = %d %q, %v, want %q", tt.url, status, body, err, tt.want)
+		}
+	}
+
+	if _, _, err := get(t, replayer, server.URL+"/unknown"); !errors.Is(err, services.ErrNotRecorded) {
+		t.Errorf("replaying an unrecorded request = %v, want ErrNotRecorded", err)
+	}
+}
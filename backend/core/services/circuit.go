@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for provider calls rejected while the provider's circuit is open
+var ErrCircuitOpen = errors.New("provider circuit breaker is open")
+
+// CircuitState is the state of a provider circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Requests flow normally
+	CircuitOpen     CircuitState = "open"      // Requests are rejected until the cool-down ends
+	CircuitHalfOpen CircuitState = "half_open" // A single trial request decides whether to close again
+)
+
+// CircuitBreakerConfig controls when a breaker trips and how long it stays open
+type CircuitBreakerConfig struct {
+	Window       time.Duration // Period over which failures are counted
+	MinRequests  int           // Requests required in the window before the breaker can trip
+	FailureRatio float64       // Share of failed requests in the window that trips the breaker
+	OpenDuration time.Duration // Cool-down before a trial request is allowed
+}
+
+// DefaultCircuitBreakerConfig returns the breaker configuration used by the health monitor
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Window:       time.Minute,
+		MinRequests:  10,
+		FailureRatio: 0.5,
+		OpenDuration: 2 * time.Minute,
+	}
+}
+
+// CircuitBreaker tracks provider failures and rejects calls while a provider is failing
+type CircuitBreaker struct {
+	config      CircuitBreakerConfig
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	successes   int
+	failures    int
+	openedAt    time.Time
+	trialActive bool
+	onChange    func(CircuitState)
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		config:      config,
+		state:       CircuitClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// Allow reports whether a call may proceed. After the cool-down, one trial call is let through.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		retryAt := cb.openedAt.Add(cb.config.OpenDuration)
+		if time.Now().Before(retryAt) {
+			return fmt.Errorf("%w until %s", ErrCircuitOpen, retryAt.Format(time.RFC3339))
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.trialActive = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.trialActive {
+			return fmt.Errorf("%w: trial request in progress", ErrCircuitOpen)
+		}
+		cb.trialActive = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// RecordSuccess records a successful call, closing a half-open circuit
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen {
+		cb.reset()
+		cb.setState(CircuitClosed)
+		return
+	}
+
+	cb.rollWindow()
+	cb.successes++
+}
+
+// RecordFailure records a failed call, tripping the breaker on an error spike
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen {
+		cb.trip()
+		return
+	}
+
+	cb.rollWindow()
+	cb.failures++
+
+	total := cb.successes + cb.failures
+	if cb.state == CircuitClosed && total >= cb.config.MinRequests &&
+		float64(cb.failures)/float64(total) >= cb.config.FailureRatio {
+		cb.trip()
+	}
+}
+
+// Release gives back a trial call that ended before reaching the provider, so the next call can
+// make the trial instead
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitHalfOpen {
+		cb.trialActive = false
+	}
+}
+
+// Trip opens the circuit immediately, e.g. when a health probe fails repeatedly
+func (cb *CircuitBreaker) Trip() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != CircuitOpen {
+		cb.trip()
+	}
+}
+
+// State returns the current state and, when open, the time a trial call will be allowed
+func (cb *CircuitBreaker) State() (CircuitState, time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen {
+		return cb.state, cb.openedAt.Add(cb.config.OpenDuration)
+	}
+	return cb.state, time.Time{}
+}
+
+// OnStateChange registers a callback invoked whenever the breaker changes state
+func (cb *CircuitBreaker) OnStateChange(callback func(CircuitState)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.onChange = callback
+}
+
+func (cb *CircuitBreaker) trip() {
+	cb.reset()
+	cb.openedAt = time.Now()
+	cb.setState(CircuitOpen)
+}
+
+func (cb *CircuitBreaker) reset() {
+	cb.successes = 0
+	cb.failures = 0
+	cb.trialActive = false
+	cb.windowStart = time.Now()
+}
+
+// rollWindow starts a new counting window once the current one has elapsed
+func (cb *CircuitBreaker) rollWindow() {
+	if time.Since(cb.windowStart) >= cb.config.Window {
+		cb.successes = 0
+		cb.failures = 0
+		cb.windowStart = time.Now()
+	}
+}
+
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+	cb.state = state
+	if cb.onChange != nil {
+		cb.onChange(state)
+	}
+}
@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+)
+
+func TestFailedHookReleasesTrialCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := services.NewBaseService(services.BaseServiceConfig{Name: "fake", RequestsPerSecond: 100})
+	breaker := services.NewCircuitBreaker(services.CircuitBreakerConfig{
+		Window: time.Minute, MinRequests: 1, FailureRatio: 0.5, OpenDuration: time.Millisecond,
+	})
+	service.SetCircuitBreaker(breaker)
+
+	failHook := true
+	service.OnRequest(func(*http.Request) error {
+		if failHook {
+			return errors.New("signing failed")
+		}
+		return nil
+	})
+
+	breaker.Trip()
+	time.Sleep(5 * time.Millisecond)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := service.DoRequest(context.Background(), req); err == nil || errors.Is(err, services.ErrCircuitOpen) {
+		t.Fatalf("DoRequest with a failing hook = %v, want the hook error", err)
+	}
+
+	// The trial call was never made, so the next call gets it and closes the circuit
+	failHook = false
+	resp, err := service.DoRequest(context.Background(), req.Clone(context.Background()))
+	if err != nil {
+		t.Fatalf("DoRequest after the failed hook = %v", err)
+	}
+	resp.Body.Close()
+	if state, _ := breaker.State(); state != services.CircuitClosed {
+		t.Errorf("breaker state = %s, want closed", state)
+	}
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxResponseBody bounds how much of a provider response is read into memory
+const maxResponseBody = 10 << 20
+
+// ProviderError is a provider API call that returned an error response
+type ProviderError struct {
+	Provider   string
+	Method     string
+	Path       string // Request path only; queries may carry credentials
+	StatusCode int
+	Code       string // Provider-specific error code, when the body has one
+	Message    string
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	message := e.Message
+	if e.Code != "" {
+		message = fmt.Sprintf("%s (code %s)", message, e.Code)
+	}
+	return fmt.Sprintf("%s API error on %s %s (status %d): %s", e.Provider, e.Method, e.Path, e.StatusCode, message)
+}
+
+// IsRetryable reports whether a failed call may succeed if repeated. Network failures and
+// retryable provider errors are; open circuits, exhausted quotas and cancellations are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable
+	}
+
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// ErrorDecoder turns a provider response into a ProviderError, or returns nil when the response
+// is not an error. Providers that report errors inside 200 responses supply their own.
+type ErrorDecoder func(resp *http.Response, body []byte) *ProviderError
+
+// RequestHook runs before every provider request is sent. Returning an error aborts the request.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook runs after every provider response is received
+type ResponseHook func(req *http.Request, resp *http.Response)
+
+// RetryConfig controls retries of idempotent provider calls
+type RetryConfig struct {
+	MaxRetries int           // Attempts after the first one
+	BaseDelay  time.Duration // Delay before the first retry, doubled for each further retry
+	MaxDelay   time.Duration // Upper bound for a single delay, including Retry-After
+	Disabled   bool          // Turns retries off entirely
+}
+
+// APIRequest describes a JSON API call made through BaseService.Do
+type APIRequest struct {
+	Method  string
+	URL     string
+	Query   url.Values   // Added to the URL's query
+	Body    any          // JSON-encoded request body
+	Form    url.Values   // Form-encoded request body, used instead of Body
+	Headers http.Header  // Extra request headers
+	Tokens  *OAuthTokens // Sent as the Authorization header when set
+
+	Idempotent bool // Allows retrying methods other than GET, HEAD, PUT and DELETE
+	NoRetry    bool // Disables retries, e.g. for single-use authorization codes
+	Cache      bool // Serves GETs from the ETag cache; only for responses that do not depend on the user
+}
+
+// retryable reports whether the call is safe to repeat
+func (r APIRequest) retryable() bool {
+	if r.NoRetry {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return r.Idempotent
+}
+
+// fullURL returns the request URL with the extra query parameters applied
+func (r APIRequest) fullURL() (string, error) {
+	if len(r.Query) == 0 {
+		return r.URL, nil
+	}
+
+	parsed, err := url.Parse(r.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid request URL: %w", err)
+	}
+	query := parsed.Query()
+	for key, values := range r.Query {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// encodeBody returns the request body and its content type
+func (r APIRequest) encodeBody() ([]byte, string, error) {
+	switch {
+	case r.Form != nil:
+		return []byte(r.Form.Encode()), "application/x-www-form-urlencoded", nil
+	case r.Body != nil:
+		body, err := json.Marshal(r.Body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode request body: %w", err)
+		}
+		return body, "application/json", nil
+	}
+	return nil, "", nil
+}
+
+// Do performs a provider API call and decodes the JSON response into out. A nil out discards
+// the body, and a *[]byte out receives it undecoded. Idempotent calls are retried with
+// exponential backoff on network errors and retryable provider errors.
+func (b *BaseService) Do(ctx context.Context, call APIRequest, out any) error {
+	if call.Method == "" {
+		call.Method = http.MethodGet
+	}
+
+	requestURL, err := call.fullURL()
+	if err != nil {
+		return err
+	}
+	body, contentType, err := call.encodeBody()
+	if err != nil {
+		return err
+	}
+
+	retries := 0
+	if call.retryable() && !b.retry.Disabled {
+		retries = b.retry.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := b.attempt(ctx, call, requestURL, body, contentType, out)
+		if err == nil || attempt >= retries || !IsRetryable(err) {
+			return err
+		}
+
+		delay, ok := b.retryDelay(attempt, err)
+		if !ok {
+			return err
+		}
+
+		b.logger.DebugContext(ctx, "Retrying provider request",
+			"method", call.Method, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt performs a single try of a call
+func (b *BaseService) attempt(ctx context.Context, call APIRequest, requestURL string, body []byte, contentType string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, call.Method, requestURL, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("Syncer/1.0 (%s)", b.name))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if call.Tokens != nil {
+		tokenType := call.Tokens.TokenType
+		if tokenType == "" {
+			tokenType = "Bearer"
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s", tokenType, call.Tokens.AccessToken))
+	}
+	for key, values := range call.Headers {
+		req.Header[key] = values
+	}
+
+	useCache := call.Cache && call.Method == http.MethodGet
+	var cached *cachedResponse
+	if useCache {
+		if cached = b.cache.get(req.URL); cached != nil {
+			if cached.fresh() {
+				return decodeResponse(cached.body, out)
+			}
+			cached.applyConditions(req)
+		}
+	}
+
+	resp, err := b.DoRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", b.name, err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		b.cache.revalidate(req.URL, resp)
+		return decodeResponse(cached.body, out)
+	}
+
+	if providerErr := b.errorDecoder(resp, respBody); providerErr != nil {
+		providerErr.Provider = b.name
+		providerErr.Method = req.Method
+		providerErr.Path = req.URL.Path
+		return providerErr
+	}
+
+	if useCache {
+		b.cache.put(req.URL, resp, respBody)
+	}
+
+	return decodeResponse(respBody, out)
+}
+
+// retryDelay returns the backoff before the next attempt. Provider Retry-After hints are honored
+// up to the maximum delay; longer hints end the retries.
+func (b *BaseService) retryDelay(attempt int, err error) (time.Duration, bool) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter, providerErr.RetryAfter <= b.retry.MaxDelay
+	}
+
+	delay := b.retry.BaseDelay << attempt
+	if delay <= 0 || delay > b.retry.MaxDelay {
+		delay = b.retry.MaxDelay
+	}
+	// Full jitter keeps replicas retrying after the same outage from arriving together
+	return time.Duration(rand.Int64N(int64(delay)) + 1), true
+}
+
+// decodeResponse decodes a JSON body into out
+func decodeResponse(body []byte, out any) error {
+	switch target := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*target = body
+		return nil
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// DefaultErrorDecoder treats non-2xx responses as errors, reading the message from the common
+// JSON error shapes: {"error": {"message", "status"|"code"}} and OAuth's error/error_description.
+func DefaultErrorDecoder(resp *http.Response, body []byte) *ProviderError {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	providerErr := &ProviderError{
+		StatusCode: resp.StatusCode,
+		Retryable:  resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+	}
+	if retryAt, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		providerErr.RetryAfter = time.Until(retryAt)
+	}
+
+	providerErr.Code, providerErr.Message = parseErrorBody(body)
+	if providerErr.Message == "" {
+		providerErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return providerErr
+}
+
+// parseErrorBody extracts an error code and message from a JSON error body
+func parseErrorBody(body []byte) (string, string) {
+	var payload struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+		Message          string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", strings.TrimSpace(truncate(string(body), 500))
+	}
+
+	var nested struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+		Status  json.RawMessage `json:"status"`
+	}
+	if err := json.Unmarshal(payload.Error, &nested); err == nil && nested.Message != "" {
+		code := strings.Trim(string(nested.Code), `"`)
+		if code == "" {
+			code = nested.Type
+		}
+		return code, nested.Message
+	}
+
+	var code string
+	if err := json.Unmarshal(payload.Error, &code); err == nil {
+		if payload.ErrorDescription != "" {
+			return code, payload.ErrorDescription
+		}
+		return code, code
+	}
+
+	return "", payload.Message
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// OnRequest registers a hook that runs before every provider request, e.g. to stub calls in tests
+func (b *BaseService) OnRequest(hook RequestHook) {
+	b.hooksMu.Lock()
+	defer b.hooksMu.Unlock()
+
+	b.requestHooks = append(b.requestHooks, hook)
+}
+
+// OnResponse registers a hook that runs after every provider response
+func (b *BaseService) OnResponse(hook ResponseHook) {
+	b.hooksMu.Lock()
+	defer b.hooksMu.Unlock()
+
+	b.responseHooks = append(b.responseHooks, hook)
+}
+
+// runRequestHooks runs the registered request hooks in order
+func (b *BaseService) runRequestHooks(req *http.Request) error {
+	b.hooksMu.RLock()
+	hooks := b.requestHooks
+	b.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runResponseHooks runs the registered response hooks in order
+func (b *BaseService) runResponseHooks(req *http.Request, resp *http.Response) {
+	b.hooksMu.RLock()
+	hooks := b.responseHooks
+	b.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(req, resp)
+	}
+}
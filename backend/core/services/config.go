@@ -0,0 +1,219 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProvidersConfig is the declarative list of providers loaded from the providers config file
+type ProvidersConfig struct {
+	Providers []ProviderConfig `yaml:"providers" json:"providers"`
+}
+
+// ProviderConfig configures one provider. Zero values keep the provider's built-in defaults.
+type ProviderConfig struct {
+	Name        string `yaml:"name" json:"name"`
+	Enabled     *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"` // Enabled unless set to false
+	DisplayName string `yaml:"display_name,omitempty" json:"display_name,omitempty"`
+
+	// Credentials maps credential names such as client_id to secret references,
+	// either env:VARIABLE or file:/path/to/secret. Secrets never appear in the file itself.
+	Credentials map[string]string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	RateLimits  ProviderRateLimits `yaml:"rate_limits,omitempty" json:"rate_limits,omitempty"`
+	Timeout     time.Duration      `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	AuthBaseURL string             `yaml:"auth_base_url,omitempty" json:"auth_base_url,omitempty"`
+	APIBaseURL  string             `yaml:"api_base_url,omitempty" json:"api_base_url,omitempty"`
+
+	// Recording records the provider's traffic to a cassette or replays it from one, for
+	// integration tests and offline demos
+	Recording *RecordingConfig `yaml:"recording,omitempty" json:"recording,omitempty"`
+}
+
+// ProviderRateLimits overrides the request quotas of a provider
+type ProviderRateLimits struct {
+	RequestsPerSecond     int `yaml:"requests_per_second,omitempty" json:"requests_per_second,omitempty"`
+	BurstSize             int `yaml:"burst_size,omitempty" json:"burst_size,omitempty"`
+	RequestsPerMinute     int `yaml:"requests_per_minute,omitempty" json:"requests_per_minute,omitempty"`
+	RequestsPerHour       int `yaml:"requests_per_hour,omitempty" json:"requests_per_hour,omitempty"`
+	UserRequestsPerMinute int `yaml:"user_requests_per_minute,omitempty" json:"user_requests_per_minute,omitempty"`
+	UserRequestsPerHour   int `yaml:"user_requests_per_hour,omitempty" json:"user_requests_per_hour,omitempty"`
+}
+
+// LoadProvidersConfig reads a providers config file. JSON files are accepted since JSON is valid YAML.
+func LoadProvidersConfig(path string) (*ProvidersConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers config: %w", err)
+	}
+
+	var config ProvidersConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse providers config %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid providers config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Validate checks that every provider is named once and its settings are usable
+func (c *ProvidersConfig) Validate() error {
+	seen := make(map[string]bool)
+	for i, provider := range c.Providers {
+		if provider.Name == "" {
+			return fmt.Errorf("provider %d has no name", i)
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("provider %s is listed more than once", provider.Name)
+		}
+		seen[provider.Name] = true
+
+		if provider.Timeout < 0 {
+			return fmt.Errorf("provider %s has a negative timeout", provider.Name)
+		}
+		if provider.Recording != nil {
+			if err := provider.Recording.Validate(); err != nil {
+				return fmt.Errorf("provider %s: %w", provider.Name, err)
+			}
+		}
+		for key, ref := range provider.Credentials {
+			if !strings.HasPrefix(ref, "env:") && !strings.HasPrefix(ref, "file:") {
+				return fmt.Errorf("provider %s credential %s must reference env: or file:", provider.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Provider returns the entry for a provider, if the config lists it
+func (c *ProvidersConfig) Provider(name string) (ProviderConfig, bool) {
+	for _, provider := range c.Providers {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return ProviderConfig{}, false
+}
+
+// IsEnabled reports whether the provider should be registered
+func (p ProviderConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// Merge returns the config with every non-zero setting of override applied on top
+func (p ProviderConfig) Merge(override ProviderConfig) ProviderConfig {
+	merged := p
+	if override.Enabled != nil {
+		merged.Enabled = override.Enabled
+	}
+	if override.DisplayName != "" {
+		merged.DisplayName = override.DisplayName
+	}
+	if len(override.Credentials) > 0 {
+		merged.Credentials = make(map[string]string, len(p.Credentials)+len(override.Credentials))
+		for key, ref := range p.Credentials {
+			merged.Credentials[key] = ref
+		}
+		for key, ref := range override.Credentials {
+			merged.Credentials[key] = ref
+		}
+	}
+	if override.Timeout != 0 {
+		merged.Timeout = override.Timeout
+	}
+	if override.AuthBaseURL != "" {
+		merged.AuthBaseURL = override.AuthBaseURL
+	}
+	if override.APIBaseURL != "" {
+		merged.APIBaseURL = override.APIBaseURL
+	}
+	if override.Recording != nil {
+		merged.Recording = override.Recording
+	}
+
+	limits := override.RateLimits
+	if limits.RequestsPerSecond != 0 {
+		merged.RateLimits.RequestsPerSecond = limits.RequestsPerSecond
+	}
+	if limits.BurstSize != 0 {
+		merged.RateLimits.BurstSize = limits.BurstSize
+	}
+	if limits.RequestsPerMinute != 0 {
+		merged.RateLimits.RequestsPerMinute = limits.RequestsPerMinute
+	}
+	if limits.RequestsPerHour != 0 {
+		merged.RateLimits.RequestsPerHour = limits.RequestsPerHour
+	}
+	if limits.UserRequestsPerMinute != 0 {
+		merged.RateLimits.UserRequestsPerMinute = limits.UserRequestsPerMinute
+	}
+	if limits.UserRequestsPerHour != 0 {
+		merged.RateLimits.UserRequestsPerHour = limits.UserRequestsPerHour
+	}
+
+	return merged
+}
+
+// Credential resolves a credential reference. A credential that is not configured resolves to "".
+func (p ProviderConfig) Credential(key string) (string, error) {
+	ref, exists := p.Credentials[key]
+	if !exists {
+		return "", nil
+	}
+
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		return os.Getenv(strings.TrimPrefix(ref, "env:")), nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s credential %s: %w", p.Name, key, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("%s credential %s must reference env: or file:", p.Name, key)
+	}
+}
+
+// Apply copies the configured settings into a base service configuration
+func (p ProviderConfig) Apply(base *BaseServiceConfig) {
+	if p.DisplayName != "" {
+		base.DisplayName = p.DisplayName
+	}
+	if p.Timeout != 0 {
+		base.HTTPTimeout = p.Timeout
+	}
+	if p.AuthBaseURL != "" {
+		base.AuthBaseURL = p.AuthBaseURL
+	}
+	if p.APIBaseURL != "" {
+		base.APIBaseURL = p.APIBaseURL
+	}
+
+	limits := p.RateLimits
+	if limits.RequestsPerSecond != 0 {
+		base.RequestsPerSecond = limits.RequestsPerSecond
+	}
+	if limits.BurstSize != 0 {
+		base.BurstSize = limits.BurstSize
+	}
+	if limits.RequestsPerMinute != 0 {
+		base.RequestsPerMinute = limits.RequestsPerMinute
+	}
+	if limits.RequestsPerHour != 0 {
+		base.RequestsPerHour = limits.RequestsPerHour
+	}
+	if limits.UserRequestsPerMinute != 0 {
+		base.UserRequestsPerMinute = limits.UserRequestsPerMinute
+	}
+	if limits.UserRequestsPerHour != 0 {
+		base.UserRequestsPerHour = limits.UserRequestsPerHour
+	}
+}
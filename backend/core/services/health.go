@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"syncer.net/core/logging"
+	"syncer.net/core/metrics"
+)
+
+// HealthStatus summarizes the most recent health probes of a provider
+type HealthStatus string
+
+const (
+	HealthUnknown   HealthStatus = "unknown"
+	HealthHealthy   HealthStatus = "healthy"
+	HealthDegraded  HealthStatus = "degraded"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// ProviderHealth is the cached health of a provider as reported by the status endpoint
+type ProviderHealth struct {
+	Name                string       `json:"name"`
+	Status              HealthStatus `json:"status"`
+	Circuit             CircuitState `json:"circuit"`
+	CircuitRetryAt      *time.Time   `json:"circuit_retry_at,omitempty"`
+	LatencyMs           int64        `json:"latency_ms"`
+	LastCheckedAt       *time.Time   `json:"last_checked_at,omitempty"`
+	LastSuccessAt       *time.Time   `json:"last_success_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time   `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+}
+
+// Available reports whether jobs may use the provider right now
+func (h ProviderHealth) Available() bool {
+	return h.Circuit != CircuitOpen
+}
+
+// HealthMonitorConfig controls probing and circuit breaking
+type HealthMonitorConfig struct {
+	Interval        time.Duration // Time between probes of every provider
+	Timeout         time.Duration // Maximum time a single probe may take
+	FailuresToTrip  int           // Consecutive failed probes that open the circuit
+	DegradedLatency time.Duration // Probes slower than this mark the provider degraded
+	BreakerConfig   CircuitBreakerConfig
+}
+
+// DefaultHealthMonitorConfig returns the configuration used unless overridden
+func DefaultHealthMonitorConfig() HealthMonitorConfig {
+	return HealthMonitorConfig{
+		Interval:        time.Minute,
+		Timeout:         10 * time.Second,
+		FailuresToTrip:  3,
+		DegradedLatency: 2 * time.Second,
+		BreakerConfig:   DefaultCircuitBreakerConfig(),
+	}
+}
+
+// circuitBreakerAware is implemented by services embedding BaseService
+type circuitBreakerAware interface {
+	SetCircuitBreaker(breaker *CircuitBreaker)
+}
+
+// HealthMonitor probes providers in the background and owns their circuit breakers
+type HealthMonitor struct {
+	registry *ServiceRegistry
+	config   HealthMonitorConfig
+	mu       sync.RWMutex
+	health   map[string]*ProviderHealth
+	breakers map[string]*CircuitBreaker
+	logger   *slog.Logger
+}
+
+// NewHealthMonitor creates a health monitor and attaches it to the registry
+func NewHealthMonitor(registry *ServiceRegistry, config HealthMonitorConfig, logger *slog.Logger) *HealthMonitor {
+	monitor := &HealthMonitor{
+		registry: registry,
+		config:   config,
+		health:   make(map[string]*ProviderHealth),
+		breakers: make(map[string]*CircuitBreaker),
+		logger:   logging.Component(logger, "health_monitor"),
+	}
+
+	registry.setHealthMonitor(monitor)
+	return monitor
+}
+
+// Start probes every provider immediately and then on each interval until ctx is done
+func (m *HealthMonitor) Start(ctx context.Context) {
+	m.logger.Info("Starting provider health monitor", "interval", m.config.Interval)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.ProbeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			m.logger.Info("Health monitor stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll runs a health check against every registered provider concurrently
+func (m *HealthMonitor) ProbeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, service := range m.registry.all() {
+		wg.Add(1)
+		go func(service ServiceProvider) {
+			defer wg.Done()
+			m.probe(ctx, service)
+		}(service)
+	}
+	wg.Wait()
+}
+
+// probe runs one health check with a timeout and records the outcome
+func (m *HealthMonitor) probe(ctx context.Context, service ServiceProvider) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		result <- service.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("health check timed out after %v", m.config.Timeout)
+	}
+
+	m.record(service.Name(), time.Since(start), err)
+}
+
+// record updates the cached health and breaker for a provider after a probe
+func (m *HealthMonitor) record(name string, latency time.Duration, err error) {
+	breaker := m.Breaker(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := m.healthFor(name)
+	now := time.Now()
+	health.LastCheckedAt = &now
+	health.LatencyMs = latency.Milliseconds()
+
+	// A probe rejected by the open circuit says nothing new about the provider
+	if errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+
+	if err != nil {
+		health.ConsecutiveFailures++
+		health.LastError = err.Error()
+		health.LastErrorAt = &now
+		health.Status = HealthUnhealthy
+
+		if health.ConsecutiveFailures >= m.config.FailuresToTrip {
+			breaker.Trip()
+		}
+
+		m.logger.Warn("Provider health check failed",
+			logging.KeyProvider, name, "consecutive_failures", health.ConsecutiveFailures, "error", err)
+		return
+	}
+
+	health.ConsecutiveFailures = 0
+	health.LastSuccessAt = &now
+	health.Status = HealthHealthy
+	if m.config.DegradedLatency > 0 && latency > m.config.DegradedLatency {
+		health.Status = HealthDegraded
+	}
+}
+
+// Breaker returns the circuit breaker for a provider, creating it on first use
+func (m *HealthMonitor) Breaker(name string) *CircuitBreaker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if breaker, exists := m.breakers[name]; exists {
+		return breaker
+	}
+
+	breaker := NewCircuitBreaker(m.config.BreakerConfig)
+	breaker.OnStateChange(func(state CircuitState) {
+		open := 0.0
+		if state == CircuitOpen {
+			open = 1
+		}
+		metrics.ProviderCircuitOpen.WithLabelValues(name).Set(open)
+		m.logger.Warn("Provider circuit changed state", logging.KeyProvider, name, "circuit", state)
+	})
+	metrics.ProviderCircuitOpen.WithLabelValues(name).Set(0)
+
+	m.breakers[name] = breaker
+	return breaker
+}
+
+// attach wires a provider's HTTP calls to its circuit breaker
+func (m *HealthMonitor) attach(service ServiceProvider) {
+	if aware, ok := service.(circuitBreakerAware); ok {
+		aware.SetCircuitBreaker(m.Breaker(service.Name()))
+	}
+}
+
+// Health returns the cached health of a provider
+func (m *HealthMonitor) Health(name string) ProviderHealth {
+	breaker := m.Breaker(name)
+	state, retryAt := breaker.State()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health := ProviderHealth{Name: name, Status: HealthUnknown}
+	if cached, exists := m.health[name]; exists {
+		health = *cached
+	}
+
+	health.Circuit = state
+	if !retryAt.IsZero() {
+		health.CircuitRetryAt = &retryAt
+	}
+	return health
+}
+
+// Statuses returns the cached health of every registered provider, sorted by name
+func (m *HealthMonitor) Statuses() []ProviderHealth {
+	services := m.registry.all()
+	statuses := make([]ProviderHealth, 0, len(services))
+	for _, service := range services {
+		statuses = append(statuses, m.Health(service.Name()))
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// healthFor returns the mutable cached entry for a provider. Callers must hold m.mu.
+func (m *HealthMonitor) healthFor(name string) *ProviderHealth {
+	health, exists := m.health[name]
+	if !exists {
+		health = &ProviderHealth{Name: name, Status: HealthUnknown}
+		m.health[name] = health
+	}
+	return health
+}
@@ -12,10 +12,13 @@ type ServiceProvider interface {
 	DisplayName() string
 	Category() ServiceCategory
 	RequiredScopes() []string
+	Capabilities() Capabilities
+	CapabilityScopes() map[Capability][]string
 
-	// OAuth Flow
-	GetAuthURL(state string, redirectURL string) (string, error)
-	ExchangeCode(code string, redirectURL string) (*OAuthTokens, error)
+	// OAuth Flow. The code challenge and verifier are an S256 PKCE pair, which providers
+	// without PKCE support ignore. The redirect URL passed to both calls is the same.
+	GetAuthURL(state string, redirectURL string, codeChallenge string) (string, error)
+	ExchangeCode(code string, redirectURL string, codeVerifier string) (*OAuthTokens, error)
 	RefreshTokens(refreshToken string) (*OAuthTokens, error)
 	ValidateTokens(tokens *OAuthTokens) (bool, error)
 
@@ -24,10 +27,28 @@ type ServiceProvider interface {
 	GetUserProfile(ctx context.Context, tokens *OAuthTokens) (*UserProfile, error)
 
 	// Health and Status
-	HealthCheck() error
+	HealthCheck(ctx context.Context) error
 	GetRateLimit() *RateLimit
 }
 
+// TokenRevoker is implemented by providers that can revoke tokens on their side, so a
+// disconnected account no longer grants access even if the stored tokens leaked
+type TokenRevoker interface {
+	RevokeTokens(ctx context.Context, tokens *OAuthTokens) error
+}
+
+// ScopeInspector is implemented by providers whose token responses do not say which scopes the
+// user granted, but which can report them for a token
+type ScopeInspector interface {
+	GrantedScopes(ctx context.Context, tokens *OAuthTokens) ([]string, error)
+}
+
+// IncrementalAuthorizer is implemented by providers that add a new grant to the scopes the user
+// already gave the app, so re-consent can ask for only the missing scopes
+type IncrementalAuthorizer interface {
+	GetAuthURLForScopes(state string, redirectURL string, codeChallenge string, scopes []string) (string, error)
+}
+
 // ServiceCategory defines the type of service
 type ServiceCategory string
 
@@ -90,20 +111,25 @@ type UserProfile struct {
 
 // RateLimit represents rate limiting information for a service
 type RateLimit struct {
-	RequestsPerSecond int           `json:"requests_per_second"`
-	RequestsPerMinute int           `json:"requests_per_minute"`
-	RequestsPerHour   int           `json:"requests_per_hour"`
-	BurstSize         int           `json:"burst_size"`
-	ResetWindow       time.Duration `json:"reset_window"`
+	RequestsPerSecond     int            `json:"requests_per_second"`
+	RequestsPerMinute     int            `json:"requests_per_minute"`
+	RequestsPerHour       int            `json:"requests_per_hour"`
+	UserRequestsPerMinute int            `json:"user_requests_per_minute"`
+	UserRequestsPerHour   int            `json:"user_requests_per_hour"`
+	BurstSize             int            `json:"burst_size"`
+	ThrottleScope         RateLimitScope `json:"throttle_scope"`
+	ResetWindow           time.Duration  `json:"reset_window"`
 }
 
 // ServiceInfo represents metadata about a service
 type ServiceInfo struct {
-	Name        string          `json:"name"`
-	DisplayName string          `json:"display_name"`
-	Category    ServiceCategory `json:"category"`
-	Scopes      []string        `json:"scopes"`
-	Available   bool            `json:"available"`
+	Name         string          `json:"name"`
+	DisplayName  string          `json:"display_name"`
+	Category     ServiceCategory `json:"category"`
+	Scopes       []string        `json:"scopes"`
+	Capabilities Capabilities    `json:"capabilities"`
+	Available    bool            `json:"available"`
+	Health       *ProviderHealth `json:"health,omitempty"`
 }
 
 // AuthInitiation represents the result of initiating an OAuth flow
@@ -114,9 +140,13 @@ type AuthInitiation struct {
 
 // CallbackResult represents the result of handling an OAuth callback
 type CallbackResult struct {
-	UserID      string       `json:"user_id"`
-	ServiceName string       `json:"service_name"`
-	Profile     *UserProfile `json:"profile,omitempty"`
+	UserID       string       `json:"user_id"`
+	ServiceName  string       `json:"service_name"`
+	ConnectionID string       `json:"connection_id"`
+	Profile      *UserProfile `json:"profile,omitempty"`
+
+	// MissingScopes lists the scopes the user declined that a capability needs
+	MissingScopes []string `json:"missing_scopes,omitempty"`
 }
 
 // SyncJobRequest represents a request to sync a user's service
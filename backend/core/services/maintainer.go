@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"syncer.net/core/email"
+	"syncer.net/core/logging"
+)
+
+// ConnectionState is the health of a connection's tokens
+type ConnectionState string
+
+const (
+	ConnectionActive        ConnectionState = "active"         // Tokens work and are refreshed ahead of expiry
+	ConnectionExpiring      ConnectionState = "expiring"       // Tokens expire soon and cannot be refreshed
+	ConnectionRefreshFailed ConnectionState = "refresh_failed" // The last refresh failed but may succeed later
+	ConnectionRevoked       ConnectionState = "revoked"        // The provider rejected the grant
+	ConnectionNeedsReauth   ConnectionState = "needs_reauth"   // The tokens expired or kept failing to refresh
+)
+
+// RequiresReauth reports whether the user must connect the account again
+func (s ConnectionState) RequiresReauth() bool {
+	return s == ConnectionRevoked || s == ConnectionNeedsReauth
+}
+
+// IsRevokedGrant reports whether a token refresh failed because the provider no longer accepts
+// the grant, as opposed to a failure that may go away
+func IsRevokedGrant(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && providerErr.Code == "invalid_grant"
+}
+
+// ReauthNotifier tells a user that a connection needs them to sign in to the provider again.
+// It is implemented by email.EmailService.
+type ReauthNotifier interface {
+	SendReconnectEmail(ctx context.Context, data email.ReconnectEmailData) error
+}
+
+// ConnectionMaintainerConfig controls how far ahead tokens are refreshed
+type ConnectionMaintainerConfig struct {
+	Interval           time.Duration // Time between maintenance passes
+	RefreshAhead       time.Duration // Tokens expiring within this window are refreshed
+	MaxRefreshFailures int           // Consecutive failed refreshes before the user must reconnect
+	BatchSize          int           // Maximum connections maintained per pass
+}
+
+// DefaultConnectionMaintainerConfig returns the configuration used unless overridden
+func DefaultConnectionMaintainerConfig() ConnectionMaintainerConfig {
+	return ConnectionMaintainerConfig{
+		Interval:           5 * time.Minute,
+		RefreshAhead:       15 * time.Minute,
+		MaxRefreshFailures: 3,
+		BatchSize:          100,
+	}
+}
+
+// ConnectionMaintainer refreshes tokens before they expire, tracks the state of every connection
+// and emails users whose connections need them to sign in again
+type ConnectionMaintainer struct {
+	oauth    *OAuthManager
+	notifier ReauthNotifier
+	config   ConnectionMaintainerConfig
+	logger   *slog.Logger
+}
+
+// NewConnectionMaintainer creates a maintainer. The notifier may be nil, in which case users are
+// not emailed.
+func NewConnectionMaintainer(oauth *OAuthManager, notifier ReauthNotifier, config ConnectionMaintainerConfig, logger *slog.Logger) *ConnectionMaintainer {
+	return &ConnectionMaintainer{
+		oauth:    oauth,
+		notifier: notifier,
+		config:   config,
+		logger:   logging.Component(logger, "connection_maintainer"),
+	}
+}
+
+// Start runs a maintenance pass immediately and then on each interval until ctx is done
+func (m *ConnectionMaintainer) Start(ctx context.Context) {
+	m.logger.Info("Starting connection maintainer",
+		"interval", m.config.Interval, "refresh_ahead", m.config.RefreshAhead)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			m.logger.Info("Connection maintainer stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maintainedConnection is a connection whose tokens are due for maintenance
+type maintainedConnection struct {
+	ID              string          `db:"id"`
+	UserID          string          `db:"user_id"`
+	ServiceName     string          `db:"service_name"`
+	State           ConnectionState `db:"connection_state"`
+	TokenExpiresAt  time.Time       `db:"token_expires_at"`
+	HasRefreshToken bool            `db:"has_refresh_token"`
+	RefreshFailures int             `db:"refresh_failures"`
+}
+
+// transition is the state a maintenance pass moves a connection to
+type transition struct {
+	State    ConnectionState
+	Failures int
+	Error    string
+}
+
+// RunOnce maintains every connection expiring within the refresh window, then notifies users
+// of connections that newly require them to reconnect. Refreshable connections of unavailable
+// providers are left out of the batch so they cannot crowd out the rest.
+func (m *ConnectionMaintainer) RunOnce(ctx context.Context) {
+	var due []maintainedConnection
+	err := m.oauth.db.SelectContext(ctx, &due, `
+		SELECT us.id, us.user_id, s.name AS service_name, us.connection_state,
+			ct.expires_at AS token_expires_at,
+			COALESCE(length(ct.refresh_token), 0) > 0 AS has_refresh_token,
+			us.refresh_failures
+		FROM user_services us
+		JOIN services s ON us.service_id = s.id
+		JOIN connection_tokens ct ON ct.user_service_id = us.id
+		WHERE us.connection_state NOT IN ('revoked', 'needs_reauth')
+			AND ct.expires_at < $1
+			AND (COALESCE(length(ct.refresh_token), 0) = 0 OR s.name = ANY($3))
+		ORDER BY ct.expires_at
+		LIMIT $2
+	`, time.Now().Add(m.config.RefreshAhead), m.config.BatchSize, pq.Array(m.oauth.Registry.availableNames()))
+	if err != nil {
+		m.logger.Error("Failed to load connections due for maintenance", "error", err)
+		return
+	}
+
+	for _, connection := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		m.maintain(ctx, connection)
+	}
+
+	m.notifyPending(ctx)
+}
+
+// maintain refreshes one connection's tokens when possible and records its new state
+func (m *ConnectionMaintainer) maintain(ctx context.Context, connection maintainedConnection) {
+	logger := m.logger.With(logging.KeyUserID, connection.UserID, logging.KeyProvider, connection.ServiceName,
+		"connection_id", connection.ID)
+
+	// A provider that is down would fail every refresh; try again once it is back
+	if connection.HasRefreshToken && !m.oauth.Registry.IsServiceAvailable(connection.ServiceName) {
+		logger.Debug("Skipping refresh while provider is unavailable")
+		return
+	}
+
+	var refreshErr error
+	if connection.HasRefreshToken {
+		refreshErr = m.oauth.RefreshUserTokens(connection.ID)
+	}
+
+	next := nextTransition(connection, refreshErr, time.Now(), m.config.MaxRefreshFailures)
+	if err := m.recordTransition(ctx, connection.ID, next); err != nil {
+		logger.Error("Failed to record connection state", "state", next.State, "error", err)
+		return
+	}
+
+	switch {
+	case next.State.RequiresReauth():
+		logger.Warn("Connection requires re-authentication", "state", next.State, "error", next.Error)
+	case next.State == ConnectionRefreshFailed:
+		logger.Warn("Token refresh failed", "failures", next.Failures, "error", next.Error)
+	case next.State != connection.State:
+		logger.Info("Connection state changed", "from", connection.State, "to", next.State)
+	}
+}
+
+// nextTransition decides the state of a connection after a maintenance pass. Connections without
+// a refresh token are never refreshed, so refreshErr is only meaningful for the others. A refresh
+// refused because the provider is down says nothing about the grant and is not counted.
+func nextTransition(connection maintainedConnection, refreshErr error, now time.Time, maxFailures int) transition {
+	if !connection.HasRefreshToken {
+		if now.Before(connection.TokenExpiresAt) {
+			return transition{State: ConnectionExpiring, Failures: connection.RefreshFailures}
+		}
+		return transition{State: ConnectionNeedsReauth, Error: "access token expired and the provider issued no refresh token"}
+	}
+
+	if refreshErr == nil {
+		return transition{State: ConnectionActive}
+	}
+	if errors.Is(refreshErr, ErrServiceUnavailable) || errors.Is(refreshErr, ErrCircuitOpen) {
+		return transition{State: connection.State, Failures: connection.RefreshFailures}
+	}
+	if IsRevokedGrant(refreshErr) {
+		return transition{State: ConnectionRevoked, Error: refreshErr.Error()}
+	}
+
+	failures := connection.RefreshFailures + 1
+	if failures >= maxFailures {
+		return transition{State: ConnectionNeedsReauth, Failures: failures, Error: refreshErr.Error()}
+	}
+	return transition{State: ConnectionRefreshFailed, Failures: failures, Error: refreshErr.Error()}
+}
+
+// recordTransition stores a connection's state, keeping the time of the last actual change
+func (m *ConnectionMaintainer) recordTransition(ctx context.Context, connectionID string, next transition) error {
+	_, err := m.oauth.db.ExecContext(ctx, `
+		UPDATE user_services SET
+			state_changed_at = CASE WHEN connection_state = $2 THEN state_changed_at ELSE NOW() END,
+			connection_state = $2,
+			refresh_failures = $3,
+			last_error = COALESCE(NULLIF($4, ''), last_error),
+			last_error_at = CASE WHEN $4 = '' THEN last_error_at ELSE NOW() END,
+			updated_at = NOW()
+		WHERE id = $1
+	`, connectionID, next.State, next.Failures, next.Error)
+	return err
+}
+
+// reauthNotice is a connection whose user has not yet been told to reconnect it
+type reauthNotice struct {
+	ID          string          `db:"id"`
+	UserID      string          `db:"user_id"`
+	ServiceName string          `db:"service_name"`
+	DisplayName string          `db:"display_name"`
+	Label       string          `db:"label"`
+	State       ConnectionState `db:"connection_state"`
+	Email       string          `db:"email"`
+	FullName    string          `db:"full_name"`
+}
+
+// notifyPending emails the user of every connection requiring re-authentication once per
+// episode. A failed email is retried on the next pass.
+func (m *ConnectionMaintainer) notifyPending(ctx context.Context) {
+	if m.notifier == nil {
+		return
+	}
+
+	var notices []reauthNotice
+	err := m.oauth.db.SelectContext(ctx, &notices, `
+		SELECT us.id, us.user_id, s.name AS service_name, s.display_name,
+			COALESCE(us.label, '') AS label, us.connection_state,
+			u.primary_email AS email, u.full_name
+		FROM user_services us
+		JOIN services s ON us.service_id = s.id
+		JOIN users u ON us.user_id = u.id
+		WHERE us.connection_state IN ('revoked', 'needs_reauth')
+			AND us.reauth_notified_at IS NULL
+		LIMIT $1
+	`, m.config.BatchSize)
+	if err != nil {
+		m.logger.Error("Failed to load connections to notify", "error", err)
+		return
+	}
+
+	for _, notice := range notices {
+		err := m.notifier.SendReconnectEmail(ctx, email.ReconnectEmailData{
+			Email:       notice.Email,
+			FullName:    notice.FullName,
+			ServiceName: notice.ServiceName,
+			DisplayName: notice.DisplayName,
+			Label:       notice.Label,
+			Reason:      reauthReason(notice.State),
+		})
+		if err != nil {
+			m.logger.Warn("Failed to send reconnect email",
+				logging.KeyUserID, notice.UserID, logging.KeyProvider, notice.ServiceName, "error", err)
+			continue
+		}
+
+		if _, err := m.oauth.db.ExecContext(ctx,
+			"UPDATE user_services SET reauth_notified_at = NOW() WHERE id = $1", notice.ID); err != nil {
+			m.logger.Error("Failed to record reconnect email", "connection_id", notice.ID, "error", err)
+			continue
+		}
+
+		m.logger.Info("Sent reconnect email",
+			logging.KeyUserID, notice.UserID, logging.KeyProvider, notice.ServiceName, "connection_id", notice.ID)
+	}
+}
+
+// reauthReason explains a state requiring re-authentication to the user
+func reauthReason(state ConnectionState) string {
+	switch state {
+	case ConnectionRevoked:
+		return "access was revoked"
+	default:
+		return "the sign-in expired"
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextTransition(t *testing.T) {
+	now := time.Now()
+	revoked := &ProviderError{Provider: "fake", StatusCode: 400, Code: "invalid_grant", Message: "Refresh token revoked"}
+	unavailable := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		connection maintainedConnection
+		refreshErr error
+		want       transition
+	}{
+		{
+			name:       "refreshed",
+			connection: maintainedConnection{HasRefreshToken: true, RefreshFailures: 2, State: ConnectionRefreshFailed},
+			want:       transition{State: ConnectionActive},
+		},
+		{
+			name:       "revoked",
+			connection: maintainedConnection{HasRefreshToken: true},
+			refreshErr: revoked,
+			want:       transition{State: ConnectionRevoked, Error: revoked.Error()},
+		},
+		{
+			name:       "transient failure",
+			connection: maintainedConnection{HasRefreshToken: true},
+			refreshErr: unavailable,
+			want:       transition{State: ConnectionRefreshFailed, Failures: 1, Error: unavailable.Error()},
+		},
+		{
+			name:       "too many failures",
+			connection: maintainedConnection{HasRefreshToken: true, RefreshFailures: 2},
+			refreshErr: unavailable,
+			want:       transition{State: ConnectionNeedsReauth, Failures: 3, Error: unavailable.Error()},
+		},
+		{
+			name:       "provider down",
+			connection: maintainedConnection{HasRefreshToken: true, RefreshFailures: 2, State: ConnectionRefreshFailed},
+			refreshErr: fmt.Errorf("fake: %w", ErrCircuitOpen),
+			want:       transition{State: ConnectionRefreshFailed, Failures: 2},
+		},
+		{
+			name:       "no refresh token yet valid",
+			connection: maintainedConnection{TokenExpiresAt: now.Add(time.Minute)},
+			want:       transition{State: ConnectionExpiring},
+		},
+		{
+			name:       "no refresh token expired",
+			connection: maintainedConnection{TokenExpiresAt: now.Add(-time.Minute)},
+			want:       transition{State: ConnectionNeedsReauth, Error: "access token expired and the provider issued no refresh token"},
+		},
+	}
+	for _, tt := range tests {
+		if got := nextTransition(tt.connection, tt.refreshErr, now, 3); got != tt.want {
+			t.Errorf("%s: nextTransition = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,12 +3,18 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"syncer.net/core/logging"
 	"syncer.net/core/security"
 	"syncer.net/utils"
 )
@@ -18,308 +24,634 @@ type OAuthManager struct {
 	Registry   *ServiceRegistry
 	db         *sqlx.DB
 	encryption *security.TokenEncryption
-	logger     *log.Logger
+	tokens     TokenStore
+	logger     *slog.Logger
+
+	hooksMu         sync.RWMutex
+	disconnectHooks []DisconnectHook
 }
 
-// NewOAuthManager creates a new OAuth manager
-func NewOAuthManager(registry *ServiceRegistry, db *sqlx.DB, encryptionKey [32]byte, logger *log.Logger) (*OAuthManager, error) {
-	if logger == nil {
-		logger = log.New(log.Writer(), "[OAuthManager] ", log.LstdFlags)
-	}
+// DisconnectHook is called after one of a user's connections has been removed
+type DisconnectHook func(ctx context.Context, userID string, connection Connection)
 
-	encryption, err := security.NewTokenEncryption(encryptionKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token encryption: %w", err)
+// NewOAuthManager creates an OAuth manager keeping tokens in Postgres, encrypted with encryption
+func NewOAuthManager(registry *ServiceRegistry, db *sqlx.DB, encryption *security.TokenEncryption, logger *slog.Logger) (*OAuthManager, error) {
+	logger = logging.Component(logger, "oauth_manager")
+
+	if encryption == nil {
+		return nil, fmt.Errorf("token encryption is required")
 	}
 
 	return &OAuthManager{
 		Registry:   registry,
 		db:         db,
 		encryption: encryption,
+		tokens:     NewPostgresTokenStore(db, encryption, logger),
 		logger:     logger,
 	}, nil
 }
 
+// SetTokenStore replaces where connection tokens are kept
+func (o *OAuthManager) SetTokenStore(store TokenStore) {
+	o.tokens = store
+}
+
 // PendingAuth represents a pending OAuth authorization
 type PendingAuth struct {
-	ID          string    `db:"id"`
-	UserID      string    `db:"user_id"`
-	ServiceName string    `db:"service_name"`
-	State       string    `db:"state"`
-	ExpiresAt   time.Time `db:"expires_at"`
-	CreatedAt   time.Time `db:"created_at"`
+	ID           string    `db:"id"`
+	UserID       string    `db:"user_id"`
+	ServiceName  string    `db:"service_name"`
+	State        string    `db:"state"`
+	RedirectURI  string    `db:"redirect_uri"`
+	CodeVerifier string    `db:"code_verifier"`
+	Label        string    `db:"label"`
+	ConnectionID string    `db:"connection_id"` // Set when re-consenting an existing connection
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
 }
 
-// InitiateAuth starts the OAuth flow for a service
-func (o *OAuthManager) InitiateAuth(serviceName, userID, redirectURL string) (*AuthInitiation, error) {
-	service, err := o.Registry.GetService(serviceName)
+// InitiateAuth starts the OAuth flow for a service. The redirect URL and a PKCE verifier are kept
+// with the pending authorization so the callback exchanges the code with exactly the same values.
+// The label names the resulting connection, telling apart several accounts of one provider.
+func (o *OAuthManager) InitiateAuth(serviceName, userID, redirectURL, label string) (*AuthInitiation, error) {
+	initiation, err := o.initiate(PendingAuth{
+		UserID:      userID,
+		ServiceName: serviceName,
+		RedirectURI: redirectURL,
+		Label:       label,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.Info("Initiated OAuth flow", logging.KeyUserID, userID, logging.KeyProvider, serviceName)
+	return initiation, nil
+}
+
+// ErrServiceUnavailable is returned when a flow starts or completes while the health monitor
+// reports the provider as down
+var ErrServiceUnavailable = errors.New("service is temporarily unavailable")
+
+// ErrScopesComplete is returned when re-consent is requested for a connection that has every
+// scope its provider's capabilities need
+var ErrScopesComplete = errors.New("connection has every scope it needs")
+
+// InitiateReconsent starts an OAuth flow granting the scopes a degraded connection is missing.
+// Providers that add new grants to earlier ones are asked for only the missing scopes; the others
+// are asked for the full set again, since their new token replaces the old grant.
+func (o *OAuthManager) InitiateReconsent(userID, connectionID, redirectURL string) (*AuthInitiation, error) {
+	connection, err := o.GetConnection(userID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(connection.MissingScopes) == 0 {
+		return nil, ErrScopesComplete
+	}
+
+	initiation, err := o.initiate(PendingAuth{
+		UserID:       userID,
+		ServiceName:  connection.ServiceName,
+		RedirectURI:  redirectURL,
+		Label:        connection.Label,
+		ConnectionID: connection.ID,
+	}, connection.MissingScopes)
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.Info("Initiated re-consent", logging.KeyUserID, userID, logging.KeyProvider, connection.ServiceName,
+		"connection_id", connection.ID, "missing_scopes", []string(connection.MissingScopes))
+	return initiation, nil
+}
+
+// initiate builds the authorization URL and stores the pending authorization with a new state
+// and PKCE verifier. Scopes, when given, narrow the request on providers that support it.
+func (o *OAuthManager) initiate(auth PendingAuth, scopes []string) (*AuthInitiation, error) {
+	if auth.RedirectURI == "" {
+		return nil, fmt.Errorf("redirect URL is required")
+	}
+
+	service, err := o.Registry.GetService(auth.ServiceName)
 	if err != nil {
 		return nil, fmt.Errorf("service not found: %w", err)
 	}
-	if err := service.HealthCheck(); err != nil {
-		return nil, fmt.Errorf("service is not healthy: %w", err)
+	if !o.Registry.IsServiceAvailable(auth.ServiceName) {
+		return nil, fmt.Errorf("%s: %w", auth.ServiceName, ErrServiceUnavailable)
 	}
 
-	state, err := utils.GenerateToken()
+	auth.State, err = utils.GenerateToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate state token: %w", err)
 	}
 
-	authURL, err := service.GetAuthURL(state, redirectURL)
+	pkce, err := NewPKCE()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get auth URL: %w", err)
+		return nil, err
 	}
+	auth.CodeVerifier = pkce.Verifier
 
-	err = o.storePendingAuth(userID, serviceName, state, time.Now().Add(10*time.Minute))
+	var authURL string
+	if incremental, ok := service.(IncrementalAuthorizer); ok && len(scopes) > 0 {
+		authURL, err = incremental.GetAuthURLForScopes(auth.State, auth.RedirectURI, pkce.Challenge, scopes)
+	} else {
+		authURL, err = service.GetAuthURL(auth.State, auth.RedirectURI, pkce.Challenge)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to store pending auth: %w", err)
+		return nil, fmt.Errorf("failed to get auth URL: %w", err)
 	}
 
-	o.logger.Printf("Initiated OAuth flow for user %s, service %s", userID, serviceName)
+	auth.ExpiresAt = time.Now().Add(10 * time.Minute)
+	if err := o.storePendingAuth(auth); err != nil {
+		return nil, fmt.Errorf("failed to store pending auth: %w", err)
+	}
 
 	return &AuthInitiation{
 		AuthURL: authURL,
-		State:   state,
+		State:   auth.State,
 	}, nil
 }
 
-// HandleCallback processes the OAuth callback
-func (o *OAuthManager) HandleCallback(serviceName, code, state string) (*CallbackResult, error) {
-	userID, err := o.validateStateToken(serviceName, state)
-	if err != nil {
-		return nil, fmt.Errorf("invalid state token: %w", err)
-	}
-
+// HandleCallback processes the OAuth callback. The pending authorization is consumed before the
+// exchange, so a state can complete at most one; an unavailable provider leaves it in place.
+func (o *OAuthManager) HandleCallback(ctx context.Context, serviceName, code, state string) (*CallbackResult, error) {
 	service, err := o.Registry.GetService(serviceName)
 	if err != nil {
 		return nil, fmt.Errorf("service not found: %w", err)
 	}
+	if !o.Registry.IsServiceAvailable(serviceName) {
+		return nil, fmt.Errorf("%s: %w", serviceName, ErrServiceUnavailable)
+	}
 
-	if err := service.HealthCheck(); err != nil {
-		return nil, fmt.Errorf("service is not healthy: %w", err)
+	auth, err := o.consumePendingAuth(serviceName, state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state token: %w", err)
 	}
 
-	tokens, err := service.ExchangeCode(code, "")
+	tokens, err := service.ExchangeCode(code, auth.RedirectURI, auth.CodeVerifier)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
 
-	profile, err := service.GetUserProfile(context.Background(), tokens)
+	// The profile identifies the account, so connecting it again updates the same connection
+	profile, err := service.GetUserProfile(ctx, tokens)
 	if err != nil {
-		o.logger.Printf("Warning: Failed to get user profile for %s: %v", serviceName, err)
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+
+	if auth.ConnectionID != "" {
+		connection, err := o.GetConnection(auth.UserID, auth.ConnectionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get re-consented connection: %w", err)
+		}
+		if connection.ServiceUserID != profile.ExternalID {
+			return nil, fmt.Errorf("re-consent was granted by a different %s account", serviceName)
+		}
 	}
 
-	err = o.storeUserTokens(userID, serviceName, tokens, profile)
+	o.inspectScopes(ctx, service, tokens)
+	report := CheckScopes(service, tokens.Scope)
+
+	connectionID, err := o.storeUserTokens(ctx, auth.UserID, serviceName, auth.Label, tokens, profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store tokens: %w", err)
 	}
 
-	o.cleanupPendingAuth(state)
+	if err := o.RecordScopes(connectionID, report); err != nil {
+		return nil, err
+	}
+	if report.Degraded() {
+		o.logger.Warn("Connection is missing scopes", logging.KeyUserID, auth.UserID, logging.KeyProvider, serviceName,
+			"connection_id", connectionID, "missing_scopes", report.MissingScopes())
+	}
 
-	o.logger.Printf("Successfully completed OAuth flow for user %s, service %s", userID, serviceName)
+	o.logger.Info("Completed OAuth flow",
+		logging.KeyUserID, auth.UserID, logging.KeyProvider, serviceName, "connection_id", connectionID)
 
 	return &CallbackResult{
-		UserID:      userID,
-		ServiceName: serviceName,
-		Profile:     profile,
+		UserID:        auth.UserID,
+		ServiceName:   serviceName,
+		ConnectionID:  connectionID,
+		Profile:       profile,
+		MissingScopes: report.MissingScopes(),
 	}, nil
 }
 
-// RefreshUserTokens refreshes expired tokens for a user service
+// inspectScopes fills in the granted scopes for providers whose token responses omit them. A
+// failed lookup leaves the grant unknown rather than failing the connection.
+func (o *OAuthManager) inspectScopes(ctx context.Context, service ServiceProvider, tokens *OAuthTokens) {
+	inspector, ok := service.(ScopeInspector)
+	if !ok || tokens.Scope != "" {
+		return
+	}
+
+	granted, err := inspector.GrantedScopes(ctx, tokens)
+	if err != nil {
+		o.logger.Warn("Failed to look up granted scopes", logging.KeyProvider, service.Name(), "error", err)
+		return
+	}
+	tokens.Scope = strings.Join(granted, " ")
+}
+
+// RecordScopes stores which scopes a connection is missing, marking it degraded while any are.
+// A report with an unknown grant leaves the recorded state unchanged.
+func (o *OAuthManager) RecordScopes(connectionID string, report ScopeReport) error {
+	if report.Unknown {
+		return nil
+	}
+
+	_, err := o.db.Exec(`
+		UPDATE user_services SET missing_scopes = $2, updated_at = NOW()
+		WHERE id = $1
+	`, connectionID, pq.Array(report.MissingScopes()))
+	if err != nil {
+		return fmt.Errorf("failed to record missing scopes: %w", err)
+	}
+	return nil
+}
+
+// RefreshUserTokens refreshes the tokens of a connection. When another refresh stored new tokens
+// meanwhile, those are kept and this refresh's tokens are dropped.
 func (o *OAuthManager) RefreshUserTokens(userServiceID string) error {
-	userService, err := o.getUserService(userServiceID)
+	ctx := context.Background()
+
+	serviceName, err := o.connectionService(userServiceID)
 	if err != nil {
 		return fmt.Errorf("failed to get user service: %w", err)
 	}
 
-	service, err := o.Registry.GetService(userService.ServiceName)
+	service, err := o.Registry.GetService(serviceName)
 	if err != nil {
 		return fmt.Errorf("service not found: %w", err)
 	}
 
-	if err := service.HealthCheck(); err != nil {
-		return fmt.Errorf("service is not healthy: %w", err)
+	if !o.Registry.IsServiceAvailable(serviceName) {
+		return fmt.Errorf("%s: %w", serviceName, ErrServiceUnavailable)
 	}
 
-	_, refreshToken, err := o.encryption.DecryptTokens(nil, userService.RefreshToken)
+	stored, err := o.tokens.Get(ctx, userServiceID, TokenReadRefresh)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt refresh token: %w", err)
+		return fmt.Errorf("failed to read refresh token: %w", err)
 	}
 
-	if refreshToken == "" {
+	if stored.RefreshToken == "" {
 		return fmt.Errorf("no refresh token available")
 	}
 
-	newTokens, err := service.RefreshTokens(refreshToken)
+	newTokens, err := service.RefreshTokens(stored.RefreshToken)
 	if err != nil {
 		return fmt.Errorf("failed to refresh tokens: %w", err)
 	}
+	if newTokens.RefreshToken == "" {
+		newTokens.RefreshToken = stored.RefreshToken
+	}
 
-	err = o.updateUserTokens(userServiceID, newTokens)
+	_, err = o.tokens.Update(ctx, userServiceID, stored.Version, newTokens)
+	if errors.Is(err, ErrTokensChanged) {
+		o.logger.Info("Tokens were replaced during refresh, keeping the newer ones", "user_service_id", userServiceID)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update tokens: %w", err)
 	}
 
-	o.logger.Printf("Successfully refreshed tokens for user service %s", userServiceID)
+	if _, err := o.db.Exec(`
+		UPDATE user_services SET last_refresh_at = NOW(), updated_at = NOW() WHERE id = $1
+	`, userServiceID); err != nil {
+		return fmt.Errorf("failed to record refresh: %w", err)
+	}
+
+	o.logger.Info("Refreshed tokens", "user_service_id", userServiceID)
 	return nil
 }
 
-// GetUserTokens retrieves and decrypts tokens for a user service
-func (o *OAuthManager) GetUserTokens(userServiceID string) (*OAuthTokens, error) {
-	userService, err := o.getUserService(userServiceID)
+// GetUserTokens retrieves and decrypts the tokens of a connection, auditing the read
+func (o *OAuthManager) GetUserTokens(ctx context.Context, userServiceID string, purpose TokenReadPurpose) (*OAuthTokens, error) {
+	stored, err := o.tokens.Get(ctx, userServiceID, purpose)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user service: %w", err)
+		return nil, fmt.Errorf("failed to read tokens: %w", err)
 	}
+	return &stored.OAuthTokens, nil
+}
 
-	accessToken, refreshToken, err := o.encryption.DecryptTokens(
-		userService.AccessToken,
-		userService.RefreshToken,
-	)
+// ErrNotConnected is returned when a user has no connection to a service
+var ErrNotConnected = errors.New("service not connected")
+
+// Connection is a user's connected provider account, without its tokens. A user may connect
+// several accounts of one provider; the label tells them apart.
+type Connection struct {
+	ID              string          `json:"id" db:"id"`
+	ServiceName     string          `json:"service_name" db:"service_name"`
+	Label           string          `json:"label,omitempty" db:"label"`
+	ServiceUserID   string          `json:"service_user_id,omitempty" db:"service_user_id"`
+	ServiceUsername string          `json:"service_username,omitempty" db:"service_username"`
+	Scopes          string          `json:"scopes,omitempty" db:"scopes"`
+	MissingScopes   pq.StringArray  `json:"missing_scopes,omitempty" db:"missing_scopes"`
+	Degraded        bool            `json:"degraded" db:"degraded"` // Missing scopes a capability needs
+	State           ConnectionState `json:"state" db:"connection_state"`
+	StateChangedAt  time.Time       `json:"state_changed_at" db:"state_changed_at"`
+	LastRefreshAt   *time.Time      `json:"last_refresh_at,omitempty" db:"last_refresh_at"`
+	LastError       string          `json:"last_error,omitempty" db:"last_error"`
+	LastErrorAt     *time.Time      `json:"last_error_at,omitempty" db:"last_error_at"`
+	TokenExpiresAt  *time.Time      `json:"token_expires_at,omitempty" db:"token_expires_at"`
+	LastSyncAt      *time.Time      `json:"last_sync_at,omitempty" db:"last_sync_at"`
+	SyncEnabled     bool            `json:"sync_enabled" db:"sync_enabled"`
+	ConnectedAt     time.Time       `json:"connected_at" db:"created_at"`
+}
+
+// connectionColumns selects a Connection from user_services us joined with services s and left
+// joined with connection_tokens ct
+const connectionColumns = `
+	us.id, s.name AS service_name,
+	COALESCE(us.label, '') AS label,
+	COALESCE(us.service_user_id, '') AS service_user_id,
+	COALESCE(us.service_username, '') AS service_username,
+	COALESCE(ct.scopes, '') AS scopes,
+	us.missing_scopes,
+	cardinality(us.missing_scopes) > 0 AS degraded,
+	us.connection_state, us.state_changed_at, us.last_refresh_at,
+	COALESCE(us.last_error, '') AS last_error, us.last_error_at,
+	ct.expires_at AS token_expires_at, us.last_sync_at,
+	COALESCE(us.sync_enabled, TRUE) AS sync_enabled,
+	us.created_at`
+
+// ListConnections returns the accounts a user has connected, oldest first within each service
+func (o *OAuthManager) ListConnections(userID string) ([]Connection, error) {
+	connections := []Connection{}
+	err := o.db.Select(&connections, `
+		SELECT `+connectionColumns+`
+		FROM user_services us
+		JOIN services s ON us.service_id = s.id
+		LEFT JOIN connection_tokens ct ON ct.user_service_id = us.id
+		WHERE us.user_id = $1
+		ORDER BY s.name, us.created_at
+	`, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt tokens: %w", err)
+		return nil, fmt.Errorf("failed to list connections: %w", err)
 	}
 
-	return &OAuthTokens{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    userService.TokenType,
-		ExpiresAt:    userService.TokenExpiresAt,
-		Scope:        userService.Scopes,
-	}, nil
+	return connections, nil
 }
 
-// storePendingAuth stores a pending OAuth authorization
-func (o *OAuthManager) storePendingAuth(userID, serviceName, state string, expiresAt time.Time) error {
+// GetConnection returns one of the user's connections
+func (o *OAuthManager) GetConnection(userID, connectionID string) (*Connection, error) {
+	if !validConnectionID(connectionID) {
+		return nil, ErrNotConnected
+	}
+
+	var connection Connection
+	err := o.db.Get(&connection, `
+		SELECT `+connectionColumns+`
+		FROM user_services us
+		JOIN services s ON us.service_id = s.id
+		LEFT JOIN connection_tokens ct ON ct.user_service_id = us.id
+		WHERE us.user_id = $1 AND us.id = $2::uuid
+	`, userID, connectionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotConnected
+		}
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	return &connection, nil
+}
+
+// RenameConnection changes the label of one of the user's connections
+func (o *OAuthManager) RenameConnection(userID, connectionID, label string) error {
+	if !validConnectionID(connectionID) {
+		return ErrNotConnected
+	}
+
+	result, err := o.db.Exec(`
+		UPDATE user_services SET label = NULLIF($3, ''), updated_at = NOW()
+		WHERE user_id = $1 AND id = $2::uuid
+	`, userID, connectionID, label)
+	if err != nil {
+		return fmt.Errorf("failed to rename connection: %w", err)
+	}
+
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// OnDisconnect registers a hook run after every successful Disconnect, used to stop work that
+// still refers to the removed connection
+func (o *OAuthManager) OnDisconnect(hook DisconnectHook) {
+	o.hooksMu.Lock()
+	defer o.hooksMu.Unlock()
+
+	o.disconnectHooks = append(o.disconnectHooks, hook)
+}
+
+// Disconnect removes one of the user's connections. The connection and its sync metadata are
+// deleted, then the tokens are revoked at the provider when it supports revocation and the
+// disconnect hooks run. A failed revocation is logged but does not keep the connection.
+func (o *OAuthManager) Disconnect(userID, connectionID string) error {
+	ctx := context.Background()
+
+	connection, err := o.GetConnection(userID, connectionID)
+	if err != nil {
+		return err
+	}
+
+	// Tokens are read before the delete removes them but revoked only once it has committed, so
+	// a failed delete leaves a connection that still works
+	tokens := o.connectionTokens(connection)
+
+	tx, err := o.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin disconnect: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sync_metadata WHERE user_service_id = $1", connection.ID); err != nil {
+		return fmt.Errorf("failed to delete sync metadata: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM user_services WHERE id = $1", connection.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete connection: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrNotConnected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit disconnect: %w", err)
+	}
+	o.revokeTokens(ctx, userID, connection.ServiceName, tokens)
+	if err := o.tokens.Delete(ctx, connection.ID); err != nil {
+		o.logger.Warn("Failed to delete tokens of disconnected service",
+			logging.KeyProvider, connection.ServiceName, "connection_id", connection.ID, "error", err)
+	}
+
+	o.hooksMu.RLock()
+	hooks := append([]DisconnectHook(nil), o.disconnectHooks...)
+	o.hooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, userID, *connection)
+	}
+
+	o.logger.Info("Disconnected service",
+		logging.KeyUserID, userID, logging.KeyProvider, connection.ServiceName, "connection_id", connection.ID)
+	return nil
+}
+
+// connectionTokens returns the decrypted tokens of a connection, or nil when they cannot be read.
+// Tokens that cannot be decrypted cannot be revoked either, but the connection is still removed.
+func (o *OAuthManager) connectionTokens(connection *Connection) *OAuthTokens {
+	tokens, err := o.GetUserTokens(context.Background(), connection.ID, TokenReadRevoke)
+	if err != nil {
+		o.logger.Warn("Failed to read tokens of disconnected service",
+			logging.KeyProvider, connection.ServiceName, "connection_id", connection.ID, "error", err)
+		return nil
+	}
+	return tokens
+}
+
+// revokeTokens revokes the tokens at the provider if it is registered and supports revocation
+func (o *OAuthManager) revokeTokens(ctx context.Context, userID, serviceName string, tokens *OAuthTokens) {
+	if tokens == nil {
+		return
+	}
+
+	service, err := o.Registry.GetService(serviceName)
+	if err != nil {
+		return
+	}
+
+	revoker, ok := service.(TokenRevoker)
+	if !ok {
+		o.logger.Debug("Provider does not support token revocation", logging.KeyProvider, serviceName)
+		return
+	}
+
+	if err := revoker.RevokeTokens(ctx, tokens); err != nil {
+		o.logger.Warn("Failed to revoke tokens at provider",
+			logging.KeyUserID, userID, logging.KeyProvider, serviceName, "error", err)
+		return
+	}
+
+	o.logger.Info("Revoked tokens at provider", logging.KeyUserID, userID, logging.KeyProvider, serviceName)
+}
+
+// storePendingAuth stores a pending OAuth authorization, replacing any earlier one of the user
+func (o *OAuthManager) storePendingAuth(auth PendingAuth) error {
 	_, err := o.db.Exec(`
-		INSERT INTO pending_oauth_auth (id, user_id, service_name, state, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO pending_oauth_auth (
+			id, user_id, service_name, state, redirect_uri, code_verifier, label, connection_id, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, $9)
 		ON CONFLICT (user_id, service_name) 
-		DO UPDATE SET state = $4, expires_at = $5, created_at = NOW()
-	`, uuid.New().String(), userID, serviceName, state, expiresAt)
+		DO UPDATE SET state = $4, redirect_uri = $5, code_verifier = $6, label = $7,
+			connection_id = NULLIF($8, '')::uuid, expires_at = $9, created_at = NOW()
+	`, uuid.New().String(), auth.UserID, auth.ServiceName, auth.State, auth.RedirectURI, auth.CodeVerifier,
+		auth.Label, auth.ConnectionID, auth.ExpiresAt)
 
 	return err
 }
 
-// validateStateToken validates the state token and returns the user ID
-func (o *OAuthManager) validateStateToken(serviceName, state string) (string, error) {
+// consumePendingAuth deletes and returns the unexpired pending authorization for the state
+func (o *OAuthManager) consumePendingAuth(serviceName, state string) (*PendingAuth, error) {
 	var auth PendingAuth
 	err := o.db.Get(&auth, `
-		SELECT user_id, service_name, expires_at 
-		FROM pending_oauth_auth 
-		WHERE service_name = $1 AND state = $2 AND expires_at > NOW()
+		DELETE FROM pending_oauth_auth
+		WHERE service_name = $1 AND state = $2
+		RETURNING id, user_id, service_name, state, redirect_uri, code_verifier, label,
+			COALESCE(connection_id::text, '') AS connection_id, expires_at, created_at
 	`, serviceName, state)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("invalid or expired state token")
+			return nil, fmt.Errorf("invalid or expired state token")
 		}
-		return "", err
+		return nil, err
 	}
 
-	return auth.UserID, nil
-}
-
-// cleanupPendingAuth removes a pending authorization
-func (o *OAuthManager) cleanupPendingAuth(state string) {
-	_, err := o.db.Exec("DELETE FROM pending_oauth_auth WHERE state = $1", state)
-	if err != nil {
-		o.logger.Printf("Warning: Failed to cleanup pending auth: %v", err)
+	if time.Now().After(auth.ExpiresAt) {
+		return nil, fmt.Errorf("invalid or expired state token")
 	}
-}
 
-// UserServiceRecord represents the enhanced user service record
-type UserServiceRecord struct {
-	ID              string         `db:"id"`
-	UserID          string         `db:"user_id"`
-	ServiceID       string         `db:"service_id"`
-	ServiceName     string         `db:"service_name"`
-	AccessToken     []byte         `db:"access_token"`
-	RefreshToken    []byte         `db:"refresh_token"`
-	Metadata        map[string]any `db:"metadata"`
-	TokenType       string         `db:"token_type"`
-	TokenExpiresAt  time.Time      `db:"token_expires_at"`
-	LastSyncAt      *time.Time     `db:"last_sync_at"`
-	SyncFrequency   string         `db:"sync_frequency"`
-	SyncEnabled     bool           `db:"sync_enabled"`
-	ServiceUserID   string         `db:"service_user_id"`
-	ServiceUsername string         `db:"service_username"`
-	Scopes          string         `db:"scopes"`
-	CreatedAt       time.Time      `db:"created_at"`
-	UpdatedAt       time.Time      `db:"updated_at"`
+	return &auth, nil
 }
 
-// storeUserTokens encrypts and stores OAuth tokens
-func (o *OAuthManager) storeUserTokens(userID, serviceName string, tokens *OAuthTokens, profile *UserProfile) error {
+// storeUserTokens stores OAuth tokens and returns the connection id. Tokens of an account the
+// user already connected replace the old ones; another account gets a new connection.
+func (o *OAuthManager) storeUserTokens(ctx context.Context, userID, serviceName, label string, tokens *OAuthTokens, profile *UserProfile) (string, error) {
 	var serviceID string
-	err := o.db.Get(&serviceID, "SELECT id FROM services WHERE name = $1", serviceName)
+	err := o.db.GetContext(ctx, &serviceID, "SELECT id FROM services WHERE name = $1", serviceName)
 	if err != nil {
-		return fmt.Errorf("service not found: %w", err)
+		return "", fmt.Errorf("service not found: %w", err)
 	}
 
-	encryptedAccess, encryptedRefresh, err := o.encryption.EncryptTokens(tokens.AccessToken, tokens.RefreshToken)
+	metadata, err := json.Marshal(profile.Metadata)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt tokens: %w", err)
+		return "", fmt.Errorf("failed to encode profile metadata: %w", err)
 	}
 
-	serviceUserID := ""
-	serviceUsername := ""
-	if profile != nil {
-		serviceUserID = profile.ExternalID
-		serviceUsername = profile.Username
+	// The connection and its tokens are written together, so a failed token write neither
+	// leaves a connection without tokens nor resets a reconnected one
+	tx, err := o.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin storing connection: %w", err)
 	}
+	defer tx.Rollback()
 
-	_, err = o.db.Exec(`
+	// Tokens are bound to their connection, so the connection is created first
+	var connectionID string
+	err = tx.GetContext(ctx, &connectionID, `
 		INSERT INTO user_services (
-			id, user_id, service_id, access_token, refresh_token,
-			token_type, token_expires_at, service_user_id, service_username, scopes, metadata
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (user_id, service_id) 
+			id, user_id, service_id, service_user_id, service_username, metadata, label
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		ON CONFLICT (user_id, service_id, service_user_id) 
 		DO UPDATE SET 
-			access_token = $4,
-			refresh_token = $5,
-			token_type = $6,
-			token_expires_at = $7,
-			service_user_id = $8,
-			service_username = $9,
-			scopes = $10,
-			metadata = $11,
+			service_username = $5,
+			metadata = $6,
+			label = COALESCE(NULLIF($7, ''), user_services.label),
+			connection_state = 'active',
+			state_changed_at = NOW(),
+			refresh_failures = 0,
+			reauth_notified_at = NULL,
 			updated_at = NOW()
-	`, uuid.New().String(), userID, serviceID, encryptedAccess, encryptedRefresh,
-		tokens.TokenType, tokens.ExpiresAt, serviceUserID, serviceUsername, tokens.Scope, profile.Metadata)
-
-	return err
-}
-
-// getUserService retrieves a user service record
-func (o *OAuthManager) getUserService(userServiceID string) (*UserServiceRecord, error) {
-	var userService UserServiceRecord
-	err := o.db.Get(&userService, `
-		SELECT us.*, s.name as service_name
-		FROM user_services us
-		JOIN services s ON us.service_id = s.id
-		WHERE us.id = $1
-	`, userServiceID)
+		RETURNING id
+	`, uuid.New().String(), userID, serviceID, profile.ExternalID, profile.Username, metadata, label)
+	if err != nil {
+		return "", err
+	}
 
+	// Stores outside the database are written before the commit, so a failure still undoes it
+	if store, ok := o.tokens.(txTokenStore); ok {
+		_, err = store.PutTx(ctx, tx, connectionID, tokens)
+	} else {
+		_, err = o.tokens.Put(ctx, connectionID, tokens)
+	}
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return &userService, nil
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit connection: %w", err)
+	}
+	return connectionID, nil
 }
 
-// updateUserTokens updates encrypted tokens for a user service
-func (o *OAuthManager) updateUserTokens(userServiceID string, tokens *OAuthTokens) error {
-	encryptedAccess, encryptedRefresh, err := o.encryption.EncryptTokens(tokens.AccessToken, tokens.RefreshToken)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt tokens: %w", err)
+// expiresAt returns the expiry to store for tokens, NULL for tokens that do not expire
+func expiresAt(tokens *OAuthTokens) *time.Time {
+	if tokens.ExpiresAt.IsZero() {
+		return nil
 	}
+	return &tokens.ExpiresAt
+}
 
-	_, err = o.db.Exec(`
-		UPDATE user_services 
-		SET encrypted_access_token = $1, encrypted_refresh_token = $2, 
-		    token_type = $3, token_expires_at = $4, scopes = $5, updated_at = NOW()
-		WHERE id = $6
-	`, encryptedAccess, encryptedRefresh, tokens.TokenType, tokens.ExpiresAt, tokens.Scope, userServiceID)
-
-	return err
+// connectionService returns the name of the provider a connection belongs to
+func (o *OAuthManager) connectionService(userServiceID string) (string, error) {
+	var serviceName string
+	err := o.db.Get(&serviceName, `
+		SELECT s.name
+		FROM user_services us
+		JOIN services s ON us.service_id = s.id
+		WHERE us.id = $1
+	`, userServiceID)
+	return serviceName, err
 }
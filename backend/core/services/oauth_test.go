@@ -0,0 +1,122 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"syncer.net/core/security"
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func newTestRegistry(t *testing.T, providers ...*servicetest.FakeProvider) (*services.ServiceRegistry, *slog.Logger) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := services.NewServiceRegistry(nil, logger)
+	for _, provider := range providers {
+		if err := registry.Register(provider); err != nil {
+			t.Fatalf("Register(%s): %v", provider.Name(), err)
+		}
+	}
+	return registry, logger
+}
+
+func TestInitiateAuthRejectsUnavailableProviders(t *testing.T) {
+	provider := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "fake"})
+	provider.SetError(servicetest.OpHealthCheck, errors.New("maintenance"))
+	registry, logger := newTestRegistry(t, provider)
+
+	// Connect flows use the cached health, so the monitor has to see the failure first
+	config := services.DefaultHealthMonitorConfig()
+	config.FailuresToTrip = 1
+	monitor := services.NewHealthMonitor(registry, config, logger)
+	monitor.ProbeAll(context.Background())
+	probes := provider.Calls(servicetest.OpHealthCheck)
+
+	encryption, err := security.NewTokenEncryption([32]byte{})
+	if err != nil {
+		t.Fatalf("NewTokenEncryption: %v", err)
+	}
+	oauth, err := services.NewOAuthManager(registry, nil, encryption, logger)
+	if err != nil {
+		t.Fatalf("NewOAuthManager: %v", err)
+	}
+
+	if _, err := oauth.InitiateAuth("missing", "user-1", "https://syncer.example/callback", ""); err == nil {
+		t.Error("InitiateAuth succeeded for an unregistered service")
+	}
+	if _, err := oauth.InitiateAuth("fake", "user-1", "https://syncer.example/callback", ""); !errors.Is(err, services.ErrServiceUnavailable) {
+		t.Errorf("InitiateAuth for an unhealthy service = %v, want ErrServiceUnavailable", err)
+	}
+	if _, err := oauth.HandleCallback(context.Background(), "fake", "code", "state"); !errors.Is(err, services.ErrServiceUnavailable) {
+		t.Errorf("HandleCallback for an unhealthy service = %v, want ErrServiceUnavailable", err)
+	}
+	if got := provider.Calls(servicetest.OpHealthCheck); got != probes {
+		t.Errorf("connect flow made %d live health checks", got-probes)
+	}
+	if got := provider.Calls(servicetest.OpAuthURL); got != 0 {
+		t.Errorf("GetAuthURL called %d times for an unhealthy service", got)
+	}
+}
+
+func TestHealthMonitorTripsCircuit(t *testing.T) {
+	healthy := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "healthy"})
+	failing := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "failing"})
+	failing.SetError(servicetest.OpHealthCheck, errors.New("connection refused"))
+	slow := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "slow", Latency: 50 * time.Millisecond})
+	registry, logger := newTestRegistry(t, healthy, failing, slow)
+
+	config := services.DefaultHealthMonitorConfig()
+	config.FailuresToTrip = 2
+	config.DegradedLatency = 10 * time.Millisecond
+	monitor := services.NewHealthMonitor(registry, config, logger)
+
+	for range config.FailuresToTrip {
+		monitor.ProbeAll(context.Background())
+	}
+
+	if health := monitor.Health("healthy"); health.Status != services.HealthHealthy || !health.Available() {
+		t.Errorf("healthy provider = %+v", health)
+	}
+	if health := monitor.Health("slow"); health.Status != services.HealthDegraded || !health.Available() {
+		t.Errorf("slow provider = %+v", health)
+	}
+
+	health := monitor.Health("failing")
+	if health.Status != services.HealthUnhealthy || health.ConsecutiveFailures != 2 {
+		t.Errorf("failing provider = %+v", health)
+	}
+	if health.Available() {
+		t.Error("circuit stayed closed after repeated failed probes")
+	}
+}
+
+func TestConnectionLookupsRejectMalformedIDs(t *testing.T) {
+	registry, logger := newTestRegistry(t)
+	encryption, err := security.NewTokenEncryption([32]byte{})
+	if err != nil {
+		t.Fatalf("NewTokenEncryption: %v", err)
+	}
+	// Without a database any query would panic, so these must be answered up front
+	oauth, err := services.NewOAuthManager(registry, nil, encryption, logger)
+	if err != nil {
+		t.Fatalf("NewOAuthManager: %v", err)
+	}
+
+	for _, id := range []string{"", "42", "not-a-uuid"} {
+		if _, err := oauth.GetConnection("user-1", id); !errors.Is(err, services.ErrNotConnected) {
+			t.Errorf("GetConnection(%q) = %v, want ErrNotConnected", id, err)
+		}
+		if err := oauth.RenameConnection("user-1", id, "Work"); !errors.Is(err, services.ErrNotConnected) {
+			t.Errorf("RenameConnection(%q) = %v, want ErrNotConnected", id, err)
+		}
+		if err := oauth.Disconnect("user-1", id); !errors.Is(err, services.ErrNotConnected) {
+			t.Errorf("Disconnect(%q) = %v, want ErrNotConnected", id, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+// maxPages guards against providers that keep reporting more pages
+const maxPages = 1000
+
+// Page is one page of an offset-paginated provider listing
+type Page[T any] struct {
+	Items   []T
+	Total   int  // Total items across all pages, when the provider reports it
+	HasMore bool // Whether the provider reports a next page
+}
+
+// PageFetcher fetches the page starting at offset
+type PageFetcher[T any] func(ctx context.Context, offset, limit int) (Page[T], error)
+
+// PaginateOffset collects every page of a listing, stopping when the provider reports no further
+// page, returns a short page or the reported total has been reached
+func PaginateOffset[T any](ctx context.Context, limit int, fetch PageFetcher[T]) ([]T, error) {
+	var items []T
+
+	for page, offset := 0, 0; ; page, offset = page+1, offset+limit {
+		if page >= maxPages {
+			return items, fmt.Errorf("pagination stopped after %d pages", maxPages)
+		}
+		if err := ctx.Err(); err != nil {
+			return items, err
+		}
+
+		result, err := fetch(ctx, offset, limit)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, result.Items...)
+
+		if !result.HasMore || len(result.Items) < limit {
+			return items, nil
+		}
+		if result.Total > 0 && offset+limit >= result.Total {
+			return items, nil
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// PKCE is the proof key of one authorization code exchange (RFC 7636)
+type PKCE struct {
+	Verifier  string
+	Challenge string // S256 challenge derived from the verifier
+}
+
+// NewPKCE generates a random code verifier and its S256 challenge
+func NewPKCE() (PKCE, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return PKCE{}, fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	verifier := base64.RawURLEncoding.EncodeToString(random)
+	return PKCE{Verifier: verifier, Challenge: PKCEChallenge(verifier)}, nil
+}
+
+// PKCEChallenge derives the S256 code challenge of a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
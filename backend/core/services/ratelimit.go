@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"syncer.net/core/metrics"
+)
+
+// ErrRateLimited is returned when a provider quota stays exhausted for longer than a request may wait
+var ErrRateLimited = errors.New("provider rate limit exceeded")
+
+// defaultRetryAfter is used for 429 responses that do not say when to retry
+const defaultRetryAfter = 30 * time.Second
+
+// RateLimitScope selects whose quota a limit or provider throttling signal applies to
+type RateLimitScope string
+
+const (
+	RateLimitScopeApp  RateLimitScope = "app"  // One quota shared by the whole application
+	RateLimitScopeUser RateLimitScope = "user" // One quota per user access token
+)
+
+// RateLimitedError reports a request rejected by a rate limit and when it may be retried
+type RateLimitedError struct {
+	Provider string
+	Scope    RateLimitScope
+	RetryAt  time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s %s rate limit exceeded, retry at %s", e.Provider, e.Scope, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// Quota is a maximum number of requests in a fixed window
+type Quota struct {
+	Window time.Duration
+	Limit  int
+}
+
+// RateLimitReservation requests one call against the quotas of a key
+type RateLimitReservation struct {
+	Key    string
+	Quotas []Quota
+}
+
+// RateLimitStore holds quota counters and provider-signalled blocks. The Postgres store shares
+// them across replicas; the memory store keeps them per process.
+type RateLimitStore interface {
+	// Reserve counts one request against every reservation. When a key is blocked or any quota is
+	// exhausted nothing is counted and the time the request may be retried is returned.
+	Reserve(ctx context.Context, reservations []RateLimitReservation) (time.Time, error)
+	// Block rejects requests for a key until the given time
+	Block(ctx context.Context, key string, until time.Time, reason string) error
+}
+
+// RateLimitConfig configures a provider rate limiter. Zero quotas are unlimited.
+type RateLimitConfig struct {
+	RequestsPerSecond     int
+	BurstSize             int
+	RequestsPerMinute     int
+	RequestsPerHour       int
+	UserRequestsPerMinute int
+	UserRequestsPerHour   int
+	ThrottleScope         RateLimitScope // Scope blocked by 429 responses and exhausted remaining headers
+	MaxWait               time.Duration  // Longest a request waits for quota before failing with ErrRateLimited
+}
+
+// RateLimiter throttles requests to a provider. A local token bucket paces requests per second,
+// while windowed quotas and blocks from provider headers live in a RateLimitStore so that every
+// replica respects the provider's app quota.
+type RateLimiter struct {
+	provider     string
+	config       RateLimitConfig
+	baseLimit    rate.Limit
+	bucket       *rate.Limiter
+	mu           sync.RWMutex
+	store        RateLimitStore
+	fallback     *MemoryRateLimitStore
+	adaptedUntil time.Time
+	logger       *slog.Logger
+}
+
+// NewRateLimiter creates a rate limiter backed by an in-process store
+func NewRateLimiter(provider string, config RateLimitConfig, logger *slog.Logger) *RateLimiter {
+	if config.ThrottleScope == "" {
+		config.ThrottleScope = RateLimitScopeApp
+	}
+	if config.MaxWait == 0 {
+		config.MaxWait = time.Minute
+	}
+
+	baseLimit := rate.Every(time.Second / time.Duration(config.RequestsPerSecond))
+	fallback := NewMemoryRateLimitStore()
+
+	return &RateLimiter{
+		provider:  provider,
+		config:    config,
+		baseLimit: baseLimit,
+		bucket:    rate.NewLimiter(baseLimit, config.BurstSize),
+		store:     fallback,
+		fallback:  fallback,
+		logger:    logger,
+	}
+}
+
+// SetStore replaces the store holding quotas and blocks, e.g. with one shared across replicas
+func (l *RateLimiter) SetStore(store RateLimitStore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.store = store
+}
+
+// Config returns the limiter configuration
+func (l *RateLimiter) Config() RateLimitConfig {
+	return l.config
+}
+
+// Wait blocks until a request may be sent. userKey identifies the user token the request is made
+// with and is empty for app-level calls such as token exchanges.
+func (l *RateLimiter) Wait(ctx context.Context, userKey string) error {
+	l.restorePace()
+	if err := l.bucket.Wait(ctx); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(l.config.MaxWait)
+	reservations := l.reservations(userKey)
+
+	for {
+		retryAt, err := l.reserve(ctx, reservations)
+		if err != nil {
+			return err
+		}
+		if retryAt.IsZero() {
+			return nil
+		}
+
+		if retryAt.After(deadline) {
+			metrics.ProviderRateLimited.WithLabelValues(l.provider, "rejected").Inc()
+			return &RateLimitedError{Provider: l.provider, Scope: l.config.ThrottleScope, RetryAt: retryAt}
+		}
+
+		timer := time.NewTimer(time.Until(retryAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Observe adapts the limiter to the rate limit signals in a provider response
+func (l *RateLimiter) Observe(ctx context.Context, resp *http.Response, userKey string) {
+	key := l.scopeKey(l.config.ThrottleScope, userKey)
+	now := time.Now()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAt, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+		if !ok {
+			retryAt = now.Add(defaultRetryAfter)
+		}
+		l.block(ctx, key, retryAt, "429")
+		return
+	}
+
+	remaining, hasRemaining := headerInt(resp.Header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	reset, hasReset := parseRateLimitReset(resp.Header, now)
+	if !hasRemaining || !hasReset {
+		return
+	}
+
+	if remaining <= 0 {
+		l.block(ctx, key, reset, "quota_exhausted")
+		return
+	}
+
+	// Spread the remaining quota over the time left until it resets
+	if until := reset.Sub(now); until > 0 {
+		pace := rate.Limit(float64(remaining) / until.Seconds())
+		if pace < l.baseLimit {
+			l.mu.Lock()
+			l.adaptedUntil = reset
+			l.mu.Unlock()
+			l.bucket.SetLimit(pace)
+		}
+	}
+}
+
+// restorePace returns to the configured rate once an adapted pace has expired
+func (l *RateLimiter) restorePace() {
+	l.mu.RLock()
+	expired := !l.adaptedUntil.IsZero() && time.Now().After(l.adaptedUntil)
+	l.mu.RUnlock()
+
+	if expired {
+		l.mu.Lock()
+		l.adaptedUntil = time.Time{}
+		l.mu.Unlock()
+		l.bucket.SetLimit(l.baseLimit)
+	}
+}
+
+// reservations builds the app and, for user requests, the per-user reservations
+func (l *RateLimiter) reservations(userKey string) []RateLimitReservation {
+	reservations := []RateLimitReservation{{
+		Key:    l.scopeKey(RateLimitScopeApp, ""),
+		Quotas: quotas(l.config.RequestsPerMinute, l.config.RequestsPerHour),
+	}}
+
+	if userKey != "" {
+		reservations = append(reservations, RateLimitReservation{
+			Key:    l.scopeKey(RateLimitScopeUser, userKey),
+			Quotas: quotas(l.config.UserRequestsPerMinute, l.config.UserRequestsPerHour),
+		})
+	}
+	return reservations
+}
+
+// reserve reserves against the configured store, falling back to the in-process store when the
+// shared store is unreachable so provider calls keep working
+func (l *RateLimiter) reserve(ctx context.Context, reservations []RateLimitReservation) (time.Time, error) {
+	l.mu.RLock()
+	store := l.store
+	l.mu.RUnlock()
+
+	retryAt, err := store.Reserve(ctx, reservations)
+	if err == nil || store == RateLimitStore(l.fallback) {
+		return retryAt, err
+	}
+
+	l.logger.WarnContext(ctx, "Shared rate limit store unavailable, using local limits", "error", err)
+	return l.fallback.Reserve(ctx, reservations)
+}
+
+// block records a provider-signalled block in the configured store and the local fallback
+func (l *RateLimiter) block(ctx context.Context, key string, until time.Time, reason string) {
+	metrics.ProviderRateLimited.WithLabelValues(l.provider, reason).Inc()
+	l.logger.WarnContext(ctx, "Provider rate limit reached", "scope_key", key, "retry_at", until, "reason", reason)
+
+	l.mu.RLock()
+	store := l.store
+	l.mu.RUnlock()
+
+	_ = l.fallback.Block(ctx, key, until, reason)
+	if store != RateLimitStore(l.fallback) {
+		if err := store.Block(ctx, key, until, reason); err != nil {
+			l.logger.WarnContext(ctx, "Failed to share rate limit block", "error", err)
+		}
+	}
+}
+
+// scopeKey builds the store key for a scope; user keys are already hashed
+func (l *RateLimiter) scopeKey(scope RateLimitScope, userKey string) string {
+	if scope == RateLimitScopeUser && userKey != "" {
+		return fmt.Sprintf("%s:user:%s", l.provider, userKey)
+	}
+	return fmt.Sprintf("%s:app", l.provider)
+}
+
+// quotas converts per-minute and per-hour limits into quotas, skipping unlimited ones
+func quotas(perMinute, perHour int) []Quota {
+	var result []Quota
+	if perMinute > 0 {
+		result = append(result, Quota{Window: time.Minute, Limit: perMinute})
+	}
+	if perHour > 0 {
+		result = append(result, Quota{Window: time.Hour, Limit: perHour})
+	}
+	return result
+}
+
+// tokenScopeKey identifies the user token of a request without keeping the token itself. Tokens
+// are read from the Authorization header or, for providers such as Deezer, the query string.
+func tokenScopeKey(req *http.Request) string {
+	token := req.Header.Get("Authorization")
+	if token == "" {
+		token = req.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// parseRateLimitReset reads the reset header, which providers send either as a Unix timestamp
+// or as seconds until the reset
+func parseRateLimitReset(header http.Header, now time.Time) (time.Time, bool) {
+	reset, ok := headerInt(header, "X-RateLimit-Reset", "RateLimit-Reset")
+	if !ok {
+		return time.Time{}, false
+	}
+	if reset > 1_000_000_000 {
+		return time.Unix(int64(reset), 0), true
+	}
+	return now.Add(time.Duration(reset) * time.Second), true
+}
+
+// headerInt returns the first of the named headers that holds an integer
+func headerInt(header http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// MemoryRateLimitStore keeps quotas and blocks in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]memoryWindow
+	blocks    map[string]time.Time
+	lastPrune time.Time
+}
+
+type memoryWindow struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+// NewMemoryRateLimitStore creates an empty in-process store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		windows:   make(map[string]memoryWindow),
+		blocks:    make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+// Reserve counts one request against every reservation unless a key is blocked or a quota is exhausted
+func (s *MemoryRateLimitStore) Reserve(ctx context.Context, reservations []RateLimitReservation) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+
+	updates := make(map[string]memoryWindow)
+	for _, reservation := range reservations {
+		if until, blocked := s.blocks[reservation.Key]; blocked && until.After(now) {
+			return until, nil
+		}
+
+		for _, quota := range reservation.Quotas {
+			key := windowKey(reservation.Key, quota.Window)
+			start := now.Truncate(quota.Window)
+
+			window := s.windows[key]
+			if !window.start.Equal(start) {
+				window = memoryWindow{start: start, end: start.Add(quota.Window)}
+			}
+			if window.count >= quota.Limit {
+				return window.end, nil
+			}
+
+			window.count++
+			updates[key] = window
+		}
+	}
+
+	for key, window := range updates {
+		s.windows[key] = window
+	}
+	return time.Time{}, nil
+}
+
+// Block rejects requests for a key until the given time, keeping the later of two blocks
+func (s *MemoryRateLimitStore) Block(ctx context.Context, key string, until time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if until.After(s.blocks[key]) {
+		s.blocks[key] = until
+	}
+	return nil
+}
+
+// prune drops expired windows and blocks once a minute so per-user state does not accumulate
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+
+	for key, window := range s.windows {
+		if !window.end.After(now) {
+			delete(s.windows, key)
+		}
+	}
+	for key, until := range s.blocks {
+		if !until.After(now) {
+			delete(s.blocks, key)
+		}
+	}
+}
+
+// windowKey names the counter of one quota window of a key
+func windowKey(key string, window time.Duration) string {
+	return fmt.Sprintf("%s:%ds", key, int(window.Seconds()))
+}
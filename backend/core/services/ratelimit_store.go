@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"syncer.net/core/logging"
+)
+
+// rateLimitPruneInterval is how often stale rate limit rows are deleted
+const rateLimitPruneInterval = 10 * time.Minute
+
+// rateLimitLeaseFraction is the share of a quota window a replica claims per round trip. Requests
+// are then counted locally until the claim is spent, so the window row is only written once per
+// claim instead of once per request.
+const rateLimitLeaseFraction = 20
+
+// rateLimitBlockCacheTTL is how long a block lookup is reused before the blocks table is read again
+const rateLimitBlockCacheTTL = 5 * time.Second
+
+// PostgresRateLimitStore shares quotas and blocks across replicas through Postgres so the fleet
+// as a whole respects a provider's app quota. Windows are aligned on database time. Each replica
+// claims part of a window at a time and hands it out locally, and caches block lookups briefly.
+type PostgresRateLimitStore struct {
+	db        *sqlx.DB
+	mu        sync.Mutex
+	leases    map[string]rateLimitLease
+	blocks    map[string]cachedBlock
+	lastPrune time.Time
+	logger    *slog.Logger
+}
+
+// rateLimitLease is the part of a quota window this replica has claimed and not yet used
+type rateLimitLease struct {
+	end       time.Time // End of the window on database time
+	remaining int
+	exhausted bool // The window has no quota left for any replica
+}
+
+// cachedBlock is the result of a block lookup for a key
+type cachedBlock struct {
+	until     time.Time
+	checkedAt time.Time
+}
+
+// NewPostgresRateLimitStore creates a rate limit store backed by the provider_rate_limit tables
+func NewPostgresRateLimitStore(db *sqlx.DB, logger *slog.Logger) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{
+		db:        db,
+		leases:    make(map[string]rateLimitLease),
+		blocks:    make(map[string]cachedBlock),
+		lastPrune: time.Now(),
+		logger:    logging.Component(logger, "rate_limit_store"),
+	}
+}
+
+// Reserve counts one request against every reservation. Quota comes from the claims this replica
+// holds, and only windows without a usable claim are read from the database, in one transaction.
+func (s *PostgresRateLimitStore) Reserve(ctx context.Context, reservations []RateLimitReservation) (time.Time, error) {
+	s.pruneIfDue(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	blockedUntil, err := s.blockedUntil(ctx, reservations, now)
+	if err != nil || !blockedUntil.IsZero() {
+		return blockedUntil, err
+	}
+
+	var claims []windowClaim
+	for _, reservation := range reservations {
+		for _, quota := range reservation.Quotas {
+			key := windowKey(reservation.Key, quota.Window)
+			if lease, ok := s.leases[key]; ok && now.Before(lease.end) && (lease.remaining > 0 || lease.exhausted) {
+				continue
+			}
+			claims = append(claims, windowClaim{key: key, quota: quota})
+		}
+	}
+	if len(claims) > 0 {
+		if err := s.claimWindows(ctx, claims); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// Nothing is taken from the claims unless every quota has room
+	var retryAt time.Time
+	for _, reservation := range reservations {
+		for _, quota := range reservation.Quotas {
+			lease := s.leases[windowKey(reservation.Key, quota.Window)]
+			if lease.remaining == 0 && lease.end.After(retryAt) {
+				retryAt = lease.end
+			}
+		}
+	}
+	if !retryAt.IsZero() {
+		return retryAt, nil
+	}
+
+	for _, reservation := range reservations {
+		for _, quota := range reservation.Quotas {
+			key := windowKey(reservation.Key, quota.Window)
+			lease := s.leases[key]
+			lease.remaining--
+			s.leases[key] = lease
+		}
+	}
+	return time.Time{}, nil
+}
+
+// blockedUntil returns the latest active block among the reservation keys. Lookups are cached
+// for rateLimitBlockCacheTTL and blocks set by this replica are visible at once.
+func (s *PostgresRateLimitStore) blockedUntil(ctx context.Context, reservations []RateLimitReservation, now time.Time) (time.Time, error) {
+	var until time.Time
+	var stale []string
+	for _, reservation := range reservations {
+		cached, ok := s.blocks[reservation.Key]
+		if !ok || now.Sub(cached.checkedAt) >= rateLimitBlockCacheTTL {
+			stale = append(stale, reservation.Key)
+			continue
+		}
+		if cached.until.After(now) && cached.until.After(until) {
+			until = cached.until
+		}
+	}
+	if len(stale) == 0 {
+		return until, nil
+	}
+
+	var rows []struct {
+		Key          string    `db:"key"`
+		BlockedUntil time.Time `db:"blocked_until"`
+	}
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT key, blocked_until FROM provider_rate_limit_blocks
+		WHERE key = ANY($1) AND blocked_until > NOW()
+	`, pq.Array(stale))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to check rate limit block: %w", err)
+	}
+
+	for _, key := range stale {
+		s.blocks[key] = cachedBlock{checkedAt: now}
+	}
+	for _, row := range rows {
+		s.blocks[row.Key] = cachedBlock{until: row.BlockedUntil, checkedAt: now}
+		if row.BlockedUntil.After(until) {
+			until = row.BlockedUntil
+		}
+	}
+	return until, nil
+}
+
+// windowClaim asks for a new share of one quota window
+type windowClaim struct {
+	key   string
+	quota Quota
+}
+
+// claimWindows claims a share of each window in a single transaction and stores the leases
+func (s *PostgresRateLimitStore) claimWindows(ctx context.Context, claims []windowClaim) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin rate limit transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	leases := make(map[string]rateLimitLease, len(claims))
+	for _, claim := range claims {
+		lease, err := claimWindow(ctx, tx, claim.key, claim.quota)
+		if err != nil {
+			return err
+		}
+		leases[claim.key] = lease
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rate limit reservation: %w", err)
+	}
+	for key, lease := range leases {
+		s.leases[key] = lease
+	}
+	return nil
+}
+
+// claimWindow takes up to a lease's worth of the current window of a quota, restarting the
+// counter when a new window has begun. The lease is exhausted when nothing was left to take.
+func claimWindow(ctx context.Context, tx *sqlx.Tx, key string, quota Quota) (rateLimitLease, error) {
+	seconds := int(quota.Window.Seconds())
+
+	// The no-op update locks an existing row so the count read here cannot change before it is
+	// written back
+	var window struct {
+		Count        int       `db:"request_count"`
+		Start        time.Time `db:"window_start"`
+		CurrentStart time.Time `db:"current_start"`
+	}
+	err := tx.GetContext(ctx, &window, `
+		WITH current_window AS (
+			SELECT to_timestamp((floor(extract(epoch FROM NOW()) / $2::int) * $2::int)::double precision) AS start
+		)
+		INSERT INTO provider_rate_limit_windows (key, window_start, request_count, updated_at)
+		SELECT $1, start, 0, NOW() FROM current_window
+		ON CONFLICT (key) DO UPDATE SET updated_at = NOW()
+		RETURNING request_count, window_start, (SELECT start FROM current_window) AS current_start
+	`, key, seconds)
+	if err != nil {
+		return rateLimitLease{}, fmt.Errorf("failed to reserve rate limit quota: %w", err)
+	}
+
+	count := window.Count
+	if !window.Start.Equal(window.CurrentStart) {
+		count = 0
+	}
+	granted := min(max(quota.Limit/rateLimitLeaseFraction, 1), quota.Limit-count)
+	lease := rateLimitLease{end: window.CurrentStart.Add(quota.Window)}
+	if granted <= 0 {
+		lease.exhausted = true
+		return lease, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE provider_rate_limit_windows SET window_start = $2, request_count = $3, updated_at = NOW()
+		WHERE key = $1
+	`, key, window.CurrentStart, count+granted)
+	if err != nil {
+		return rateLimitLease{}, fmt.Errorf("failed to reserve rate limit quota: %w", err)
+	}
+
+	lease.remaining = granted
+	return lease, nil
+}
+
+// Block rejects requests for a key until the given time, keeping the later of two blocks
+func (s *PostgresRateLimitStore) Block(ctx context.Context, key string, until time.Time, reason string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO provider_rate_limit_blocks (key, blocked_until, reason, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			blocked_until = GREATEST(provider_rate_limit_blocks.blocked_until, EXCLUDED.blocked_until),
+			reason = EXCLUDED.reason,
+			updated_at = NOW()
+	`, key, until, reason)
+	if err != nil {
+		return fmt.Errorf("failed to store rate limit block: %w", err)
+	}
+
+	s.mu.Lock()
+	if cached := s.blocks[key]; until.After(cached.until) {
+		s.blocks[key] = cachedBlock{until: until, checkedAt: cached.checkedAt}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// pruneIfDue deletes windows and blocks that can no longer affect a request, along with expired
+// leases and cached lookups
+func (s *PostgresRateLimitStore) pruneIfDue(ctx context.Context) {
+	s.mu.Lock()
+	now := time.Now()
+	if now.Sub(s.lastPrune) < rateLimitPruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	for key, lease := range s.leases {
+		if !now.Before(lease.end) {
+			delete(s.leases, key)
+		}
+	}
+	for key, cached := range s.blocks {
+		if now.Sub(cached.checkedAt) >= rateLimitBlockCacheTTL && !cached.until.After(now) {
+			delete(s.blocks, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM provider_rate_limit_windows WHERE updated_at < NOW() - INTERVAL '2 hours'
+	`); err != nil {
+		s.logger.WarnContext(ctx, "Failed to prune rate limit windows", "error", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM provider_rate_limit_blocks WHERE blocked_until < NOW()
+	`); err != nil {
+		s.logger.WarnContext(ctx, "Failed to prune rate limit blocks", "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"syncer.net/core/logging"
+)
+
+// TokenReencryptorConfig controls how stored tokens are moved to the current key
+type TokenReencryptorConfig struct {
+	Interval  time.Duration // Time between passes over stored tokens
+	BatchSize int           // Rows re-encrypted per transaction
+}
+
+// DefaultTokenReencryptorConfig returns the configuration used unless overridden
+func DefaultTokenReencryptorConfig() TokenReencryptorConfig {
+	return TokenReencryptorConfig{
+		Interval:  time.Hour,
+		BatchSize: 100,
+	}
+}
+
+// TokenReencryptor re-encrypts stored tokens with the OAuth manager's current key, bound to their
+// row, so keys that were rotated out can eventually be removed and unbound tokens refused
+type TokenReencryptor struct {
+	oauth  *OAuthManager
+	config TokenReencryptorConfig
+	logger *slog.Logger
+}
+
+// NewTokenReencryptor creates a re-encryption job for the OAuth manager's stored tokens
+func NewTokenReencryptor(oauth *OAuthManager, config TokenReencryptorConfig, logger *slog.Logger) *TokenReencryptor {
+	return &TokenReencryptor{
+		oauth:  oauth,
+		config: config,
+		logger: logging.Component(logger, "token_reencryptor"),
+	}
+}
+
+// Start runs a pass immediately and then on each interval until ctx is done
+func (r *TokenReencryptor) Start(ctx context.Context) {
+	r.logger.Info("Starting token re-encryption",
+		"interval", r.config.Interval, "current_key", r.oauth.encryption.CurrentKeyRef())
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil {
+			r.logger.Error("Token re-encryption pass failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Token re-encryption stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce re-encrypts every connection's tokens not yet bound under the current key and returns
+// how many it moved. Tokens that fail to decrypt are skipped, so one bad row does not stall the pass.
+func (r *TokenReencryptor) RunOnce(ctx context.Context) (int, error) {
+	if err := r.oauth.encryption.RefreshKeys(ctx); err != nil {
+		return 0, fmt.Errorf("failed to refresh the current key: %w", err)
+	}
+
+	var migrated, failed int
+	after := ""
+	for ctx.Err() == nil {
+		batch, err := r.oauth.tokens.Reencrypt(ctx, after, r.config.BatchSize)
+		if err != nil {
+			return migrated, err
+		}
+		migrated += batch.Moved
+		failed += batch.Failed
+		if batch.Last == "" {
+			break
+		}
+		after = batch.Last
+	}
+
+	if migrated > 0 || failed > 0 {
+		r.logger.Info("Re-encrypted stored tokens", "rows", migrated, "failed", failed,
+			"current_key", r.oauth.encryption.CurrentKeyRef())
+	}
+	return migrated, ctx.Err()
+}
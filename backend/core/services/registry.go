@@ -2,10 +2,11 @@ package services
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 
 	"github.com/jmoiron/sqlx"
+	"syncer.net/core/logging"
 )
 
 // ServiceRegistry manages all available service providers
@@ -13,19 +14,37 @@ type ServiceRegistry struct {
 	services map[string]ServiceProvider
 	mu       sync.RWMutex
 	db       *sqlx.DB
-	logger   *log.Logger
+	logger   *slog.Logger
+	health   *HealthMonitor
+
+	rateLimits RateLimitStore
+
+	applyMu    sync.Mutex // Serializes config reloads
+	factories  map[string]providerSpec
+	applied    map[string]appliedConfig // Config each factory-built provider was built from
+	configPath string
+}
+
+// rateLimitStoreAware is implemented by services embedding BaseService
+type rateLimitStoreAware interface {
+	SetRateLimitStore(store RateLimitStore)
 }
 
 // NewServiceRegistry creates a new service registry
-func NewServiceRegistry(db *sqlx.DB, logger *log.Logger) *ServiceRegistry {
-	if logger == nil {
-		logger = log.New(log.Writer(), "[ServiceRegistry] ", log.LstdFlags)
-	}
+func NewServiceRegistry(db *sqlx.DB, logger *slog.Logger) *ServiceRegistry {
+	logger = logging.Component(logger, "service_registry")
 
 	registry := &ServiceRegistry{
-		services: make(map[string]ServiceProvider),
-		db:       db,
-		logger:   logger,
+		services:  make(map[string]ServiceProvider),
+		db:        db,
+		logger:    logger,
+		factories: make(map[string]providerSpec),
+		applied:   make(map[string]appliedConfig),
+	}
+
+	// Share provider quotas across replicas whenever a database is available
+	if db != nil {
+		registry.rateLimits = NewPostgresRateLimitStore(db, logger)
 	}
 
 	return registry
@@ -42,11 +61,22 @@ func (r *ServiceRegistry) Register(service ServiceProvider) error {
 	}
 
 	r.services[name] = service
-	r.logger.Printf("Registered service: %s (category: %s)", name, service.Category())
+	r.wire(service)
+	r.logger.Info("Registered service", logging.KeyProvider, name, "category", service.Category())
 
 	return nil
 }
 
+// wire connects a service to the health monitor and shared rate limit store. Callers must hold r.mu.
+func (r *ServiceRegistry) wire(service ServiceProvider) {
+	if r.health != nil {
+		r.health.attach(service)
+	}
+	if aware, ok := service.(rateLimitStoreAware); ok && r.rateLimits != nil {
+		aware.SetRateLimitStore(r.rateLimits)
+	}
+}
+
 // GetService retrieves a service provider by name
 func (r *ServiceRegistry) GetService(name string) (ServiceProvider, error) {
 	r.mu.RLock()
@@ -69,11 +99,13 @@ func (r *ServiceRegistry) ListServices() []ServiceInfo {
 	for _, service := range r.services {
 
 		services = append(services, ServiceInfo{
-			Name:        service.Name(),
-			DisplayName: service.DisplayName(),
-			Category:    service.Category(),
-			Scopes:      service.RequiredScopes(),
-			Available:   service.HealthCheck() == nil,
+			Name:         service.Name(),
+			DisplayName:  service.DisplayName(),
+			Category:     service.Category(),
+			Scopes:       service.RequiredScopes(),
+			Capabilities: service.Capabilities(),
+			Available:    r.available(service.Name()),
+			Health:       r.healthOf(service.Name()),
 		})
 	}
 
@@ -90,11 +122,13 @@ func (r *ServiceRegistry) GetServicesByCategory(category ServiceCategory) []Serv
 		if service.Category() == category {
 
 			services = append(services, ServiceInfo{
-				Name:        service.Name(),
-				DisplayName: service.DisplayName(),
-				Category:    service.Category(),
-				Scopes:      service.RequiredScopes(),
-				Available:   service.HealthCheck() == nil,
+				Name:         service.Name(),
+				DisplayName:  service.DisplayName(),
+				Category:     service.Category(),
+				Scopes:       service.RequiredScopes(),
+				Capabilities: service.Capabilities(),
+				Available:    r.available(service.Name()),
+				Health:       r.healthOf(service.Name()),
 			})
 		}
 	}
@@ -102,13 +136,85 @@ func (r *ServiceRegistry) GetServicesByCategory(category ServiceCategory) []Serv
 	return services
 }
 
-// IsServiceAvailable checks if a service is registered and available
+// IsServiceAvailable checks if a service is registered and its circuit is not open.
+// It uses the health monitor's cached state and never calls the provider.
 func (r *ServiceRegistry) IsServiceAvailable(name string) bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	_, exists := r.services[name]
-	return exists && r.services[name].HealthCheck() == nil
+	return exists && r.available(name)
+}
+
+// IsServiceRegistered checks if a service is registered, regardless of its health
+func (r *ServiceRegistry) IsServiceRegistered(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.services[name]
+	return exists
+}
+
+// HealthMonitor returns the monitor attached to the registry, or nil when none is running
+func (r *ServiceRegistry) HealthMonitor() *HealthMonitor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.health
+}
+
+// setHealthMonitor attaches a monitor and wires its breakers into the registered services
+func (r *ServiceRegistry) setHealthMonitor(monitor *HealthMonitor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.health = monitor
+	for _, service := range r.services {
+		monitor.attach(service)
+	}
+}
+
+// all returns the registered services
+func (r *ServiceRegistry) all() []ServiceProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services := make([]ServiceProvider, 0, len(r.services))
+	for _, service := range r.services {
+		services = append(services, service)
+	}
+	return services
+}
+
+// availableNames returns the names of registered services whose circuit is not open
+func (r *ServiceRegistry) availableNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		if r.available(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// available reports cached availability. Without a monitor every registered service is available.
+func (r *ServiceRegistry) available(name string) bool {
+	if r.health == nil {
+		return true
+	}
+	return r.health.Health(name).Available()
+}
+
+// healthOf returns the cached health of a service, or nil without a monitor
+func (r *ServiceRegistry) healthOf(name string) *ProviderHealth {
+	if r.health == nil {
+		return nil
+	}
+	health := r.health.Health(name)
+	return &health
 }
 
 // GetServiceCount returns the number of registered services
@@ -128,8 +234,10 @@ func (r *ServiceRegistry) Unregister(name string) error {
 		return fmt.Errorf("service %s not found", name)
 	}
 
+	r.closeRecorder(name, r.applied[name])
 	delete(r.services, name)
-	r.logger.Printf("Unregistered service: %s", name)
+	delete(r.applied, name)
+	r.logger.Info("Unregistered service", logging.KeyProvider, name)
 
 	return nil
 }
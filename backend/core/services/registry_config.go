@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"syncer.net/core/logging"
+)
+
+// ProviderFactory builds a provider from its merged configuration
+type ProviderFactory func(config ProviderConfig) (ServiceProvider, error)
+
+// providerSpec is a provider the registry can build from configuration
+type providerSpec struct {
+	defaults ProviderConfig
+	factory  ProviderFactory
+}
+
+// appliedConfig is the config a provider was built from, with a digest of the credentials it
+// resolved to so that rotating a secret behind the same reference rebuilds the provider
+type appliedConfig struct {
+	config      ProviderConfig
+	credentials [sha256.Size]byte
+	recorder    io.Closer // Recording transport to flush when the provider is replaced or removed
+}
+
+// RegisterFactory makes a provider available to ApplyConfig. The defaults are the settings used
+// for anything the providers config leaves out.
+func (r *ServiceRegistry) RegisterFactory(defaults ProviderConfig, factory ProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[defaults.Name] = providerSpec{defaults: defaults, factory: factory}
+}
+
+// DefaultProvidersConfig enables every provider with a registered factory using its defaults
+func (r *ServiceRegistry) DefaultProvidersConfig() *ProvidersConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	config := &ProvidersConfig{}
+	for name := range r.factories {
+		config.Providers = append(config.Providers, ProviderConfig{Name: name})
+	}
+	sort.Slice(config.Providers, func(i, j int) bool { return config.Providers[i].Name < config.Providers[j].Name })
+	return config
+}
+
+// LoadConfig applies the providers config file at path, or every provider's defaults when path
+// is empty, and remembers the path for ReloadConfig and WatchConfig
+func (r *ServiceRegistry) LoadConfig(ctx context.Context, path string) error {
+	config := r.DefaultProvidersConfig()
+	if path != "" {
+		loaded, err := LoadProvidersConfig(path)
+		if err != nil {
+			return err
+		}
+		config = loaded
+	}
+
+	if err := r.ApplyConfig(ctx, config); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.configPath = path
+	r.mu.Unlock()
+	return nil
+}
+
+// ReloadConfig re-reads the providers config file given to LoadConfig
+func (r *ServiceRegistry) ReloadConfig(ctx context.Context) error {
+	r.mu.RLock()
+	path := r.configPath
+	r.mu.RUnlock()
+
+	return r.LoadConfig(ctx, path)
+}
+
+// ApplyConfig registers, replaces and removes factory-built providers to match the config.
+// Providers the config does not list, or lists as disabled, are removed. Nothing changes when
+// any provider fails to build or the services table cannot be updated, so a bad reload leaves
+// the running providers in place.
+func (r *ServiceRegistry) ApplyConfig(ctx context.Context, config *ProvidersConfig) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid providers config: %w", err)
+	}
+
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	r.mu.RLock()
+	for _, provider := range config.Providers {
+		if _, exists := r.factories[provider.Name]; !exists {
+			r.mu.RUnlock()
+			return fmt.Errorf("unknown provider %s in providers config", provider.Name)
+		}
+	}
+
+	desired := make(map[string]appliedConfig)
+	for _, provider := range config.Providers {
+		merged := r.factories[provider.Name].defaults.Merge(provider)
+		if !merged.IsEnabled() {
+			continue
+		}
+		credentials, err := credentialDigest(merged)
+		if err != nil {
+			r.mu.RUnlock()
+			return err
+		}
+		desired[provider.Name] = appliedConfig{config: merged, credentials: credentials}
+	}
+
+	built := make(map[string]ServiceProvider)
+	recorders := make(map[string]io.Closer)
+	for name, want := range desired {
+		_, registered := r.services[name]
+		if registered && r.applied[name].credentials == want.credentials && reflect.DeepEqual(r.applied[name].config, want.config) {
+			continue
+		}
+
+		service, err := r.factories[name].factory(want.config)
+		if err != nil {
+			r.mu.RUnlock()
+			closeRecorders(recorders)
+			return fmt.Errorf("failed to build provider %s: %w", name, err)
+		}
+		if service.Name() != name {
+			r.mu.RUnlock()
+			closeRecorders(recorders)
+			return fmt.Errorf("factory for %s built provider %s", name, service.Name())
+		}
+		if want.config.Recording != nil {
+			recorder, err := useRecording(service, *want.config.Recording)
+			if err != nil {
+				r.mu.RUnlock()
+				closeRecorders(recorders)
+				return fmt.Errorf("failed to set up recording for provider %s: %w", name, err)
+			}
+			if recorder != nil {
+				recorders[name] = recorder
+			}
+		}
+		built[name] = service
+	}
+
+	// The services table is brought in line first, so a failure leaves memory unchanged too
+	var enabled []ServiceProvider
+	for name, service := range r.services {
+		_, factoryBuilt := r.applied[name]
+		_, keep := desired[name]
+		_, rebuilt := built[name]
+		if (!factoryBuilt || keep) && !rebuilt {
+			enabled = append(enabled, service)
+		}
+	}
+	for _, service := range built {
+		enabled = append(enabled, service)
+	}
+	r.mu.RUnlock()
+
+	if err := r.syncServicesTable(ctx, enabled); err != nil {
+		closeRecorders(recorders)
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, applied := range r.applied {
+		if _, keep := desired[name]; !keep {
+			delete(r.services, name)
+			delete(r.applied, name)
+			r.closeRecorder(name, applied)
+			r.logger.Info("Disabled service", logging.KeyProvider, name)
+		}
+	}
+	for name, service := range built {
+		_, replaced := r.services[name]
+		r.closeRecorder(name, r.applied[name])
+		r.services[name] = service
+		applied := desired[name]
+		applied.recorder = recorders[name]
+		r.applied[name] = applied
+		r.wire(service)
+
+		if replaced {
+			r.logger.Info("Reconfigured service", logging.KeyProvider, name)
+		} else {
+			r.logger.Info("Registered service", logging.KeyProvider, name, "category", service.Category())
+		}
+	}
+	return nil
+}
+
+// credentialDigest hashes the values a provider's credential references resolve to
+func credentialDigest(config ProviderConfig) ([sha256.Size]byte, error) {
+	keys := make([]string, 0, len(config.Credentials))
+	for key := range config.Credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		value, err := config.Credential(key)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", key, value)
+	}
+
+	var digest [sha256.Size]byte
+	hash.Sum(digest[:0])
+	return digest, nil
+}
+
+// transportAware is implemented by services embedding BaseService
+type transportAware interface {
+	SetTransport(transport http.RoundTripper)
+}
+
+// useRecording routes a provider's HTTP calls through a recording or replaying transport and
+// returns the transport when it has recordings to flush
+func useRecording(service ServiceProvider, recording RecordingConfig) (io.Closer, error) {
+	aware, ok := service.(transportAware)
+	if !ok {
+		return nil, fmt.Errorf("%T does not allow replacing its HTTP transport", service)
+	}
+
+	transport, err := recording.Transport()
+	if err != nil {
+		return nil, err
+	}
+	aware.SetTransport(transport)
+
+	recorder, _ := transport.(io.Closer)
+	return recorder, nil
+}
+
+// closeRecorders discards the recorders of providers that were built but not applied
+func closeRecorders(recorders map[string]io.Closer) {
+	for _, recorder := range recorders {
+		recorder.Close()
+	}
+}
+
+// closeRecorder flushes the recording of a provider that is being replaced or removed
+func (r *ServiceRegistry) closeRecorder(name string, applied appliedConfig) {
+	if applied.recorder == nil {
+		return
+	}
+	if err := applied.recorder.Close(); err != nil {
+		r.logger.Error("Failed to save provider recording", logging.KeyProvider, name, "error", err)
+	}
+}
+
+// WatchConfig re-applies the providers config file whenever it changes until ctx is done.
+// A config that fails to load is logged and the running providers are kept.
+func (r *ServiceRegistry) WatchConfig(ctx context.Context, interval time.Duration) {
+	r.mu.RLock()
+	path := r.configPath
+	r.mu.RUnlock()
+
+	if path == "" {
+		return
+	}
+
+	r.logger.Info("Watching providers config", "path", path, "interval", interval)
+
+	lastModified := configModTime(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modified := configModTime(path)
+		if modified.Equal(lastModified) {
+			continue
+		}
+		lastModified = modified
+
+		if err := r.ReloadConfig(ctx); err != nil {
+			r.logger.Error("Failed to reload providers config", "path", path, "error", err)
+			continue
+		}
+		r.logger.Info("Reloaded providers config", "path", path, "count", r.GetServiceCount())
+	}
+}
+
+// configModTime returns the modification time of the config file, or zero when it is missing
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// syncServicesTable upserts the enabled providers into the services table and marks every
+// other row disabled, keeping rows referenced by existing connections
+func (r *ServiceRegistry) syncServicesTable(ctx context.Context, enabled []ServiceProvider) error {
+	if r.db == nil {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin services sync: %w", err)
+	}
+	defer tx.Rollback()
+
+	names := make([]string, 0, len(enabled))
+	for _, service := range enabled {
+		names = append(names, service.Name())
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO services (name, display_name, category, oauth_required, enabled)
+			VALUES ($1, $2, $3, TRUE, TRUE)
+			ON CONFLICT (name) DO UPDATE
+			SET display_name = EXCLUDED.display_name,
+				category = EXCLUDED.category,
+				enabled = TRUE,
+				updated_at = NOW()
+		`, service.Name(), service.DisplayName(), string(service.Category()))
+		if err != nil {
+			return fmt.Errorf("failed to upsert service %s: %w", service.Name(), err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE services SET enabled = FALSE, updated_at = NOW()
+		WHERE enabled AND NOT (name = ANY($1))
+	`, pq.Array(names))
+	if err != nil {
+		return fmt.Errorf("failed to disable removed services: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit services sync: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package services_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func TestApplyConfigRebuildsOnRotatedSecret(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+	secret := filepath.Join(t.TempDir(), "client_secret")
+	if err := os.WriteFile(secret, []byte("first"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var built []string
+	registry.RegisterFactory(services.ProviderConfig{Name: "fake"}, func(config services.ProviderConfig) (services.ServiceProvider, error) {
+		value, err := config.Credential("client_secret")
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, value)
+		return servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "fake"}), nil
+	})
+
+	config := &services.ProvidersConfig{Providers: []services.ProviderConfig{{
+		Name:        "fake",
+		Credentials: map[string]string{"client_secret": "file:" + secret},
+	}}}
+	for range 2 {
+		if err := registry.ApplyConfig(context.Background(), config); err != nil {
+			t.Fatalf("ApplyConfig: %v", err)
+		}
+	}
+	if len(built) != 1 {
+		t.Fatalf("provider built %d times for an unchanged config, want 1", len(built))
+	}
+
+	// The reference stays the same while the secret behind it is rotated
+	if err := os.WriteFile(secret, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.ApplyConfig(context.Background(), config); err != nil {
+		t.Fatalf("ApplyConfig after rotation: %v", err)
+	}
+	if len(built) != 2 || built[1] != "second" {
+		t.Errorf("builds = %q, want a rebuild with the rotated secret", built)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"slices"
+	"strings"
+)
+
+// ScopeReport compares the scopes granted to a connection with those its provider's
+// capabilities need
+type ScopeReport struct {
+	Granted []string                `json:"granted"`
+	Missing map[Capability][]string `json:"missing,omitempty"`
+
+	// Unknown is set when the grant was not reported, so nothing could be verified
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// ParseScopes splits a granted scope string, separated by spaces or commas depending on the provider
+func ParseScopes(scope string) []string {
+	return strings.FieldsFunc(scope, func(r rune) bool { return r == ' ' || r == ',' })
+}
+
+// CheckScopes compares the granted scope string with the scopes every declared capability needs.
+// An empty grant is reported as unknown rather than as missing everything.
+func CheckScopes(provider ServiceProvider, granted string) ScopeReport {
+	report := ScopeReport{Granted: ParseScopes(granted)}
+	if len(report.Granted) == 0 {
+		report.Unknown = true
+		return report
+	}
+
+	needs := provider.CapabilityScopes()
+	for _, capability := range provider.Capabilities() {
+		for _, scope := range needs[capability] {
+			if slices.Contains(report.Granted, scope) {
+				continue
+			}
+			if report.Missing == nil {
+				report.Missing = make(map[Capability][]string)
+			}
+			report.Missing[capability] = append(report.Missing[capability], scope)
+		}
+	}
+	return report
+}
+
+// Degraded reports whether any capability lacks a scope it needs
+func (r ScopeReport) Degraded() bool {
+	return len(r.Missing) > 0
+}
+
+// MissingScopes returns every missing scope once, sorted
+func (r ScopeReport) MissingScopes() []string {
+	var all Capabilities
+	for capability := range r.Missing {
+		all = append(all, capability)
+	}
+	return r.MissingFor(all)
+}
+
+// MissingFor returns the missing scopes the given capabilities need, sorted
+func (r ScopeReport) MissingFor(capabilities Capabilities) []string {
+	missing := []string{}
+	for _, capability := range capabilities {
+		for _, scope := range r.Missing[capability] {
+			if !slices.Contains(missing, scope) {
+				missing = append(missing, scope)
+			}
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
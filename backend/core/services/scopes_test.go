@@ -0,0 +1,44 @@
+package services_test
+
+import (
+	"slices"
+	"testing"
+
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func TestCheckScopes(t *testing.T) {
+	provider := servicetest.NewFakeProvider(servicetest.FakeConfig{
+		Name: "fake",
+		Capabilities: services.Capabilities{
+			services.CapabilityReadFavorites, services.CapabilityWriteFavorites, services.CapabilityReadHistory,
+		},
+		CapabilityScopes: map[services.Capability][]string{
+			services.CapabilityReadFavorites:  {"library-read"},
+			services.CapabilityWriteFavorites: {"library-read", "library-modify"},
+			services.CapabilityReadHistory:    {"history"},
+		},
+	})
+
+	report := services.CheckScopes(provider, "library-read,profile")
+	if !report.Degraded() {
+		t.Fatal("report is not degraded although scopes are missing")
+	}
+	if got := report.MissingScopes(); !slices.Equal(got, []string{"history", "library-modify"}) {
+		t.Errorf("MissingScopes() = %v", got)
+	}
+	if got := report.MissingFor(services.Capabilities{services.CapabilityReadFavorites}); len(got) != 0 {
+		t.Errorf("MissingFor(read_favorites) = %v, want none", got)
+	}
+	if got := report.MissingFor(services.Capabilities{services.CapabilityWriteFavorites}); !slices.Equal(got, []string{"library-modify"}) {
+		t.Errorf("MissingFor(write_favorites) = %v", got)
+	}
+
+	if report := services.CheckScopes(provider, "history library-read library-modify"); report.Degraded() {
+		t.Errorf("fully granted connection reported missing %v", report.Missing)
+	}
+	if report := services.CheckScopes(provider, ""); !report.Unknown || report.Degraded() {
+		t.Errorf("empty grant = %+v, want unknown and not degraded", report)
+	}
+}
@@ -0,0 +1,619 @@
+package servicetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+)
+
+// Provider names served by FakeMusicAPI
+const (
+	Spotify = "spotify"
+	Deezer  = "deezer"
+)
+
+// FakeMusicAPI is an httptest server emulating the Spotify and Deezer endpoints the provider
+// integrations call. Route a provider to it with SetTransport(api.Transport()).
+type FakeMusicAPI struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	libraries map[string][]Track
+	playlists map[string][]fakePlaylist
+	history   map[string][]Track
+	catalog   []Track
+	failures  map[string][]int
+	requests  []string
+
+	challenges map[string]string // PKCE challenge of each authorization code issued with one
+
+	deezerPermissions []string // Permissions Deezer reports for any token; nil grants all it asks for
+	revoked           map[string]bool
+}
+
+type fakePlaylist struct {
+	id     string
+	name   string
+	tracks []Track
+}
+
+// NewFakeMusicAPI starts a fake API server that is closed when the test ends
+func NewFakeMusicAPI(t testing.TB) *FakeMusicAPI {
+	api := &FakeMusicAPI{
+		libraries: make(map[string][]Track),
+		playlists: make(map[string][]fakePlaylist),
+		history:   make(map[string][]Track),
+		failures:  make(map[string][]int),
+
+		challenges: make(map[string]string),
+		revoked:    make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	api.spotifyRoutes(mux)
+	api.deezerRoutes(mux)
+	api.server = httptest.NewServer(api.record(mux))
+	t.Cleanup(api.server.Close)
+
+	return api
+}
+
+// Transport sends every request to the fake server, keeping the original host for routing
+func (a *FakeMusicAPI) Transport() http.RoundTripper {
+	return &redirectTransport{api: a, next: a.server.Client().Transport}
+}
+
+type redirectTransport struct {
+	api  *FakeMusicAPI
+	next http.RoundTripper
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.Host = req.URL.Host
+	redirected.URL.Scheme = "http"
+	redirected.URL.Host = rt.api.server.Listener.Addr().String()
+	return rt.next.RoundTrip(redirected)
+}
+
+// SetLibrary replaces a provider's saved tracks
+func (a *FakeMusicAPI) SetLibrary(provider string, tracks []Track) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.libraries[provider] = append([]Track(nil), tracks...)
+}
+
+// Library returns a copy of a provider's saved tracks
+func (a *FakeMusicAPI) Library(provider string) []Track {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]Track(nil), a.libraries[provider]...)
+}
+
+// AddPlaylist adds a playlist to a provider; Deezer playlist IDs must be numeric
+func (a *FakeMusicAPI) AddPlaylist(provider, id, name string, tracks []Track) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.playlists[provider] = append(a.playlists[provider], fakePlaylist{id: id, name: name, tracks: tracks})
+}
+
+// SetHistory replaces a provider's recently played tracks
+func (a *FakeMusicAPI) SetHistory(provider string, tracks []Track) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.history[provider] = append([]Track(nil), tracks...)
+}
+
+// SetCatalog replaces the tracks search endpoints can find, on every provider
+func (a *FakeMusicAPI) SetCatalog(tracks []Track) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.catalog = append([]Track(nil), tracks...)
+}
+
+// FailNext makes the next requests to a path fail with the given statuses, one per request.
+// 429 and 503 responses carry a one second Retry-After.
+func (a *FakeMusicAPI) FailNext(path string, statuses ...int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures[path] = append(a.failures[path], statuses...)
+}
+
+// IssueCode makes the Spotify token endpoint require a verifier matching the PKCE challenge
+// when the authorization code is exchanged, as if the code had been issued for that challenge
+func (a *FakeMusicAPI) IssueCode(code, challenge string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.challenges[code] = challenge
+}
+
+// RevokeRefreshToken makes Spotify reject refreshes with the token as a revoked grant
+func (a *FakeMusicAPI) RevokeRefreshToken(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.revoked[token] = true
+}
+
+// SetDeezerPermissions limits the permissions Deezer reports as granted
+func (a *FakeMusicAPI) SetDeezerPermissions(permissions ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.deezerPermissions = permissions
+}
+
+// Requests returns the "METHOD host/path" of every request received
+func (a *FakeMusicAPI) Requests() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.requests...)
+}
+
+// record logs requests and applies injected failures before routing
+func (a *FakeMusicAPI) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.requests = append(a.requests, fmt.Sprintf("%s %s%s", r.Method, r.Host, r.URL.Path))
+		status := 0
+		if queued := a.failures[r.URL.Path]; len(queued) > 0 {
+			status, a.failures[r.URL.Path] = queued[0], queued[1:]
+		}
+		a.mu.Unlock()
+
+		if status != 0 {
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeJSON(w, status, map[string]any{
+				"error": map[string]any{"status": status, "message": http.StatusText(status)},
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the access token of a Spotify request
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func (a *FakeMusicAPI) spotifyRoutes(mux *http.ServeMux) {
+	// Spotify rejects calls without a bearer token with a 401
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if bearerToken(r) == "" {
+				writeJSON(w, http.StatusUnauthorized, map[string]any{
+					"error": map[string]any{"status": 401, "message": "No token provided"},
+				})
+				return
+			}
+			handler(w, r)
+		}
+	}
+
+	mux.HandleFunc("POST accounts.spotify.com/api/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Basic ") {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+			return
+		}
+
+		response := map[string]any{"token_type": "Bearer", "expires_in": 3600, "scope": "user-library-read user-library-modify"}
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			code := r.PostForm.Get("code")
+			if r.PostForm.Get("redirect_uri") == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "Invalid redirect URI"})
+				return
+			}
+			a.mu.Lock()
+			challenge, pkce := a.challenges[code]
+			a.mu.Unlock()
+			if pkce && services.PKCEChallenge(r.PostForm.Get("code_verifier")) != challenge {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "code_verifier was incorrect"})
+				return
+			}
+			response["access_token"] = "spotify-access-" + code
+			response["refresh_token"] = "spotify-refresh-" + code
+		case "refresh_token":
+			a.mu.Lock()
+			revoked := a.revoked[r.PostForm.Get("refresh_token")]
+			a.mu.Unlock()
+			if revoked {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "Refresh token revoked"})
+				return
+			}
+			response["access_token"] = fmt.Sprintf("spotify-access-%d", time.Now().UnixNano())
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "unsupported_grant_type"})
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	mux.HandleFunc("GET api.spotify.com/v1/browse/featured-playlists", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"playlists": map[string]any{"items": []any{}}})
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/me", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":           "fake-spotify-user",
+			"display_name": "Fake Spotify User",
+			"email":        "spotify-user@example.com",
+			"images":       []any{},
+		})
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/me/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spotifyPage(r, savedTracks(a.Library(Spotify), spotifyTrackJSON)))
+	}))
+
+	mux.HandleFunc("PUT api.spotify.com/v1/me/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		for id := range strings.SplitSeq(r.URL.Query().Get("ids"), ",") {
+			a.save(Spotify, id)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/me/playlists", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		var playlists []any
+		for _, playlist := range a.playlists[Spotify] {
+			playlists = append(playlists, map[string]any{
+				"id":     playlist.id,
+				"name":   playlist.name,
+				"tracks": map[string]any{"total": len(playlist.tracks)},
+			})
+		}
+		a.mu.Unlock()
+		writeJSON(w, http.StatusOK, spotifyPage(r, playlists))
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/playlists/{id}/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		playlist, ok := a.playlist(Spotify, r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": map[string]any{"status": 404, "message": "Not found."}})
+			return
+		}
+		writeJSON(w, http.StatusOK, spotifyPage(r, savedTracks(playlist.tracks, spotifyTrackJSON)))
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/me/player/recently-played", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		var items []any
+		for _, track := range a.history[Spotify] {
+			items = append(items, map[string]any{"played_at": playedAt(track), "track": spotifyTrackJSON(track)})
+		}
+		a.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	}))
+
+	mux.HandleFunc("GET api.spotify.com/v1/search", authorized(func(w http.ResponseWriter, r *http.Request) {
+		var items []any
+		if track, ok := a.search(spotifyQuery(r.URL.Query().Get("q"))); ok {
+			items = append(items, spotifyTrackJSON(track))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"tracks": map[string]any{"items": items, "total": len(items)}})
+	}))
+}
+
+func (a *FakeMusicAPI) deezerRoutes(mux *http.ServeMux) {
+	// Deezer reports a missing token inside a 200 response
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("access_token") == "" {
+				writeJSON(w, http.StatusOK, deezerError("OAuthException", "An active access token must be used to query information about the current user", 200))
+				return
+			}
+			handler(w, r)
+		}
+	}
+
+	mux.HandleFunc("GET connect.deezer.com/oauth/access_token.php", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("app_id") == "" || query.Get("secret") == "" || query.Get("code") == "" {
+			w.Write([]byte("wrong code"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("access_token=deezer-access-" + query.Get("code") + "&expires=3600"))
+	})
+
+	mux.HandleFunc("GET api.deezer.com/genre", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": []any{map[string]any{"id": 0, "name": "All"}}})
+	})
+
+	mux.HandleFunc("GET api.deezer.com/user/me", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":       1000001,
+			"name":     "fake-deezer-user",
+			"lastname": "User",
+			"email":    "deezer-user@example.com",
+		})
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/user/me/permissions", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		granted := a.deezerPermissions
+		a.mu.Unlock()
+		if granted == nil {
+			granted = []string{"basic_access", "email", "offline_access", "manage_library", "manage_community"}
+		}
+
+		permissions := make(map[string]bool)
+		for _, permission := range granted {
+			permissions[permission] = true
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"permissions": permissions})
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/user/me/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		var tracks []any
+		for _, track := range a.Library(Deezer) {
+			tracks = append(tracks, deezerTrackJSON(withAddedAt(track)))
+		}
+		writeJSON(w, http.StatusOK, deezerPage(r, tracks))
+	}))
+
+	mux.HandleFunc("POST api.deezer.com/user/me/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.save(Deezer, r.URL.Query().Get("track_id"))
+		writeJSON(w, http.StatusOK, true)
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/user/me/playlists", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		var playlists []any
+		for _, playlist := range a.playlists[Deezer] {
+			id, _ := strconv.ParseInt(playlist.id, 10, 64)
+			playlists = append(playlists, map[string]any{"id": id, "title": playlist.name, "nb_tracks": len(playlist.tracks)})
+		}
+		a.mu.Unlock()
+		writeJSON(w, http.StatusOK, deezerPage(r, playlists))
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/playlist/{id}/tracks", authorized(func(w http.ResponseWriter, r *http.Request) {
+		playlist, ok := a.playlist(Deezer, r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusOK, deezerError("DataException", "no data", 800))
+			return
+		}
+		var tracks []any
+		for _, track := range playlist.tracks {
+			tracks = append(tracks, deezerTrackJSON(track))
+		}
+		writeJSON(w, http.StatusOK, deezerPage(r, tracks))
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/user/me/flow", authorized(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		var tracks []any
+		for _, track := range a.history[Deezer] {
+			tracks = append(tracks, deezerTrackJSON(track))
+		}
+		a.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"data": tracks})
+	}))
+
+	mux.HandleFunc("GET api.deezer.com/search", func(w http.ResponseWriter, r *http.Request) {
+		var tracks []any
+		for _, track := range a.searchAll(r.URL.Query().Get("q")) {
+			tracks = append(tracks, deezerTrackJSON(track))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": tracks, "total": len(tracks)})
+	})
+
+	mux.HandleFunc("GET api.deezer.com/track/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		isrc, byISRC := strings.CutPrefix(r.PathValue("ref"), "isrc:")
+		query := Track{ID: r.PathValue("ref")}
+		if byISRC {
+			query = Track{ISRC: isrc}
+		}
+		track, ok := a.lookup(query)
+		if !ok {
+			writeJSON(w, http.StatusOK, deezerError("DataException", "no data", 800))
+			return
+		}
+		writeJSON(w, http.StatusOK, deezerTrackJSON(track))
+	})
+}
+
+// save adds a catalog track to a provider's library
+func (a *FakeMusicAPI) save(provider, id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, saved := range a.libraries[provider] {
+		if saved.ID == id {
+			return
+		}
+	}
+	track := Track{ID: id}
+	for _, known := range a.catalog {
+		if known.ID == id {
+			track = known
+			break
+		}
+	}
+	track.AddedAt = time.Now()
+	a.libraries[provider] = append(a.libraries[provider], track)
+}
+
+func (a *FakeMusicAPI) playlist(provider, id string) (fakePlaylist, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, playlist := range a.playlists[provider] {
+		if playlist.id == id {
+			return playlist, true
+		}
+	}
+	return fakePlaylist{}, false
+}
+
+// lookup finds a catalog track by ID or ISRC
+func (a *FakeMusicAPI) lookup(query Track) (Track, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, track := range a.catalog {
+		if (query.ID != "" && track.ID == query.ID) || (query.ISRC != "" && track.ISRC == query.ISRC) {
+			return track, true
+		}
+	}
+	return Track{}, false
+}
+
+// search finds the first catalog track matching a structured query
+func (a *FakeMusicAPI) search(query Track) (Track, bool) {
+	if query.ISRC != "" || query.ID != "" {
+		return a.lookup(query)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, track := range a.catalog {
+		if matches(track, query) {
+			return track, true
+		}
+	}
+	return Track{}, false
+}
+
+// searchAll finds catalog tracks whose title and artist both appear in a free-text query
+func (a *FakeMusicAPI) searchAll(q string) []Track {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	q = strings.ToLower(q)
+	var found []Track
+	for _, track := range a.catalog {
+		if strings.Contains(q, strings.ToLower(track.Title)) && strings.Contains(q, strings.ToLower(track.Artist)) {
+			found = append(found, track)
+		}
+	}
+	return found
+}
+
+// spotifyQuery parses the field filters of a Spotify search query, e.g. isrc:X or
+// track:"T" artist:"A"
+func spotifyQuery(q string) Track {
+	var query Track
+	for field, value := range spotifyFilters(q) {
+		switch field {
+		case "isrc":
+			query.ISRC = value
+		case "track":
+			query.Title = value
+		case "artist":
+			query.Artist = value
+		}
+	}
+	return query
+}
+
+func spotifyFilters(q string) map[string]string {
+	filters := make(map[string]string)
+	for q != "" {
+		q = strings.TrimSpace(q)
+		field, rest, ok := strings.Cut(q, ":")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		filters[field] = value
+		q = rest
+	}
+	return filters
+}
+
+// savedTracks wraps tracks in Spotify's saved track objects
+func savedTracks(tracks []Track, render func(Track) map[string]any) []any {
+	items := make([]any, 0, len(tracks))
+	for _, track := range tracks {
+		items = append(items, map[string]any{"added_at": playedAt(withAddedAt(track)), "track": render(track)})
+	}
+	return items
+}
+
+// spotifyPage slices items by the offset and limit parameters into a Spotify paging object
+func spotifyPage(r *http.Request, items []any) map[string]any {
+	page, more := paginate(r, "offset", items)
+	var next any
+	if more {
+		next = "https://api.spotify.com/next"
+	}
+	return map[string]any{"items": page, "total": len(items), "next": next}
+}
+
+// deezerPage slices items by the index and limit parameters into a Deezer listing
+func deezerPage(r *http.Request, items []any) map[string]any {
+	page, more := paginate(r, "index", items)
+	response := map[string]any{"data": page, "total": len(items)}
+	if more {
+		response["next"] = "https://api.deezer.com/next"
+	}
+	return response
+}
+
+func paginate(r *http.Request, offsetParam string, items []any) ([]any, bool) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get(offsetParam))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	offset = min(max(offset, 0), len(items))
+	end := min(offset+limit, len(items))
+	page := slices.Clone(items[offset:end])
+	if page == nil {
+		page = []any{}
+	}
+	return page, end < len(items)
+}
+
+func withAddedAt(track Track) Track {
+	if track.AddedAt.IsZero() {
+		track.AddedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return track
+}
+
+func playedAt(track Track) string {
+	return withAddedAt(track).AddedAt.UTC().Format(time.RFC3339)
+}
+
+func deezerError(errorType, message string, code int) map[string]any {
+	return map[string]any{"error": map[string]any{"type": errorType, "message": message, "code": code}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
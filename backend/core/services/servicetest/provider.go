@@ -0,0 +1,496 @@
+// Package servicetest provides in-memory and HTTP fakes of music providers so the sync engine,
+// the OAuth flow and the provider integrations can be exercised without network access.
+package servicetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"syncer.net/core/services"
+)
+
+// Track is a track held by a fake provider or a fake API library
+type Track struct {
+	ID         string
+	Title      string
+	Artist     string
+	Album      string
+	ISRC       string
+	DurationMs int
+	AddedAt    time.Time
+	ItemType   string // Sync item type reported by GetUserData, "saved_track" by default
+}
+
+// GetItemType implements the sync engine's UniversalItem interface
+func (t Track) GetItemType() string {
+	return "track"
+}
+
+// GetItemIdentifier implements the sync engine's UniversalItem interface
+func (t Track) GetItemIdentifier() string {
+	return t.ID
+}
+
+// GetItemAction implements the sync engine's UniversalItem interface
+func (t Track) GetItemAction() services.SyncAction {
+	return services.ActionCreate
+}
+
+// Operation names a FakeProvider call that errors can be injected into
+type Operation string
+
+const (
+	OpAuthURL        Operation = "auth_url"
+	OpExchangeCode   Operation = "exchange_code"
+	OpRefreshTokens  Operation = "refresh_tokens"
+	OpRevokeTokens   Operation = "revoke_tokens"
+	OpGetUserData    Operation = "get_user_data"
+	OpGetUserProfile Operation = "get_user_profile"
+	OpHealthCheck    Operation = "health_check"
+	OpSearchTrack    Operation = "search_track"
+	OpSaveTrack      Operation = "save_track"
+)
+
+// FakeConfig configures a FakeProvider
+type FakeConfig struct {
+	Name         string
+	DisplayName  string // Defaults to Name
+	Category     services.ServiceCategory
+	Scopes       []string
+	Capabilities services.Capabilities // Defaults to favorites read and write plus ISRC search
+
+	CapabilityScopes map[services.Capability][]string
+	GrantedScopes    []string // Scopes issued tokens carry; defaults to Scopes
+
+	Library []Track       // The connected user's saved tracks
+	Catalog []Track       // Tracks that can be found by search; the library is always searchable
+	Latency time.Duration // Added to every call
+
+	// Calls allowed per second before RateLimitedError is returned. Zero means unlimited.
+	RequestsPerSecond int
+}
+
+// FakeProvider is an in-memory ServiceProvider. Named "spotify" or "deezer", it reports tracks in
+// that provider's API shape so the music transformer handles them like real data.
+type FakeProvider struct {
+	mu       sync.Mutex
+	config   FakeConfig
+	library  []Track
+	catalog  []Track
+	errors   map[Operation]error
+	failNext map[Operation][]error
+	calls    map[Operation]int
+
+	// The redirect URL and PKCE challenge of the last authorization, checked on exchange
+	authRedirectURL string
+	authChallenge   string
+
+	windowStart time.Time
+	windowCalls int
+}
+
+var (
+	_ services.ServiceProvider       = (*FakeProvider)(nil)
+	_ services.TokenRevoker          = (*FakeProvider)(nil)
+	_ services.IncrementalAuthorizer = (*FakeProvider)(nil)
+)
+
+// NewFakeProvider creates a fake provider from the configuration
+func NewFakeProvider(config FakeConfig) *FakeProvider {
+	if config.DisplayName == "" {
+		config.DisplayName = config.Name
+	}
+	if config.Category == "" {
+		config.Category = services.CategoryMusic
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"library"}
+	}
+	if config.GrantedScopes == nil {
+		config.GrantedScopes = config.Scopes
+	}
+	if config.Capabilities == nil {
+		config.Capabilities = services.Capabilities{
+			services.CapabilityReadFavorites,
+			services.CapabilityWriteFavorites,
+			services.CapabilitySearchISRC,
+		}
+	}
+
+	return &FakeProvider{
+		config:   config,
+		library:  append([]Track(nil), config.Library...),
+		catalog:  append([]Track(nil), config.Catalog...),
+		errors:   make(map[Operation]error),
+		failNext: make(map[Operation][]error),
+		calls:    make(map[Operation]int),
+	}
+}
+
+// SetError makes every call of an operation fail with err until it is cleared with nil
+func (f *FakeProvider) SetError(op Operation, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.errors, op)
+		return
+	}
+	f.errors[op] = err
+}
+
+// FailNext makes the next calls of an operation fail with the given errors, one per call
+func (f *FakeProvider) FailNext(op Operation, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failNext[op] = append(f.failNext[op], errs...)
+}
+
+// Calls returns how many times an operation was called, including failed calls
+func (f *FakeProvider) Calls(op Operation) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+// Library returns a copy of the user's saved tracks
+func (f *FakeProvider) Library() []Track {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Track(nil), f.library...)
+}
+
+// call records an operation and applies the configured latency, rate limit and errors
+func (f *FakeProvider) call(ctx context.Context, op Operation) error {
+	f.mu.Lock()
+	f.calls[op]++
+	latency := f.config.Latency
+
+	var err error
+	if queued := f.failNext[op]; len(queued) > 0 {
+		err, f.failNext[op] = queued[0], queued[1:]
+	} else if f.errors[op] != nil {
+		err = f.errors[op]
+	} else {
+		err = f.takeQuota()
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// takeQuota counts a call against the per-second limit. Callers hold f.mu.
+func (f *FakeProvider) takeQuota() error {
+	if f.config.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(f.windowStart) >= time.Second {
+		f.windowStart, f.windowCalls = now, 0
+	}
+	if f.windowCalls >= f.config.RequestsPerSecond {
+		return &services.RateLimitedError{
+			Provider: f.config.Name,
+			Scope:    services.RateLimitScopeApp,
+			RetryAt:  f.windowStart.Add(time.Second),
+		}
+	}
+	f.windowCalls++
+	return nil
+}
+
+func (f *FakeProvider) Name() string {
+	return f.config.Name
+}
+
+func (f *FakeProvider) DisplayName() string {
+	return f.config.DisplayName
+}
+
+func (f *FakeProvider) Category() services.ServiceCategory {
+	return f.config.Category
+}
+
+func (f *FakeProvider) RequiredScopes() []string {
+	return f.config.Scopes
+}
+
+func (f *FakeProvider) Capabilities() services.Capabilities {
+	return f.config.Capabilities
+}
+
+func (f *FakeProvider) CapabilityScopes() map[services.Capability][]string {
+	return f.config.CapabilityScopes
+}
+
+// GetAuthURL returns a URL on a fake authorization server carrying the state, redirect URL and
+// PKCE challenge
+func (f *FakeProvider) GetAuthURL(state string, redirectURL string, codeChallenge string) (string, error) {
+	return f.GetAuthURLForScopes(state, redirectURL, codeChallenge, f.config.Scopes)
+}
+
+// GetAuthURLForScopes is GetAuthURL requesting only the given scopes
+func (f *FakeProvider) GetAuthURLForScopes(state string, redirectURL string, codeChallenge string, scopes []string) (string, error) {
+	if err := f.call(context.Background(), OpAuthURL); err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	f.authRedirectURL, f.authChallenge = redirectURL, codeChallenge
+	f.mu.Unlock()
+
+	params := url.Values{
+		"state":        {state},
+		"redirect_uri": {redirectURL},
+		"scope":        {strings.Join(scopes, " ")},
+	}
+	if codeChallenge != "" {
+		params.Set("code_challenge_method", "S256")
+		params.Set("code_challenge", codeChallenge)
+	}
+	return fmt.Sprintf("https://%s.auth.test/authorize?%s", f.config.Name, params.Encode()), nil
+}
+
+// ExchangeCode issues tokens derived from the code. Like real providers it rejects a redirect URL
+// or PKCE verifier that does not match the last authorization.
+func (f *FakeProvider) ExchangeCode(code string, redirectURL string, codeVerifier string) (*services.OAuthTokens, error) {
+	if err := f.call(context.Background(), OpExchangeCode); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	authRedirectURL, authChallenge := f.authRedirectURL, f.authChallenge
+	f.mu.Unlock()
+
+	if authRedirectURL != "" && redirectURL != authRedirectURL {
+		return nil, fmt.Errorf("invalid_grant: redirect_uri %q does not match %q", redirectURL, authRedirectURL)
+	}
+	if authChallenge != "" && services.PKCEChallenge(codeVerifier) != authChallenge {
+		return nil, fmt.Errorf("invalid_grant: code_verifier does not match the code challenge")
+	}
+	return f.issueTokens("access-"+code, "refresh-"+code), nil
+}
+
+// RefreshTokens issues a new access token for the refresh token
+func (f *FakeProvider) RefreshTokens(refreshToken string) (*services.OAuthTokens, error) {
+	if err := f.call(context.Background(), OpRefreshTokens); err != nil {
+		return nil, err
+	}
+	return f.issueTokens(fmt.Sprintf("access-%d", time.Now().UnixNano()), refreshToken), nil
+}
+
+// RevokeTokens revokes the tokens at the fake provider
+func (f *FakeProvider) RevokeTokens(ctx context.Context, tokens *services.OAuthTokens) error {
+	if tokens == nil || (tokens.AccessToken == "" && tokens.RefreshToken == "") {
+		return fmt.Errorf("no token to revoke")
+	}
+	return f.call(ctx, OpRevokeTokens)
+}
+
+func (f *FakeProvider) issueTokens(accessToken, refreshToken string) *services.OAuthTokens {
+	return &services.OAuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Scope:        strings.Join(f.config.GrantedScopes, " "),
+	}
+}
+
+// ValidateTokens rejects missing and expired tokens
+func (f *FakeProvider) ValidateTokens(tokens *services.OAuthTokens) (bool, error) {
+	if tokens == nil || tokens.AccessToken == "" {
+		return false, fmt.Errorf("access token is required")
+	}
+	if !tokens.ExpiresAt.IsZero() && time.Now().After(tokens.ExpiresAt) {
+		return false, fmt.Errorf("access token is expired")
+	}
+	return true, nil
+}
+
+// GetUserData returns the library tracks added after lastSync
+func (f *FakeProvider) GetUserData(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) (*services.UserDataResult, error) {
+	if valid, err := f.ValidateTokens(tokens); !valid {
+		return nil, fmt.Errorf("invalid tokens: %w", err)
+	}
+	if err := f.call(ctx, OpGetUserData); err != nil {
+		return nil, err
+	}
+
+	var items []services.SyncItem
+	for _, track := range f.Library() {
+		if !track.AddedAt.IsZero() && !track.AddedAt.After(lastSync) {
+			continue
+		}
+		itemType := track.ItemType
+		if itemType == "" {
+			itemType = "saved_track"
+		}
+		items = append(items, services.SyncItem{
+			ExternalID: track.ID,
+			ItemType:   itemType,
+			Action:     services.ActionCreate,
+			Data:       f.trackData(track),
+		})
+	}
+
+	return &services.UserDataResult{
+		Success:  true,
+		Items:    items,
+		Metadata: map[string]any{"service": f.config.Name, "items_synced": len(items)},
+	}, nil
+}
+
+// trackData reports a track the way the provider of the same name does
+func (f *FakeProvider) trackData(track Track) any {
+	switch f.config.Name {
+	case "spotify":
+		return spotifyTrackJSON(track)
+	case "deezer":
+		return deezerTrackJSON(track)
+	default:
+		return track
+	}
+}
+
+// GetUserProfile returns a profile derived from the access token
+func (f *FakeProvider) GetUserProfile(ctx context.Context, tokens *services.OAuthTokens) (*services.UserProfile, error) {
+	if valid, err := f.ValidateTokens(tokens); !valid {
+		return nil, fmt.Errorf("invalid tokens: %w", err)
+	}
+	if err := f.call(ctx, OpGetUserProfile); err != nil {
+		return nil, err
+	}
+
+	return &services.UserProfile{
+		ExternalID:  f.config.Name + "-user",
+		Username:    f.config.Name + "-user",
+		DisplayName: "Fake " + f.config.DisplayName + " User",
+		Metadata:    map[string]any{},
+	}, nil
+}
+
+// HealthCheck fails only when an error is injected
+func (f *FakeProvider) HealthCheck(ctx context.Context) error {
+	return f.call(ctx, OpHealthCheck)
+}
+
+// GetRateLimit reports the configured per-second limit
+func (f *FakeProvider) GetRateLimit() *services.RateLimit {
+	return &services.RateLimit{
+		RequestsPerSecond: f.config.RequestsPerSecond,
+		BurstSize:         f.config.RequestsPerSecond,
+		ThrottleScope:     services.RateLimitScopeApp,
+		ResetWindow:       time.Second,
+	}
+}
+
+// SearchTrack finds a track in the catalog or library by ISRC, or by title and artist
+func (f *FakeProvider) SearchTrack(ctx context.Context, tokens *services.OAuthTokens, query Track) (*Track, error) {
+	if valid, err := f.ValidateTokens(tokens); !valid {
+		return nil, fmt.Errorf("invalid tokens: %w", err)
+	}
+	if err := f.call(ctx, OpSearchTrack); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, candidates := range [][]Track{f.catalog, f.library} {
+		for _, track := range candidates {
+			if matches(track, query) {
+				return &track, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("track %q by %q not found on %s", query.Title, query.Artist, f.config.Name)
+}
+
+// SaveTrack adds a track to the user's library; saving a track twice is a no-op
+func (f *FakeProvider) SaveTrack(ctx context.Context, tokens *services.OAuthTokens, track Track) error {
+	if valid, err := f.ValidateTokens(tokens); !valid {
+		return fmt.Errorf("invalid tokens: %w", err)
+	}
+	if err := f.call(ctx, OpSaveTrack); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, saved := range f.library {
+		if saved.ID == track.ID {
+			return nil
+		}
+	}
+	if track.AddedAt.IsZero() {
+		track.AddedAt = time.Now()
+	}
+	f.library = append(f.library, track)
+	return nil
+}
+
+// matches compares by ISRC when both tracks have one, otherwise by title and artist
+func matches(track, query Track) bool {
+	if track.ISRC != "" && query.ISRC != "" {
+		return track.ISRC == query.ISRC
+	}
+	return strings.EqualFold(track.Title, query.Title) && strings.EqualFold(track.Artist, query.Artist)
+}
+
+// spotifyTrackJSON renders a track as a Spotify Web API track object
+func spotifyTrackJSON(track Track) map[string]any {
+	return map[string]any{
+		"id":   track.ID,
+		"name": track.Title,
+		"uri":  "spotify:track:" + track.ID,
+		"artists": []any{
+			map[string]any{"id": slug(track.Artist), "name": track.Artist},
+		},
+		"album":        map[string]any{"id": slug(track.Album), "name": track.Album},
+		"duration_ms":  track.DurationMs,
+		"external_ids": map[string]any{"isrc": track.ISRC},
+	}
+}
+
+// deezerTrackJSON renders a track as a Deezer API track object. Deezer IDs are numeric, so
+// tracks meant for Deezer need numeric IDs.
+func deezerTrackJSON(track Track) map[string]any {
+	id, _ := strconv.ParseInt(track.ID, 10, 64)
+	data := map[string]any{
+		"id":       id,
+		"title":    track.Title,
+		"duration": track.DurationMs / 1000,
+		"isrc":     track.ISRC,
+		"artist":   map[string]any{"id": 1, "name": track.Artist},
+		"album":    map[string]any{"id": 1, "title": track.Album},
+	}
+	if !track.AddedAt.IsZero() {
+		data["time_add"] = track.AddedAt.Unix()
+	}
+	return data
+}
+
+// slug derives a stable identifier from a name
+func slug(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "-")
+}
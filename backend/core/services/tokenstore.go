@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"syncer.net/core/security"
+)
+
+// TokenReadPurpose says why a connection's tokens were read, as recorded in the audit log
+type TokenReadPurpose string
+
+const (
+	TokenReadSync      TokenReadPurpose = "sync"      // Calling the provider during a sync
+	TokenReadRefresh   TokenReadPurpose = "refresh"   // Exchanging the refresh token
+	TokenReadRevoke    TokenReadPurpose = "revoke"    // Revoking the tokens on disconnect
+	TokenReadReencrypt TokenReadPurpose = "reencrypt" // Moving the tokens to the current key
+)
+
+var (
+	// ErrNoTokens is returned when a connection has no stored tokens
+	ErrNoTokens = errors.New("connection has no stored tokens")
+	// ErrTokensChanged is returned when tokens were replaced since the version an update started from
+	ErrTokensChanged = errors.New("stored tokens changed since they were read")
+)
+
+// validConnectionID reports whether an id can name a connection. Ids from requests are checked
+// before they reach a query comparing them with a uuid column.
+func validConnectionID(connectionID string) bool {
+	_, err := uuid.Parse(connectionID)
+	return err == nil
+}
+
+// StoredTokens are a connection's decrypted tokens with the version they were stored as
+type StoredTokens struct {
+	OAuthTokens
+	Version int64
+	KeyRef  string // Key the tokens are encrypted under
+}
+
+// TokenRead is an audited read of a connection's tokens
+type TokenRead struct {
+	ConnectionID string           `json:"connection_id" db:"user_service_id"`
+	Version      int64            `json:"version" db:"version"`
+	Purpose      TokenReadPurpose `json:"purpose" db:"purpose"`
+	ReadAt       time.Time        `json:"read_at" db:"read_at"`
+}
+
+// TokenReencryption is the outcome of re-encrypting one batch of stored tokens
+type TokenReencryption struct {
+	Moved  int    // Connections whose tokens were re-encrypted
+	Failed int    // Connections whose tokens could not be decrypted and were skipped
+	Last   string // Connection id the next batch starts after; empty when none were left
+}
+
+// TokenStore keeps the OAuth tokens of connections, encrypted and bound to their connection.
+// Every write of new tokens creates a new version, and an update only applies to the version it
+// was read at, so of two concurrent refreshes the later one cannot overwrite the earlier one's
+// tokens. Re-encryption keeps the version. Reads are audited with their purpose.
+type TokenStore interface {
+	// Get decrypts a connection's tokens and records the read
+	Get(ctx context.Context, connectionID string, purpose TokenReadPurpose) (*StoredTokens, error)
+	// Put replaces a connection's tokens, as after an authorization, and returns the new version
+	Put(ctx context.Context, connectionID string, tokens *OAuthTokens) (int64, error)
+	// Update replaces tokens read at the given version and returns the new version, or
+	// ErrTokensChanged when they were replaced since. An empty scope keeps the stored one.
+	Update(ctx context.Context, connectionID string, version int64, tokens *OAuthTokens) (int64, error)
+	// Delete removes a connection's tokens
+	Delete(ctx context.Context, connectionID string) error
+	// Reencrypt moves up to limit connections after the given id, in id order, to the current key
+	Reencrypt(ctx context.Context, after string, limit int) (TokenReencryption, error)
+}
+
+// txTokenStore is implemented by token stores that can write within a database transaction
+type txTokenStore interface {
+	PutTx(ctx context.Context, tx *sqlx.Tx, connectionID string, tokens *OAuthTokens) (int64, error)
+}
+
+// MemoryTokenStore keeps encrypted tokens in process memory
+type MemoryTokenStore struct {
+	encryption *security.TokenEncryption
+
+	mu     sync.Mutex
+	tokens map[string]memoryTokens
+	reads  []TokenRead
+}
+
+type memoryTokens struct {
+	access    []byte
+	refresh   []byte
+	tokenType string
+	expiresAt time.Time
+	scope     string
+	keyRef    string
+	version   int64
+}
+
+// NewMemoryTokenStore creates an empty in-process token store
+func NewMemoryTokenStore(encryption *security.TokenEncryption) *MemoryTokenStore {
+	return &MemoryTokenStore{
+		encryption: encryption,
+		tokens:     make(map[string]memoryTokens),
+	}
+}
+
+// Get decrypts a connection's tokens and records the read
+func (s *MemoryTokenStore) Get(ctx context.Context, connectionID string, purpose TokenReadPurpose) (*StoredTokens, error) {
+	s.mu.Lock()
+	stored, ok := s.tokens[connectionID]
+	if ok {
+		s.reads = append(s.reads, TokenRead{
+			ConnectionID: connectionID,
+			Version:      stored.version,
+			Purpose:      purpose,
+			ReadAt:       time.Now(),
+		})
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrNoTokens
+	}
+
+	accessToken, refreshToken, err := s.encryption.DecryptTokens(connectionID, stored.access, stored.refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredTokens{
+		OAuthTokens: OAuthTokens{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    stored.tokenType,
+			ExpiresAt:    stored.expiresAt,
+			Scope:        stored.scope,
+		},
+		Version: stored.version,
+		KeyRef:  stored.keyRef,
+	}, nil
+}
+
+// Put replaces a connection's tokens and returns the new version
+func (s *MemoryTokenStore) Put(ctx context.Context, connectionID string, tokens *OAuthTokens) (int64, error) {
+	sealed, err := s.seal(connectionID, tokens)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sealed.version = s.tokens[connectionID].version + 1
+	s.tokens[connectionID] = sealed
+	return sealed.version, nil
+}
+
+// Update replaces tokens read at the given version and returns the new version
+func (s *MemoryTokenStore) Update(ctx context.Context, connectionID string, version int64, tokens *OAuthTokens) (int64, error) {
+	sealed, err := s.seal(connectionID, tokens)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[connectionID]
+	if !ok {
+		return 0, ErrNoTokens
+	}
+	if stored.version != version {
+		return 0, ErrTokensChanged
+	}
+
+	if sealed.scope == "" {
+		sealed.scope = stored.scope
+	}
+	sealed.version = version + 1
+	s.tokens[connectionID] = sealed
+	return sealed.version, nil
+}
+
+// Delete removes a connection's tokens
+func (s *MemoryTokenStore) Delete(ctx context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, connectionID)
+	return nil
+}
+
+// Reencrypt moves up to limit connections after the given id to the current key
+func (s *MemoryTokenStore) Reencrypt(ctx context.Context, after string, limit int) (TokenReencryption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for id, stored := range s.tokens {
+		if id > after && (s.encryption.NeedsRotation(stored.access) || s.encryption.NeedsRotation(stored.refresh)) {
+			due = append(due, id)
+		}
+	}
+	slices.Sort(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var result TokenReencryption
+	for _, id := range due {
+		stored := s.tokens[id]
+		access, refresh, err := reencryptTokens(s.encryption, id, stored.access, stored.refresh)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		s.reads = append(s.reads, TokenRead{ConnectionID: id, Version: stored.version, Purpose: TokenReadReencrypt, ReadAt: time.Now()})
+		stored.access, stored.refresh = access, refresh
+		stored.keyRef = s.encryption.CurrentKeyRef()
+		s.tokens[id] = stored
+		result.Moved++
+	}
+	if len(due) > 0 {
+		result.Last = due[len(due)-1]
+	}
+	return result, nil
+}
+
+// Reads returns the audited reads of a connection's tokens, oldest first
+func (s *MemoryTokenStore) Reads(connectionID string) []TokenRead {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reads []TokenRead
+	for _, read := range s.reads {
+		if read.ConnectionID == connectionID {
+			reads = append(reads, read)
+		}
+	}
+	return reads
+}
+
+// seal encrypts tokens for storage without assigning a version
+func (s *MemoryTokenStore) seal(connectionID string, tokens *OAuthTokens) (memoryTokens, error) {
+	access, refresh, err := s.encryption.EncryptTokens(connectionID, tokens.AccessToken, tokens.RefreshToken)
+	if err != nil {
+		return memoryTokens{}, fmt.Errorf("failed to encrypt tokens: %w", err)
+	}
+	return memoryTokens{
+		access:    access,
+		refresh:   refresh,
+		tokenType: tokenType(tokens),
+		expiresAt: tokens.ExpiresAt,
+		scope:     tokens.Scope,
+		keyRef:    s.encryption.CurrentKeyRef(),
+	}, nil
+}
+
+// reencryptTokens moves both ciphertexts of a connection to the current key
+func reencryptTokens(encryption *security.TokenEncryption, connectionID string, access, refresh []byte) ([]byte, []byte, error) {
+	access, err := encryption.Reencrypt(access,
+		security.Binding{RecordID: connectionID, Field: security.AccessTokenField})
+	if err != nil {
+		return nil, nil, fmt.Errorf("access token: %w", err)
+	}
+	refresh, err = encryption.Reencrypt(refresh,
+		security.Binding{RecordID: connectionID, Field: security.RefreshTokenField})
+	if err != nil {
+		return nil, nil, fmt.Errorf("refresh token: %w", err)
+	}
+	return access, refresh, nil
+}
+
+// tokenType returns the token type to store, Bearer unless the provider named another
+func tokenType(tokens *OAuthTokens) string {
+	if tokens.TokenType == "" {
+		return "Bearer"
+	}
+	return tokens.TokenType
+}
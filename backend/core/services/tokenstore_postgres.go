@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"syncer.net/core/logging"
+	"syncer.net/core/security"
+)
+
+var (
+	_ TokenStore   = (*PostgresTokenStore)(nil)
+	_ txTokenStore = (*PostgresTokenStore)(nil)
+)
+
+// PostgresTokenStore keeps tokens in the connection_tokens table, one row per connection, and
+// audits reads in token_reads
+type PostgresTokenStore struct {
+	db         *sqlx.DB
+	encryption *security.TokenEncryption
+	logger     *slog.Logger
+}
+
+// NewPostgresTokenStore creates a token store backed by the connection_tokens table
+func NewPostgresTokenStore(db *sqlx.DB, encryption *security.TokenEncryption, logger *slog.Logger) *PostgresTokenStore {
+	return &PostgresTokenStore{
+		db:         db,
+		encryption: encryption,
+		logger:     logging.Component(logger, "token_store"),
+	}
+}
+
+// tokenRow is a row of connection_tokens
+type tokenRow struct {
+	ConnectionID string     `db:"user_service_id"`
+	AccessToken  []byte     `db:"access_token"`
+	RefreshToken []byte     `db:"refresh_token"`
+	TokenType    string     `db:"token_type"`
+	ExpiresAt    *time.Time `db:"expires_at"`
+	Scopes       string     `db:"scopes"`
+	KeyRef       string     `db:"key_ref"`
+	Version      int64      `db:"version"`
+}
+
+// Get decrypts a connection's tokens. The read is recorded in the same statement that loads
+// them, so no tokens leave the database unaudited.
+func (s *PostgresTokenStore) Get(ctx context.Context, connectionID string, purpose TokenReadPurpose) (*StoredTokens, error) {
+	if !validConnectionID(connectionID) {
+		return nil, ErrNoTokens
+	}
+
+	var row tokenRow
+	err := s.db.GetContext(ctx, &row, `
+		WITH token AS (
+			SELECT user_service_id::text AS user_service_id, access_token, refresh_token, token_type,
+				expires_at, scopes, COALESCE(key_ref, '') AS key_ref, version
+			FROM connection_tokens
+			WHERE user_service_id = $1::uuid
+		), audit AS (
+			INSERT INTO token_reads (user_service_id, version, purpose)
+			SELECT $1::uuid, version, $2 FROM token
+		)
+		SELECT * FROM token
+	`, connectionID, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoTokens
+		}
+		return nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+
+	accessToken, refreshToken, err := s.encryption.DecryptTokens(connectionID, row.AccessToken, row.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &StoredTokens{
+		OAuthTokens: OAuthTokens{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    row.TokenType,
+			Scope:        row.Scopes,
+		},
+		Version: row.Version,
+		KeyRef:  row.KeyRef,
+	}
+	if row.ExpiresAt != nil {
+		tokens.ExpiresAt = *row.ExpiresAt
+	}
+	return tokens, nil
+}
+
+// Put replaces a connection's tokens and returns the new version
+func (s *PostgresTokenStore) Put(ctx context.Context, connectionID string, tokens *OAuthTokens) (int64, error) {
+	return s.put(ctx, s.db, connectionID, tokens)
+}
+
+// PutTx replaces a connection's tokens within a transaction, so they are stored together with
+// the connection they belong to
+func (s *PostgresTokenStore) PutTx(ctx context.Context, tx *sqlx.Tx, connectionID string, tokens *OAuthTokens) (int64, error) {
+	return s.put(ctx, tx, connectionID, tokens)
+}
+
+func (s *PostgresTokenStore) put(ctx context.Context, q sqlx.QueryerContext, connectionID string, tokens *OAuthTokens) (int64, error) {
+	if !validConnectionID(connectionID) {
+		return 0, ErrNotConnected
+	}
+
+	access, refresh, err := s.encryption.EncryptTokens(connectionID, tokens.AccessToken, tokens.RefreshToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt tokens: %w", err)
+	}
+
+	var version int64
+	err = sqlx.GetContext(ctx, q, &version, `
+		INSERT INTO connection_tokens (
+			user_service_id, access_token, refresh_token, token_type, expires_at, scopes, key_ref, bound
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE)
+		ON CONFLICT (user_service_id) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = EXCLUDED.refresh_token,
+			token_type = EXCLUDED.token_type,
+			expires_at = EXCLUDED.expires_at,
+			scopes = EXCLUDED.scopes,
+			key_ref = EXCLUDED.key_ref,
+			bound = TRUE,
+			version = connection_tokens.version + 1,
+			updated_at = NOW()
+		RETURNING version
+	`, connectionID, access, refresh, tokenType(tokens), expiresAt(tokens), tokens.Scope,
+		s.encryption.CurrentKeyRef())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store tokens: %w", err)
+	}
+	return version, nil
+}
+
+// Update replaces tokens read at the given version and returns the new version
+func (s *PostgresTokenStore) Update(ctx context.Context, connectionID string, version int64, tokens *OAuthTokens) (int64, error) {
+	if !validConnectionID(connectionID) {
+		return 0, ErrNoTokens
+	}
+
+	access, refresh, err := s.encryption.EncryptTokens(connectionID, tokens.AccessToken, tokens.RefreshToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt tokens: %w", err)
+	}
+
+	var updated int64
+	err = s.db.GetContext(ctx, &updated, `
+		UPDATE connection_tokens SET
+			access_token = $3,
+			refresh_token = $4,
+			token_type = $5,
+			expires_at = $6,
+			scopes = COALESCE(NULLIF($7, ''), scopes),
+			key_ref = $8,
+			bound = TRUE,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE user_service_id = $1::uuid AND version = $2
+		RETURNING version
+	`, connectionID, version, access, refresh, tokenType(tokens), expiresAt(tokens), tokens.Scope,
+		s.encryption.CurrentKeyRef())
+	if err == nil {
+		return updated, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to update tokens: %w", err)
+	}
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, `
+		SELECT EXISTS (SELECT 1 FROM connection_tokens WHERE user_service_id = $1::uuid)
+	`, connectionID); err != nil {
+		return 0, fmt.Errorf("failed to check tokens: %w", err)
+	}
+	if !exists {
+		return 0, ErrNoTokens
+	}
+	return 0, ErrTokensChanged
+}
+
+// Delete removes a connection's tokens. Removing the connection also removes them.
+func (s *PostgresTokenStore) Delete(ctx context.Context, connectionID string) error {
+	// A malformed id names no connection, so there is nothing to delete
+	if !validConnectionID(connectionID) {
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM connection_tokens WHERE user_service_id = $1::uuid", connectionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tokens: %w", err)
+	}
+	return nil
+}
+
+// Reencrypt moves one batch of connections to the current key in a single transaction. The rows
+// stay locked until it commits, so a concurrent update waits and then applies as usual. Rows
+// that fail to decrypt are logged and skipped, so one bad row does not stall the job.
+func (s *PostgresTokenStore) Reencrypt(ctx context.Context, after string, limit int) (TokenReencryption, error) {
+	current := s.encryption.CurrentKeyRef()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return TokenReencryption{}, fmt.Errorf("failed to begin re-encryption: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rows []tokenRow
+	err = tx.SelectContext(ctx, &rows, `
+		SELECT user_service_id::text AS user_service_id, access_token, refresh_token, version
+		FROM connection_tokens
+		WHERE (key_ref IS DISTINCT FROM $1 OR NOT bound)
+			AND (access_token IS NOT NULL OR refresh_token IS NOT NULL)
+			AND ($2 = '' OR user_service_id > $2::uuid)
+		ORDER BY user_service_id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, current, after, limit)
+	if err != nil {
+		return TokenReencryption{}, fmt.Errorf("failed to load tokens to re-encrypt: %w", err)
+	}
+	if len(rows) == 0 {
+		return TokenReencryption{}, nil
+	}
+
+	result := TokenReencryption{Last: rows[len(rows)-1].ConnectionID}
+	for _, row := range rows {
+		access, refresh, err := reencryptTokens(s.encryption, row.ConnectionID, row.AccessToken, row.RefreshToken)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to re-encrypt tokens", "connection_id", row.ConnectionID, "error", err)
+			result.Failed++
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			WITH audit AS (
+				INSERT INTO token_reads (user_service_id, version, purpose) VALUES ($1::uuid, $5, $6)
+			)
+			UPDATE connection_tokens SET
+				access_token = $2, refresh_token = $3, key_ref = $4, bound = TRUE, updated_at = NOW()
+			WHERE user_service_id = $1::uuid
+		`, row.ConnectionID, access, refresh, current, row.Version, TokenReadReencrypt)
+		if err != nil {
+			return TokenReencryption{}, fmt.Errorf("failed to store re-encrypted tokens: %w", err)
+		}
+		result.Moved++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenReencryption{}, fmt.Errorf("failed to commit re-encryption: %w", err)
+	}
+	return result, nil
+}
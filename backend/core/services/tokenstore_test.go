@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"syncer.net/core/security"
+	"syncer.net/core/services"
+)
+
+const testConnection = "7e3a9c1d-4b2f-4e8a-9d6c-1f0b5a2e7c34"
+
+func TestMemoryTokenStoreVersions(t *testing.T) {
+	ctx := context.Background()
+	encryption, _ := security.NewTokenEncryption([32]byte{1})
+	store := services.NewMemoryTokenStore(encryption)
+
+	if _, err := store.Get(ctx, testConnection, services.TokenReadSync); !errors.Is(err, services.ErrNoTokens) {
+		t.Fatalf("Get before Put = %v, want ErrNoTokens", err)
+	}
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	version, err := store.Put(ctx, testConnection, &services.OAuthTokens{
+		AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: expiry, Scope: "library-read",
+	})
+	if err != nil || version != 1 {
+		t.Fatalf("Put = %d, %v; want version 1", version, err)
+	}
+
+	stored, err := store.Get(ctx, testConnection, services.TokenReadRefresh)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if stored.AccessToken != "access-1" || stored.RefreshToken != "refresh-1" || stored.TokenType != "Bearer" ||
+		!stored.ExpiresAt.Equal(expiry) || stored.Version != 1 || stored.KeyRef != "keyring/1" {
+		t.Errorf("Get = %+v", stored)
+	}
+
+	// Two refreshes start from version 1; only the first is stored
+	first, err := store.Update(ctx, testConnection, stored.Version, &services.OAuthTokens{
+		AccessToken: "access-2", RefreshToken: "refresh-2",
+	})
+	if err != nil || first != 2 {
+		t.Fatalf("first Update = %d, %v; want version 2", first, err)
+	}
+	if _, err := store.Update(ctx, testConnection, stored.Version, &services.OAuthTokens{
+		AccessToken: "access-stale", RefreshToken: "refresh-stale",
+	}); !errors.Is(err, services.ErrTokensChanged) {
+		t.Fatalf("second Update = %v, want ErrTokensChanged", err)
+	}
+
+	refreshed, _ := store.Get(ctx, testConnection, services.TokenReadSync)
+	if refreshed.AccessToken != "access-2" || refreshed.Scope != "library-read" {
+		t.Errorf("after refresh = %+v, want access-2 keeping the earlier scope", refreshed)
+	}
+
+	reads := store.Reads(testConnection)
+	if len(reads) != 2 || reads[0].Purpose != services.TokenReadRefresh || reads[0].Version != 1 ||
+		reads[1].Purpose != services.TokenReadSync || reads[1].Version != 2 {
+		t.Errorf("audited reads = %+v", reads)
+	}
+
+	if err := store.Delete(ctx, testConnection); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Update(ctx, testConnection, first, &services.OAuthTokens{AccessToken: "access-3"}); !errors.Is(err, services.ErrNoTokens) {
+		t.Errorf("Update after Delete = %v, want ErrNoTokens", err)
+	}
+}
+
+func TestMemoryTokenStoreReencrypt(t *testing.T) {
+	ctx := context.Background()
+	kms, _ := security.NewSoftwareKMS()
+	encryption, _ := security.NewEnvelopeEncryption(kms, nil)
+	store := services.NewMemoryTokenStore(encryption)
+
+	connections := []string{
+		"1c6f2a8e-0d3b-4f7a-8e5c-2b9d4a1f6e03",
+		"5a2d8f1c-7e4b-4a9d-b3c6-0f8e2d5a1b97",
+		"9b4e1f7a-3c8d-4e2b-a6f5-8d1c0e3b7a52",
+	}
+	for _, id := range connections {
+		if _, err := store.Put(ctx, id, &services.OAuthTokens{AccessToken: "access-" + id, RefreshToken: "refresh-" + id}); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// Batches of two continue after the last connection of the previous batch
+	first, err := store.Reencrypt(ctx, "", 2)
+	if err != nil || first.Moved != 2 || first.Last != connections[1] {
+		t.Fatalf("first batch = %+v, %v", first, err)
+	}
+	second, err := store.Reencrypt(ctx, first.Last, 2)
+	if err != nil || second.Moved != 1 || second.Last != connections[2] {
+		t.Fatalf("second batch = %+v, %v", second, err)
+	}
+	if rest, _ := store.Reencrypt(ctx, "", 2); rest.Moved != 0 || rest.Last != "" {
+		t.Errorf("pass after every connection moved = %+v", rest)
+	}
+
+	for _, id := range connections {
+		stored, err := store.Get(ctx, id, services.TokenReadSync)
+		if err != nil || stored.AccessToken != "access-"+id || stored.KeyRef != "kms/v2" || stored.Version != 1 {
+			t.Errorf("Get(%s) = %+v, %v; want kms/v2 at version 1", id, stored, err)
+		}
+		if reads := store.Reads(id); len(reads) != 2 || reads[0].Purpose != services.TokenReadReencrypt {
+			t.Errorf("audited reads of %s = %+v", id, reads)
+		}
+	}
+}
+
+func TestPostgresTokenStoreRejectsMalformedIDs(t *testing.T) {
+	ctx := context.Background()
+	encryption, _ := security.NewTokenEncryption([32]byte{1})
+	// Without a database any query would panic, so these must be answered up front
+	store := services.NewPostgresTokenStore(nil, encryption, nil)
+	tokens := &services.OAuthTokens{AccessToken: "access"}
+
+	for _, id := range []string{"", "42", "not-a-uuid", testConnection + "x"} {
+		if _, err := store.Get(ctx, id, services.TokenReadSync); !errors.Is(err, services.ErrNoTokens) {
+			t.Errorf("Get(%q) = %v, want ErrNoTokens", id, err)
+		}
+		if _, err := store.Put(ctx, id, tokens); !errors.Is(err, services.ErrNotConnected) {
+			t.Errorf("Put(%q) = %v, want ErrNotConnected", id, err)
+		}
+		if _, err := store.Update(ctx, id, 1, tokens); !errors.Is(err, services.ErrNoTokens) {
+			t.Errorf("Update(%q) = %v, want ErrNoTokens", id, err)
+		}
+		if err := store.Delete(ctx, id); err != nil {
+			t.Errorf("Delete(%q) = %v, want nil", id, err)
+		}
+	}
+}
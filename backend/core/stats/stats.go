@@ -0,0 +1,374 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"syncer.net/core/logging"
+)
+
+// Rollup dimensions stored in sync_stats_daily
+const (
+	DimensionTotal    = "total"
+	DimensionProvider = "provider"
+	DimensionPair     = "pair"
+)
+
+// dayLayout is the date format accepted and returned by the stats API
+const dayLayout = "2006-01-02"
+
+// StatsService computes daily rollups of sync activity and serves dashboard queries from them
+type StatsService struct {
+	db       *sqlx.DB
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewStatsService creates a stats service that re-aggregates recent days on the given interval
+func NewStatsService(db *sqlx.DB, interval time.Duration, logger *slog.Logger) *StatsService {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	return &StatsService{
+		db:       db,
+		interval: interval,
+		logger:   logging.Component(logger, "stats"),
+	}
+}
+
+// StatsRow is the rollup for one day, or a range of days, and one dimension value.
+// For provider and pair rows, jobs count the pair runs involving that provider or pair.
+type StatsRow struct {
+	Day           *string `json:"day,omitempty" db:"day"`
+	Dimension     string  `json:"dimension" db:"dimension"`
+	Key           string  `json:"key,omitempty" db:"dimension_key"`
+	JobsTotal     int     `json:"jobs_total" db:"jobs_total"`
+	JobsSucceeded int     `json:"jobs_succeeded" db:"jobs_succeeded"`
+	JobsFailed    int     `json:"jobs_failed" db:"jobs_failed"`
+	ItemsSynced   int64   `json:"items_synced" db:"items_synced"`
+	ItemsFailed   int64   `json:"items_failed" db:"items_failed"`
+	SuccessRate   float64 `json:"success_rate" db:"-"`
+	DurationAvgMs float64 `json:"duration_avg_ms" db:"duration_avg_ms"`
+	DurationP50Ms float64 `json:"duration_p50_ms" db:"duration_p50_ms"`
+	DurationP95Ms float64 `json:"duration_p95_ms" db:"duration_p95_ms"`
+}
+
+// StatsReport is a dashboard view over a date range. Percentiles over more than one day are
+// job-weighted averages of the daily percentiles.
+type StatsReport struct {
+	UserID    string     `json:"user_id,omitempty"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Totals    StatsRow   `json:"totals"`
+	Daily     []StatsRow `json:"daily"`
+	Providers []StatsRow `json:"providers"`
+	Pairs     []StatsRow `json:"pairs"`
+}
+
+// Start aggregates unprocessed days and then keeps today's and yesterday's rollups current
+func (s *StatsService) Start(ctx context.Context) {
+	s.logger.Info("Starting stats aggregator", "interval", s.interval)
+
+	if err := s.Backfill(ctx); err != nil {
+		s.logger.Error("Failed to backfill daily stats", "error", err)
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.aggregateRecent(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Stats aggregator stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// aggregateRecent refreshes the rollups for yesterday, to catch late-finishing jobs, and today
+func (s *StatsService) aggregateRecent(ctx context.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		if err := s.AggregateDay(ctx, day); err != nil {
+			s.logger.Error("Failed to aggregate daily stats", "day", day.Format(dayLayout), "error", err)
+		}
+	}
+}
+
+// Backfill aggregates every day that has finished jobs but no global rollup yet
+func (s *StatsService) Backfill(ctx context.Context) error {
+	var days []time.Time
+	err := s.db.SelectContext(ctx, &days, `
+		SELECT DISTINCT created_at::date AS day
+		FROM sync_jobs
+		WHERE finished_at IS NOT NULL
+		  AND created_at::date NOT IN (
+			SELECT day FROM sync_stats_daily
+			WHERE user_id IS NULL AND dimension = 'total'
+		  )
+		ORDER BY day
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to find unaggregated days: %w", err)
+	}
+
+	for _, day := range days {
+		if err := s.AggregateDay(ctx, day); err != nil {
+			return err
+		}
+	}
+
+	if len(days) > 0 {
+		s.logger.Info("Backfilled daily stats", "days", len(days))
+	}
+	return nil
+}
+
+// AggregateDay recomputes all rollups for a single day, per user and globally
+func (s *StatsService) AggregateDay(ctx context.Context, day time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin aggregation: %w", err)
+	}
+	defer tx.Rollback()
+
+	date := day.Format(dayLayout)
+
+	// Jobs per user and overall. GROUPING SETS yields a NULL user_id row for the global rollup.
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sync_stats_daily (
+			day, user_id, dimension, dimension_key,
+			jobs_total, jobs_succeeded, jobs_failed, items_synced, items_failed,
+			duration_avg_ms, duration_p50_ms, duration_p95_ms
+		)
+		SELECT $1::date, user_id, 'total', '',
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'completed'),
+		       COUNT(*) FILTER (WHERE status = 'failed'),
+		       COALESCE(SUM(items_synced), 0),
+		       COALESCE(SUM(items_failed), 0),
+		       COALESCE(AVG(duration_ms), 0),
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms), 0),
+		       COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms), 0)
+		FROM sync_jobs
+		WHERE finished_at IS NOT NULL
+		  AND created_at >= $1::date AND created_at < $1::date + 1
+		GROUP BY GROUPING SETS ((user_id), ())
+		HAVING COUNT(*) > 0
+		`+upsertRollup, date)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate job totals for %s: %w", date, err)
+	}
+
+	// Pair and provider rollups come from the per-pair results stored with each job
+	_, err = tx.ExecContext(ctx, pairResultsCTE+`
+		INSERT INTO sync_stats_daily (
+			day, user_id, dimension, dimension_key,
+			jobs_total, jobs_succeeded, jobs_failed, items_synced, items_failed,
+			duration_avg_ms, duration_p50_ms, duration_p95_ms
+		)
+		SELECT $1::date, user_id, 'pair', source_service || '→' || target_service,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE success),
+		       COUNT(*) FILTER (WHERE NOT success),
+		       SUM(items_synced), SUM(items_failed),
+		       AVG(duration_ms),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms)
+		FROM pair_results
+		GROUP BY GROUPING SETS ((user_id, source_service, target_service), (source_service, target_service))
+		`+upsertRollup, date)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate pair stats for %s: %w", date, err)
+	}
+
+	_, err = tx.ExecContext(ctx, pairResultsCTE+`
+		, provider_results AS (
+			SELECT pr.*, provider
+			FROM pair_results pr
+			-- A pair between two accounts of one provider counts once for it
+			CROSS JOIN LATERAL (
+				SELECT DISTINCT provider FROM unnest(ARRAY[pr.source_service, pr.target_service]) AS provider
+			) providers
+		)
+		INSERT INTO sync_stats_daily (
+			day, user_id, dimension, dimension_key,
+			jobs_total, jobs_succeeded, jobs_failed, items_synced, items_failed,
+			duration_avg_ms, duration_p50_ms, duration_p95_ms
+		)
+		SELECT $1::date, user_id, 'provider', provider,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE success),
+		       COUNT(*) FILTER (WHERE NOT success),
+		       SUM(items_synced), SUM(items_failed),
+		       AVG(duration_ms),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms)
+		FROM provider_results
+		GROUP BY GROUPING SETS ((user_id, provider), (provider))
+		`+upsertRollup, date)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate provider stats for %s: %w", date, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit aggregation for %s: %w", date, err)
+	}
+
+	s.logger.Debug("Aggregated daily stats", "day", date)
+	return nil
+}
+
+// pairResultsCTE flattens the service pair results of the day's finished jobs
+const pairResultsCTE = `
+	WITH pair_results AS (
+		SELECT sj.user_id,
+		       p->>'source_service' AS source_service,
+		       p->>'target_service' AS target_service,
+		       COALESCE((p->>'success')::boolean, false) AS success,
+		       CASE WHEN jsonb_typeof(p->'items_synced') = 'array' THEN jsonb_array_length(p->'items_synced') ELSE 0 END AS items_synced,
+		       CASE WHEN jsonb_typeof(p->'items_failed') = 'array' THEN jsonb_array_length(p->'items_failed') ELSE 0 END AS items_failed,
+		       COALESCE((p->>'duration')::bigint, 0) / 1000000.0 AS duration_ms
+		FROM sync_jobs sj
+		JOIN sync_results sr ON sr.job_id = sj.id
+		CROSS JOIN LATERAL jsonb_array_elements(
+			CASE WHEN jsonb_typeof(sr.result_data->'service_pairs') = 'array'
+			     THEN sr.result_data->'service_pairs' ELSE '[]'::jsonb END
+		) AS p
+		WHERE sj.finished_at IS NOT NULL
+		  AND sj.created_at >= $1::date AND sj.created_at < $1::date + 1
+	)`
+
+// upsertRollup replaces an existing rollup row with the freshly computed values
+const upsertRollup = `
+	ON CONFLICT (day, user_id, dimension, dimension_key) DO UPDATE SET
+		jobs_total = EXCLUDED.jobs_total,
+		jobs_succeeded = EXCLUDED.jobs_succeeded,
+		jobs_failed = EXCLUDED.jobs_failed,
+		items_synced = EXCLUDED.items_synced,
+		items_failed = EXCLUDED.items_failed,
+		duration_avg_ms = EXCLUDED.duration_avg_ms,
+		duration_p50_ms = EXCLUDED.duration_p50_ms,
+		duration_p95_ms = EXCLUDED.duration_p95_ms,
+		updated_at = NOW()`
+
+// GetUserStats returns a user's dashboard report for the inclusive date range
+func (s *StatsService) GetUserStats(ctx context.Context, userID string, from, to time.Time) (*StatsReport, error) {
+	report, err := s.report(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.UserID = userID
+	return report, nil
+}
+
+// UserSummary is the all-time totals for a user, as shown on the sync status page
+type UserSummary struct {
+	Totals     StatsRow   `json:"totals"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+}
+
+// GetUserSummary returns a user's all-time totals and the time of their last completed sync
+func (s *StatsService) GetUserSummary(ctx context.Context, userID string) (*UserSummary, error) {
+	report, err := s.report(ctx, userID, time.Time{}, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &UserSummary{Totals: report.Totals}
+	err = s.db.GetContext(ctx, &summary.LastSyncAt, `
+		SELECT MAX(finished_at)
+		FROM sync_jobs
+		WHERE user_id = $1 AND status = 'completed'
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last sync time: %w", err)
+	}
+
+	return summary, nil
+}
+
+// GetGlobalStats returns the report across all users for the inclusive date range
+func (s *StatsService) GetGlobalStats(ctx context.Context, from, to time.Time) (*StatsReport, error) {
+	return s.report(ctx, "", from, to)
+}
+
+// report builds a report from the rollups. An empty userID selects the global rollups.
+func (s *StatsService) report(ctx context.Context, userID string, from, to time.Time) (*StatsReport, error) {
+	report := &StatsReport{
+		From:      from.Format(dayLayout),
+		To:        to.Format(dayLayout),
+		Totals:    StatsRow{Dimension: DimensionTotal},
+		Daily:     []StatsRow{},
+		Providers: []StatsRow{},
+		Pairs:     []StatsRow{},
+	}
+
+	err := s.db.SelectContext(ctx, &report.Daily, `
+		SELECT to_char(day, 'YYYY-MM-DD') AS day, dimension, dimension_key,
+		       jobs_total, jobs_succeeded, jobs_failed, items_synced, items_failed,
+		       duration_avg_ms, duration_p50_ms, duration_p95_ms
+		FROM sync_stats_daily
+		WHERE dimension = 'total' AND day BETWEEN $1::date AND $2::date
+		  AND user_id IS NOT DISTINCT FROM NULLIF($3, '')::uuid
+		ORDER BY day
+	`, report.From, report.To, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load daily stats: %w", err)
+	}
+
+	var rows []StatsRow
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT dimension, dimension_key,
+		       SUM(jobs_total) AS jobs_total,
+		       SUM(jobs_succeeded) AS jobs_succeeded,
+		       SUM(jobs_failed) AS jobs_failed,
+		       SUM(items_synced) AS items_synced,
+		       SUM(items_failed) AS items_failed,
+		       COALESCE(SUM(duration_avg_ms * jobs_total) / NULLIF(SUM(jobs_total), 0), 0) AS duration_avg_ms,
+		       COALESCE(SUM(duration_p50_ms * jobs_total) / NULLIF(SUM(jobs_total), 0), 0) AS duration_p50_ms,
+		       COALESCE(SUM(duration_p95_ms * jobs_total) / NULLIF(SUM(jobs_total), 0), 0) AS duration_p95_ms
+		FROM sync_stats_daily
+		WHERE day BETWEEN $1::date AND $2::date
+		  AND user_id IS NOT DISTINCT FROM NULLIF($3, '')::uuid
+		GROUP BY dimension, dimension_key
+		ORDER BY dimension, SUM(jobs_total) DESC, dimension_key
+	`, report.From, report.To, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load range stats: %w", err)
+	}
+
+	for i := range report.Daily {
+		report.Daily[i].SuccessRate = successRate(report.Daily[i])
+	}
+
+	for _, row := range rows {
+		row.SuccessRate = successRate(row)
+		switch row.Dimension {
+		case DimensionTotal:
+			report.Totals = row
+		case DimensionProvider:
+			report.Providers = append(report.Providers, row)
+		case DimensionPair:
+			report.Pairs = append(report.Pairs, row)
+		}
+	}
+
+	return report, nil
+}
+
+// successRate returns the share of successful jobs in a rollup row
+func successRate(row StatsRow) float64 {
+	if row.JobsTotal == 0 {
+		return 0
+	}
+	return float64(row.JobsSucceeded) / float64(row.JobsTotal)
+}
@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// errorTypeAuth is the sync error type recorded when a job cannot use a service's tokens
+const errorTypeAuth = "auth_error"
+
+// errorTypeUnavailable is the sync error type recorded when a provider is down or its circuit is open
+const errorTypeUnavailable = "provider_unavailable"
+
+// AdaptivePolicy controls how scheduled syncs back off idle libraries and failing schedules
+type AdaptivePolicy struct {
+	HistoryWindow        int           // Number of recent scheduled jobs to consider
+	IdleRunsPerStep      int           // Consecutive idle runs before the frequency doubles
+	MaxIdleMultiplier    int           // Upper bound for the idle backoff multiplier
+	MaxFailureMultiplier int           // Upper bound for the failure backoff multiplier
+	MaxFrequency         time.Duration // Effective frequency never exceeds this interval
+	AuthFailureThreshold int           // Consecutive auth failures before the schedule is disabled
+}
+
+// DefaultAdaptivePolicy returns the policy used by the scheduler unless overridden
+func DefaultAdaptivePolicy() AdaptivePolicy {
+	return AdaptivePolicy{
+		HistoryWindow:        10,
+		IdleRunsPerStep:      3,
+		MaxIdleMultiplier:    8,
+		MaxFailureMultiplier: 16,
+		MaxFrequency:         7 * 24 * time.Hour,
+		AuthFailureThreshold: 3,
+	}
+}
+
+// JobOutcome summarizes a finished scheduled job as stored in sync_jobs
+type JobOutcome struct {
+	Status      SyncJobStatus  `db:"status"`
+	ItemsSynced int            `db:"items_synced"`
+	ErrorTypes  pq.StringArray `db:"error_types"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// outage reports whether the job was cancelled or only hit unavailable providers. Such runs say
+// nothing about the schedule itself, so the policy skips them.
+func (o JobOutcome) outage() bool {
+	if o.Status == SyncStatusCancelled {
+		return true
+	}
+	return o.ItemsSynced == 0 && len(o.ErrorTypes) > 0 && !slices.ContainsFunc(o.ErrorTypes, func(errorType string) bool {
+		return errorType != errorTypeUnavailable
+	})
+}
+
+// failed reports whether the job failed outright or errored without syncing anything.
+// Pair-level errors such as missing tokens do not mark the job failed on their own.
+func (o JobOutcome) failed() bool {
+	return o.Status == SyncStatusFailed || (o.ItemsSynced == 0 && len(o.ErrorTypes) > 0)
+}
+
+// ScheduleAdjustment is the outcome of evaluating a schedule against its recent history
+type ScheduleAdjustment struct {
+	EffectiveFrequency time.Duration `json:"effective_frequency"`
+	Reason             string        `json:"reason,omitempty"`
+	Disable            bool          `json:"disable"`
+}
+
+// Evaluate computes the effective frequency for a schedule from its recent outcomes,
+// ordered from newest to oldest
+func (p AdaptivePolicy) Evaluate(base time.Duration, outcomes []JobOutcome) ScheduleAdjustment {
+	outcomes = slices.DeleteFunc(slices.Clone(outcomes), JobOutcome.outage)
+
+	authFailures := countLeading(outcomes, func(o JobOutcome) bool {
+		return o.failed() && slices.Contains(o.ErrorTypes, errorTypeAuth)
+	})
+
+	if p.AuthFailureThreshold > 0 && authFailures >= p.AuthFailureThreshold {
+		return ScheduleAdjustment{
+			EffectiveFrequency: base,
+			Reason:             fmt.Sprintf("disabled after %d consecutive authentication failures - reconnect the service to resume", authFailures),
+			Disable:            true,
+		}
+	}
+
+	failures := countLeading(outcomes, JobOutcome.failed)
+	if failures > 0 {
+		multiplier := min(1<<min(failures, 30), p.MaxFailureMultiplier)
+		return p.adjust(base, multiplier, fmt.Sprintf("backing off after %d consecutive failed runs", failures))
+	}
+
+	idle := countLeading(outcomes, func(o JobOutcome) bool {
+		return !o.failed() && o.ItemsSynced == 0
+	})
+	if p.IdleRunsPerStep > 0 && idle >= p.IdleRunsPerStep {
+		multiplier := min(1<<min(idle/p.IdleRunsPerStep, 30), p.MaxIdleMultiplier)
+		return p.adjust(base, multiplier, fmt.Sprintf("no changes found in the last %d runs", idle))
+	}
+
+	return ScheduleAdjustment{EffectiveFrequency: base}
+}
+
+// adjust scales the base frequency by the multiplier, capped at MaxFrequency
+func (p AdaptivePolicy) adjust(base time.Duration, multiplier int, reason string) ScheduleAdjustment {
+	if multiplier <= 1 {
+		return ScheduleAdjustment{EffectiveFrequency: base}
+	}
+
+	effective := base * time.Duration(multiplier)
+	if p.MaxFrequency > 0 && effective > p.MaxFrequency {
+		effective = max(p.MaxFrequency, base)
+	}
+
+	return ScheduleAdjustment{
+		EffectiveFrequency: effective,
+		Reason:             reason,
+	}
+}
+
+// countLeading counts how many outcomes at the start of the slice satisfy the predicate
+func countLeading(outcomes []JobOutcome, predicate func(JobOutcome) bool) int {
+	count := 0
+	for _, outcome := range outcomes {
+		if !predicate(outcome) {
+			break
+		}
+		count++
+	}
+	return count
+}
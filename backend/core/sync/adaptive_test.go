@@ -0,0 +1,97 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func repeat(outcome JobOutcome, n int) []JobOutcome {
+	outcomes := make([]JobOutcome, n)
+	for i := range outcomes {
+		outcomes[i] = outcome
+	}
+	return outcomes
+}
+
+func TestAdaptivePolicyEvaluate(t *testing.T) {
+	idle := JobOutcome{Status: SyncStatusCompleted}
+	synced := JobOutcome{Status: SyncStatusCompleted, ItemsSynced: 5}
+	failed := JobOutcome{Status: SyncStatusFailed}
+	authFailed := JobOutcome{Status: SyncStatusCompleted, ErrorTypes: []string{errorTypeAuth}}
+	partial := JobOutcome{Status: SyncStatusCompleted, ItemsSynced: 2, ErrorTypes: []string{"rate_limited"}}
+	cancelled := JobOutcome{Status: SyncStatusCancelled, ErrorTypes: []string{errorTypeUnavailable}}
+	unavailable := JobOutcome{Status: SyncStatusCompleted, ErrorTypes: []string{errorTypeUnavailable}}
+
+	tests := []struct {
+		name     string
+		base     time.Duration
+		outcomes []JobOutcome
+		want     time.Duration
+		disable  bool
+		reason   bool
+	}{
+		{name: "no history", base: time.Hour, want: time.Hour},
+		{name: "recent changes", base: time.Hour, outcomes: repeat(synced, 5), want: time.Hour},
+		{name: "idle below first step", base: time.Hour, outcomes: repeat(idle, 2), want: time.Hour},
+		{name: "idle first step", base: time.Hour, outcomes: repeat(idle, 3), want: 2 * time.Hour, reason: true},
+		{name: "idle second step", base: time.Hour, outcomes: repeat(idle, 6), want: 4 * time.Hour, reason: true},
+		{name: "idle multiplier capped", base: time.Hour, outcomes: repeat(idle, 30), want: 8 * time.Hour, reason: true},
+		{name: "idle streak broken by changes", base: time.Hour, outcomes: append(repeat(idle, 2), repeat(synced, 1)...), want: time.Hour},
+		{name: "one failure", base: time.Hour, outcomes: repeat(failed, 1), want: 2 * time.Hour, reason: true},
+		{name: "two failures", base: time.Hour, outcomes: repeat(failed, 2), want: 4 * time.Hour, reason: true},
+		{name: "failure multiplier capped", base: time.Hour, outcomes: repeat(failed, 10), want: 16 * time.Hour, reason: true},
+		{name: "failures before a success", base: time.Hour, outcomes: append(repeat(synced, 1), repeat(failed, 4)...), want: time.Hour},
+		{name: "partial errors are not failures", base: time.Hour, outcomes: repeat(partial, 4), want: time.Hour},
+		{name: "clamped to max frequency", base: 24 * time.Hour, outcomes: repeat(failed, 5), want: 7 * 24 * time.Hour, reason: true},
+		{name: "base above max frequency", base: 10 * 24 * time.Hour, outcomes: repeat(failed, 1), want: 10 * 24 * time.Hour, reason: true},
+		{name: "auth failures below threshold", base: time.Hour, outcomes: repeat(authFailed, 2), want: 4 * time.Hour, reason: true},
+		{name: "auth failures disable", base: time.Hour, outcomes: repeat(authFailed, 3), want: time.Hour, disable: true, reason: true},
+		{name: "cancelled for an outage", base: time.Hour, outcomes: repeat(cancelled, 10), want: time.Hour},
+		{name: "providers unavailable", base: time.Hour, outcomes: repeat(unavailable, 10), want: time.Hour},
+		{name: "outage does not break failures", base: time.Hour, outcomes: append(append(repeat(failed, 1), cancelled, unavailable), failed), want: 4 * time.Hour, reason: true},
+		{name: "outage does not break auth failures", base: time.Hour, outcomes: append(repeat(authFailed, 2), cancelled, authFailed), want: time.Hour, disable: true, reason: true},
+		{name: "outage mixed with other errors", base: time.Hour, outcomes: repeat(JobOutcome{ErrorTypes: []string{errorTypeUnavailable, errorTypeAuth}}, 1), want: 2 * time.Hour, reason: true},
+		{name: "auth streak broken by other failure", base: time.Hour, outcomes: append(repeat(authFailed, 2), failed, authFailed), want: 16 * time.Hour, reason: true},
+	}
+
+	policy := DefaultAdaptivePolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Evaluate(tt.base, tt.outcomes)
+			if got.EffectiveFrequency != tt.want {
+				t.Errorf("EffectiveFrequency = %s, want %s", got.EffectiveFrequency, tt.want)
+			}
+			if got.Disable != tt.disable {
+				t.Errorf("Disable = %v, want %v", got.Disable, tt.disable)
+			}
+			if (got.Reason != "") != tt.reason {
+				t.Errorf("Reason = %q, want reason %v", got.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCountLeading(t *testing.T) {
+	idle := JobOutcome{Status: SyncStatusCompleted}
+	synced := JobOutcome{Status: SyncStatusCompleted, ItemsSynced: 1}
+	isIdle := func(o JobOutcome) bool { return o.ItemsSynced == 0 }
+
+	tests := []struct {
+		name     string
+		outcomes []JobOutcome
+		want     int
+	}{
+		{name: "empty", want: 0},
+		{name: "all match", outcomes: []JobOutcome{idle, idle, idle}, want: 3},
+		{name: "first does not match", outcomes: []JobOutcome{synced, idle, idle}, want: 0},
+		{name: "stops at first mismatch", outcomes: []JobOutcome{idle, idle, synced, idle}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countLeading(tt.outcomes, isIdle); got != tt.want {
+				t.Errorf("countLeading = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
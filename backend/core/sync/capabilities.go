@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"syncer.net/core/logging"
+	"syncer.net/core/services"
+)
+
+// SyncTypeRequirements lists the capabilities a sync type needs from the service items are read
+// from and from the service they are written to
+type SyncTypeRequirements struct {
+	Read  services.Capabilities
+	Write services.Capabilities
+}
+
+// CapabilityChecker decides whether a service pair can run a sync type
+type CapabilityChecker interface {
+	CheckPair(pair ServicePair, syncType string) error
+}
+
+// CheckPair reports why a pair cannot sync the given type in its mode, or nil when every
+// direction the mode runs is supported by both providers
+func (e *SyncEngine) CheckPair(pair ServicePair, syncType string) error {
+	requirements, ok := e.transformer.SyncTypeRequirements(syncType)
+	if !ok {
+		return fmt.Errorf("unsupported sync type %s", syncType)
+	}
+
+	switch pair.SyncMode {
+	case SyncModeFrom:
+		return e.checkDirection(pair.SourceService, pair.TargetService, requirements)
+	case SyncModeTo:
+		return e.checkDirection(pair.TargetService, pair.SourceService, requirements)
+	case SyncModeBidirectional:
+		if err := e.checkDirection(pair.SourceService, pair.TargetService, requirements); err != nil {
+			return err
+		}
+		return e.checkDirection(pair.TargetService, pair.SourceService, requirements)
+	default:
+		return fmt.Errorf("invalid sync mode %s", pair.SyncMode)
+	}
+}
+
+// checkDirection verifies that items can be read from one service and written to the other
+func (e *SyncEngine) checkDirection(from, to string, requirements SyncTypeRequirements) error {
+	source, err := e.oauth.Registry.GetService(from)
+	if err != nil {
+		return err
+	}
+	target, err := e.oauth.Registry.GetService(to)
+	if err != nil {
+		return err
+	}
+
+	if missing := source.Capabilities().Missing(requirements.Read); len(missing) > 0 {
+		return fmt.Errorf("%s cannot be synced from: missing %s", from, joinCapabilities(missing))
+	}
+	if missing := target.Capabilities().Missing(requirements.Write); len(missing) > 0 {
+		return fmt.Errorf("%s cannot be synced to: missing %s", to, joinCapabilities(missing))
+	}
+	return nil
+}
+
+// pairCapabilities returns the capabilities each side of a pair uses when the pair syncs a type
+// with the given requirements in its mode
+func pairCapabilities(mode SyncMode, requirements SyncTypeRequirements) (source, target services.Capabilities) {
+	switch mode {
+	case SyncModeFrom:
+		return requirements.Read, requirements.Write
+	case SyncModeTo:
+		return requirements.Write, requirements.Read
+	case SyncModeBidirectional:
+		both := append(slices.Clone(requirements.Read), requirements.Write...)
+		return both, both
+	default:
+		return nil, nil
+	}
+}
+
+// checkScopes verifies that both connections of a pair were granted the scopes the capabilities
+// they use need. Providers that can report their grant are asked for it, so a permission revoked
+// since the callback is caught; the others are checked against the scopes stored with the
+// tokens. When scope recording is on, the report of each connection is stored, so a connection
+// whose grant shrank shows as degraded.
+func (e *SyncEngine) checkScopes(ctx context.Context, pair ServicePair, syncType string, source, target services.ServiceProvider, sourceTokens, targetTokens *services.OAuthTokens) *services.SyncError {
+	requirements, ok := e.transformer.SyncTypeRequirements(syncType)
+	if !ok {
+		return nil
+	}
+	sourceNeeds, targetNeeds := pairCapabilities(pair.SyncMode, requirements)
+
+	sides := []struct {
+		provider     services.ServiceProvider
+		tokens       *services.OAuthTokens
+		connectionID string
+		needs        services.Capabilities
+	}{
+		{source, sourceTokens, pair.SourceConnection, sourceNeeds},
+		{target, targetTokens, pair.TargetConnection, targetNeeds},
+	}
+	for _, side := range sides {
+		report := services.CheckScopes(side.provider, e.grantedScope(ctx, side.provider, side.tokens))
+		if side.connectionID != "" && e.recordScopes {
+			if err := e.oauth.RecordScopes(side.connectionID, report); err != nil {
+				e.logger.WarnContext(ctx, "Failed to record connection scopes", "connection_id", side.connectionID, "error", err)
+			}
+		}
+
+		if missing := report.MissingFor(side.needs); len(missing) > 0 {
+			return &services.SyncError{
+				Type:    "missing_scopes",
+				Error:   fmt.Sprintf("%s connection is missing scopes %s; reconnect it to grant them", side.provider.Name(), strings.Join(missing, ", ")),
+				Context: "scope_verification",
+			}
+		}
+	}
+	return nil
+}
+
+// grantedScope returns the scopes currently granted to tokens. A failed lookup falls back to the
+// scopes stored with the tokens rather than failing the job.
+func (e *SyncEngine) grantedScope(ctx context.Context, provider services.ServiceProvider, tokens *services.OAuthTokens) string {
+	inspector, ok := provider.(services.ScopeInspector)
+	if !ok {
+		return tokens.Scope
+	}
+
+	granted, err := inspector.GrantedScopes(ctx, tokens)
+	if err != nil {
+		e.logger.WarnContext(ctx, "Failed to look up granted scopes, using stored scopes",
+			logging.KeyProvider, provider.Name(), "error", err)
+		return tokens.Scope
+	}
+	return strings.Join(granted, " ")
+}
+
+func joinCapabilities(capabilities services.Capabilities) string {
+	names := make([]string, len(capabilities))
+	for i, capability := range capabilities {
+		names[i] = string(capability)
+	}
+	return strings.Join(names, ", ")
+}
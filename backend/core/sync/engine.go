@@ -3,31 +3,68 @@ package sync
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"syncer.net/core/logging"
 	"syncer.net/core/services"
+	"syncer.net/core/tracing"
 )
 
+// maxJobDeferrals caps how often a job is postponed for an open provider circuit. Past the cap
+// the job runs anyway and its pairs fail fast on the open circuit.
+const maxJobDeferrals = 5
+
+// disconnectRetention is how long a disconnect is remembered to cancel jobs queued before it
+const disconnectRetention = 24 * time.Hour
+
+// disconnection records a connection the user removed, to cancel jobs queued before it
+type disconnection struct {
+	userID     string
+	connection services.Connection
+	at         time.Time
+}
+
 // SyncEngine handles real-time synchronization between paired services
 type SyncEngine struct {
 	oauth       *services.OAuthManager
 	transformer DataTransformer
 	adder       CrossServiceAdder
+	tokens      TokenSource
 	scheduler   *SyncScheduler
 	db          *sqlx.DB
 	manualQueue chan *CrossServiceSyncRequest
 	autoQueue   chan *CrossServiceSyncRequest
 	workers     int
-	logger      *log.Logger
+	logger      *slog.Logger
 	metrics     *SyncMetrics
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
+
+	disconnectedMu sync.Mutex
+	disconnected   []disconnection
+
+	deferredMu sync.Mutex
+	deferred   map[*time.Timer]*deferredJob
+
+	recordScopes bool // Store each job's scope reports on the connections
+}
+
+// deferredJob is a job waiting for a provider's circuit to allow a trial call
+type deferredJob struct {
+	req      *CrossServiceSyncRequest
+	provider string
 }
 
 // NewSyncEngine creates a new sync engine with generic interfaces
@@ -37,25 +74,39 @@ func NewSyncEngine(
 	adder CrossServiceAdder,
 	db *sqlx.DB,
 	workers int,
+	logger *slog.Logger,
 ) *SyncEngine {
-	return &SyncEngine{
+	metrics := NewSyncMetrics(logging.Component(logger, "sync_metrics"))
+	engine := &SyncEngine{
 		oauth:       oauth,
 		transformer: transformer,
 		adder:       adder,
-		scheduler:   NewSyncScheduler(db),
+		scheduler:   NewSyncScheduler(db, metrics, logger),
 		db:          db,
 		manualQueue: make(chan *CrossServiceSyncRequest, 500),
 		autoQueue:   make(chan *CrossServiceSyncRequest, 200),
 		workers:     workers,
-		logger:      log.New(log.Writer(), "[SyncEngine] ", log.LstdFlags),
-		metrics:     NewSyncMetrics(),
+		logger:      logging.Component(logger, "sync_engine"),
+		metrics:     metrics,
 		stopChan:    make(chan struct{}),
+		deferred:    make(map[*time.Timer]*deferredJob),
+
+		recordScopes: true,
+	}
+
+	if oauth != nil {
+		oauth.OnDisconnect(engine.HandleDisconnect)
 	}
+
+	return engine
 }
 
 // Start initializes worker goroutines and automatic scheduler
 func (e *SyncEngine) Start(ctx context.Context) error {
-	e.logger.Printf("Starting sync engine with %d workers", e.workers)
+	e.logger.Info("Starting sync engine", "workers", e.workers)
+
+	e.metrics.SetWorkerPoolSize("manual", e.workers/2)
+	e.metrics.SetWorkerPoolSize("automatic", e.workers/2)
 
 	for i := range e.workers / 2 {
 		e.wg.Add(1)
@@ -72,15 +123,28 @@ func (e *SyncEngine) Start(ctx context.Context) error {
 
 // Stop gracefully shuts down the sync engine
 func (e *SyncEngine) Stop() error {
-	e.logger.Printf("Stopping sync engine")
+	e.logger.Info("Stopping sync engine")
 	close(e.stopChan)
+
+	// Deferred jobs whose timer has not fired yet would otherwise be lost without a trace
+	e.deferredMu.Lock()
+	for timer, job := range e.deferred {
+		if timer.Stop() {
+			delete(e.deferred, timer)
+			e.recordCancelledJob(job)
+			e.wg.Done()
+		}
+	}
+	e.deferredMu.Unlock()
+
 	e.wg.Wait()
 	return nil
 }
 
-// QueueManualSync queues a user-initiated sync job
-func (e *SyncEngine) QueueManualSync(req *SyncJobRequest) error {
-	if err := req.Validate(); err != nil {
+// QueueManualSync queues a user-initiated sync job. The span in ctx, typically the API
+// request, is linked from the job's trace.
+func (e *SyncEngine) QueueManualSync(ctx context.Context, req *SyncJobRequest) error {
+	if err := req.Validate(e); err != nil {
 		return fmt.Errorf("invalid sync request: %w", err)
 	}
 
@@ -92,13 +156,16 @@ func (e *SyncEngine) QueueManualSync(req *SyncJobRequest) error {
 		SyncJobRequest: req,
 		Priority:       PriorityMedium,
 		RequestedBy:    req.UserID,
+		Origin:         trace.SpanContextFromContext(ctx),
+		QueuedAt:       time.Now(),
 	}
 	crossServiceReq.IsScheduled = false
 
 	select {
 	case e.manualQueue <- crossServiceReq:
-		e.logger.Printf("Queued manual sync for user %s with %d service pairs",
-			req.UserID, len(req.ServicePairs))
+		e.metrics.RecordQueueDepth("manual", len(e.manualQueue))
+		e.logger.InfoContext(ctx, "Queued manual sync",
+			logging.KeyUserID, req.UserID, "service_pairs", len(req.ServicePairs))
 		return nil
 	default:
 		return fmt.Errorf("manual sync queue is full")
@@ -107,7 +174,7 @@ func (e *SyncEngine) QueueManualSync(req *SyncJobRequest) error {
 
 // ScheduleAutoSync sets up automatic background sync
 func (e *SyncEngine) ScheduleAutoSync(req *SyncJobRequest) error {
-	if err := req.Validate(); err != nil {
+	if err := req.Validate(e); err != nil {
 		return fmt.Errorf("invalid sync request: %w", err)
 	}
 	if req.Schedule == nil {
@@ -118,24 +185,193 @@ func (e *SyncEngine) ScheduleAutoSync(req *SyncJobRequest) error {
 	return e.scheduler.Schedule(req)
 }
 
-// validateServicesAvailability ensures all required services are registered
+// ReloadSchedule refreshes the scheduler's copy of a schedule changed in the database
+func (e *SyncEngine) ReloadSchedule(scheduleID string) error {
+	return e.scheduler.ReloadSchedule(scheduleID)
+}
+
+// validateServicesAvailability ensures all required services are registered. Services whose
+// circuit is open are accepted; the job is deferred until they recover.
 func (e *SyncEngine) validateServicesAvailability(req *SyncJobRequest) error {
 	for _, pair := range req.ServicePairs {
-		if !e.oauth.Registry.IsServiceAvailable(pair.SourceService) {
+		if !e.oauth.Registry.IsServiceRegistered(pair.SourceService) {
 			return fmt.Errorf("source service %s not available", pair.SourceService)
 		}
-		if !e.oauth.Registry.IsServiceAvailable(pair.TargetService) {
+		if !e.oauth.Registry.IsServiceRegistered(pair.TargetService) {
 			return fmt.Errorf("target service %s not available", pair.TargetService)
 		}
 	}
 	return nil
 }
 
+// unavailableProvider returns the first provider of the request whose circuit is open, along with
+// the time its breaker allows a trial call
+func (e *SyncEngine) unavailableProvider(req *CrossServiceSyncRequest) (string, time.Time, bool) {
+	monitor := e.oauth.Registry.HealthMonitor()
+	if monitor == nil {
+		return "", time.Time{}, false
+	}
+
+	for _, pair := range req.ServicePairs {
+		for _, name := range []string{pair.SourceService, pair.TargetService} {
+			health := monitor.Health(name)
+			if !health.Available() {
+				retryAt := time.Now()
+				if health.CircuitRetryAt != nil {
+					retryAt = *health.CircuitRetryAt
+				}
+				return name, retryAt, true
+			}
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// deferJob requeues a job once the open circuit of one of its providers allows a trial call.
+// It returns false when the job has been deferred too often and should run now.
+func (e *SyncEngine) deferJob(req *CrossServiceSyncRequest, queue chan *CrossServiceSyncRequest, provider string, retryAt time.Time, logger *slog.Logger) bool {
+	if req.Deferrals >= maxJobDeferrals {
+		logger.Warn("Provider still unavailable after maximum deferrals, running sync job",
+			logging.KeyUserID, req.UserID, logging.KeyProvider, provider, "deferrals", req.Deferrals)
+		return false
+	}
+
+	req.Deferrals++
+	delay := max(time.Until(retryAt), time.Second)
+
+	logger.Info("Provider circuit open, deferring sync job",
+		logging.KeyUserID, req.UserID, logging.KeyProvider, provider, "retry_in", delay, "deferrals", req.Deferrals)
+
+	job := &deferredJob{req: req, provider: provider}
+
+	e.deferredMu.Lock()
+	defer e.deferredMu.Unlock()
+
+	// The timer counts as running work until it requeues the job or the engine stops
+	e.wg.Add(1)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		defer e.wg.Done()
+
+		e.deferredMu.Lock()
+		delete(e.deferred, timer)
+		e.deferredMu.Unlock()
+
+		// Wait for room in the queue rather than dropping the job
+		select {
+		case <-e.stopChan:
+			e.recordCancelledJob(job)
+			return
+		default:
+		}
+		select {
+		case <-e.stopChan:
+			e.recordCancelledJob(job)
+		case queue <- req:
+		}
+	})
+	e.deferred[timer] = job
+	return true
+}
+
+// recordCancelledJob stores a deferred job that never ran because the engine stopped
+func (e *SyncEngine) recordCancelledJob(job *deferredJob) {
+	_, err := e.db.Exec(`
+		INSERT INTO sync_jobs (
+			id, user_id, status, sync_type, service_pairs_count, is_scheduled, priority, schedule_id,
+			error_count, error_types, finished_at, created_at
+		) VALUES ($1, $2, 'cancelled', $3, $4, $5, $6, NULLIF($7, '')::uuid, 1, $8, NOW(), $9)
+	`, uuid.New().String(), job.req.UserID, job.req.SyncType, len(job.req.ServicePairs),
+		job.req.IsScheduled, job.req.Priority, job.req.ScheduleID,
+		pq.Array([]string{errorTypeUnavailable}), job.req.QueuedAt)
+	if err != nil {
+		e.logger.Error("Failed to record cancelled sync job",
+			logging.KeyUserID, job.req.UserID, logging.KeyProvider, job.provider, "error", err)
+		return
+	}
+
+	e.logger.Warn("Sync engine stopped before deferred job could run",
+		logging.KeyUserID, job.req.UserID, logging.KeyProvider, job.provider, "deferrals", job.req.Deferrals)
+}
+
+// HandleDisconnect stops sync work that uses a connection the user removed: schedules are
+// rewritten without it or disabled, and jobs already queued skip it when they are dequeued
+func (e *SyncEngine) HandleDisconnect(ctx context.Context, userID string, connection services.Connection) {
+	now := time.Now()
+
+	e.disconnectedMu.Lock()
+	e.disconnected = slices.DeleteFunc(e.disconnected, func(d disconnection) bool {
+		return now.Sub(d.at) > disconnectRetention
+	})
+	e.disconnected = append(e.disconnected, disconnection{userID: userID, connection: connection, at: now})
+	e.disconnectedMu.Unlock()
+
+	if e.db == nil {
+		return
+	}
+
+	rewritten, disabled, err := e.scheduler.RemoveConnection(userID, connection)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "Failed to update schedules of disconnected service",
+			logging.KeyUserID, userID, logging.KeyProvider, connection.ServiceName, "error", err)
+		return
+	}
+	if rewritten > 0 || disabled > 0 {
+		e.logger.InfoContext(ctx, "Updated schedules of disconnected service",
+			logging.KeyUserID, userID, logging.KeyProvider, connection.ServiceName, "rewritten", rewritten, "disabled", disabled)
+	}
+}
+
+// withoutDisconnectedServices drops the pairs of a queued job that use a connection the user
+// removed after the job was queued. It returns false when no pair is left to run.
+func (e *SyncEngine) withoutDisconnectedServices(req *CrossServiceSyncRequest, logger *slog.Logger) (*CrossServiceSyncRequest, bool) {
+	e.disconnectedMu.Lock()
+	defer e.disconnectedMu.Unlock()
+
+	if len(e.disconnected) == 0 {
+		return req, true
+	}
+
+	removed := func(pair ServicePair) bool {
+		for _, d := range e.disconnected {
+			if d.userID == req.UserID && !d.at.Before(req.QueuedAt) && pair.UsesConnection(d.connection.ID, d.connection.ServiceName) {
+				return true
+			}
+		}
+		return false
+	}
+
+	pairs := make([]ServicePair, 0, len(req.ServicePairs))
+	for _, pair := range req.ServicePairs {
+		if !removed(pair) {
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == len(req.ServicePairs) {
+		return req, true
+	}
+
+	if len(pairs) == 0 {
+		logger.Info("Cancelled queued sync job, its services were disconnected", logging.KeyUserID, req.UserID)
+		return nil, false
+	}
+
+	// The job request may be shared with its schedule, so the pairs are changed on a copy
+	jobRequest := *req.SyncJobRequest
+	jobRequest.ServicePairs = pairs
+	trimmed := *req
+	trimmed.SyncJobRequest = &jobRequest
+
+	logger.Info("Removed disconnected services from queued sync job",
+		logging.KeyUserID, req.UserID, "removed_pairs", len(req.ServicePairs)-len(pairs))
+	return &trimmed, true
+}
+
 // manualWorker processes user-initiated sync requests
 func (e *SyncEngine) manualWorker(ctx context.Context, workerID int) {
 	defer e.wg.Done()
-	logger := log.New(log.Writer(), fmt.Sprintf("[ManualWorker-%d] ", workerID), log.LstdFlags)
-	logger.Printf("Manual sync worker started")
+	logger := e.logger.With("worker", fmt.Sprintf("manual-%d", workerID))
+	logger.Debug("Manual sync worker started")
 
 	for {
 		select {
@@ -144,7 +380,17 @@ func (e *SyncEngine) manualWorker(ctx context.Context, workerID int) {
 		case <-e.stopChan:
 			return
 		case req := <-e.manualQueue:
+			e.metrics.RecordQueueDepth("manual", len(e.manualQueue))
+			req, keep := e.withoutDisconnectedServices(req, logger)
+			if !keep {
+				continue
+			}
+			if provider, retryAt, down := e.unavailableProvider(req); down && e.deferJob(req, e.manualQueue, provider, retryAt, logger) {
+				continue
+			}
+			done := e.metrics.WorkerBusy("manual")
 			e.processSyncJob(ctx, req, logger)
+			done()
 		}
 	}
 }
@@ -152,8 +398,8 @@ func (e *SyncEngine) manualWorker(ctx context.Context, workerID int) {
 // autoWorker processes scheduled background sync requests
 func (e *SyncEngine) autoWorker(ctx context.Context, workerID int) {
 	defer e.wg.Done()
-	logger := log.New(log.Writer(), fmt.Sprintf("[AutoWorker-%d] ", workerID), log.LstdFlags)
-	logger.Printf("Automatic sync worker started")
+	logger := e.logger.With("worker", fmt.Sprintf("automatic-%d", workerID))
+	logger.Debug("Automatic sync worker started")
 
 	for {
 		select {
@@ -162,13 +408,23 @@ func (e *SyncEngine) autoWorker(ctx context.Context, workerID int) {
 		case <-e.stopChan:
 			return
 		case req := <-e.autoQueue:
+			e.metrics.RecordQueueDepth("automatic", len(e.autoQueue))
+			req, keep := e.withoutDisconnectedServices(req, logger)
+			if !keep {
+				continue
+			}
+			if provider, retryAt, down := e.unavailableProvider(req); down && e.deferJob(req, e.autoQueue, provider, retryAt, logger) {
+				continue
+			}
+			done := e.metrics.WorkerBusy("automatic")
 			e.processSyncJob(ctx, req, logger)
+			done()
 		}
 	}
 }
 
 // processSyncJob performs synchronization for all service pairs in the request
-func (e *SyncEngine) processSyncJob(ctx context.Context, req *CrossServiceSyncRequest, logger *log.Logger) {
+func (e *SyncEngine) processSyncJob(ctx context.Context, req *CrossServiceSyncRequest, logger *slog.Logger) {
 	jobID := uuid.New().String()
 	startTime := time.Now()
 	syncType := "manual"
@@ -176,34 +432,77 @@ func (e *SyncEngine) processSyncJob(ctx context.Context, req *CrossServiceSyncRe
 		syncType = "automatic"
 	}
 
-	logger.Printf("Processing %s sync job %s for user %s with %d service pairs (type: %s)",
-		syncType, jobID, req.UserID, len(req.ServicePairs), req.SyncType)
+	// Jobs run after the queuing request has finished, so they start a new trace linked to it
+	spanOptions := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("sync.job_id", jobID),
+			attribute.String("sync.trigger", syncType),
+			attribute.String("sync.type", req.SyncType),
+			attribute.String("user.id", req.UserID),
+			attribute.Int("sync.service_pairs", len(req.ServicePairs)),
+		),
+	}
+	if req.Origin.IsValid() {
+		spanOptions = append(spanOptions, trace.WithLinks(trace.Link{SpanContext: req.Origin}))
+	}
+	if req.ScheduleID != "" {
+		spanOptions = append(spanOptions, trace.WithAttributes(attribute.String("sync.schedule_id", req.ScheduleID)))
+	}
+
+	ctx, span := tracing.Start(ctx, "sync.job", spanOptions...)
+	defer span.End()
+
+	ctx = logging.WithAttrs(ctx, logging.KeyJobID, jobID, logging.KeyUserID, req.UserID)
+
+	logger.InfoContext(ctx, "Processing sync job",
+		"trigger", syncType, "sync_type", req.SyncType, "service_pairs", len(req.ServicePairs))
+
+	timeline := &timelineWriter{db: e.db, logger: logger, jobID: jobID, syncType: req.SyncType}
 
 	if err := e.createSyncJobRecord(jobID, req); err != nil {
-		logger.Printf("Failed to create sync job record: %v", err)
+		logger.ErrorContext(ctx, "Failed to create sync job record", "error", err)
+		timeline.disabled = true
 	}
 
+	timeline.record(ctx, LogLevelInfo, PhaseJob, "Sync job started", map[string]any{
+		"trigger":       syncType,
+		"service_pairs": len(req.ServicePairs),
+		"schedule_id":   req.ScheduleID,
+		"dry_run":       req.SyncOptions.DryRun,
+	})
+
 	var servicePairResults []ServicePairResult
 	totalSynced := []UniversalItem{}
 	totalFailed := []UniversalItem{}
 	var allErrors []services.SyncError
 
 	for i, pair := range req.ServicePairs {
-		pairLogger := log.New(log.Writer(), fmt.Sprintf("[Job-%s-Pair-%d] ", jobID[:8], i), log.LstdFlags)
+		pairLogger := logger.With("pair", i, "source", pair.SourceService, "target", pair.TargetService)
 
-		result := e.processServicePair(ctx, req.UserID, pair, req.SyncType, req.SyncOptions, pairLogger)
+		pairTimeline := timeline.forPair(pair)
+
+		result := e.processServicePair(ctx, req.UserID, pair, req.SyncType, req.SyncOptions, pairLogger, pairTimeline)
 		servicePairResults = append(servicePairResults, result)
 
 		totalSynced = append(totalSynced, result.ItemsSynced...)
 		totalFailed = append(totalFailed, result.ItemsFailed...)
 		allErrors = append(allErrors, result.Errors...)
 
+		pairDetails := map[string]any{
+			"items_synced": len(result.ItemsSynced),
+			"items_failed": len(result.ItemsFailed),
+			"errors":       len(result.Errors),
+			"duration_ms":  result.Duration.Milliseconds(),
+		}
+
 		if result.Success {
-			pairLogger.Printf("Service pair sync completed: %s ↔ %s - %d items synced in %v",
-				pair.SourceService, pair.TargetService, result.ItemsSynced, result.Duration)
+			pairLogger.InfoContext(ctx, "Service pair sync completed",
+				"items_synced", len(result.ItemsSynced), "duration", result.Duration)
+			pairTimeline.record(ctx, LogLevelInfo, PhasePair, "Service pair sync completed", pairDetails)
 		} else {
-			pairLogger.Printf("Service pair sync failed: %s ↔ %s - %d errors",
-				pair.SourceService, pair.TargetService, len(result.Errors))
+			pairLogger.WarnContext(ctx, "Service pair sync failed", "errors", len(result.Errors))
+			pairTimeline.record(ctx, LogLevelWarning, PhasePair, "Service pair sync finished with errors", pairDetails)
 		}
 	}
 
@@ -223,46 +522,84 @@ func (e *SyncEngine) processSyncJob(ctx context.Context, req *CrossServiceSyncRe
 			"service_pairs": len(req.ServicePairs),
 			"user_id":       req.UserID,
 			"requested_by":  req.RequestedBy,
+			"schedule_id":   req.ScheduleID,
 			"timestamp":     startTime,
 		},
 	}
 
 	if err := e.updateSyncJobRecord(jobID, syncResult); err != nil {
-		logger.Printf("Failed to update sync job record: %v", err)
+		logger.ErrorContext(ctx, "Failed to update sync job record", "error", err)
 	}
 
 	if err := e.storeSyncResult(syncResult); err != nil {
-		logger.Printf("Failed to store sync result: %v", err)
-	} else {
-		logger.Printf("Stored complete sync result for job %s", jobID)
+		logger.ErrorContext(ctx, "Failed to store sync result", "error", err)
+	}
+
+	span.SetAttributes(
+		attribute.Int("sync.items_synced", len(totalSynced)),
+		attribute.Int("sync.items_failed", len(totalFailed)),
+		attribute.Int("sync.errors", len(allErrors)),
+	)
+
+	jobDetails := map[string]any{
+		"items_synced": len(totalSynced),
+		"items_failed": len(totalFailed),
+		"errors":       len(allErrors),
+		"duration_ms":  duration.Milliseconds(),
 	}
 
 	if syncResult.Success {
-		e.metrics.RecordSyncJobSuccess(req.UserID, syncType, len(req.ServicePairs), len(totalSynced), duration)
-		logger.Printf("Sync job %s completed successfully in %v: %d total items synced across %d pairs",
-			jobID, duration, len(totalSynced), len(req.ServicePairs))
+		timeline.record(ctx, LogLevelInfo, PhaseJob, "Sync job completed", jobDetails)
+		e.metrics.RecordSyncJobSuccess(syncType, req.SyncType, len(req.ServicePairs), len(totalSynced), duration)
+		logger.InfoContext(ctx, "Sync job completed",
+			"duration", duration, "items_synced", len(totalSynced), "service_pairs", len(req.ServicePairs))
 	} else {
-		e.metrics.RecordSyncJobFailure(req.UserID, syncType, len(req.ServicePairs), len(totalFailed))
-		logger.Printf("Sync job %s completed with errors after %v: %d total items failed",
-			jobID, duration, len(totalFailed))
+		timeline.record(ctx, LogLevelError, PhaseJob, "Sync job failed", jobDetails)
+		tracing.RecordError(span, fmt.Errorf("%d items failed to sync", len(totalFailed)))
+		e.metrics.RecordSyncJobFailure(syncType, req.SyncType, len(req.ServicePairs), len(totalFailed), duration)
+		logger.WarnContext(ctx, "Sync job completed with errors",
+			"duration", duration, "items_failed", len(totalFailed))
 	}
 }
 
 // processServicePair handles sync for a single service pair based on sync mode
-func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair ServicePair, syncType string, options SyncOptions, logger *log.Logger) ServicePairResult {
+func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair ServicePair, syncType string, options SyncOptions, logger *slog.Logger, timeline *timelineWriter) (result ServicePairResult) {
 	startTime := time.Now()
 
-	result := ServicePairResult{
-		SourceService: pair.SourceService,
-		TargetService: pair.TargetService,
-		SyncMode:      pair.SyncMode,
-		Success:       false,
-		ItemsSynced:   []UniversalItem{},
-		ItemsFailed:   []UniversalItem{},
-		Errors:        []services.SyncError{},
+	ctx, span := tracing.Start(ctx, "sync.pair", trace.WithAttributes(
+		attribute.String("sync.source", pair.SourceService),
+		attribute.String("sync.target", pair.TargetService),
+		attribute.String("sync.mode", string(pair.SyncMode)),
+	))
+	defer func() {
+		// Direction-level errors are recorded where they occur; only pair setup errors are recorded here
+		for _, syncErr := range result.Errors {
+			if syncErr.Context == "service_resolution" || syncErr.Context == "token_retrieval" ||
+				syncErr.Context == "scope_verification" || syncErr.Context == "sync_mode_validation" {
+				timeline.record(ctx, LogLevelError, PhasePair, syncErr.Error, map[string]any{"type": syncErr.Type})
+			}
+		}
+
+		span.SetAttributes(attribute.Int("sync.errors", len(result.Errors)))
+		if !result.Success && len(result.Errors) > 0 {
+			tracing.RecordError(span, errors.New(result.Errors[0].Error))
+		}
+		span.End()
+	}()
+
+	result = ServicePairResult{
+		SourceService:    pair.SourceService,
+		TargetService:    pair.TargetService,
+		SourceConnection: pair.SourceConnection,
+		TargetConnection: pair.TargetConnection,
+		SyncMode:         pair.SyncMode,
+		Success:          false,
+		ItemsSynced:      []UniversalItem{},
+		ItemsFailed:      []UniversalItem{},
+		Errors:           []services.SyncError{},
 	}
 
-	logger.Printf("Processing service pair: %s ↔ %s (mode: %s)", pair.SourceService, pair.TargetService, pair.SyncMode)
+	logger.DebugContext(ctx, "Processing service pair", "mode", pair.SyncMode)
 
 	sourceService, err := e.oauth.Registry.GetService(pair.SourceService)
 	if err != nil {
@@ -286,7 +623,7 @@ func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair
 		return result
 	}
 
-	sourceTokens, err := e.getUserTokens(userID, pair.SourceService)
+	sourceTokens, err := e.getUserTokens(ctx, userID, pair.SourceService, pair.SourceConnection)
 	if err != nil {
 		result.Errors = append(result.Errors, services.SyncError{
 			Type:    "auth_error",
@@ -297,7 +634,7 @@ func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair
 		return result
 	}
 
-	targetTokens, err := e.getUserTokens(userID, pair.TargetService)
+	targetTokens, err := e.getUserTokens(ctx, userID, pair.TargetService, pair.TargetConnection)
 	if err != nil {
 		result.Errors = append(result.Errors, services.SyncError{
 			Type:    "auth_error",
@@ -308,24 +645,30 @@ func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair
 		return result
 	}
 
+	if scopeErr := e.checkScopes(ctx, pair, syncType, sourceService, targetService, sourceTokens, targetTokens); scopeErr != nil {
+		result.Errors = append(result.Errors, *scopeErr)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
 	switch pair.SyncMode {
 	case SyncModeFrom:
-		synced, syncErrors := e.performDirectionalSync(ctx, sourceService, targetService, sourceTokens, targetTokens, syncType, options, logger)
+		synced, syncErrors := e.performDirectionalSync(ctx, sourceService, targetService, sourceTokens, targetTokens, syncType, options, logger, timeline)
 		result.ItemsSynced = synced.Items
 		result.ItemsFailed = synced.Failed
 		result.Errors = syncErrors
 		result.Success = len(syncErrors) == 0
 
 	case SyncModeTo:
-		synced, syncErrors := e.performDirectionalSync(ctx, targetService, sourceService, targetTokens, sourceTokens, syncType, options, logger)
+		synced, syncErrors := e.performDirectionalSync(ctx, targetService, sourceService, targetTokens, sourceTokens, syncType, options, logger, timeline)
 		result.ItemsSynced = synced.Items
 		result.ItemsFailed = synced.Failed
 		result.Errors = syncErrors
 		result.Success = len(syncErrors) == 0
 
 	case SyncModeBidirectional:
-		synced1, errors1 := e.performDirectionalSync(ctx, sourceService, targetService, sourceTokens, targetTokens, syncType, options, logger)
-		synced2, errors2 := e.performDirectionalSync(ctx, targetService, sourceService, targetTokens, sourceTokens, syncType, options, logger)
+		synced1, errors1 := e.performDirectionalSync(ctx, sourceService, targetService, sourceTokens, targetTokens, syncType, options, logger, timeline)
+		synced2, errors2 := e.performDirectionalSync(ctx, targetService, sourceService, targetTokens, sourceTokens, syncType, options, logger, timeline)
 
 		result.ItemsSynced = append(synced1.Items, synced2.Items...)
 		result.ItemsFailed = append(synced1.Failed, synced2.Failed...)
@@ -344,31 +687,76 @@ func (e *SyncEngine) processServicePair(ctx context.Context, userID string, pair
 	return result
 }
 
-// performDirectionalSync performs one-way sync from source to target
+// performDirectionalSync performs one-way sync from source to target. The result is never nil,
+// even when the fetch fails.
 func (e *SyncEngine) performDirectionalSync(
 	ctx context.Context,
 	sourceService, targetService services.ServiceProvider,
 	sourceTokens, targetTokens *services.OAuthTokens,
 	syncType string,
 	options SyncOptions,
-	logger *log.Logger,
+	logger *slog.Logger,
+	timeline *timelineWriter,
 ) (*SyncResult, []services.SyncError) {
-	logger.Printf("Starting directional sync: %s → %s (type: %s)", sourceService.Name(), targetService.Name(), syncType)
+	logger = logger.With("direction", fmt.Sprintf("%s→%s", sourceService.Name(), targetService.Name()))
+	logger.DebugContext(ctx, "Starting directional sync", "sync_type", syncType)
+
+	direction := map[string]any{"from": sourceService.Name(), "to": targetService.Name()}
+	withDirection := func(details map[string]any) map[string]any {
+		maps.Copy(details, direction)
+		return details
+	}
 
-	sourceResult, err := sourceService.GetUserData(ctx, sourceTokens, time.Time{})
+	ctx, span := tracing.Start(ctx, "sync.direction", trace.WithAttributes(
+		attribute.String("sync.source", sourceService.Name()),
+		attribute.String("sync.target", targetService.Name()),
+		attribute.String("sync.type", syncType),
+		attribute.Bool("sync.dry_run", options.DryRun),
+	))
+	defer span.End()
+
+	directionStart := time.Now()
+	defer func() {
+		e.metrics.RecordDirection(sourceService.Name(), targetService.Name(), syncType, time.Since(directionStart))
+	}()
+
+	fetchCtx, fetchSpan := tracing.Start(ctx, "sync.fetch", trace.WithAttributes(
+		attribute.String("sync.provider", sourceService.Name()),
+	))
+	sourceResult, err := sourceService.GetUserData(fetchCtx, sourceTokens, time.Time{})
 	if err != nil {
-		return nil, []services.SyncError{{
+		tracing.RecordError(fetchSpan, err)
+		fetchSpan.End()
+		tracing.RecordError(span, err)
+		timeline.record(ctx, LogLevelError, PhaseFetch, fmt.Sprintf("Failed to fetch data from %s", sourceService.Name()),
+			withDirection(map[string]any{"error": err.Error()}))
+		fetchErrors := []services.SyncError{{
 			Type:    "sync_error",
 			Error:   fmt.Sprintf("failed to fetch source data: %v", err),
 			Context: "source_data_fetch",
 		}}
+		return &SyncResult{Errors: fetchErrors}, fetchErrors
 	}
 
-	if !sourceResult.Success || len(sourceResult.Items) == 0 {
-		logger.Printf("No data found in source service %s", sourceService.Name())
-		return nil, nil
+	fetchSpan.SetAttributes(attribute.Int("sync.items_fetched", len(sourceResult.Items)))
+	fetchSpan.End()
+
+	// A partial fetch still syncs what was read; failed sections are only logged
+	if !sourceResult.Success {
+		logger.WarnContext(ctx, "Source data fetched with errors", "errors", len(sourceResult.Errors))
 	}
 
+	if len(sourceResult.Items) == 0 {
+		logger.InfoContext(ctx, "No data found in source service")
+		timeline.record(ctx, LogLevelInfo, PhaseFetch, fmt.Sprintf("No data found in %s", sourceService.Name()), withDirection(map[string]any{}))
+		return &SyncResult{}, nil
+	}
+
+	timeline.record(ctx, LogLevelInfo, PhaseFetch, fmt.Sprintf("Fetched %d items from %s", len(sourceResult.Items), sourceService.Name()),
+		withDirection(map[string]any{"items": len(sourceResult.Items)}))
+
+	_, transformSpan := tracing.Start(ctx, "sync.transform")
+
 	var universalItems []UniversalItem
 	var transformErrors []services.SyncError
 
@@ -376,6 +764,9 @@ func (e *SyncEngine) performDirectionalSync(
 		if e.itemMatchesSyncType(item.ItemType, syncType) {
 			universalItem, err := e.transformer.TransformToUniversal(sourceService.Name(), item.Data)
 			if err != nil {
+				e.metrics.RecordItem(sourceService.Name(), syncType, ItemOutcomeTransformFailed, 0)
+				timeline.recordItem(ctx, LogLevelWarning, PhaseTransform, item.ExternalID, "Failed to transform item",
+					withDirection(map[string]any{"item_type": item.ItemType, "error": err.Error()}))
 				transformErrors = append(transformErrors, services.SyncError{
 					Type:    "transform_error",
 					Error:   fmt.Sprintf("failed to transform item: %v", err),
@@ -388,19 +779,60 @@ func (e *SyncEngine) performDirectionalSync(
 		}
 	}
 
-	logger.Printf("Transformed %d items to universal format", len(universalItems))
+	transformSpan.SetAttributes(
+		attribute.Int("sync.items_transformed", len(universalItems)),
+		attribute.Int("sync.transform_errors", len(transformErrors)),
+	)
+	transformSpan.End()
+
+	logger.DebugContext(ctx, "Transformed items to universal format", "items", len(universalItems))
+	timeline.record(ctx, LogLevelInfo, PhaseTransform, fmt.Sprintf("Transformed %d items", len(universalItems)),
+		withDirection(map[string]any{"items": len(universalItems), "errors": len(transformErrors)}))
 
 	if options.DryRun {
-		logger.Printf("DRY RUN: Would sync %d items", len(universalItems))
-		return nil, transformErrors
+		logger.InfoContext(ctx, "Dry run, skipping add phase", "items", len(universalItems))
+		timeline.record(ctx, LogLevelInfo, PhaseAdd, fmt.Sprintf("Dry run: would add %d items to %s", len(universalItems), targetService.Name()),
+			withDirection(map[string]any{"items": len(universalItems)}))
+		for range universalItems {
+			e.metrics.RecordItem(targetService.Name(), syncType, ItemOutcomeSkipped, 0)
+		}
+		return &SyncResult{Errors: transformErrors}, transformErrors
 	}
 
+	addCtx, addSpan := tracing.Start(ctx, "sync.add", trace.WithAttributes(
+		attribute.String("sync.provider", targetService.Name()),
+		attribute.Int("sync.items", len(universalItems)),
+	))
+
 	syncedItems := make([]UniversalItem, 0, len(universalItems))
 	var syncErrors []services.SyncError
 
-	for _, universalItem := range universalItems {
-		err := e.adder.AddItemToService(ctx, targetService, targetTokens, universalItem, options)
+	for i, universalItem := range universalItems {
+		itemStart := time.Now()
+		err := e.adder.AddItemToService(addCtx, targetService, targetTokens, universalItem, options)
+		if errors.Is(err, services.ErrCircuitOpen) {
+			// The provider is down; the remaining items would all fail the same way
+			remaining := len(universalItems) - i
+			addSpan.AddEvent("circuit_open", trace.WithAttributes(attribute.Int("sync.items_skipped", remaining)))
+			for range remaining {
+				e.metrics.RecordItem(targetService.Name(), syncType, ItemOutcomeSkipped, 0)
+			}
+			timeline.record(ctx, LogLevelError, PhaseAdd,
+				fmt.Sprintf("%s is unavailable, skipped %d remaining items", targetService.Name(), remaining),
+				withDirection(map[string]any{"items_skipped": remaining, "error": err.Error()}))
+			syncErrors = append(syncErrors, services.SyncError{
+				Type:    errorTypeUnavailable,
+				Error:   fmt.Sprintf("%s unavailable, skipped %d items: %v", targetService.Name(), remaining, err),
+				Context: fmt.Sprintf("adding_to_%s", targetService.Name()),
+			})
+			break
+		}
 		if err != nil {
+			addSpan.AddEvent("item_failed", trace.WithAttributes(attribute.String("error", err.Error())))
+			e.metrics.RecordItem(targetService.Name(), syncType, ItemOutcomeFailed, time.Since(itemStart))
+			timeline.recordItem(ctx, LogLevelError, PhaseAdd, universalItem.GetItemIdentifier(),
+				fmt.Sprintf("Failed to add item to %s", targetService.Name()),
+				withDirection(map[string]any{"item_type": universalItem.GetItemType(), "error": err.Error()}))
 			syncErrors = append(syncErrors, services.SyncError{
 				Type:    "add_error",
 				Error:   fmt.Sprintf("failed to add item to %s: %v", targetService.Name(), err),
@@ -409,16 +841,25 @@ func (e *SyncEngine) performDirectionalSync(
 			continue
 		}
 
+		e.metrics.RecordItem(targetService.Name(), syncType, ItemOutcomeSynced, time.Since(itemStart))
 		syncedItems = append(syncedItems, universalItem)
 
-		logger.Printf("Successfully synced item to %s", targetService.Name())
+		logger.DebugContext(ctx, "Synced item", logging.KeyProvider, targetService.Name())
 
 		time.Sleep(100 * time.Millisecond)
 	}
 
+	addSpan.SetAttributes(
+		attribute.Int("sync.items_synced", len(syncedItems)),
+		attribute.Int("sync.add_errors", len(syncErrors)),
+	)
+	addSpan.End()
+
 	allErrors := append(transformErrors, syncErrors...)
 
-	logger.Printf("Generic sync completed: %d/%d items synced successfully", len(syncedItems), len(universalItems))
+	logger.InfoContext(ctx, "Directional sync completed", "items_synced", len(syncedItems), "items", len(universalItems))
+	timeline.record(ctx, LogLevelInfo, PhaseAdd, fmt.Sprintf("Added %d of %d items to %s", len(syncedItems), len(universalItems), targetService.Name()),
+		withDirection(map[string]any{"items_synced": len(syncedItems), "errors": len(syncErrors)}))
 	return &SyncResult{
 		Items:    syncedItems,
 		Errors:   allErrors,
@@ -431,21 +872,59 @@ func (e *SyncEngine) itemMatchesSyncType(itemType string, syncType string) bool
 	return e.transformer.MatchesSyncType(itemType, syncType)
 }
 
-// Helper method to get user tokens
-func (e *SyncEngine) getUserTokens(userID, serviceName string) (*services.OAuthTokens, error) {
-	var userServiceID string
-	err := e.db.Get(&userServiceID, `
-		SELECT us.id
-		FROM user_services us
-		JOIN services s ON us.service_id = s.id
-		WHERE us.user_id = $1 AND s.name = $2
-	`, userID, serviceName)
+// SetTokenSource replaces where the engine reads users' service tokens from, the OAuth manager's
+// token store by default
+func (e *SyncEngine) SetTokenSource(source TokenSource) {
+	e.tokens = source
+}
+
+// SetScopeRecording turns storing the scope reports of each job's connections on or off. It is
+// on by default.
+func (e *SyncEngine) SetScopeRecording(record bool) {
+	e.recordScopes = record
+}
+
+// ListConnections returns the accounts a user has connected, which pairs refer to
+func (e *SyncEngine) ListConnections(userID string) ([]services.Connection, error) {
+	return e.oauth.ListConnections(userID)
+}
+
+// getUserTokens returns the tokens of a user's connection. Pairs saved before they referenced
+// connections have none and use the user's only connection to the service.
+func (e *SyncEngine) getUserTokens(ctx context.Context, userID, serviceName, connectionID string) (*services.OAuthTokens, error) {
+	if e.tokens != nil {
+		return e.tokens.UserTokens(userID, serviceName, connectionID)
+	}
+
+	if connectionID == "" {
+		var connectionIDs []string
+		err := e.db.Select(&connectionIDs, `
+			SELECT us.id
+			FROM user_services us
+			JOIN services s ON us.service_id = s.id
+			WHERE us.user_id = $1 AND s.name = $2
+		`, userID, serviceName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up connection: %w", err)
+		}
+		if len(connectionIDs) != 1 {
+			return nil, fmt.Errorf("user service not found: %d %s connections, the pair must name one", len(connectionIDs), serviceName)
+		}
+		connectionID = connectionIDs[0]
+	}
 
+	connection, err := e.oauth.GetConnection(userID, connectionID)
 	if err != nil {
 		return nil, fmt.Errorf("user service not found: %w", err)
 	}
+	if connection.ServiceName != serviceName {
+		return nil, fmt.Errorf("connection %s is a %s account, not %s", connectionID, connection.ServiceName, serviceName)
+	}
+	if connection.State.RequiresReauth() {
+		return nil, fmt.Errorf("%s connection must be reconnected: %s", serviceName, connection.State)
+	}
 
-	return e.oauth.GetUserTokens(userServiceID)
+	return e.oauth.GetUserTokens(ctx, connection.ID, services.TokenReadSync)
 }
 
 // Database operations for sync job tracking (metadata only)
@@ -453,10 +932,10 @@ func (e *SyncEngine) createSyncJobRecord(jobID string, req *CrossServiceSyncRequ
 	_, err := e.db.Exec(`
 		INSERT INTO sync_jobs (
 			id, user_id, status, sync_type, service_pairs_count, 
-			is_scheduled, priority, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+			is_scheduled, priority, schedule_id, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, NOW())
 	`, jobID, req.UserID, "running", req.SyncType, len(req.ServicePairs),
-		req.IsScheduled, req.Priority)
+		req.IsScheduled, req.Priority, req.ScheduleID)
 
 	return err
 }
@@ -467,6 +946,13 @@ func (e *SyncEngine) updateSyncJobRecord(jobID string, result *CrossServiceSyncR
 		status = "failed"
 	}
 
+	errorTypes := []string{}
+	for _, syncErr := range result.Errors {
+		if !slices.Contains(errorTypes, syncErr.Type) {
+			errorTypes = append(errorTypes, syncErr.Type)
+		}
+	}
+
 	_, err := e.db.Exec(`
 		UPDATE sync_jobs SET 
 			status = $1, 
@@ -474,10 +960,11 @@ func (e *SyncEngine) updateSyncJobRecord(jobID string, result *CrossServiceSyncR
 			items_failed = $3, 
 			duration_ms = $4,
 			error_count = $5,
+			error_types = $6,
 			finished_at = NOW()
-		WHERE id = $6
+		WHERE id = $7
 	`, status, result.TotalSynced, result.TotalFailed,
-		result.Duration.Milliseconds(), len(result.Errors), jobID)
+		result.Duration.Milliseconds(), len(result.Errors), pq.Array(errorTypes), jobID)
 
 	return err
 }
@@ -527,6 +1014,11 @@ func (e *SyncEngine) GetSyncResult(jobID string) (*CrossServiceSyncResult, error
 	return &result, nil
 }
 
+// GetScheduleHistory retrieves recent runs and statistics for a schedule
+func (e *SyncEngine) GetScheduleHistory(scheduleID string, since time.Time, limit int) (*ScheduleHistory, error) {
+	return e.scheduler.GetScheduleHistory(scheduleID, since, limit)
+}
+
 // GetUserSyncResults retrieves sync results for a specific user with optional pagination and filtering
 func (e *SyncEngine) GetUserSyncResults(userID string, limit int, offset int, successOnly *bool) ([]*CrossServiceSyncResult, error) {
 	//TODO: properly implement this
@@ -0,0 +1,315 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"syncer.net/core/security"
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+// trackTransformer passes fake provider tracks through unchanged
+type trackTransformer struct{}
+
+func (trackTransformer) TransformToUniversal(serviceName string, data any) (UniversalItem, error) {
+	track, ok := data.(servicetest.Track)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T", data)
+	}
+	return track, nil
+}
+
+func (trackTransformer) FindBestMatch(UniversalItem, []UniversalItem, float64) UniversalMatch {
+	return UniversalMatch{}
+}
+
+func (trackTransformer) AnalyzeMatches([]UniversalMatch) map[string]any {
+	return nil
+}
+
+func (trackTransformer) MatchesSyncType(itemType string, syncType string) bool {
+	return syncType == "favorites" && itemType == "saved_track"
+}
+
+func (trackTransformer) SyncTypeRequirements(syncType string) (SyncTypeRequirements, bool) {
+	if syncType != "favorites" {
+		return SyncTypeRequirements{}, false
+	}
+	return SyncTypeRequirements{
+		Read:  services.Capabilities{services.CapabilityReadFavorites},
+		Write: services.Capabilities{services.CapabilityWriteFavorites},
+	}, true
+}
+
+// trackAdder saves tracks into fake providers
+type trackAdder struct{}
+
+func (trackAdder) AddItemToService(ctx context.Context, target services.ServiceProvider, tokens *services.OAuthTokens, item UniversalItem, options any) error {
+	return target.(*servicetest.FakeProvider).SaveTrack(ctx, tokens, item.(servicetest.Track))
+}
+
+// staticTokens hands out the same valid tokens for every user and service
+type staticTokens struct{}
+
+func (staticTokens) UserTokens(userID, serviceName, connectionID string) (*services.OAuthTokens, error) {
+	return &services.OAuthTokens{AccessToken: userID + "-" + serviceName, TokenType: "Bearer"}, nil
+}
+
+func newTestEngine(t *testing.T, providers ...*servicetest.FakeProvider) *SyncEngine {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := services.NewServiceRegistry(nil, logger)
+	for _, provider := range providers {
+		if err := registry.Register(provider); err != nil {
+			t.Fatalf("Register(%s): %v", provider.Name(), err)
+		}
+	}
+
+	encryption, err := security.NewTokenEncryption([32]byte{})
+	if err != nil {
+		t.Fatalf("NewTokenEncryption: %v", err)
+	}
+	oauth, err := services.NewOAuthManager(registry, nil, encryption, logger)
+	if err != nil {
+		t.Fatalf("NewOAuthManager: %v", err)
+	}
+
+	engine := NewSyncEngine(oauth, trackTransformer{}, trackAdder{}, nil, 2, logger)
+	engine.SetTokenSource(staticTokens{})
+	return engine
+}
+
+func (e *SyncEngine) runPair(t *testing.T, pair ServicePair) ServicePairResult {
+	t.Helper()
+	return e.processServicePair(context.Background(), "user-1", pair, "favorites", SyncOptions{}, e.logger, nil)
+}
+
+var (
+	trackA = servicetest.Track{ID: "a", Title: "Track A", Artist: "Artist"}
+	trackB = servicetest.Track{ID: "b", Title: "Track B", Artist: "Artist"}
+	trackC = servicetest.Track{ID: "c", Title: "Track C", Artist: "Artist"}
+)
+
+func TestProcessServicePairCopiesLibrary(t *testing.T) {
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", Library: []servicetest.Track{trackA, trackB}})
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target", Library: []servicetest.Track{trackC}})
+	engine := newTestEngine(t, source, target)
+
+	result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeFrom})
+
+	if !result.Success {
+		t.Fatalf("sync failed: %+v", result.Errors)
+	}
+	if len(result.ItemsSynced) != 2 {
+		t.Errorf("synced %d items, want 2", len(result.ItemsSynced))
+	}
+	if got := len(target.Library()); got != 3 {
+		t.Errorf("target library has %d tracks, want 3", got)
+	}
+	if got := len(source.Library()); got != 2 {
+		t.Errorf("one-way sync changed the source library to %d tracks", got)
+	}
+}
+
+func TestProcessServicePairBidirectional(t *testing.T) {
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", Library: []servicetest.Track{trackA}})
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target", Library: []servicetest.Track{trackB}})
+	engine := newTestEngine(t, source, target)
+
+	result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeBidirectional})
+
+	if !result.Success {
+		t.Fatalf("sync failed: %+v", result.Errors)
+	}
+	for _, provider := range []*servicetest.FakeProvider{source, target} {
+		if got := len(provider.Library()); got != 2 {
+			t.Errorf("%s library has %d tracks, want 2", provider.Name(), got)
+		}
+	}
+}
+
+func TestProcessServicePairSourceFetchFails(t *testing.T) {
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", Library: []servicetest.Track{trackA}})
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target"})
+	source.SetError(servicetest.OpGetUserData, errors.New("upstream exploded"))
+	engine := newTestEngine(t, source, target)
+
+	// Both modes read from the failing source
+	for _, mode := range []SyncMode{SyncModeFrom, SyncModeBidirectional} {
+		result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: mode})
+
+		if result.Success {
+			t.Errorf("%s: sync succeeded despite a failing source", mode)
+		}
+		if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Error, "upstream exploded") {
+			t.Errorf("%s: errors %+v do not report the fetch failure", mode, result.Errors)
+		}
+	}
+}
+
+func TestProcessServicePairStopsOnOpenCircuit(t *testing.T) {
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", Library: []servicetest.Track{trackA, trackB, trackC}})
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target"})
+	target.SetError(servicetest.OpSaveTrack, fmt.Errorf("target unavailable: %w", services.ErrCircuitOpen))
+	engine := newTestEngine(t, source, target)
+
+	result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeFrom})
+
+	if got := target.Calls(servicetest.OpSaveTrack); got != 1 {
+		t.Errorf("target called %d times after its circuit opened, want 1", got)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Type != "provider_unavailable" {
+		t.Errorf("errors = %+v, want one provider_unavailable error", result.Errors)
+	}
+}
+
+func TestCheckPairRequiresCapabilities(t *testing.T) {
+	readOnly := servicetest.NewFakeProvider(servicetest.FakeConfig{
+		Name:         "read-only",
+		Capabilities: services.Capabilities{services.CapabilityReadFavorites},
+	})
+	full := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "full"})
+	engine := newTestEngine(t, readOnly, full)
+
+	tests := []struct {
+		pair    ServicePair
+		wantErr bool
+	}{
+		{ServicePair{SourceService: "read-only", TargetService: "full", SyncMode: SyncModeFrom}, false},
+		{ServicePair{SourceService: "read-only", TargetService: "full", SyncMode: SyncModeTo}, true},
+		{ServicePair{SourceService: "read-only", TargetService: "full", SyncMode: SyncModeBidirectional}, true},
+		{ServicePair{SourceService: "full", TargetService: "read-only", SyncMode: SyncModeTo}, false},
+	}
+	for _, tt := range tests {
+		err := engine.CheckPair(tt.pair, "favorites")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckPair(%s→%s, %s) = %v, want error: %v", tt.pair.SourceService, tt.pair.TargetService, tt.pair.SyncMode, err, tt.wantErr)
+		}
+	}
+
+	if err := engine.CheckPair(ServicePair{SourceService: "full", TargetService: "read-only", SyncMode: SyncModeTo}, "playlists"); err == nil {
+		t.Error("CheckPair accepted an unknown sync type")
+	}
+}
+
+// grantedTokens hands out tokens carrying the scopes granted to each service
+type grantedTokens map[string]string
+
+func (g grantedTokens) UserTokens(userID, serviceName, connectionID string) (*services.OAuthTokens, error) {
+	return &services.OAuthTokens{AccessToken: userID + "-" + serviceName, TokenType: "Bearer", Scope: g[serviceName]}, nil
+}
+
+func TestProcessServicePairRequiresScopes(t *testing.T) {
+	scopes := map[services.Capability][]string{
+		services.CapabilityReadFavorites:  {"library-read"},
+		services.CapabilityWriteFavorites: {"library-modify"},
+	}
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", CapabilityScopes: scopes, Library: []servicetest.Track{trackA}})
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target", CapabilityScopes: scopes, Library: []servicetest.Track{trackB}})
+	engine := newTestEngine(t, source, target)
+	engine.SetTokenSource(grantedTokens{"source": "library-read library-modify", "target": "library-read"})
+
+	result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeFrom})
+	if result.Success || len(result.Errors) != 1 || result.Errors[0].Type != "missing_scopes" {
+		t.Fatalf("errors = %+v, want one missing_scopes error", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0].Error, "library-modify") {
+		t.Errorf("error %q does not name the missing scope", result.Errors[0].Error)
+	}
+	if got := source.Calls(servicetest.OpGetUserData); got != 0 {
+		t.Errorf("source read %d times although the pair cannot run", got)
+	}
+
+	// Reading from the target only needs the scope it was granted
+	result = engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeTo})
+	if !result.Success {
+		t.Errorf("sync into the fully granted source failed: %+v", result.Errors)
+	}
+}
+
+// inspectedProvider reports its current grant, as Deezer does, whatever the tokens claim
+type inspectedProvider struct {
+	*servicetest.FakeProvider
+	granted []string
+}
+
+func (p inspectedProvider) GrantedScopes(context.Context, *services.OAuthTokens) ([]string, error) {
+	return p.granted, nil
+}
+
+func TestProcessServicePairInspectsGrantedScopes(t *testing.T) {
+	scopes := map[services.Capability][]string{
+		services.CapabilityReadFavorites:  {"library-read"},
+		services.CapabilityWriteFavorites: {"library-modify"},
+	}
+	source := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "source", CapabilityScopes: scopes, Library: []servicetest.Track{trackA}})
+	engine := newTestEngine(t, source)
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "target", CapabilityScopes: scopes})
+	if err := engine.oauth.Registry.Register(inspectedProvider{target, []string{"library-read"}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// The stored scopes still list the permission the user revoked since connecting
+	engine.SetTokenSource(grantedTokens{"source": "library-read", "target": "library-read library-modify"})
+
+	result := engine.runPair(t, ServicePair{SourceService: "source", TargetService: "target", SyncMode: SyncModeFrom})
+	if result.Success || len(result.Errors) != 1 || result.Errors[0].Type != "missing_scopes" {
+		t.Fatalf("errors = %+v, want one missing_scopes error", result.Errors)
+	}
+	if got := target.Calls(servicetest.OpSaveTrack); got != 0 {
+		t.Errorf("target written %d times without the permission", got)
+	}
+}
+
+func TestDisconnectCancelsQueuedJobs(t *testing.T) {
+	engine := newTestEngine(t)
+
+	queued := func(pairs ...ServicePair) *CrossServiceSyncRequest {
+		return &CrossServiceSyncRequest{
+			SyncJobRequest: &SyncJobRequest{UserID: "user-1", ServicePairs: pairs, SyncType: "favorites"},
+			QueuedAt:       time.Now(),
+		}
+	}
+	personalToFamily := ServicePair{SourceService: "spotify", SourceConnection: "personal", TargetService: "spotify", TargetConnection: "family", SyncMode: SyncModeFrom}
+	personalToDeezer := ServicePair{SourceService: "spotify", SourceConnection: "personal", TargetService: "deezer", TargetConnection: "deezer-1", SyncMode: SyncModeFrom}
+	familyToDeezer := ServicePair{SourceService: "spotify", SourceConnection: "family", TargetService: "deezer", TargetConnection: "deezer-1", SyncMode: SyncModeFrom}
+	legacyToDeezer := ServicePair{SourceService: "spotify", TargetService: "deezer", SyncMode: SyncModeFrom}
+
+	onlyFamily := queued(personalToFamily, familyToDeezer)
+	mixed := queued(personalToFamily, personalToDeezer)
+	legacy := queued(legacyToDeezer)
+	other := queued(personalToFamily)
+	other.UserID = "user-2"
+	engine.HandleDisconnect(context.Background(), "user-1", services.Connection{ID: "family", ServiceName: "spotify"})
+	later := queued(personalToFamily)
+
+	if _, keep := engine.withoutDisconnectedServices(onlyFamily, engine.logger); keep {
+		t.Error("kept a queued job whose pairs all use the disconnected account")
+	}
+
+	trimmed, keep := engine.withoutDisconnectedServices(mixed, engine.logger)
+	if !keep || len(trimmed.ServicePairs) != 1 || trimmed.ServicePairs[0] != personalToDeezer {
+		t.Errorf("trimmed job = %+v, %v, want only the pair of the other spotify account", trimmed, keep)
+	}
+	if len(mixed.ServicePairs) != 2 {
+		t.Error("trimming changed the original request, which may be shared with its schedule")
+	}
+
+	if _, keep := engine.withoutDisconnectedServices(legacy, engine.logger); keep {
+		t.Error("kept a job whose pair names the disconnected service without a connection")
+	}
+	if got, keep := engine.withoutDisconnectedServices(later, engine.logger); !keep || got != later {
+		t.Error("cancelled a job queued after the service was reconnected")
+	}
+	if _, keep := engine.withoutDisconnectedServices(other, engine.logger); !keep {
+		t.Error("cancelled another user's job")
+	}
+}
@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScheduleRun represents a single job spawned by a schedule
+type ScheduleRun struct {
+	JobID       string         `json:"job_id" db:"id"`
+	Status      SyncJobStatus  `json:"status" db:"status"`
+	ItemsSynced int            `json:"items_synced" db:"items_synced"`
+	ItemsFailed int            `json:"items_failed" db:"items_failed"`
+	DurationMs  int64          `json:"duration_ms" db:"duration_ms"`
+	ErrorCount  int            `json:"error_count" db:"error_count"`
+	ErrorTypes  pq.StringArray `json:"error_types" db:"error_types"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// ScheduleError describes the most recent error reported by a schedule's jobs
+type ScheduleError struct {
+	JobID      string    `json:"job_id" db:"job_id"`
+	Type       string    `json:"type" db:"type"`
+	Error      string    `json:"error" db:"error"`
+	OccurredAt time.Time `json:"occurred_at" db:"occurred_at"`
+}
+
+// ScheduleStats aggregates the performance of a schedule over a period
+type ScheduleStats struct {
+	TotalRuns          int            `json:"total_runs" db:"total_runs"`
+	SuccessfulRuns     int            `json:"successful_runs" db:"successful_runs"`
+	FailedRuns         int            `json:"failed_runs" db:"failed_runs"`
+	SuccessRate        float64        `json:"success_rate"`
+	AverageItemsSynced float64        `json:"average_items_synced" db:"avg_items_synced"`
+	AverageDurationMs  float64        `json:"average_duration_ms" db:"avg_duration_ms"`
+	LastRunAt          *time.Time     `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastError          *ScheduleError `json:"last_error,omitempty"`
+}
+
+// ScheduleHistory combines recent runs with statistics for a schedule
+type ScheduleHistory struct {
+	ScheduleID string        `json:"schedule_id"`
+	Since      time.Time     `json:"since"`
+	Stats      ScheduleStats `json:"stats"`
+	Runs       []ScheduleRun `json:"runs"`
+}
+
+// GetScheduleHistory returns recent runs and statistics for a schedule since the given time
+func (s *SyncScheduler) GetScheduleHistory(scheduleID string, since time.Time, limit int) (*ScheduleHistory, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	history := &ScheduleHistory{
+		ScheduleID: scheduleID,
+		Since:      since,
+		Runs:       []ScheduleRun{},
+	}
+
+	err := s.db.Select(&history.Runs, `
+		SELECT id, status,
+		       COALESCE(items_synced, 0) AS items_synced,
+		       COALESCE(items_failed, 0) AS items_failed,
+		       COALESCE(duration_ms, 0) AS duration_ms,
+		       COALESCE(error_count, 0) AS error_count,
+		       error_types, created_at, finished_at
+		FROM sync_jobs
+		WHERE schedule_id = $1 AND created_at >= $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, scheduleID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schedule runs: %w", err)
+	}
+
+	err = s.db.Get(&history.Stats, `
+		SELECT COUNT(*) AS total_runs,
+		       COUNT(CASE WHEN status = 'completed' THEN 1 END) AS successful_runs,
+		       COUNT(CASE WHEN status = 'failed' THEN 1 END) AS failed_runs,
+		       COALESCE(AVG(items_synced), 0) AS avg_items_synced,
+		       COALESCE(AVG(duration_ms), 0) AS avg_duration_ms,
+		       MAX(created_at) AS last_run_at
+		FROM sync_jobs
+		WHERE schedule_id = $1 AND created_at >= $2 AND finished_at IS NOT NULL
+	`, scheduleID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute schedule statistics: %w", err)
+	}
+
+	if history.Stats.TotalRuns > 0 {
+		history.Stats.SuccessRate = float64(history.Stats.SuccessfulRuns) / float64(history.Stats.TotalRuns)
+	}
+
+	var lastError ScheduleError
+	err = s.db.Get(&lastError, `
+		SELECT sj.id AS job_id,
+		       COALESCE(sr.result_data->'errors'->0->>'type', '') AS type,
+		       COALESCE(sr.result_data->'errors'->0->>'error', '') AS error,
+		       COALESCE(sj.finished_at, sj.created_at) AS occurred_at
+		FROM sync_jobs sj
+		JOIN sync_results sr ON sr.job_id = sj.id
+		WHERE sj.schedule_id = $1 AND sj.error_count > 0
+		ORDER BY sj.created_at DESC
+		LIMIT 1
+	`, scheduleID)
+	switch {
+	case err == nil:
+		history.Stats.LastError = &lastError
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("failed to load last schedule error: %w", err)
+	}
+
+	return history, nil
+}
@@ -1,72 +1,124 @@
 package sync
 
 import (
-	"log"
+	"log/slog"
 	"sync"
 	"time"
+
+	"syncer.net/core/metrics"
+)
+
+// Item outcomes reported to the metrics collector
+const (
+	ItemOutcomeSynced          = "synced"
+	ItemOutcomeFailed          = "failed"
+	ItemOutcomeTransformFailed = "transform_failed"
+	ItemOutcomeSkipped         = "skipped"
 )
 
-// SyncMetrics collects and tracks synchronization metrics
+// SyncMetrics collects and tracks synchronization metrics. Totals are exported to
+// Prometheus through the metrics package, and a per-process summary is kept for GetStats.
 type SyncMetrics struct {
-	mu           sync.RWMutex
-	jobsTotal    map[string]int
-	jobsSuccess  map[string]int
-	jobsFailure  map[string]int
-	itemsSynced  map[string]int
-	avgDurations map[string]time.Duration
-	lastSync     map[string]time.Time
-	logger       *log.Logger
+	mu             sync.RWMutex
+	jobsTotal      map[string]int
+	jobsSuccess    map[string]int
+	jobsFailure    map[string]int
+	itemsSynced    map[string]int
+	totalDurations map[string]time.Duration
+	lastSync       map[string]time.Time
+	logger         *slog.Logger
 }
 
 // NewSyncMetrics creates a new sync metrics collector
-func NewSyncMetrics() *SyncMetrics {
+func NewSyncMetrics(logger *slog.Logger) *SyncMetrics {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &SyncMetrics{
-		jobsTotal:    make(map[string]int),
-		jobsSuccess:  make(map[string]int),
-		jobsFailure:  make(map[string]int),
-		itemsSynced:  make(map[string]int),
-		avgDurations: make(map[string]time.Duration),
-		lastSync:     make(map[string]time.Time),
-		logger:       log.New(log.Writer(), "[SyncMetrics] ", log.LstdFlags),
+		jobsTotal:      make(map[string]int),
+		jobsSuccess:    make(map[string]int),
+		jobsFailure:    make(map[string]int),
+		itemsSynced:    make(map[string]int),
+		totalDurations: make(map[string]time.Duration),
+		lastSync:       make(map[string]time.Time),
+		logger:         logger,
 	}
 }
 
 // RecordSyncJobSuccess records a successful sync job
-func (m *SyncMetrics) RecordSyncJobSuccess(userID, syncType string, pairCount, itemsSynced int, duration time.Duration) {
+func (m *SyncMetrics) RecordSyncJobSuccess(trigger, syncType string, pairCount, itemsSynced int, duration time.Duration) {
+	m.recordJob(trigger, syncType, string(SyncStatusCompleted), duration)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	key := syncType
+	m.jobsSuccess[syncType]++
+	m.itemsSynced[syncType] += itemsSynced
+	m.lastSync[syncType] = time.Now()
+
+	m.logger.Debug("Recorded successful sync",
+		"trigger", trigger, "sync_type", syncType, "service_pairs", pairCount, "items_synced", itemsSynced, "duration", duration)
+}
 
-	m.jobsTotal[key]++
-	m.jobsSuccess[key]++
-	m.itemsSynced[key] += itemsSynced
-	m.lastSync[key] = time.Now()
+// RecordSyncJobFailure records a failed sync job
+func (m *SyncMetrics) RecordSyncJobFailure(trigger, syncType string, pairCount, itemsFailed int, duration time.Duration) {
+	m.recordJob(trigger, syncType, string(SyncStatusFailed), duration)
 
-	if prev, exists := m.avgDurations[key]; exists {
-		m.avgDurations[key] = (prev + duration) / 2
-	} else {
-		m.avgDurations[key] = duration
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobsFailure[syncType]++
 
-	m.logger.Printf("Recorded successful sync: type=%s, items=%d, duration=%v",
-		syncType, itemsSynced, duration)
+	m.logger.Debug("Recorded failed sync",
+		"trigger", trigger, "sync_type", syncType, "service_pairs", pairCount, "items_failed", itemsFailed)
 }
 
-// RecordSyncJobFailure records a failed sync job
-func (m *SyncMetrics) RecordSyncJobFailure(userID, syncType string, pairCount, itemsFailed int) {
+// recordJob updates the counters shared by successful and failed jobs
+func (m *SyncMetrics) recordJob(trigger, syncType, status string, duration time.Duration) {
+	metrics.SyncJobsTotal.WithLabelValues(trigger, syncType, status).Inc()
+	metrics.SyncJobDuration.WithLabelValues(trigger, syncType).Observe(duration.Seconds())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	key := syncType
+	m.jobsTotal[syncType]++
+	m.totalDurations[syncType] += duration
+}
 
-	m.jobsTotal[key]++
-	m.jobsFailure[key]++
+// RecordDirection records the duration of a single directional sync between two providers
+func (m *SyncMetrics) RecordDirection(source, target, syncType string, duration time.Duration) {
+	metrics.SyncPairDuration.WithLabelValues(source, target, syncType).Observe(duration.Seconds())
+}
 
-	m.logger.Printf("Recorded failed sync: type=%s, failures=%d", syncType, itemsFailed)
+// RecordItem records the outcome of processing a single item for a provider.
+// A zero duration skips the latency observation.
+func (m *SyncMetrics) RecordItem(provider, syncType, outcome string, duration time.Duration) {
+	metrics.SyncItemsTotal.WithLabelValues(provider, syncType, outcome).Inc()
+	if duration > 0 {
+		metrics.SyncItemDuration.WithLabelValues(provider, syncType).Observe(duration.Seconds())
+	}
 }
 
-// GetStats returns current sync statistics
+// RecordQueueDepth reports the current number of waiting jobs in a queue
+func (m *SyncMetrics) RecordQueueDepth(queue string, depth int) {
+	metrics.SyncQueueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+// SetWorkerPoolSize reports the configured number of workers in a pool
+func (m *SyncMetrics) SetWorkerPoolSize(pool string, size int) {
+	metrics.SyncWorkers.WithLabelValues(pool).Set(float64(size))
+}
+
+// WorkerBusy marks a worker in the pool as busy and returns a function marking it idle again
+func (m *SyncMetrics) WorkerBusy(pool string) func() {
+	gauge := metrics.SyncWorkersBusy.WithLabelValues(pool)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// GetStats returns statistics for this process since it started. Persistent, cross-replica
+// statistics are served from the daily rollups in the stats package.
 func (m *SyncMetrics) GetStats() SyncStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -95,13 +147,11 @@ func (m *SyncMetrics) GetStats() SyncStats {
 	}
 
 	var totalDuration time.Duration
-	durationCount := 0
-	for _, duration := range m.avgDurations {
+	for _, duration := range m.totalDurations {
 		totalDuration += duration
-		durationCount++
 	}
-	if durationCount > 0 {
-		avgDuration = totalDuration / time.Duration(durationCount)
+	if totalJobs > 0 {
+		avgDuration = totalDuration / time.Duration(totalJobs)
 	}
 
 	for _, syncTime := range m.lastSync {
@@ -125,17 +175,22 @@ func (m *SyncMetrics) GetStatsForSyncType(syncType string) map[string]any {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	var avgDuration time.Duration
+	if m.jobsTotal[syncType] > 0 {
+		avgDuration = m.totalDurations[syncType] / time.Duration(m.jobsTotal[syncType])
+	}
+
 	return map[string]any{
 		"total_jobs":      m.jobsTotal[syncType],
 		"successful_jobs": m.jobsSuccess[syncType],
 		"failed_jobs":     m.jobsFailure[syncType],
 		"items_synced":    m.itemsSynced[syncType],
-		"avg_duration":    m.avgDurations[syncType],
+		"avg_duration":    avgDuration,
 		"last_sync":       m.lastSync[syncType],
 	}
 }
 
-// Reset clears all metrics
+// Reset clears the in-process summary. Prometheus counters are cumulative and are not reset.
 func (m *SyncMetrics) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -144,8 +199,8 @@ func (m *SyncMetrics) Reset() {
 	m.jobsSuccess = make(map[string]int)
 	m.jobsFailure = make(map[string]int)
 	m.itemsSynced = make(map[string]int)
-	m.avgDurations = make(map[string]time.Duration)
+	m.totalDurations = make(map[string]time.Duration)
 	m.lastSync = make(map[string]time.Time)
 
-	m.logger.Printf("Metrics reset")
+	m.logger.Info("Metrics reset")
 }
@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+
+	"syncer.net/core/logging"
+	"syncer.net/core/services"
 )
 
 // SyncScheduler handles automatic background sync scheduling
@@ -16,30 +19,44 @@ type SyncScheduler struct {
 	db        *sqlx.DB
 	schedules map[string]*SyncJobRequest
 	ticker    *time.Ticker
-	logger    *log.Logger
+	policy    AdaptivePolicy
+	metrics   *SyncMetrics
+	logger    *slog.Logger
 	mu        sync.RWMutex
 }
 
 // NewSyncScheduler creates a new sync scheduler
-func NewSyncScheduler(db *sqlx.DB) *SyncScheduler {
+func NewSyncScheduler(db *sqlx.DB, metrics *SyncMetrics, logger *slog.Logger) *SyncScheduler {
 	return &SyncScheduler{
 		db:        db,
 		schedules: make(map[string]*SyncJobRequest),
 		ticker:    time.NewTicker(10 * time.Minute),
-		logger:    log.New(log.Writer(), "[SyncScheduler] ", log.LstdFlags),
+		policy:    DefaultAdaptivePolicy(),
+		metrics:   metrics,
+		logger:    logging.Component(logger, "sync_scheduler"),
 	}
 }
 
+// SetAdaptivePolicy replaces the policy used to back off idle and failing schedules
+func (s *SyncScheduler) SetAdaptivePolicy(policy AdaptivePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policy = policy
+}
+
 // Start begins the automatic sync scheduler
 func (s *SyncScheduler) Start(ctx context.Context, autoQueue chan *CrossServiceSyncRequest) {
-	s.logger.Printf("Starting automatic sync scheduler")
+	s.logger.Info("Starting automatic sync scheduler")
 
 	s.loadSchedules()
+	// Schedules that fell due while the process was down run now rather than a tick later
+	s.checkScheduledSyncs(autoQueue)
 
 	for {
 		select {
 		case <-ctx.Done():
-			s.logger.Printf("Sync scheduler stopping due to context cancellation")
+			s.logger.Info("Sync scheduler stopping due to context cancellation")
 			return
 		case <-s.ticker.C:
 			s.checkScheduledSyncs(autoQueue)
@@ -66,8 +83,9 @@ func (s *SyncScheduler) Schedule(req *SyncJobRequest) error {
 	}
 	s.schedules[scheduleID] = req
 
-	s.logger.Printf("Scheduled automatic sync for user %s: type=%s, frequency=%v, next_run=%v",
-		req.UserID, req.SyncType, req.Schedule.Frequency, req.Schedule.NextRun)
+	s.logger.Info("Scheduled automatic sync",
+		"schedule_id", scheduleID, logging.KeyUserID, req.UserID, "sync_type", req.SyncType,
+		"frequency", req.Schedule.Frequency, "next_run", req.Schedule.NextRun)
 
 	return nil
 }
@@ -88,14 +106,79 @@ func (s *SyncScheduler) Unschedule(scheduleID string) error {
 		return fmt.Errorf("failed to remove schedule: %w", err)
 	}
 
-	s.logger.Printf("Unscheduled automatic sync for schedule %s", scheduleID)
+	s.logger.Info("Unscheduled automatic sync", "schedule_id", scheduleID)
 	return nil
 }
 
+// RemoveConnection takes a disconnected account out of the user's schedules. Schedules with other
+// pairs left are rewritten without it; schedules that only used it are disabled.
+func (s *SyncScheduler) RemoveConnection(userID string, connection services.Connection) (rewritten int, disabled int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []struct {
+		ID           string `db:"id"`
+		ScheduleData []byte `db:"schedule_data"`
+	}
+	err = s.db.Select(&rows, "SELECT id, schedule_data FROM sync_schedules WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load schedules: %w", err)
+	}
+
+	for _, row := range rows {
+		current, loaded := s.schedules[row.ID]
+		if !loaded {
+			current = &SyncJobRequest{}
+			if err := json.Unmarshal(row.ScheduleData, current); err != nil {
+				s.logger.Error("Failed to unmarshal schedule data", "schedule_id", row.ID, "error", err)
+				continue
+			}
+		}
+		if current.Schedule == nil {
+			continue
+		}
+
+		pairs := make([]ServicePair, 0, len(current.ServicePairs))
+		for _, pair := range current.ServicePairs {
+			if !pair.UsesConnection(connection.ID, connection.ServiceName) {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == len(current.ServicePairs) {
+			continue
+		}
+
+		// Queued jobs may hold the current request, so the change is made on a copy
+		req := *current
+		schedule := *current.Schedule
+		req.Schedule = &schedule
+		if len(pairs) == 0 {
+			req.Schedule.Enabled = false
+			req.Schedule.DisabledReason = fmt.Sprintf("%s was disconnected - reconnect the service and enable the schedule to resume", connection.ServiceName)
+			req.Schedule.AdjustmentReason = ""
+			disabled++
+		} else {
+			req.ServicePairs = pairs
+			rewritten++
+		}
+
+		if _, err := s.saveScheduleToDatabase(&req); err != nil {
+			return rewritten, disabled, fmt.Errorf("failed to update schedule %s: %w", row.ID, err)
+		}
+		if loaded {
+			s.schedules[row.ID] = &req
+		}
+		s.logger.Info("Removed disconnected service from schedule",
+			"schedule_id", row.ID, logging.KeyUserID, userID, logging.KeyProvider, connection.ServiceName, "enabled", req.Schedule.Enabled)
+	}
+
+	return rewritten, disabled, nil
+}
+
 // checkScheduledSyncs looks for sync jobs that are due to run
 func (s *SyncScheduler) checkScheduledSyncs(autoQueue chan *CrossServiceSyncRequest) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	now := time.Now()
 	scheduled := 0
@@ -106,32 +189,89 @@ func (s *SyncScheduler) checkScheduledSyncs(autoQueue chan *CrossServiceSyncRequ
 		}
 
 		if now.After(req.Schedule.NextRun) {
+			adjustment, err := s.evaluateSchedule(scheduleID, req)
+			if err != nil {
+				s.logger.Warn("Failed to evaluate schedule history, using base frequency", "schedule_id", scheduleID, "error", err)
+				adjustment = ScheduleAdjustment{EffectiveFrequency: req.Schedule.Frequency}
+			}
+
+			if adjustment.Disable {
+				req.Schedule.Enabled = false
+				req.Schedule.DisabledReason = adjustment.Reason
+				req.Schedule.AdjustmentReason = ""
+
+				if _, err := s.saveScheduleToDatabase(req); err != nil {
+					s.logger.Error("Failed to disable schedule", "schedule_id", scheduleID, "error", err)
+				}
+				s.logger.Warn("Disabled schedule", "schedule_id", scheduleID, logging.KeyUserID, req.UserID, "reason", adjustment.Reason)
+				continue
+			}
+
 			crossServiceReq := &CrossServiceSyncRequest{
 				SyncJobRequest: req,
 				Priority:       PriorityLow,
 				RequestedBy:    "system",
+				ScheduleID:     scheduleID,
+				QueuedAt:       now,
 			}
 
 			select {
 			case autoQueue <- crossServiceReq:
-				s.logger.Printf("Queued automatic sync for user %s, type %s", req.UserID, req.SyncType)
+				s.metrics.RecordQueueDepth("automatic", len(autoQueue))
+				s.logger.Info("Queued automatic sync", "schedule_id", scheduleID, logging.KeyUserID, req.UserID, "sync_type", req.SyncType)
 				scheduled++
 
-				req.Schedule.NextRun = now.Add(req.Schedule.Frequency)
+				req.Schedule.EffectiveFrequency = adjustment.EffectiveFrequency
+				req.Schedule.AdjustmentReason = adjustment.Reason
+				req.Schedule.NextRun = now.Add(adjustment.EffectiveFrequency)
+
+				if adjustment.Reason != "" {
+					s.logger.Info("Adjusted schedule frequency",
+						"schedule_id", scheduleID, "effective_frequency", adjustment.EffectiveFrequency, "reason", adjustment.Reason)
+				}
 
 				if _, err := s.saveScheduleToDatabase(req); err != nil {
-					s.logger.Printf("Failed to update next run time for schedule %s: %v", scheduleID, err)
+					s.logger.Error("Failed to update next run time", "schedule_id", scheduleID, "error", err)
 				}
 
 			default:
-				s.logger.Printf("Failed to queue automatic sync - queue full")
+				s.logger.Warn("Failed to queue automatic sync, queue full", "schedule_id", scheduleID)
 			}
 		}
 	}
 
 	if scheduled > 0 {
-		s.logger.Printf("Scheduled %d automatic sync jobs", scheduled)
+		s.logger.Info("Queued scheduled sync jobs", "count", scheduled)
+	}
+}
+
+// evaluateSchedule applies the adaptive policy to the schedule's recent job history
+func (s *SyncScheduler) evaluateSchedule(scheduleID string, req *SyncJobRequest) (ScheduleAdjustment, error) {
+	outcomes, err := s.getRecentOutcomes(scheduleID, req)
+	if err != nil {
+		return ScheduleAdjustment{}, err
+	}
+
+	return s.policy.Evaluate(req.Schedule.Frequency, outcomes), nil
+}
+
+// getRecentOutcomes loads the most recent finished scheduled jobs for a schedule, newest first.
+// Jobs cancelled for a provider outage never ran, so they do not take up the history window.
+func (s *SyncScheduler) getRecentOutcomes(scheduleID string, req *SyncJobRequest) ([]JobOutcome, error) {
+	var outcomes []JobOutcome
+	err := s.db.Select(&outcomes, `
+		SELECT status, COALESCE(items_synced, 0) AS items_synced, error_types, created_at
+		FROM sync_jobs
+		WHERE schedule_id = $1 AND finished_at IS NOT NULL AND created_at >= $2 AND status <> 'cancelled'
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, scheduleID, req.Schedule.EnabledAt, s.policy.HistoryWindow)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent scheduled jobs: %w", err)
 	}
+
+	return outcomes, nil
 }
 
 // GetSchedules returns all active schedules for a user
@@ -199,16 +339,31 @@ func (s *SyncScheduler) saveScheduleToDatabase(req *SyncJobRequest) (string, err
 		return "", fmt.Errorf("failed to marshal schedule: %w", err)
 	}
 
+	effectiveFrequency := req.Schedule.EffectiveFrequency
+	if effectiveFrequency == 0 {
+		effectiveFrequency = req.Schedule.Frequency
+	}
+
 	err = s.db.QueryRow(`
-		INSERT INTO sync_schedules (user_id, sync_type, schedule_data, next_run, enabled, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		INSERT INTO sync_schedules (
+			user_id, sync_type, schedule_data, next_run, enabled,
+			effective_frequency_ms, adjustment_reason, disabled_reason, disabled_at,
+			created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''),
+			CASE WHEN $5 THEN NULL ELSE NOW() END, NOW(), NOW())
 		ON CONFLICT (user_id, sync_type) DO UPDATE SET
 			schedule_data = $3,
 			next_run = $4,
 			enabled = $5,
+			effective_frequency_ms = $6,
+			adjustment_reason = NULLIF($7, ''),
+			disabled_reason = NULLIF($8, ''),
+			disabled_at = CASE WHEN $5 THEN NULL ELSE COALESCE(sync_schedules.disabled_at, NOW()) END,
 			updated_at = NOW()
 		RETURNING id
-	`, req.UserID, req.SyncType, scheduleData, req.Schedule.NextRun, req.Schedule.Enabled).Scan(&scheduleID)
+	`, req.UserID, req.SyncType, scheduleData, req.Schedule.NextRun, req.Schedule.Enabled,
+		effectiveFrequency.Milliseconds(), req.Schedule.AdjustmentReason, req.Schedule.DisabledReason).Scan(&scheduleID)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to save schedule: %w", err)
@@ -217,47 +372,110 @@ func (s *SyncScheduler) saveScheduleToDatabase(req *SyncJobRequest) (string, err
 	return scheduleID, nil
 }
 
-// loadSchedules loads existing sync schedules from database
-func (s *SyncScheduler) loadSchedules() {
-	s.logger.Printf("Loading existing sync schedules from database")
+// scheduleRow is a sync_schedules row. The columns are kept up to date by direct updates such
+// as re-enabling through the API, so they win over the copy of the schedule in schedule_data.
+type scheduleRow struct {
+	ID                 string    `db:"id"`
+	ScheduleData       []byte    `db:"schedule_data"`
+	Enabled            bool      `db:"enabled"`
+	NextRun            time.Time `db:"next_run"`
+	EffectiveFrequency *int64    `db:"effective_frequency_ms"`
+	AdjustmentReason   *string   `db:"adjustment_reason"`
+	DisabledReason     *string   `db:"disabled_reason"`
+}
+
+const scheduleColumns = `id, schedule_data, enabled, next_run, effective_frequency_ms, adjustment_reason, disabled_reason`
 
-	rows, err := s.db.Query(`
-		SELECT id, schedule_data FROM sync_schedules
-		WHERE enabled = true AND next_run > NOW()
-	`)
+// request decodes the schedule and overlays the stored columns
+func (row scheduleRow) request() (*SyncJobRequest, error) {
+	req := &SyncJobRequest{}
+	if err := json.Unmarshal(row.ScheduleData, req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule data: %w", err)
+	}
+	if req.Schedule == nil {
+		return nil, fmt.Errorf("invalid schedule configuration")
+	}
+
+	req.Schedule.Enabled = row.Enabled
+	req.Schedule.NextRun = row.NextRun
+	req.Schedule.EffectiveFrequency = 0
+	if row.EffectiveFrequency != nil {
+		req.Schedule.EffectiveFrequency = time.Duration(*row.EffectiveFrequency) * time.Millisecond
+	}
+	req.Schedule.AdjustmentReason = ""
+	if row.AdjustmentReason != nil {
+		req.Schedule.AdjustmentReason = *row.AdjustmentReason
+	}
+	req.Schedule.DisabledReason = ""
+	if row.DisabledReason != nil {
+		req.Schedule.DisabledReason = *row.DisabledReason
+	}
+	return req, nil
+}
+
+// ReloadSchedule replaces the in-memory copy of a schedule with its row in the database, after it
+// was changed there directly
+func (s *SyncScheduler) ReloadSchedule(scheduleID string) error {
+	var row scheduleRow
+	err := s.db.Get(&row, "SELECT "+scheduleColumns+" FROM sync_schedules WHERE id = $1", scheduleID)
+	if err != nil {
+		return fmt.Errorf("failed to load schedule: %w", err)
+	}
+
+	req, err := row.request()
 	if err != nil {
-		s.logger.Printf("Failed to load schedules: %v", err)
+		return err
+	}
+
+	// Queued jobs may still hold the previous request, so it is replaced rather than changed
+	s.mu.Lock()
+	s.schedules[scheduleID] = req
+	s.mu.Unlock()
+
+	s.logger.Info("Reloaded schedule", "schedule_id", scheduleID, "enabled", req.Schedule.Enabled,
+		"frequency", req.Schedule.Frequency, "next_run", req.Schedule.NextRun)
+	return nil
+}
+
+// loadSchedules loads every sync schedule from the database. Disabled schedules are kept so they
+// can be enabled again, and schedules that fell due while the process was down run at the next
+// check.
+func (s *SyncScheduler) loadSchedules() {
+	s.logger.Info("Loading existing sync schedules from database")
+
+	var rows []scheduleRow
+	if err := s.db.Select(&rows, "SELECT "+scheduleColumns+" FROM sync_schedules"); err != nil {
+		s.logger.Error("Failed to load schedules", "error", err)
 		return
 	}
-	defer rows.Close()
 
-	count := 0
-	for rows.Next() {
-		var scheduleID string
-		var scheduleData []byte
-		if err := rows.Scan(&scheduleID, &scheduleData); err != nil {
-			s.logger.Printf("Failed to scan schedule data: %v", err)
-			continue
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		var req SyncJobRequest
-		if err := json.Unmarshal(scheduleData, &req); err != nil {
-			s.logger.Printf("Failed to unmarshal schedule data: %v", err)
+	count, overdue := 0, 0
+	now := time.Now()
+	for _, row := range rows {
+		req, err := row.request()
+		if err != nil {
+			s.logger.Error("Failed to load schedule", "schedule_id", row.ID, "error", err)
 			continue
 		}
 
-		s.schedules[scheduleID] = &req
+		s.schedules[row.ID] = req
 		count++
+		if req.Schedule.Enabled && !now.Before(req.Schedule.NextRun) {
+			overdue++
+		}
 	}
 
-	s.logger.Printf("Loaded %d existing sync schedules", count)
+	s.logger.Info("Loaded existing sync schedules", "count", count, "overdue", overdue)
 }
 
 // Stop stops the scheduler
 func (s *SyncScheduler) Stop() {
 	if s.ticker != nil {
 		s.ticker.Stop()
-		s.logger.Printf("Sync scheduler stopped")
+		s.logger.Info("Sync scheduler stopped")
 	}
 }
 
@@ -276,14 +494,18 @@ func (s *SyncScheduler) EnableSchedule(scheduleID string) error {
 	}
 
 	req.Schedule.Enabled = true
+	req.Schedule.EnabledAt = time.Now()
 	req.Schedule.NextRun = time.Now().Add(req.Schedule.Frequency)
+	req.Schedule.EffectiveFrequency = req.Schedule.Frequency
+	req.Schedule.AdjustmentReason = ""
+	req.Schedule.DisabledReason = ""
 
 	_, err := s.saveScheduleToDatabase(req)
 	if err != nil {
 		return fmt.Errorf("failed to enable schedule: %w", err)
 	}
 
-	s.logger.Printf("Enabled schedule for schedule %s", scheduleID)
+	s.logger.Info("Enabled schedule", "schedule_id", scheduleID)
 	return nil
 }
 
@@ -308,7 +530,7 @@ func (s *SyncScheduler) DisableSchedule(scheduleID string) error {
 		return fmt.Errorf("failed to disable schedule: %w", err)
 	}
 
-	s.logger.Printf("Disabled schedule for schedule %s", scheduleID)
+	s.logger.Info("Disabled schedule", "schedule_id", scheduleID)
 	return nil
 }
 
@@ -331,6 +553,8 @@ func (s *SyncScheduler) UpdateScheduleFrequency(scheduleID string, frequency tim
 	}
 
 	req.Schedule.Frequency = frequency
+	req.Schedule.EffectiveFrequency = frequency
+	req.Schedule.AdjustmentReason = ""
 	req.Schedule.NextRun = time.Now().Add(frequency)
 
 	_, err := s.saveScheduleToDatabase(req)
@@ -338,6 +562,6 @@ func (s *SyncScheduler) UpdateScheduleFrequency(scheduleID string, frequency tim
 		return fmt.Errorf("failed to update schedule frequency: %w", err)
 	}
 
-	s.logger.Printf("Updated schedule frequency for schedule %s to %v", scheduleID, frequency)
+	s.logger.Info("Updated schedule frequency", "schedule_id", scheduleID, "frequency", frequency)
 	return nil
 }
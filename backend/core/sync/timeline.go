@@ -0,0 +1,149 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LogLevel is the severity of a sync log entry
+type LogLevel string
+
+const (
+	LogLevelDebug   LogLevel = "debug"
+	LogLevelInfo    LogLevel = "info"
+	LogLevelWarning LogLevel = "warning"
+	LogLevelError   LogLevel = "error"
+)
+
+// SyncPhase identifies the stage of a job a log entry belongs to
+type SyncPhase string
+
+const (
+	PhaseJob       SyncPhase = "job"
+	PhasePair      SyncPhase = "pair"
+	PhaseFetch     SyncPhase = "fetch"
+	PhaseTransform SyncPhase = "transform"
+	PhaseAdd       SyncPhase = "add"
+)
+
+// SyncLogEntry is a single persisted entry in a job's timeline
+type SyncLogEntry struct {
+	ID            string          `json:"id" db:"id"`
+	JobID         string          `json:"job_id" db:"sync_job_id"`
+	Level         LogLevel        `json:"level" db:"level"`
+	Phase         SyncPhase       `json:"phase" db:"phase"`
+	Message       string          `json:"message" db:"message"`
+	SyncType      *string         `json:"sync_type,omitempty" db:"sync_type"`
+	SourceService *string         `json:"source_service,omitempty" db:"source_service"`
+	TargetService *string         `json:"target_service,omitempty" db:"target_service"`
+	SyncDirection *string         `json:"sync_direction,omitempty" db:"sync_direction"`
+	ItemID        *string         `json:"item_id,omitempty" db:"item_id"`
+	Details       json.RawMessage `json:"details,omitempty" db:"details"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// JobTimeline is a page of a job's log entries
+type JobTimeline struct {
+	JobID   string         `json:"job_id"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	Entries []SyncLogEntry `json:"entries"`
+}
+
+// timelineWriter persists log entries for a single job. Entries written for a pair carry the
+// configured pair and mode so the service_pair_stats view can attribute them.
+type timelineWriter struct {
+	db       *sqlx.DB
+	logger   *slog.Logger
+	jobID    string
+	syncType string
+	pair     *ServicePair
+	disabled bool
+}
+
+// forPair returns a writer whose entries are attributed to the given service pair
+func (w *timelineWriter) forPair(pair ServicePair) *timelineWriter {
+	scoped := *w
+	scoped.pair = &pair
+	return &scoped
+}
+
+// record writes an entry for the job. Failures are logged and never interrupt the sync.
+func (w *timelineWriter) record(ctx context.Context, level LogLevel, phase SyncPhase, message string, details map[string]any) {
+	w.recordItem(ctx, level, phase, "", message, details)
+}
+
+// recordItem writes an entry referencing a single item
+func (w *timelineWriter) recordItem(ctx context.Context, level LogLevel, phase SyncPhase, itemID, message string, details map[string]any) {
+	if w == nil || w.disabled {
+		return
+	}
+
+	if details == nil {
+		details = map[string]any{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		w.logger.WarnContext(ctx, "Failed to encode sync log details", "error", err)
+		detailsJSON = []byte("{}")
+	}
+
+	var source, target, direction string
+	if w.pair != nil {
+		source, target, direction = w.pair.SourceService, w.pair.TargetService, string(w.pair.SyncMode)
+	}
+
+	_, err = w.db.ExecContext(ctx, `
+		INSERT INTO sync_logs (
+			sync_job_id, level, phase, message, sync_type,
+			source_service, target_service, sync_direction, item_id, details
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10)
+	`, w.jobID, level, phase, message, w.syncType, source, target, direction, itemID, detailsJSON)
+	if err != nil {
+		w.logger.WarnContext(ctx, "Failed to write sync log entry", "phase", phase, "error", err)
+	}
+}
+
+// GetJobTimeline returns a page of a job's log entries in chronological order.
+// An empty level returns entries of every severity.
+func (e *SyncEngine) GetJobTimeline(jobID string, level LogLevel, limit, offset int) (*JobTimeline, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	timeline := &JobTimeline{
+		JobID:   jobID,
+		Limit:   limit,
+		Offset:  offset,
+		Entries: []SyncLogEntry{},
+	}
+
+	err := e.db.Get(&timeline.Total, `
+		SELECT COUNT(*) FROM sync_logs
+		WHERE sync_job_id = $1 AND ($2 = '' OR level = $2)
+	`, jobID, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sync log entries: %w", err)
+	}
+
+	err = e.db.Select(&timeline.Entries, `
+		SELECT id, sync_job_id, level, phase, message, sync_type,
+		       source_service, target_service, sync_direction, item_id,
+		       details, created_at
+		FROM sync_logs
+		WHERE sync_job_id = $1 AND ($2 = '' OR level = $2)
+		ORDER BY created_at, id
+		LIMIT $3 OFFSET $4
+	`, jobID, level, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync log entries: %w", err)
+	}
+
+	return timeline, nil
+}
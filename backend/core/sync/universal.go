@@ -6,6 +6,8 @@ import (
 	"slices"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"syncer.net/core/services"
 )
 
@@ -15,6 +17,7 @@ type DataTransformer interface {
 	FindBestMatch(sourceItem UniversalItem, candidates []UniversalItem, threshold float64) UniversalMatch
 	AnalyzeMatches(matches []UniversalMatch) map[string]any
 	MatchesSyncType(itemType string, syncType string) bool
+	SyncTypeRequirements(syncType string) (SyncTypeRequirements, bool)
 }
 
 // CrossServiceAdder defines the interface for adding items to services
@@ -22,6 +25,12 @@ type CrossServiceAdder interface {
 	AddItemToService(ctx context.Context, targetService services.ServiceProvider, tokens *services.OAuthTokens, universalItem UniversalItem, options any) error
 }
 
+// TokenSource resolves the OAuth tokens of a user's connection. An empty connection id selects
+// the user's only connection to the service.
+type TokenSource interface {
+	UserTokens(userID, serviceName, connectionID string) (*services.OAuthTokens, error)
+}
+
 // SyncJobRequest defines a sync operation between paired services
 type SyncJobRequest struct {
 	UserID       string        `json:"user_id"`
@@ -33,11 +42,35 @@ type SyncJobRequest struct {
 	Schedule     *SyncSchedule `json:"schedule,omitempty"`
 }
 
-// ServicePair defines a sync relationship between two services with direction
+// ServicePair defines a sync relationship between two connected accounts with direction.
+// Each side names a connection, a service, or both; ResolveConnections fills in the other.
 type ServicePair struct {
-	SourceService string   `json:"source_service" binding:"required"`
-	TargetService string   `json:"target_service" binding:"required"`
-	SyncMode      SyncMode `json:"sync_mode" binding:"required"`
+	SourceService    string   `json:"source_service"`
+	TargetService    string   `json:"target_service"`
+	SourceConnection string   `json:"source_connection,omitempty"`
+	TargetConnection string   `json:"target_connection,omitempty"`
+	SyncMode         SyncMode `json:"sync_mode" binding:"required"`
+}
+
+// UsesConnection reports whether either side of the pair is the connection. Sides without a
+// connection, saved before pairs referenced connections, match any connection of their service.
+func (p ServicePair) UsesConnection(connectionID, serviceName string) bool {
+	uses := func(connection, service string) bool {
+		if connection != "" {
+			return connection == connectionID
+		}
+		return service == serviceName
+	}
+	return uses(p.SourceConnection, p.SourceService) || uses(p.TargetConnection, p.TargetService)
+}
+
+// sameAccount reports whether both sides may be the same account. Sides of one service can only
+// be told apart by their connections.
+func (p ServicePair) sameAccount() bool {
+	if p.SourceConnection != "" && p.TargetConnection != "" {
+		return p.SourceConnection == p.TargetConnection
+	}
+	return p.SourceService == p.TargetService
 }
 
 // SyncMode defines the direction of synchronization
@@ -51,9 +84,13 @@ const (
 
 // SyncSchedule defines automatic background sync configuration
 type SyncSchedule struct {
-	Enabled   bool          `json:"enabled"`
-	Frequency time.Duration `json:"frequency"`
-	NextRun   time.Time     `json:"next_run"`
+	Enabled            bool          `json:"enabled"`
+	Frequency          time.Duration `json:"frequency"`
+	NextRun            time.Time     `json:"next_run"`
+	EffectiveFrequency time.Duration `json:"effective_frequency,omitempty"` // Frequency after adaptive backoff
+	AdjustmentReason   string        `json:"adjustment_reason,omitempty"`
+	DisabledReason     string        `json:"disabled_reason,omitempty"`
+	EnabledAt          time.Time     `json:"enabled_at,omitempty"` // History before this point is ignored
 }
 
 // SyncOptions defines options for sync operations
@@ -116,6 +153,16 @@ type CrossServiceSyncRequest struct {
 	*SyncJobRequest
 	Priority    SyncPriority `json:"priority"`
 	RequestedBy string       `json:"requested_by"`
+	ScheduleID  string       `json:"schedule_id,omitempty"` // Set when spawned by a schedule
+
+	// Origin is the span that queued the job; the job span links back to it
+	Origin trace.SpanContext `json:"-"`
+
+	// Deferrals counts how often the job was postponed because a provider's circuit was open
+	Deferrals int `json:"-"`
+
+	// QueuedAt is when the job entered a queue; services disconnected later are skipped
+	QueuedAt time.Time `json:"-"`
 }
 
 // SyncResult represents the result of a cross-service sync operation
@@ -132,14 +179,16 @@ type CrossServiceSyncResult struct {
 
 // ServicePairResult represents the result for a single service pair
 type ServicePairResult struct {
-	SourceService string               `json:"source_service"`
-	TargetService string               `json:"target_service"`
-	SyncMode      SyncMode             `json:"sync_mode"`
-	Success       bool                 `json:"success"`
-	ItemsSynced   []UniversalItem      `json:"items_synced"`
-	ItemsFailed   []UniversalItem      `json:"items_failed"`
-	Errors        []services.SyncError `json:"errors"`
-	Duration      time.Duration        `json:"duration"`
+	SourceService    string               `json:"source_service"`
+	TargetService    string               `json:"target_service"`
+	SourceConnection string               `json:"source_connection,omitempty"`
+	TargetConnection string               `json:"target_connection,omitempty"`
+	SyncMode         SyncMode             `json:"sync_mode"`
+	Success          bool                 `json:"success"`
+	ItemsSynced      []UniversalItem      `json:"items_synced"`
+	ItemsFailed      []UniversalItem      `json:"items_failed"`
+	Errors           []services.SyncError `json:"errors"`
+	Duration         time.Duration        `json:"duration"`
 }
 
 // SyncStats represents statistics about sync operations
@@ -152,8 +201,9 @@ type SyncStats struct {
 	LastSyncAt       time.Time     `json:"last_sync_at"`
 }
 
-// ValidateSyncJobRequest validates a sync job request
-func (r *SyncJobRequest) Validate() error {
+// Validate validates a sync job request and fills in default options.
+// A non-nil checker also rejects pairs whose providers lack the capabilities the sync type needs.
+func (r *SyncJobRequest) Validate(checker CapabilityChecker) error {
 	if len(r.ServicePairs) == 0 {
 		return fmt.Errorf("at least one service pair is required - cannot sync a service alone")
 	}
@@ -173,8 +223,8 @@ func (r *SyncJobRequest) Validate() error {
 		if pair.TargetService == "" {
 			return fmt.Errorf("service pair %d: target service is required", i)
 		}
-		if pair.SourceService == pair.TargetService {
-			return fmt.Errorf("service pair %d: source and target services must be different", i)
+		if pair.sameAccount() {
+			return fmt.Errorf("service pair %d: source and target must be different accounts", i)
 		}
 		if pair.SyncMode == "" {
 			return fmt.Errorf("service pair %d: sync mode is required", i)
@@ -184,6 +234,12 @@ func (r *SyncJobRequest) Validate() error {
 		if !slices.Contains(validModes, pair.SyncMode) {
 			return fmt.Errorf("service pair %d: invalid sync mode %s", i, pair.SyncMode)
 		}
+
+		if checker != nil {
+			if err := checker.CheckPair(pair, r.SyncType); err != nil {
+				return fmt.Errorf("service pair %d: %w", i, err)
+			}
+		}
 	}
 
 	if r.SyncOptions.MatchThreshold < 0 || r.SyncOptions.MatchThreshold > 1 {
@@ -201,6 +257,60 @@ func (r *SyncJobRequest) Validate() error {
 	return nil
 }
 
+// ResolveConnections completes every pair from the user's connections: a side naming a
+// connection gets its service, and a side naming only a service gets the user's connection to it.
+// Services with several connected accounts need the connection to be named.
+func (r *SyncJobRequest) ResolveConnections(connections []services.Connection) error {
+	for i := range r.ServicePairs {
+		pair := &r.ServicePairs[i]
+		if err := resolveConnection(&pair.SourceService, &pair.SourceConnection, connections); err != nil {
+			return fmt.Errorf("service pair %d: source %w", i, err)
+		}
+		if err := resolveConnection(&pair.TargetService, &pair.TargetConnection, connections); err != nil {
+			return fmt.Errorf("service pair %d: target %w", i, err)
+		}
+	}
+	return nil
+}
+
+// resolveConnection fills in the service or connection of one side of a pair
+func resolveConnection(serviceName, connectionID *string, connections []services.Connection) error {
+	if *connectionID != "" {
+		for _, connection := range connections {
+			if connection.ID != *connectionID {
+				continue
+			}
+			if *serviceName != "" && *serviceName != connection.ServiceName {
+				return fmt.Errorf("connection %s is a %s account, not %s", connection.ID, connection.ServiceName, *serviceName)
+			}
+			*serviceName = connection.ServiceName
+			return nil
+		}
+		return fmt.Errorf("connection %s: %w", *connectionID, services.ErrNotConnected)
+	}
+
+	if *serviceName == "" {
+		return fmt.Errorf("service or connection is required")
+	}
+
+	var matches []services.Connection
+	for _, connection := range connections {
+		if connection.ServiceName == *serviceName {
+			matches = append(matches, connection)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("service %s: %w", *serviceName, services.ErrNotConnected)
+	case 1:
+		*connectionID = matches[0].ID
+		return nil
+	default:
+		return fmt.Errorf("service %s has %d connected accounts, choose one by its connection", *serviceName, len(matches))
+	}
+}
+
 // GetDescription returns a human-readable description of the sync job
 func (r *SyncJobRequest) GetDescription() string {
 	description := fmt.Sprintf("Sync %s between %d service pairs", r.SyncType, len(r.ServicePairs))
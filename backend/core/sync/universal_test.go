@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+
+	"syncer.net/core/services"
+)
+
+func TestResolveConnections(t *testing.T) {
+	connections := []services.Connection{
+		{ID: "personal", ServiceName: "spotify", Label: "Personal"},
+		{ID: "family", ServiceName: "spotify", Label: "Family"},
+		{ID: "deezer-1", ServiceName: "deezer"},
+	}
+
+	tests := []struct {
+		name    string
+		pair    ServicePair
+		want    ServicePair
+		wantErr string
+	}{
+		{
+			name: "connections fill in services",
+			pair: ServicePair{SourceConnection: "personal", TargetConnection: "family", SyncMode: SyncModeFrom},
+			want: ServicePair{SourceService: "spotify", SourceConnection: "personal", TargetService: "spotify", TargetConnection: "family", SyncMode: SyncModeFrom},
+		},
+		{
+			name: "single account services fill in connections",
+			pair: ServicePair{SourceConnection: "family", TargetService: "deezer", SyncMode: SyncModeTo},
+			want: ServicePair{SourceService: "spotify", SourceConnection: "family", TargetService: "deezer", TargetConnection: "deezer-1", SyncMode: SyncModeTo},
+		},
+		{
+			name:    "service with several accounts",
+			pair:    ServicePair{SourceService: "spotify", TargetService: "deezer", SyncMode: SyncModeFrom},
+			wantErr: "2 connected accounts",
+		},
+		{
+			name:    "connection of another service",
+			pair:    ServicePair{SourceService: "deezer", SourceConnection: "personal", TargetService: "spotify", TargetConnection: "family", SyncMode: SyncModeFrom},
+			wantErr: "is a spotify account",
+		},
+		{
+			name:    "unknown connection",
+			pair:    ServicePair{SourceConnection: "someone-else", TargetConnection: "family", SyncMode: SyncModeFrom},
+			wantErr: services.ErrNotConnected.Error(),
+		},
+		{
+			name:    "service not connected",
+			pair:    ServicePair{SourceConnection: "personal", TargetService: "tidal", SyncMode: SyncModeFrom},
+			wantErr: services.ErrNotConnected.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &SyncJobRequest{UserID: "user-1", SyncType: "favorites", ServicePairs: []ServicePair{tt.pair}}
+			err := req.ResolveConnections(connections)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveConnections = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveConnections: %v", err)
+			}
+			if req.ServicePairs[0] != tt.want {
+				t.Errorf("resolved pair = %+v, want %+v", req.ServicePairs[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAllowsSameServiceAcrossAccounts(t *testing.T) {
+	accounts := &SyncJobRequest{UserID: "user-1", SyncType: "favorites", ServicePairs: []ServicePair{
+		{SourceService: "spotify", SourceConnection: "personal", TargetService: "spotify", TargetConnection: "family", SyncMode: SyncModeFrom},
+	}}
+	if err := accounts.Validate(nil); err != nil {
+		t.Errorf("Validate rejected a pair of two accounts of one service: %v", err)
+	}
+
+	sameAccount := &SyncJobRequest{UserID: "user-1", SyncType: "favorites", ServicePairs: []ServicePair{
+		{SourceService: "spotify", SourceConnection: "personal", TargetService: "spotify", TargetConnection: "personal", SyncMode: SyncModeFrom},
+	}}
+	if err := sameAccount.Validate(nil); err == nil {
+		t.Error("Validate accepted a pair syncing an account with itself")
+	}
+
+	unresolved := &SyncJobRequest{UserID: "user-1", SyncType: "favorites", ServicePairs: []ServicePair{
+		{SourceService: "spotify", TargetService: "spotify", SyncMode: SyncModeFrom},
+	}}
+	if err := unresolved.Validate(nil); err == nil {
+		t.Error("Validate accepted a same-service pair without connections")
+	}
+}
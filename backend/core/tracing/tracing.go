@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "syncer.net"
+
+// Config controls whether and where traces are exported
+type Config struct {
+	Enabled     bool
+	ServiceName string
+	SampleRatio float64
+}
+
+// ConfigFromEnv builds a tracing configuration from environment variables.
+// Tracing is enabled by TRACING_ENABLED=true or when an OTLP endpoint is configured;
+// the exporter itself honours the standard OTEL_EXPORTER_OTLP_* variables.
+func ConfigFromEnv() Config {
+	config := Config{
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+		SampleRatio: 1.0,
+	}
+
+	if config.ServiceName == "" {
+		config.ServiceName = "syncer-backend"
+	}
+
+	enabled, _ := strconv.ParseBool(os.Getenv("TRACING_ENABLED"))
+	config.Enabled = enabled ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+
+	if ratio, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64); err == nil && ratio >= 0 && ratio <= 1 {
+		config.SampleRatio = ratio
+	}
+
+	return config
+}
+
+// Init installs the global tracer provider and propagator. The returned function flushes
+// and shuts down the exporter and must be called on exit.
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !config.Enabled {
+		log.Printf("Tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", config.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("Tracing enabled: exporting spans for %s via OTLP", config.ServiceName)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used across the backend
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span using the backend tracer
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// RecordError marks the span as failed with the given error
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,7 @@
+-- Migration rollback: Remove adaptive scheduling state
+DROP INDEX IF EXISTS idx_sync_jobs_scheduled_history;
+ALTER TABLE sync_schedules DROP COLUMN IF EXISTS disabled_at,
+    DROP COLUMN IF EXISTS disabled_reason,
+    DROP COLUMN IF EXISTS adjustment_reason,
+    DROP COLUMN IF EXISTS effective_frequency_ms;
+ALTER TABLE sync_jobs DROP COLUMN IF EXISTS error_types;
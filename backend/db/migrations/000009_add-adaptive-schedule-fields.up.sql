@@ -0,0 +1,13 @@
+-- Migration: Track job error types and adaptive scheduling state
+-- Distinct error types per job let the scheduler tell auth failures from transient ones
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS error_types TEXT [] NOT NULL DEFAULT '{}';
+-- Adaptive scheduling state surfaced to users alongside the schedule
+ALTER TABLE sync_schedules
+ADD COLUMN IF NOT EXISTS effective_frequency_ms BIGINT CHECK (effective_frequency_ms > 0),
+    ADD COLUMN IF NOT EXISTS adjustment_reason TEXT,
+    ADD COLUMN IF NOT EXISTS disabled_reason TEXT,
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+-- Index for looking up the recent scheduled runs of a schedule
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_scheduled_history ON sync_jobs(user_id, sync_type, created_at DESC)
+WHERE is_scheduled = true;
@@ -0,0 +1,3 @@
+-- Migration rollback: Remove schedule reference from sync jobs
+DROP INDEX IF EXISTS idx_sync_jobs_schedule;
+ALTER TABLE sync_jobs DROP COLUMN IF EXISTS schedule_id;
@@ -0,0 +1,15 @@
+-- Migration: Link scheduled sync jobs back to the schedule that spawned them
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS schedule_id UUID REFERENCES sync_schedules(id) ON DELETE
+SET NULL;
+-- Index for per-schedule history and statistics
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_schedule ON sync_jobs(schedule_id, created_at DESC)
+WHERE schedule_id IS NOT NULL;
+-- Backfill existing scheduled jobs from the (user, sync type) schedule they belonged to
+UPDATE sync_jobs sj
+SET schedule_id = ss.id
+FROM sync_schedules ss
+WHERE sj.is_scheduled = true
+    AND sj.schedule_id IS NULL
+    AND sj.user_id = ss.user_id
+    AND sj.sync_type = ss.sync_type;
@@ -0,0 +1,25 @@
+-- Migration rollback: Restore the original sync_logs shape and pair statistics view
+CREATE OR REPLACE VIEW service_pair_stats AS
+SELECT sl.source_service,
+    sl.target_service,
+    sl.sync_direction,
+    COUNT(*) as total_syncs,
+    COUNT(
+        CASE
+            WHEN sj.status = 'completed' THEN 1
+        END
+    ) as successful_syncs,
+    COALESCE(AVG(sj.items_synced), 0) as avg_items_synced
+FROM sync_logs sl
+    JOIN sync_jobs sj ON sl.sync_job_id = sj.id
+WHERE sl.source_service IS NOT NULL
+    AND sl.target_service IS NOT NULL
+GROUP BY sl.source_service,
+    sl.target_service,
+    sl.sync_direction;
+DROP INDEX IF EXISTS idx_sync_logs_errors;
+DROP INDEX IF EXISTS idx_sync_logs_job_timeline;
+ALTER TABLE sync_logs DROP COLUMN IF EXISTS details,
+    DROP COLUMN IF EXISTS item_id,
+    DROP COLUMN IF EXISTS phase,
+    DROP COLUMN IF EXISTS level;
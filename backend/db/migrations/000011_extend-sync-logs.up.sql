@@ -0,0 +1,36 @@
+-- Migration: Structured sync log entries per job phase and pair direction
+ALTER TABLE sync_logs
+ADD COLUMN IF NOT EXISTS level TEXT NOT NULL DEFAULT 'info' CHECK (level IN ('debug', 'info', 'warning', 'error')),
+    ADD COLUMN IF NOT EXISTS phase TEXT NOT NULL DEFAULT 'job',
+    ADD COLUMN IF NOT EXISTS item_id TEXT,
+    ADD COLUMN IF NOT EXISTS details JSONB NOT NULL DEFAULT '{}'::jsonb;
+-- Index for paging through a job's timeline in order
+CREATE INDEX IF NOT EXISTS idx_sync_logs_job_timeline ON sync_logs(sync_job_id, created_at, id);
+-- Index for finding errors across jobs
+CREATE INDEX IF NOT EXISTS idx_sync_logs_errors ON sync_logs(created_at DESC)
+WHERE level = 'error';
+-- A job now writes several entries per pair, so count each job once per pair
+CREATE OR REPLACE VIEW service_pair_stats AS
+SELECT sl.source_service,
+    sl.target_service,
+    sl.sync_direction,
+    COUNT(*) as total_syncs,
+    COUNT(
+        CASE
+            WHEN sj.status = 'completed' THEN 1
+        END
+    ) as successful_syncs,
+    COALESCE(AVG(sj.items_synced), 0) as avg_items_synced
+FROM (
+        SELECT DISTINCT sync_job_id,
+            source_service,
+            target_service,
+            sync_direction
+        FROM sync_logs
+        WHERE source_service IS NOT NULL
+            AND target_service IS NOT NULL
+    ) sl
+    JOIN sync_jobs sj ON sl.sync_job_id = sj.id
+GROUP BY sl.source_service,
+    sl.target_service,
+    sl.sync_direction;
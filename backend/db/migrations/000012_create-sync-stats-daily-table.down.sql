@@ -0,0 +1,3 @@
+-- Migration rollback: Remove daily sync rollups
+DROP INDEX IF EXISTS idx_sync_jobs_finished_day;
+DROP TABLE IF EXISTS sync_stats_daily;
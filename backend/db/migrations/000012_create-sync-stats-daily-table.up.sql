@@ -0,0 +1,28 @@
+-- Migration: Daily rollups of sync activity computed from sync_jobs and sync_results
+-- Rows with a NULL user_id hold the global rollup across all users
+CREATE TABLE IF NOT EXISTS sync_stats_daily (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    day DATE NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    dimension TEXT NOT NULL CHECK (dimension IN ('total', 'provider', 'pair')),
+    dimension_key TEXT NOT NULL DEFAULT '',
+    jobs_total INTEGER NOT NULL DEFAULT 0,
+    jobs_succeeded INTEGER NOT NULL DEFAULT 0,
+    jobs_failed INTEGER NOT NULL DEFAULT 0,
+    items_synced BIGINT NOT NULL DEFAULT 0,
+    items_failed BIGINT NOT NULL DEFAULT 0,
+    duration_avg_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    duration_p50_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    duration_p95_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE NULLS NOT DISTINCT (day, user_id, dimension, dimension_key)
+);
+-- Indexes for per-user and global dashboard range queries
+CREATE INDEX IF NOT EXISTS idx_sync_stats_daily_user ON sync_stats_daily(user_id, dimension, day)
+WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sync_stats_daily_global ON sync_stats_daily(dimension, day)
+WHERE user_id IS NULL;
+-- Index for finding unaggregated days
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_finished_day ON sync_jobs((created_at::date))
+WHERE finished_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Migration rollback: Remove shared provider rate limit state
+DROP TABLE IF EXISTS provider_rate_limit_blocks;
+DROP TABLE IF EXISTS provider_rate_limit_windows;
@@ -0,0 +1,18 @@
+-- Migration: Shared provider rate limit state so every replica respects the same quotas
+-- One row per quota key; the counter restarts whenever a new window begins
+CREATE TABLE IF NOT EXISTS provider_rate_limit_windows (
+    key TEXT PRIMARY KEY,
+    window_start TIMESTAMPTZ NOT NULL,
+    request_count INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- Blocks set from provider signals such as 429 Retry-After or an exhausted remaining quota
+CREATE TABLE IF NOT EXISTS provider_rate_limit_blocks (
+    key TEXT PRIMARY KEY,
+    blocked_until TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- Index for pruning stale per-user state
+CREATE INDEX IF NOT EXISTS idx_provider_rate_limit_windows_updated ON provider_rate_limit_windows(updated_at);
+CREATE INDEX IF NOT EXISTS idx_provider_rate_limit_blocks_until ON provider_rate_limit_blocks(blocked_until);
@@ -0,0 +1,2 @@
+-- Migration rollback: Remove the services enabled flag
+ALTER TABLE services DROP COLUMN IF EXISTS enabled;
@@ -0,0 +1,4 @@
+-- Migration: Track which providers the providers config currently enables
+-- Rows are kept when a provider is disabled so existing connections stay intact
+ALTER TABLE services
+ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,4 @@
+-- Migration rollback: Remove the redirect URI and PKCE code verifier of pending OAuth authorizations
+ALTER TABLE pending_oauth_auth
+DROP COLUMN IF EXISTS code_verifier,
+DROP COLUMN IF EXISTS redirect_uri;
@@ -0,0 +1,5 @@
+-- Migration: Keep the redirect URI and PKCE code verifier of each pending OAuth authorization
+-- The callback must exchange the code with the same redirect URI and the verifier behind the challenge
+ALTER TABLE pending_oauth_auth
+ADD COLUMN IF NOT EXISTS redirect_uri TEXT NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS code_verifier TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Migration rollback: Allow a single account per provider again
+-- Only the most recently updated connection of each provider is kept
+DELETE FROM user_services us
+USING user_services newer
+WHERE us.user_id = newer.user_id
+    AND us.service_id = newer.service_id
+    AND (us.updated_at, us.id) < (newer.updated_at, newer.id);
+ALTER TABLE user_services DROP CONSTRAINT IF EXISTS user_services_user_id_service_id_account_key;
+ALTER TABLE user_services
+ADD CONSTRAINT user_services_user_id_service_id_key UNIQUE (user_id, service_id);
+ALTER TABLE user_services DROP COLUMN IF EXISTS label;
+ALTER TABLE pending_oauth_auth DROP COLUMN IF EXISTS label;
@@ -0,0 +1,10 @@
+-- Migration: Allow a user to connect several accounts of the same provider
+-- Connections are told apart by the provider account id and named with a user-chosen label
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS label TEXT;
+ALTER TABLE user_services DROP CONSTRAINT IF EXISTS user_services_user_id_service_id_key;
+ALTER TABLE user_services
+ADD CONSTRAINT user_services_user_id_service_id_account_key UNIQUE (user_id, service_id, service_user_id);
+-- The label chosen when connecting is kept until the callback creates the connection
+ALTER TABLE pending_oauth_auth
+ADD COLUMN IF NOT EXISTS label TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Migration rollback: Stop tracking scopes a connection is missing
+ALTER TABLE pending_oauth_auth DROP COLUMN IF EXISTS connection_id;
+ALTER TABLE user_services DROP COLUMN IF EXISTS missing_scopes;
@@ -0,0 +1,7 @@
+-- Migration: Track scopes a connection is missing
+-- A connection missing scopes its provider's capabilities need is degraded until re-consented
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS missing_scopes TEXT[] NOT NULL DEFAULT '{}';
+-- A pending re-consent names the connection it grants missing scopes to
+ALTER TABLE pending_oauth_auth
+ADD COLUMN IF NOT EXISTS connection_id UUID REFERENCES user_services(id) ON DELETE CASCADE;
@@ -0,0 +1,9 @@
+-- Migration rollback: Stop tracking connection health
+DROP INDEX IF EXISTS idx_user_services_state_expiry;
+ALTER TABLE user_services DROP COLUMN IF EXISTS reauth_notified_at,
+    DROP COLUMN IF EXISTS refresh_failures,
+    DROP COLUMN IF EXISTS last_error_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS last_refresh_at,
+    DROP COLUMN IF EXISTS state_changed_at,
+    DROP COLUMN IF EXISTS connection_state;
@@ -0,0 +1,20 @@
+-- Migration: Track the health of each connection
+-- The connection maintainer refreshes tokens ahead of expiry and records the outcome here
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS connection_state TEXT NOT NULL DEFAULT 'active' CHECK (
+        connection_state IN (
+            'active',
+            'expiring',
+            'refresh_failed',
+            'revoked',
+            'needs_reauth'
+        )
+    ),
+ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+ADD COLUMN IF NOT EXISTS last_refresh_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS last_error TEXT,
+ADD COLUMN IF NOT EXISTS last_error_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS refresh_failures INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS reauth_notified_at TIMESTAMP;
+-- Index for the maintainer's scan of connections nearing expiry
+CREATE INDEX IF NOT EXISTS idx_user_services_state_expiry ON user_services(connection_state, token_expires_at);
@@ -0,0 +1,3 @@
+-- Migration rollback: Stop recording token encryption keys
+DROP INDEX IF EXISTS idx_user_services_token_key_id;
+ALTER TABLE user_services DROP COLUMN IF EXISTS token_key_id;
@@ -0,0 +1,6 @@
+-- Migration: Record which encryption key each connection's tokens use
+-- NULL marks tokens encrypted before keys were versioned; the re-encryption job moves every
+-- row to the current key so retired keys can be removed
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS token_key_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_user_services_token_key_id ON user_services(token_key_id);
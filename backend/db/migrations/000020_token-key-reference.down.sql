@@ -0,0 +1,6 @@
+-- Migration rollback: Store token key ids as integers
+-- References to keys other than keyring keys are cleared so the re-encryption job revisits them
+ALTER TABLE user_services
+ALTER COLUMN token_key_id TYPE INTEGER USING CASE
+        WHEN token_key_id ~ '^keyring/[0-9]+$' THEN substring(token_key_id FROM 9)::integer
+    END;
@@ -0,0 +1,5 @@
+-- Migration: Store token key references as provider/key
+-- Envelope encryption names key-encryption keys of providers such as Vault, so the key id
+-- becomes text; ids of keyring keys written so far become keyring/<id>
+ALTER TABLE user_services
+ALTER COLUMN token_key_id TYPE TEXT USING 'keyring/' || token_key_id::text;
@@ -0,0 +1,4 @@
+-- Migration rollback: Record whether a connection's tokens are bound to their row
+-- Tokens written since stay bound; releases from before binding cannot decrypt them
+DROP INDEX IF EXISTS idx_user_services_tokens_unbound;
+ALTER TABLE user_services DROP COLUMN IF EXISTS tokens_bound;
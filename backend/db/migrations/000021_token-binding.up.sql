@@ -0,0 +1,6 @@
+-- Migration: Record whether a connection's tokens are bound to their row
+-- Tokens are sealed with the row id, column and key as associated data; rows written before
+-- that stay unbound until the re-encryption job rewrites them
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS tokens_bound BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_user_services_tokens_unbound ON user_services(id) WHERE NOT tokens_bound;
@@ -0,0 +1,24 @@
+-- Migration rollback: Store connection tokens in user_services again
+ALTER TABLE user_services
+ADD COLUMN IF NOT EXISTS access_token BYTEA,
+ADD COLUMN IF NOT EXISTS refresh_token BYTEA,
+ADD COLUMN IF NOT EXISTS token_type TEXT DEFAULT 'Bearer',
+ADD COLUMN IF NOT EXISTS token_expires_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS scopes TEXT,
+ADD COLUMN IF NOT EXISTS token_key_id TEXT,
+ADD COLUMN IF NOT EXISTS tokens_bound BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE user_services us
+SET access_token = ct.access_token,
+    refresh_token = ct.refresh_token,
+    token_type = ct.token_type,
+    token_expires_at = ct.expires_at,
+    scopes = NULLIF(ct.scopes, ''),
+    token_key_id = ct.key_ref,
+    tokens_bound = ct.bound
+FROM connection_tokens ct
+WHERE ct.user_service_id = us.id;
+CREATE INDEX IF NOT EXISTS idx_user_services_state_expiry ON user_services(connection_state, token_expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_services_token_key_id ON user_services(token_key_id);
+CREATE INDEX IF NOT EXISTS idx_user_services_tokens_unbound ON user_services(id) WHERE NOT tokens_bound;
+DROP TABLE IF EXISTS token_reads;
+DROP TABLE IF EXISTS connection_tokens;
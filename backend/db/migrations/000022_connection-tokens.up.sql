@@ -0,0 +1,48 @@
+-- Migration: Move connection tokens out of user_services
+-- Tokens get their own table, with a version bumped on every write so a refresh only replaces
+-- the tokens it started from. Ciphertexts are bound to the connection id and column name,
+-- which stay the same, so they are copied unchanged.
+CREATE TABLE IF NOT EXISTS connection_tokens (
+    user_service_id UUID PRIMARY KEY REFERENCES user_services(id) ON DELETE CASCADE,
+    access_token BYTEA,
+    refresh_token BYTEA,
+    token_type TEXT NOT NULL DEFAULT 'Bearer',
+    expires_at TIMESTAMPTZ,
+    scopes TEXT NOT NULL DEFAULT '',
+    key_ref TEXT,
+    bound BOOLEAN NOT NULL DEFAULT FALSE,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO connection_tokens (
+        user_service_id, access_token, refresh_token, token_type, expires_at, scopes, key_ref, bound
+    )
+SELECT id, access_token, refresh_token, COALESCE(token_type, 'Bearer'), token_expires_at,
+    COALESCE(scopes, ''), token_key_id, tokens_bound
+FROM user_services
+WHERE access_token IS NOT NULL OR refresh_token IS NOT NULL
+ON CONFLICT (user_service_id) DO NOTHING;
+-- Index for the maintainer's scan of connections nearing expiry
+CREATE INDEX IF NOT EXISTS idx_connection_tokens_expires_at ON connection_tokens(expires_at);
+-- Index for the re-encryption job's scan of tokens not yet bound under the current key
+CREATE INDEX IF NOT EXISTS idx_connection_tokens_key_ref ON connection_tokens(key_ref, bound);
+-- Audit log of token reads; rows outlive the connection they refer to
+CREATE TABLE IF NOT EXISTS token_reads (
+    id BIGSERIAL PRIMARY KEY,
+    user_service_id UUID NOT NULL,
+    version BIGINT NOT NULL,
+    purpose TEXT NOT NULL,
+    read_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_token_reads_connection ON token_reads(user_service_id, read_at);
+DROP INDEX IF EXISTS idx_user_services_state_expiry;
+DROP INDEX IF EXISTS idx_user_services_token_key_id;
+DROP INDEX IF EXISTS idx_user_services_tokens_unbound;
+ALTER TABLE user_services DROP COLUMN IF EXISTS access_token,
+    DROP COLUMN IF EXISTS refresh_token,
+    DROP COLUMN IF EXISTS token_type,
+    DROP COLUMN IF EXISTS token_expires_at,
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS token_key_id,
+    DROP COLUMN IF EXISTS tokens_bound;
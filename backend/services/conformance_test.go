@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+	"syncer.net/services/music"
+)
+
+// fixture holds the recorded HTTP interactions a provider is replayed against
+type fixture struct {
+	SupportsRefresh bool `json:"supports_refresh"`
+	services.Cassette
+}
+
+// replayTransport answers provider requests from a fixture and fails the test on anything unrecorded
+type replayTransport struct {
+	t      *testing.T
+	replay *services.ReplayTransport
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.replay.RoundTrip(req)
+	if errors.Is(err, services.ErrNotRecorded) {
+		rt.t.Errorf("unrecorded request: %v", err)
+	}
+	return resp, err
+}
+
+// transportSetter is implemented by providers embedding BaseService
+type transportSetter interface {
+	SetTransport(transport http.RoundTripper)
+}
+
+func loadFixture(t *testing.T, name string) fixture {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "fixtures", name+".json"))
+	if err != nil {
+		t.Fatalf("provider %s has no recorded fixtures: %v", name, err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("invalid fixture for %s: %v", name, err)
+	}
+	return f
+}
+
+// TestProviderConformance replays every registered provider against its recorded fixtures and
+// checks that it honors the ServiceProvider contract the sync engine relies on
+func TestProviderConformance(t *testing.T) {
+	t.Setenv("SPOTIFY_CLIENT_ID", "conformance-client")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "conformance-secret")
+	t.Setenv("DEEZER_APP_ID", "conformance-app")
+	t.Setenv("DEEZER_APP_SECRET", "conformance-secret")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := services.NewServiceRegistry(nil, logger)
+	if err := InitializeServices(context.Background(), registry, "", logger); err != nil {
+		t.Fatalf("InitializeServices: %v", err)
+	}
+
+	infos := registry.ListServices()
+	if len(infos) == 0 {
+		t.Fatal("no providers registered")
+	}
+
+	for _, info := range infos {
+		t.Run(info.Name, func(t *testing.T) {
+			provider, err := registry.GetService(info.Name)
+			if err != nil {
+				t.Fatalf("GetService: %v", err)
+			}
+
+			f := loadFixture(t, info.Name)
+			setter, ok := provider.(transportSetter)
+			if !ok {
+				t.Fatalf("%T does not allow replacing its HTTP transport", provider)
+			}
+			setter.SetTransport(&replayTransport{t: t, replay: services.NewReplayTransport(&f.Cassette)})
+
+			testProviderContract(t, provider, f)
+		})
+	}
+}
+
+func testProviderContract(t *testing.T, provider services.ServiceProvider, f fixture) {
+	ctx := context.Background()
+	name := provider.Name()
+
+	t.Run("metadata", func(t *testing.T) {
+		if provider.DisplayName() == "" {
+			t.Error("DisplayName is empty")
+		}
+		if provider.Category() != services.CategoryMusic && provider.Category() != services.CategoryCalendar {
+			t.Errorf("unknown category %q", provider.Category())
+		}
+		if len(provider.RequiredScopes()) == 0 {
+			t.Error("RequiredScopes is empty")
+		}
+		if len(provider.Capabilities()) == 0 {
+			t.Error("Capabilities is empty")
+		}
+		if limit := provider.GetRateLimit(); limit == nil || limit.RequestsPerSecond <= 0 {
+			t.Errorf("GetRateLimit = %+v, want a positive request rate", limit)
+		}
+	})
+
+	t.Run("auth_url", func(t *testing.T) {
+		authURL, err := provider.GetAuthURL("conformance-state", "https://syncer.example/callback", services.PKCEChallenge("conformance-verifier"))
+		if err != nil {
+			t.Fatalf("GetAuthURL: %v", err)
+		}
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatalf("invalid auth URL %q: %v", authURL, err)
+		}
+		if parsed.Query().Get("state") != "conformance-state" {
+			t.Errorf("auth URL %q does not carry the state", authURL)
+		}
+		if parsed.Query().Get("redirect_uri") != "https://syncer.example/callback" {
+			t.Errorf("auth URL %q does not carry the redirect URL", authURL)
+		}
+	})
+
+	t.Run("exchange_code", func(t *testing.T) {
+		tokens, err := provider.ExchangeCode("conformance-code", "https://syncer.example/callback", "conformance-verifier")
+		if err != nil {
+			t.Fatalf("ExchangeCode: %v", err)
+		}
+		if tokens.AccessToken == "" {
+			t.Error("ExchangeCode returned no access token")
+		}
+		if !tokens.ExpiresAt.After(time.Now()) {
+			t.Errorf("ExchangeCode returned tokens expiring at %v", tokens.ExpiresAt)
+		}
+	})
+
+	t.Run("refresh_tokens", func(t *testing.T) {
+		tokens, err := provider.RefreshTokens("conformance-refresh-token")
+		if !f.SupportsRefresh {
+			if err == nil {
+				t.Error("RefreshTokens succeeded for a provider recorded without refresh support")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("RefreshTokens: %v", err)
+		}
+		if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+			t.Errorf("RefreshTokens returned incomplete tokens %+v", tokens)
+		}
+	})
+
+	t.Run("validate_tokens", func(t *testing.T) {
+		expired := &services.OAuthTokens{AccessToken: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+		if valid, _ := provider.ValidateTokens(expired); valid {
+			t.Error("ValidateTokens accepted expired tokens")
+		}
+		if valid, err := provider.ValidateTokens(conformanceTokens()); !valid {
+			t.Errorf("ValidateTokens rejected valid tokens: %v", err)
+		}
+	})
+
+	t.Run("health_check", func(t *testing.T) {
+		if err := provider.HealthCheck(ctx); err != nil {
+			t.Errorf("HealthCheck: %v", err)
+		}
+	})
+
+	t.Run("user_profile", func(t *testing.T) {
+		profile, err := provider.GetUserProfile(ctx, conformanceTokens())
+		if err != nil {
+			t.Fatalf("GetUserProfile: %v", err)
+		}
+		if profile.ExternalID == "" {
+			t.Error("GetUserProfile returned no external ID")
+		}
+	})
+
+	t.Run("user_data", func(t *testing.T) {
+		result, err := provider.GetUserData(ctx, conformanceTokens(), time.Time{})
+		if err != nil {
+			t.Fatalf("GetUserData: %v", err)
+		}
+		if !result.Success {
+			t.Errorf("GetUserData reported errors: %+v", result.Errors)
+		}
+		if len(result.Items) == 0 {
+			t.Fatal("GetUserData returned no items")
+		}
+
+		transformer := music.NewMusicTrackTransformer(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		for _, item := range result.Items {
+			if item.ExternalID == "" {
+				t.Errorf("item without external ID: %+v", item)
+			}
+			if item.Action != services.ActionCreate {
+				t.Errorf("item %s has action %q", item.ExternalID, item.Action)
+			}
+			if !slices.ContainsFunc(music.GetSupportedSyncTypes(), func(syncType string) bool {
+				return transformer.MatchesSyncType(item.ItemType, syncType)
+			}) {
+				t.Errorf("item %s has type %q that no sync type selects", item.ExternalID, item.ItemType)
+			}
+
+			universal, err := transformer.TransformToUniversal(name, item.Data)
+			if err != nil {
+				t.Errorf("item %s cannot be transformed: %v", item.ExternalID, err)
+				continue
+			}
+			track := universal.(music.UniversalTrack)
+			if track.Artist == "" || track.ExternalIDs[name] != item.ExternalID {
+				t.Errorf("item %s transformed to incomplete track %+v", item.ExternalID, track)
+			}
+		}
+	})
+
+}
+
+func conformanceTokens() *services.OAuthTokens {
+	return &services.OAuthTokens{
+		AccessToken: "conformance-access-token",
+		TokenType:   "Bearer",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+}
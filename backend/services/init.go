@@ -1,58 +1,51 @@
 package services
 
 import (
-	"log"
+	"context"
+	"log/slog"
 
 	"syncer.net/core/services"
 	"syncer.net/services/music/deezer"
 	"syncer.net/services/music/spotify"
 )
 
-// InitializeServices registers all available service providers with the registry
+// InitializeServices registers all available service providers with the registry and applies
+// the providers config file at configPath, or every provider's defaults when configPath is empty.
 // This is the ONLY place where specific service implementations are imported
 // and connected to the generic service registry
-func InitializeServices(registry *services.ServiceRegistry, logger *log.Logger) error {
+func InitializeServices(ctx context.Context, registry *services.ServiceRegistry, configPath string, logger *slog.Logger) error {
 	if logger == nil {
-		logger = log.New(log.Writer(), "[ServiceInit] ", log.LstdFlags)
+		logger = slog.Default()
 	}
 
-	logger.Println("Initializing and registering service providers...")
+	logger.Info("Initializing and registering service providers", "config", configPath)
 
 	// Register music services
-	if err := registerMusicServices(registry, logger); err != nil {
-		return err
-	}
+	registerMusicServices(registry, logger)
 
 	// Future: Register calendar services
-	// if err := registerCalendarServices(registry, logger); err != nil {
-	//     return err
-	// }
-
-	logger.Printf("Successfully registered %d services", registry.GetServiceCount())
-	return nil
-}
+	// registerCalendarServices(registry, logger)
 
-// registerMusicServices registers all music streaming service providers
-func registerMusicServices(registry *services.ServiceRegistry, logger *log.Logger) error {
-	// Register Spotify service
-	spotifyService := spotify.NewSpotifyService()
-	if err := registry.Register(spotifyService); err != nil {
-		logger.Printf("Failed to register Spotify service: %v", err)
+	if err := registry.LoadConfig(ctx, configPath); err != nil {
+		logger.Error("Failed to apply providers config", "config", configPath, "error", err)
 		return err
 	}
-	logger.Printf("Registered Spotify music service")
-
-	// Register Deezer service
-	deezerService := deezer.NewDeezerService()
-	if err := registry.Register(deezerService); err != nil {
-		logger.Printf("Failed to register Deezer service: %v", err)
-		return err
-	}
-	logger.Printf("Registered Deezer music service")
 
+	logger.Info("Registered service providers", "count", registry.GetServiceCount())
 	return nil
 }
 
+// registerMusicServices makes all music streaming service providers available to the registry
+func registerMusicServices(registry *services.ServiceRegistry, logger *slog.Logger) {
+	registry.RegisterFactory(spotify.DefaultConfig(), func(config services.ProviderConfig) (services.ServiceProvider, error) {
+		return spotify.NewSpotifyServiceWithConfig(config, logger)
+	})
+
+	registry.RegisterFactory(deezer.DefaultConfig(), func(config services.ProviderConfig) (services.ServiceProvider, error) {
+		return deezer.NewDeezerServiceWithConfig(config, logger)
+	})
+}
+
 // GetAllRegisteredServices returns information about all registered services
 // This can be used for debugging and service discovery
 func GetAllRegisteredServices(registry *services.ServiceRegistry) []services.ServiceInfo {
@@ -3,22 +3,27 @@ package music
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"syncer.net/core/logging"
 	"syncer.net/core/services"
 	"syncer.net/core/sync"
+	"syncer.net/core/tracing"
 )
 
 // MusicCrossServiceAdder handles adding music tracks to different services
 // Implements the CrossServiceAdder interface from core/sync
 type MusicCrossServiceAdder struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewMusicCrossServiceAdder creates a new music cross-service adder
-func NewMusicCrossServiceAdder() *MusicCrossServiceAdder {
+func NewMusicCrossServiceAdder(logger *slog.Logger) *MusicCrossServiceAdder {
 	return &MusicCrossServiceAdder{
-		logger: log.New(log.Writer(), "[MusicAdder] ", log.LstdFlags),
+		logger: logging.Component(logger, "music_adder"),
 	}
 }
 
@@ -52,20 +57,28 @@ func (a *MusicCrossServiceAdder) AddItemToService(
 	serviceName := targetService.Name()
 
 	// Check if we already have the track ID for this service
-	if existingID, exists := track.ExternalIDs[serviceName]; exists && existingID != "" {
-		a.logger.Printf("Track already exists in %s with ID: %s", serviceName, existingID)
+	_, span := tracing.Start(ctx, "sync.match", trace.WithAttributes(
+		attribute.String("sync.provider", serviceName),
+		attribute.String("music.track.isrc", track.ISRC),
+	))
+	existingID, exists := track.ExternalIDs[serviceName]
+	span.SetAttributes(attribute.Bool("sync.matched", exists && existingID != ""))
+	span.End()
+
+	if exists && existingID != "" {
+		a.logger.DebugContext(ctx, "Track already exists in target service", logging.KeyProvider, serviceName, "external_id", existingID)
 		return nil // Consider this a success since the track already exists
 	}
 
 	if musicOptions.DryRun {
-		a.logger.Printf("DRY RUN: Would add track '%s' by '%s' to %s", track.Title, track.Artist, serviceName)
+		a.logger.InfoContext(ctx, "Dry run, not adding track", logging.KeyProvider, serviceName, "title", track.Title, "artist", track.Artist)
 		return nil
 	}
 
 	// For now, return a placeholder error indicating this needs service-specific implementation
 	// This is where we would implement search and add functionality for each service
-	a.logger.Printf("Adding track '%s' by '%s' to %s - service-specific implementation needed",
-		track.Title, track.Artist, serviceName)
+	a.logger.WarnContext(ctx, "Adding track needs a service-specific implementation",
+		logging.KeyProvider, serviceName, "title", track.Title, "artist", track.Artist)
 
 	return fmt.Errorf("cross-service track addition for %s not yet fully implemented - needs service-specific search and add logic", serviceName)
 }
@@ -86,8 +99,8 @@ func (a *MusicCrossServiceAdder) SearchAndAddTrack(
 
 	// This would be implemented with service-specific search and add logic
 	// For now, we'll return a placeholder
-	a.logger.Printf("Would search for track '%s' by '%s' on %s and add it",
-		track.Title, track.Artist, serviceName)
+	a.logger.WarnContext(ctx, "Search and add not implemented",
+		logging.KeyProvider, serviceName, "title", track.Title, "artist", track.Artist)
 
 	return fmt.Errorf("search and add functionality not yet implemented for %s", serviceName)
 }
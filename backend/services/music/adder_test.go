@@ -0,0 +1,76 @@
+package music
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestTransformFakeProviderData(t *testing.T) {
+	track := servicetest.Track{ID: "123", Title: "Song", Artist: "Band", Album: "Record", ISRC: "USRC17607839", DurationMs: 180000}
+	transformer := NewMusicTrackTransformer(testLogger())
+	tokens := &services.OAuthTokens{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)}
+
+	for _, name := range SupportedMusicServices {
+		provider := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: name, Library: []servicetest.Track{track}})
+		result, err := provider.GetUserData(context.Background(), tokens, time.Time{})
+		if err != nil {
+			t.Fatalf("%s: GetUserData: %v", name, err)
+		}
+
+		item, err := transformer.TransformToUniversal(name, result.Items[0].Data)
+		if err != nil {
+			t.Fatalf("%s: TransformToUniversal: %v", name, err)
+		}
+		universal := item.(UniversalTrack)
+		if universal.Title != "Song" || universal.Artist != "Band" || universal.Album != "Record" {
+			t.Errorf("%s: transformed to %+v", name, universal)
+		}
+		if universal.ExternalIDs[name] != "123" || universal.Duration != 180000 {
+			t.Errorf("%s: transformed IDs and duration to %v, %d", name, universal.ExternalIDs, universal.Duration)
+		}
+	}
+}
+
+func TestTransformRejectsUnknownData(t *testing.T) {
+	transformer := NewMusicTrackTransformer(testLogger())
+
+	if _, err := transformer.TransformToUniversal("spotify", 42); err == nil {
+		t.Error("TransformToUniversal accepted data that is not a track")
+	}
+	if _, err := transformer.TransformToUniversal("tidal", map[string]any{"name": "Song"}); err == nil {
+		t.Error("TransformToUniversal accepted an unsupported service")
+	}
+}
+
+func TestAddItemToService(t *testing.T) {
+	adder := NewMusicCrossServiceAdder(testLogger())
+	target := servicetest.NewFakeProvider(servicetest.FakeConfig{Name: "deezer"})
+	tokens := &services.OAuthTokens{AccessToken: "token"}
+
+	known := UniversalTrack{Title: "Song", Artist: "Band", ExternalIDs: map[string]string{"deezer": "123"}}
+	if err := adder.AddItemToService(context.Background(), target, tokens, known, nil); err != nil {
+		t.Errorf("track already on the target: %v", err)
+	}
+
+	unknown := UniversalTrack{Title: "Song", Artist: "Band", ExternalIDs: map[string]string{"spotify": "abc"}}
+	if err := adder.AddItemToService(context.Background(), target, tokens, unknown, MusicSyncOptions{DryRun: true}); err != nil {
+		t.Errorf("dry run: %v", err)
+	}
+	if got := len(target.Library()); got != 0 {
+		t.Errorf("dry run changed the target library to %d tracks", got)
+	}
+
+	if err := adder.AddItemToService(context.Background(), target, tokens, servicetest.Track{Title: "Song"}, nil); err == nil {
+		t.Error("AddItemToService accepted an item that is not a track")
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,12 @@ type DeezerService struct {
 	appSecret string
 }
 
+var (
+	_ services.ServiceProvider       = (*DeezerService)(nil)
+	_ services.ScopeInspector        = (*DeezerService)(nil)
+	_ services.IncrementalAuthorizer = (*DeezerService)(nil)
+)
+
 // DeezerTrack represents a Deezer track with comprehensive metadata
 type DeezerTrack struct {
 	ID              int64             `json:"id"`
@@ -77,9 +84,36 @@ type DeezerUser struct {
 	Name string `json:"name"`
 }
 
-// NewDeezerService creates a new Deezer service with proper configuration
-func NewDeezerService() *DeezerService {
-	baseService := services.NewBaseService(services.BaseServiceConfig{
+// DefaultConfig returns the provider settings used when the providers config does not override them
+func DefaultConfig() services.ProviderConfig {
+	return services.ProviderConfig{
+		Name: "deezer",
+		Credentials: map[string]string{
+			"app_id":     "env:DEEZER_APP_ID",
+			"app_secret": "env:DEEZER_APP_SECRET",
+		},
+	}
+}
+
+// NewDeezerService creates a new Deezer service with credentials from the environment
+func NewDeezerService(logger *slog.Logger) *DeezerService {
+	// Environment references cannot fail to resolve
+	service, _ := NewDeezerServiceWithConfig(DefaultConfig(), logger)
+	return service
+}
+
+// NewDeezerServiceWithConfig creates a new Deezer service with settings from the providers config
+func NewDeezerServiceWithConfig(config services.ProviderConfig, logger *slog.Logger) (*DeezerService, error) {
+	appID, err := config.Credential("app_id")
+	if err != nil {
+		return nil, err
+	}
+	appSecret, err := config.Credential("app_secret")
+	if err != nil {
+		return nil, err
+	}
+
+	baseConfig := services.BaseServiceConfig{
 		Name:        "deezer",
 		DisplayName: "Deezer",
 		Category:    services.CategoryMusic,
@@ -90,29 +124,96 @@ func NewDeezerService() *DeezerService {
 			"manage_library",
 			"manage_community",
 		},
+		Capabilities: services.Capabilities{
+			services.CapabilityReadFavorites,
+			services.CapabilityWriteFavorites,
+			services.CapabilityReadPlaylists,
+			services.CapabilityReadHistory,
+			services.CapabilitySearchISRC,
+		},
+		CapabilityScopes: map[services.Capability][]string{
+			services.CapabilityReadFavorites:  {"basic_access"},
+			services.CapabilityWriteFavorites: {"manage_library"},
+			services.CapabilityReadPlaylists:  {"basic_access"},
+			services.CapabilityReadHistory:    {"basic_access"},
+		},
 		RequestsPerSecond: 10, // Deezer is more permissive than Spotify
 		BurstSize:         15,
 		HTTPTimeout:       30 * time.Second,
-	})
+		Logger:            logger,
+
+		// Deezer allows 50 requests per 5 seconds for each user token
+		UserRequestsPerMinute: 500,
+		ThrottleScope:         services.RateLimitScopeUser,
+
+		ErrorDecoder: decodeDeezerError,
+
+		AuthBaseURL: "https://connect.deezer.com/oauth",
+		APIBaseURL:  "https://api.deezer.com",
+	}
+	config.Apply(&baseConfig)
 
 	return &DeezerService{
-		BaseService: baseService,
-		appID:       os.Getenv("DEEZER_APP_ID"),
-		appSecret:   os.Getenv("DEEZER_APP_SECRET"),
+		BaseService: services.NewBaseService(baseConfig),
+		appID:       appID,
+		appSecret:   appSecret,
+	}, nil
+}
+
+// Deezer error codes reported inside response bodies
+const (
+	deezerErrQuota        = 4
+	deezerErrServiceBusy  = 700
+	deezerErrDataNotFound = 800
+)
+
+// decodeDeezerError also catches the errors Deezer reports inside 200 responses
+func decodeDeezerError(resp *http.Response, body []byte) *services.ProviderError {
+	if providerErr := services.DefaultErrorDecoder(resp, body); providerErr != nil {
+		return providerErr
 	}
+
+	var payload struct {
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error == nil {
+		return nil
+	}
+
+	providerErr := &services.ProviderError{
+		StatusCode: resp.StatusCode,
+		Code:       strconv.Itoa(payload.Error.Code),
+		Message:    fmt.Sprintf("%s: %s", payload.Error.Type, payload.Error.Message),
+		Retryable:  payload.Error.Code == deezerErrQuota || payload.Error.Code == deezerErrServiceBusy,
+	}
+	if payload.Error.Code == deezerErrQuota {
+		providerErr.RetryAfter = 5 * time.Second // Deezer quotas are counted over 5 seconds
+	}
+	return providerErr
+}
+
+// GetAuthURL generates the OAuth authorization URL for Deezer. Deezer does not support PKCE,
+// so the code challenge is ignored.
+func (d *DeezerService) GetAuthURL(state, redirectURL, codeChallenge string) (string, error) {
+	return d.GetAuthURLForScopes(state, redirectURL, codeChallenge, d.RequiredScopes())
 }
 
-// GetAuthURL generates the OAuth authorization URL for Deezer
-func (d *DeezerService) GetAuthURL(state, redirectURL string) (string, error) {
+// GetAuthURLForScopes generates an authorization URL asking for only the given permissions.
+// Deezer adds them to the permissions the user already gave the app.
+func (d *DeezerService) GetAuthURLForScopes(state, redirectURL, codeChallenge string, scopes []string) (string, error) {
 	if d.appID == "" {
-		return "", fmt.Errorf("DEEZER_APP_ID environment variable not set")
+		return "", fmt.Errorf("Deezer app_id credential not configured")
 	}
 
-	baseURL := "https://connect.deezer.com/oauth/auth.php"
+	baseURL := d.AuthURL("/auth.php")
 	params := url.Values{
 		"app_id":       {d.appID},
 		"redirect_uri": {redirectURL},
-		"perms":        {strings.Join(d.RequiredScopes(), ",")},
+		"perms":        {strings.Join(scopes, ",")},
 		"state":        {state},
 	}
 
@@ -122,41 +223,26 @@ func (d *DeezerService) GetAuthURL(state, redirectURL string) (string, error) {
 	return authURL, nil
 }
 
-// ExchangeCode exchanges authorization code for access tokens
-func (d *DeezerService) ExchangeCode(code, redirectURL string) (*services.OAuthTokens, error) {
+// ExchangeCode exchanges authorization code for access tokens. The code verifier is ignored.
+func (d *DeezerService) ExchangeCode(code, redirectURL, codeVerifier string) (*services.OAuthTokens, error) {
 	if d.appID == "" || d.appSecret == "" {
 		return nil, fmt.Errorf("Deezer credentials not configured")
 	}
 
-	tokenURL := "https://connect.deezer.com/oauth/access_token.php"
-	params := url.Values{
-		"app_id":       {d.appID},
-		"secret":       {d.appSecret},
-		"code":         {code},
-		"redirect_uri": {redirectURL},
-	}
-
 	// Deezer uses GET request for token exchange
-	fullURL := fmt.Sprintf("%s?%s", tokenURL, params.Encode())
-
-	if err := d.WaitForRateLimit(context.Background()); err != nil {
-		return nil, err
-	}
-
-	resp, err := http.Get(fullURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token exchange failed (status %d): %s", resp.StatusCode, body)
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	var body []byte
+	err := d.Do(context.Background(), services.APIRequest{
+		URL: d.AuthURL("/access_token.php"),
+		Query: url.Values{
+			"app_id":       {d.appID},
+			"secret":       {d.appSecret},
+			"code":         {code},
+			"redirect_uri": {redirectURL},
+		},
+		NoRetry: true, // Authorization codes are single-use
+	}, &body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("token exchange failed: %w", err)
 	}
 
 	// Deezer returns access_token=TOKEN&expires=SECONDS
@@ -170,18 +256,19 @@ func (d *DeezerService) ExchangeCode(code, redirectURL string) (*services.OAuthT
 		return nil, fmt.Errorf("no access token in response: %s", body)
 	}
 
-	expiresIn, _ := strconv.Atoi(responseParams.Get("expires"))
-	if expiresIn == 0 {
-		expiresIn = 3600 // Default 1 hour
+	tokens := &services.OAuthTokens{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+	}
+
+	// Tokens granted with offline_access report an expiry of 0 and do not expire
+	if expiresIn, _ := strconv.Atoi(responseParams.Get("expires")); expiresIn > 0 {
+		tokens.ExpiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
 	}
 
 	d.LogInfo("Successfully exchanged code for tokens")
 
-	return &services.OAuthTokens{
-		AccessToken: accessToken,
-		TokenType:   "Bearer",
-		ExpiresAt:   time.Now().Add(time.Duration(expiresIn) * time.Second),
-	}, nil
+	return tokens, nil
 }
 
 // RefreshTokens - Deezer doesn't support refresh tokens, return error
@@ -196,29 +283,6 @@ func (d *DeezerService) GetUserProfile(ctx context.Context, tokens *services.OAu
 		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	if err := d.WaitForRateLimit(ctx); err != nil {
-		return nil, err
-	}
-
-	url := fmt.Sprintf("https://api.deezer.com/user/me?access_token=%s", tokens.AccessToken)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := d.DoRequest(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get user profile (status %d): %s", resp.StatusCode, body)
-	}
-
 	var deezerUser struct {
 		ID       int64  `json:"id"`
 		Name     string `json:"name"`
@@ -227,8 +291,12 @@ func (d *DeezerService) GetUserProfile(ctx context.Context, tokens *services.OAu
 		Picture  string `json:"picture"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&deezerUser); err != nil {
-		return nil, fmt.Errorf("failed to decode user profile: %w", err)
+	err = d.Do(ctx, services.APIRequest{
+		URL:   d.APIURL("/user/me"),
+		Query: url.Values{"access_token": {tokens.AccessToken}},
+	}, &deezerUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
 	}
 
 	profile := &services.UserProfile{
@@ -243,8 +311,32 @@ func (d *DeezerService) GetUserProfile(ctx context.Context, tokens *services.OAu
 	return profile, nil
 }
 
-// SyncUserData fetches user data from Deezer for cross-service sync
-func (d *DeezerService) SyncUserData(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) (*services.UserDataResult, error) {
+// GrantedScopes returns the permissions the user gave the app, since Deezer token responses
+// do not list them
+func (d *DeezerService) GrantedScopes(ctx context.Context, tokens *services.OAuthTokens) ([]string, error) {
+	var response struct {
+		Permissions map[string]bool `json:"permissions"`
+	}
+	err := d.Do(ctx, services.APIRequest{
+		URL:   d.APIURL("/user/me/permissions"),
+		Query: url.Values{"access_token": {tokens.AccessToken}},
+	}, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	var granted []string
+	for permission, allowed := range response.Permissions {
+		if allowed {
+			granted = append(granted, permission)
+		}
+	}
+	sort.Strings(granted)
+	return granted, nil
+}
+
+// GetUserData fetches user data from Deezer for cross-service sync
+func (d *DeezerService) GetUserData(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) (*services.UserDataResult, error) {
 	d.LogInfo("Starting Deezer sync for user")
 
 	valid, err := d.ValidateTokens(tokens)
@@ -318,74 +410,58 @@ func (d *DeezerService) SyncUserData(ctx context.Context, tokens *services.OAuth
 	return anyResult, nil
 }
 
-// fetchFavoriteTracks retrieves user's favorite tracks from Deezer
-func (d *DeezerService) fetchFavoriteTracks(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
-	var items []services.SyncItem
-	index := 0
-	limit := 100
-
-	for {
-		if err := d.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
-
-		url := fmt.Sprintf("https://api.deezer.com/user/me/tracks?access_token=%s&index=%d&limit=%d",
-			tokens.AccessToken, index, limit)
+// deezerPage is the envelope Deezer wraps listings in
+type deezerPage[T any] struct {
+	Data  []T     `json:"data"`
+	Total int     `json:"total"`
+	Next  *string `json:"next"`
+}
 
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Accept", "application/json")
+// deezerFavoriteTrack is a track in the user's favorites with the time it was added
+type deezerFavoriteTrack struct {
+	TimeAdd int64 `json:"time_add"`
+	DeezerTrack
+}
 
-		resp, err := d.DoRequest(ctx, req)
+// listAll collects every page of a Deezer listing endpoint
+func listAll[T any](ctx context.Context, d *DeezerService, tokens *services.OAuthTokens, endpoint string, limit int) ([]T, error) {
+	return services.PaginateOffset(ctx, limit, func(ctx context.Context, index, limit int) (services.Page[T], error) {
+		var page deezerPage[T]
+		err := d.Do(ctx, services.APIRequest{
+			URL: endpoint,
+			Query: url.Values{
+				"access_token": {tokens.AccessToken},
+				"index":        {strconv.Itoa(index)},
+				"limit":        {strconv.Itoa(limit)},
+			},
+		}, &page)
 		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("Deezer API error (status %d): %s", resp.StatusCode, body)
+			return services.Page[T]{}, err
 		}
+		return services.Page[T]{Items: page.Data, Total: page.Total, HasMore: page.Next != nil}, nil
+	})
+}
 
-		var result struct {
-			Data []struct {
-				TimeAdd int64 `json:"time_add"`
-				DeezerTrack
-			} `json:"data"`
-			Total int     `json:"total"`
-			Next  *string `json:"next"`
-		}
+// fetchFavoriteTracks retrieves user's favorite tracks from Deezer
+func (d *DeezerService) fetchFavoriteTracks(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
+	favorites, err := listAll[deezerFavoriteTrack](ctx, d, tokens, d.APIURL("/user/me/tracks"), 100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite tracks: %w", err)
+	}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode favorites response: %w", err)
-		}
+	var items []services.SyncItem
+	for _, item := range favorites {
+		addedTime := time.Unix(item.TimeAdd, 0)
+		if addedTime.After(lastSync) {
+			item.DeezerTrack.TimeAdd = item.TimeAdd
 
-		// Process items
-		for _, item := range result.Data {
-			addedTime := time.Unix(item.TimeAdd, 0)
-			if addedTime.After(lastSync) {
-				item.DeezerTrack.TimeAdd = item.TimeAdd
-
-				syncItem := services.SyncItem{
-					ExternalID: strconv.FormatInt(item.DeezerTrack.ID, 10),
-					ItemType:   "favorite_track",
-					Action:     services.ActionCreate,
-					Data:       item.DeezerTrack,
-				}
-				items = append(items, syncItem)
+			syncItem := services.SyncItem{
+				ExternalID: strconv.FormatInt(item.DeezerTrack.ID, 10),
+				ItemType:   "favorite_track",
+				Action:     services.ActionCreate,
+				Data:       item.DeezerTrack,
 			}
-		}
-
-		if result.Next == nil || len(result.Data) < limit {
-			break
-		}
-		index += limit
-
-		// Safety check
-		if index > result.Total {
-			break
+			items = append(items, syncItem)
 		}
 	}
 
@@ -417,121 +493,31 @@ func (d *DeezerService) fetchUserPlaylists(ctx context.Context, tokens *services
 
 // getUserPlaylists gets user's playlists
 func (d *DeezerService) getUserPlaylists(ctx context.Context, tokens *services.OAuthTokens) ([]DeezerPlaylist, error) {
-	var playlists []DeezerPlaylist
-	index := 0
-	limit := 50
-
-	for {
-		if err := d.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
-
-		url := fmt.Sprintf("https://api.deezer.com/user/me/playlists?access_token=%s&index=%d&limit=%d",
-			tokens.AccessToken, index, limit)
-
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := d.DoRequest(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("failed to get playlists (status %d): %s", resp.StatusCode, body)
-		}
-
-		var result struct {
-			Data  []DeezerPlaylist `json:"data"`
-			Total int              `json:"total"`
-			Next  *string          `json:"next"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode playlists response: %w", err)
-		}
-
-		playlists = append(playlists, result.Data...)
-
-		if result.Next == nil || len(result.Data) < limit {
-			break
-		}
-		index += limit
-
-		if index > result.Total {
-			break
-		}
+	playlists, err := listAll[DeezerPlaylist](ctx, d, tokens, d.APIURL("/user/me/playlists"), 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlists: %w", err)
 	}
-
 	return playlists, nil
 }
 
 // getPlaylistTracks gets tracks from a specific playlist
 func (d *DeezerService) getPlaylistTracks(ctx context.Context, tokens *services.OAuthTokens, playlistID int64, lastSync time.Time) ([]services.SyncItem, error) {
-	var items []services.SyncItem
-	index := 0
-	limit := 100
-
-	for {
-		if err := d.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
-
-		url := fmt.Sprintf("https://api.deezer.com/playlist/%d/tracks?access_token=%s&index=%d&limit=%d",
-			playlistID, tokens.AccessToken, index, limit)
-
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := d.DoRequest(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("failed to get playlist tracks (status %d): %s", resp.StatusCode, body)
-		}
-
-		var result struct {
-			Data  []DeezerTrack `json:"data"`
-			Total int           `json:"total"`
-			Next  *string       `json:"next"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode playlist tracks response: %w", err)
-		}
-
-		// Process items - Note: Deezer playlist tracks don't have individual timestamps
-		// so we use the last sync time as approximation
-		for _, track := range result.Data {
-			syncItem := services.SyncItem{
-				ExternalID: strconv.FormatInt(track.ID, 10),
-				ItemType:   "playlist_track",
-				Action:     services.ActionCreate,
-				Data:       track,
-			}
-			items = append(items, syncItem)
-		}
-
-		if result.Next == nil || len(result.Data) < limit {
-			break
-		}
-		index += limit
+	endpoint := d.APIURL(fmt.Sprintf("/playlist/%d/tracks", playlistID))
+	tracks, err := listAll[DeezerTrack](ctx, d, tokens, endpoint, 100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist tracks: %w", err)
+	}
 
-		if index > result.Total {
-			break
+	// Deezer playlist tracks don't have individual timestamps, so every track is included
+	var items []services.SyncItem
+	for _, track := range tracks {
+		syncItem := services.SyncItem{
+			ExternalID: strconv.FormatInt(track.ID, 10),
+			ItemType:   "playlist_track",
+			Action:     services.ActionCreate,
+			Data:       track,
 		}
+		items = append(items, syncItem)
 	}
 
 	return items, nil
@@ -541,36 +527,14 @@ func (d *DeezerService) getPlaylistTracks(ctx context.Context, tokens *services.
 func (d *DeezerService) fetchListeningHistory(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
 	var items []services.SyncItem
 
-	if err := d.WaitForRateLimit(ctx); err != nil {
-		return nil, err
-	}
-
 	// Get user's flow (listening history/recommendations)
-	url := fmt.Sprintf("https://api.deezer.com/user/me/flow?access_token=%s&limit=50", tokens.AccessToken)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := d.DoRequest(ctx, req)
+	var result deezerPage[DeezerTrack]
+	err := d.Do(ctx, services.APIRequest{
+		URL:   d.APIURL("/user/me/flow"),
+		Query: url.Values{"access_token": {tokens.AccessToken}, "limit": {"50"}},
+	}, &result)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get flow (status %d): %s", resp.StatusCode, body)
-	}
-
-	var result struct {
-		Data []DeezerTrack `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode flow response: %w", err)
+		return nil, fmt.Errorf("failed to get flow: %w", err)
 	}
 
 	// Process items
@@ -596,14 +560,14 @@ func (d *DeezerService) generateTrackChecksum(track DeezerTrack) string {
 }
 
 // HealthCheck performs a health check on the Deezer API
-func (d *DeezerService) HealthCheck() error {
+func (d *DeezerService) HealthCheck(ctx context.Context) error {
 	// Simple health check by calling a public endpoint
-	req, err := http.NewRequestWithContext(context.Background(), "GET", "https://api.deezer.com/genre", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", d.APIURL("/genre"), nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := d.DoRequest(context.Background(), req)
+	resp, err := d.DoRequest(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -626,49 +590,28 @@ func (d *DeezerService) AddTrack(ctx context.Context, tokens *services.OAuthToke
 	return fmt.Errorf("AddTrack not yet implemented - awaiting universal track type implementation")
 }
 
-// SearchTrack searches for a track on Deezer using universal track data
+// SearchTrack searches for a track on Deezer using universal track data. Results are cached
+// since catalog searches repeat across users and syncs.
 func (d *DeezerService) SearchTrack(ctx context.Context, tokens *services.OAuthTokens, title, artist, album string) (*DeezerTrack, error) {
 	valid, err := d.ValidateTokens(tokens)
 	if err != nil || !valid {
 		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	if err := d.WaitForRateLimit(ctx); err != nil {
-		return nil, err
-	}
-
 	// Construct search query
 	query := fmt.Sprintf("%s %s", title, artist)
 	if album != "" {
 		query += " " + album
 	}
 
-	url := fmt.Sprintf("https://api.deezer.com/search?q=%s&access_token=%s&limit=1",
-		url.QueryEscape(query), tokens.AccessToken)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := d.DoRequest(ctx, req)
+	var result deezerPage[DeezerTrack]
+	err = d.Do(ctx, services.APIRequest{
+		URL:   d.APIURL("/search"),
+		Query: url.Values{"q": {query}, "access_token": {tokens.AccessToken}, "limit": {"1"}},
+		Cache: true,
+	}, &result)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("search failed (status %d): %s", resp.StatusCode, body)
-	}
-
-	var result struct {
-		Data []DeezerTrack `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode search response: %w", err)
+		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	if len(result.Data) == 0 {
@@ -678,35 +621,49 @@ func (d *DeezerService) SearchTrack(ctx context.Context, tokens *services.OAuthT
 	return &result.Data[0], nil
 }
 
-// AddToFavorites adds a track to user's favorite tracks
-func (d *DeezerService) AddToFavorites(ctx context.Context, tokens *services.OAuthTokens, trackID int64) error {
+// SearchTrackByISRC looks up a track by its ISRC, the most reliable cross-service match
+func (d *DeezerService) SearchTrackByISRC(ctx context.Context, tokens *services.OAuthTokens, isrc string) (*DeezerTrack, error) {
 	valid, err := d.ValidateTokens(tokens)
 	if err != nil || !valid {
-		return fmt.Errorf("invalid tokens: %w", err)
+		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	if err := d.WaitForRateLimit(ctx); err != nil {
-		return err
+	var track DeezerTrack
+	err = d.Do(ctx, services.APIRequest{
+		URL:   d.APIURL("/track/isrc:" + url.PathEscape(isrc)),
+		Query: url.Values{"access_token": {tokens.AccessToken}},
+		Cache: true,
+	}, &track)
+	if err != nil {
+		var providerErr *services.ProviderError
+		if errors.As(err, &providerErr) && providerErr.Code == strconv.Itoa(deezerErrDataNotFound) {
+			return nil, fmt.Errorf("track with ISRC %s not found on Deezer", isrc)
+		}
+		return nil, fmt.Errorf("ISRC search failed: %w", err)
 	}
 
-	url := fmt.Sprintf("https://api.deezer.com/user/me/tracks?access_token=%s&track_id=%d",
-		tokens.AccessToken, trackID)
+	return &track, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
-	if err != nil {
-		return err
+// AddToFavorites adds a track to user's favorite tracks. Adding a favorite twice has no further
+// effect, so the call is retried like an idempotent one.
+func (d *DeezerService) AddToFavorites(ctx context.Context, tokens *services.OAuthTokens, trackID int64) error {
+	valid, err := d.ValidateTokens(tokens)
+	if err != nil || !valid {
+		return fmt.Errorf("invalid tokens: %w", err)
 	}
-	req.Header.Set("Accept", "application/json")
 
-	resp, err := d.DoRequest(ctx, req)
+	err = d.Do(ctx, services.APIRequest{
+		Method: http.MethodPost,
+		URL:    d.APIURL("/user/me/tracks"),
+		Query: url.Values{
+			"access_token": {tokens.AccessToken},
+			"track_id":     {strconv.FormatInt(trackID, 10)},
+		},
+		Idempotent: true,
+	}, nil)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to add to favorites (status %d): %s", resp.StatusCode, body)
+		return fmt.Errorf("failed to add to favorites: %w", err)
 	}
 
 	d.LogInfo("Successfully added track %d to user's favorites", trackID)
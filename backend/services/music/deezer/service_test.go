@@ -0,0 +1,154 @@
+package deezer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func newTestService(t *testing.T) (*DeezerService, *servicetest.FakeMusicAPI) {
+	t.Helper()
+	t.Setenv("DEEZER_APP_ID", "test-app")
+	t.Setenv("DEEZER_APP_SECRET", "test-secret")
+
+	api := servicetest.NewFakeMusicAPI(t)
+	service := NewDeezerService(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	service.SetTransport(api.Transport())
+	return service, api
+}
+
+func testTokens() *services.OAuthTokens {
+	return &services.OAuthTokens{AccessToken: "test-token", TokenType: "Bearer", ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+func TestExchangeCode(t *testing.T) {
+	service, _ := newTestService(t)
+
+	tokens, err := service.ExchangeCode("code-1", "https://syncer.example/callback", "")
+	if err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+	if tokens.AccessToken != "deezer-access-code-1" {
+		t.Errorf("ExchangeCode = %+v", tokens)
+	}
+	if _, err := service.RefreshTokens("anything"); err == nil {
+		t.Error("RefreshTokens succeeded although Deezer has no refresh tokens")
+	}
+}
+
+func TestMissingPermissionsAndReconsent(t *testing.T) {
+	service, api := newTestService(t)
+	api.SetDeezerPermissions("basic_access", "email", "offline_access")
+
+	granted, err := service.GrantedScopes(context.Background(), testTokens())
+	if err != nil {
+		t.Fatalf("GrantedScopes: %v", err)
+	}
+	report := services.CheckScopes(service, strings.Join(granted, ","))
+	missing := report.MissingScopes()
+	if len(missing) != 1 || missing[0] != "manage_library" {
+		t.Fatalf("missing scopes = %v, want [manage_library]", missing)
+	}
+	if len(report.Missing) != 1 || report.Missing[services.CapabilityWriteFavorites] == nil {
+		t.Errorf("missing by capability = %v, want only write_favorites", report.Missing)
+	}
+
+	authURL, err := service.GetAuthURLForScopes("state", "https://syncer.example/callback", "", missing)
+	if err != nil {
+		t.Fatalf("GetAuthURLForScopes: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth URL: %v", err)
+	}
+	if perms := parsed.Query().Get("perms"); perms != "manage_library" {
+		t.Errorf("perms = %q, want only the missing permission", perms)
+	}
+}
+
+func TestGetUserDataPaginates(t *testing.T) {
+	service, api := newTestService(t)
+
+	var library []servicetest.Track
+	for i := range 250 {
+		library = append(library, servicetest.Track{ID: strconv.Itoa(1000 + i), Title: fmt.Sprintf("Song %d", i), Artist: "Band"})
+	}
+	api.SetLibrary(servicetest.Deezer, library)
+	api.AddPlaylist(servicetest.Deezer, "42", "Mix", library[:3])
+	api.SetHistory(servicetest.Deezer, library[:2])
+
+	result, err := service.GetUserData(context.Background(), testTokens(), time.Time{})
+	if err != nil {
+		t.Fatalf("GetUserData: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("GetUserData reported errors: %+v", result.Errors)
+	}
+
+	counts := make(map[string]int)
+	for _, item := range result.Items {
+		counts[item.ItemType]++
+	}
+	if counts["favorite_track"] != 250 || counts["playlist_track"] != 3 || counts["flow_track"] != 2 {
+		t.Errorf("item counts = %v", counts)
+	}
+}
+
+func TestErrorsInsideSuccessfulResponses(t *testing.T) {
+	service, _ := newTestService(t)
+
+	// Deezer answers unknown ISRCs with a 200 carrying a "no data" error object
+	_, err := service.SearchTrackByISRC(context.Background(), testTokens(), "UNKNOWN")
+	var providerErr *services.ProviderError
+	if err == nil || errors.As(err, &providerErr) {
+		t.Errorf("SearchTrackByISRC(unknown) = %v, want a not found error", err)
+	}
+}
+
+func TestSearchAndAddToFavorites(t *testing.T) {
+	service, api := newTestService(t)
+	api.SetCatalog([]servicetest.Track{
+		{ID: "3135556", Title: "Song", Artist: "Band", ISRC: "USRC17607839"},
+	})
+
+	track, err := service.SearchTrack(context.Background(), testTokens(), "Song", "Band", "")
+	if err != nil || track.ID != 3135556 {
+		t.Fatalf("SearchTrack = %+v, %v", track, err)
+	}
+
+	track, err = service.SearchTrackByISRC(context.Background(), testTokens(), "USRC17607839")
+	if err != nil || track.ID != 3135556 {
+		t.Fatalf("SearchTrackByISRC = %+v, %v", track, err)
+	}
+
+	if err := service.AddToFavorites(context.Background(), testTokens(), track.ID); err != nil {
+		t.Fatalf("AddToFavorites: %v", err)
+	}
+	if library := api.Library(servicetest.Deezer); len(library) != 1 || library[0].Title != "Song" {
+		t.Errorf("library after AddToFavorites = %+v", library)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	service, api := newTestService(t)
+	api.FailNext("/user/me", http.StatusServiceUnavailable)
+
+	profile, err := service.GetUserProfile(context.Background(), testTokens())
+	if err != nil {
+		t.Fatalf("GetUserProfile: %v", err)
+	}
+	if profile.ExternalID != "1000001" {
+		t.Errorf("profile = %+v", profile)
+	}
+}
@@ -4,12 +4,11 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +22,8 @@ type SpotifyService struct {
 	clientSecret string
 }
 
+var _ services.ServiceProvider = (*SpotifyService)(nil)
+
 // SpotifyTrack represents a Spotify track with comprehensive metadata
 type SpotifyTrack struct {
 	ID              string            `json:"id"`
@@ -85,9 +86,36 @@ type SpotifyTracks struct {
 	Total int `json:"total"`
 }
 
-// NewSpotifyService creates a new Spotify service with proper configuration
-func NewSpotifyService() *SpotifyService {
-	baseService := services.NewBaseService(services.BaseServiceConfig{
+// DefaultConfig returns the provider settings used when the providers config does not override them
+func DefaultConfig() services.ProviderConfig {
+	return services.ProviderConfig{
+		Name: "spotify",
+		Credentials: map[string]string{
+			"client_id":     "env:SPOTIFY_CLIENT_ID",
+			"client_secret": "env:SPOTIFY_CLIENT_SECRET",
+		},
+	}
+}
+
+// NewSpotifyService creates a new Spotify service with credentials from the environment
+func NewSpotifyService(logger *slog.Logger) *SpotifyService {
+	// Environment references cannot fail to resolve
+	service, _ := NewSpotifyServiceWithConfig(DefaultConfig(), logger)
+	return service
+}
+
+// NewSpotifyServiceWithConfig creates a new Spotify service with settings from the providers config
+func NewSpotifyServiceWithConfig(config services.ProviderConfig, logger *slog.Logger) (*SpotifyService, error) {
+	clientID, err := config.Credential("client_id")
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := config.Credential("client_secret")
+	if err != nil {
+		return nil, err
+	}
+
+	baseConfig := services.BaseServiceConfig{
 		Name:        "spotify",
 		DisplayName: "Spotify",
 		Category:    services.CategoryMusic,
@@ -102,25 +130,42 @@ func NewSpotifyService() *SpotifyService {
 			"user-read-recently-played",
 			"user-top-read",
 		},
+		Capabilities: services.Capabilities{
+			services.CapabilityReadFavorites,
+			services.CapabilityWriteFavorites,
+			services.CapabilityReadPlaylists,
+			services.CapabilityReadHistory,
+			services.CapabilitySearchISRC,
+		},
+		CapabilityScopes: map[services.Capability][]string{
+			services.CapabilityReadFavorites:  {"user-library-read"},
+			services.CapabilityWriteFavorites: {"user-library-modify"},
+			services.CapabilityReadPlaylists:  {"playlist-read-private"},
+			services.CapabilityReadHistory:    {"user-read-recently-played"},
+		},
 		RequestsPerSecond: 5,
 		BurstSize:         10,
 		HTTPTimeout:       30 * time.Second,
-	})
+		Logger:            logger,
+		AuthBaseURL:       "https://accounts.spotify.com",
+		APIBaseURL:        "https://api.spotify.com/v1",
+	}
+	config.Apply(&baseConfig)
 
 	return &SpotifyService{
-		BaseService:  baseService,
-		clientID:     os.Getenv("SPOTIFY_CLIENT_ID"),
-		clientSecret: os.Getenv("SPOTIFY_CLIENT_SECRET"),
-	}
+		BaseService:  services.NewBaseService(baseConfig),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}, nil
 }
 
-// GetAuthURL generates the OAuth authorization URL
-func (s *SpotifyService) GetAuthURL(state, redirectURL string) (string, error) {
+// GetAuthURL generates the OAuth authorization URL, protected by PKCE when a challenge is given
+func (s *SpotifyService) GetAuthURL(state, redirectURL, codeChallenge string) (string, error) {
 	if s.clientID == "" {
-		return "", fmt.Errorf("SPOTIFY_CLIENT_ID environment variable not set")
+		return "", fmt.Errorf("Spotify client_id credential not configured")
 	}
 
-	baseURL := "https://accounts.spotify.com/authorize"
+	baseURL := s.AuthURL("/authorize")
 	params := url.Values{
 		"client_id":     {s.clientID},
 		"response_type": {"code"},
@@ -129,6 +174,10 @@ func (s *SpotifyService) GetAuthURL(state, redirectURL string) (string, error) {
 		"state":         {state},
 		"show_dialog":   {"true"},
 	}
+	if codeChallenge != "" {
+		params.Set("code_challenge_method", "S256")
+		params.Set("code_challenge", codeChallenge)
+	}
 
 	authURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 	s.LogInfo("Generated auth URL for Spotify")
@@ -136,61 +185,36 @@ func (s *SpotifyService) GetAuthURL(state, redirectURL string) (string, error) {
 	return authURL, nil
 }
 
-// ExchangeCode exchanges authorization code for access tokens
-func (s *SpotifyService) ExchangeCode(code, redirectURL string) (*services.OAuthTokens, error) {
+// ExchangeCode exchanges authorization code for access tokens, proving the PKCE verifier when given
+func (s *SpotifyService) ExchangeCode(code, redirectURL, codeVerifier string) (*services.OAuthTokens, error) {
 	if s.clientID == "" || s.clientSecret == "" {
 		return nil, fmt.Errorf("Spotify credentials not configured")
 	}
 
-	data := url.Values{
+	form := url.Values{
 		"grant_type":   {"authorization_code"},
 		"code":         {code},
 		"redirect_uri": {redirectURL},
 	}
-
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token",
-		strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, err
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
 	}
 
-	auth := base64.StdEncoding.EncodeToString([]byte(s.clientID + ":" + s.clientSecret))
-	req.Header.Set("Authorization", "Basic "+auth)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := s.DoRequest(context.Background(), req)
+	var tokenResp spotifyTokenResponse
+	err := s.Do(context.Background(), services.APIRequest{
+		Method:  http.MethodPost,
+		URL:     s.AuthURL("/api/token"),
+		Form:    form,
+		Headers: s.clientAuthHeader(),
+		NoRetry: true, // Authorization codes are single-use
+	}, &tokenResp)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token exchange failed (status %d): %s", resp.StatusCode, body)
-	}
-
-	var tokenResp struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-		TokenType    string `json:"token_type"`
-		ExpiresIn    int    `json:"expires_in"`
-		Scope        string `json:"scope"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode token response: %w", err)
+		return nil, fmt.Errorf("token exchange failed: %w", err)
 	}
 
 	s.LogInfo("Successfully exchanged code for tokens")
 
-	return &services.OAuthTokens{
-		AccessToken:  tokenResp.AccessToken,
-		RefreshToken: tokenResp.RefreshToken,
-		TokenType:    tokenResp.TokenType,
-		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
-		Scope:        tokenResp.Scope,
-	}, nil
+	return tokenResp.tokens(), nil
 }
 
 // RefreshTokens refreshes expired access tokens
@@ -199,43 +223,18 @@ func (s *SpotifyService) RefreshTokens(refreshToken string) (*services.OAuthToke
 		return nil, fmt.Errorf("Spotify credentials not configured")
 	}
 
-	data := url.Values{
-		"grant_type":    {"refresh_token"},
-		"refresh_token": {refreshToken},
-	}
-
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token",
-		strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, err
-	}
-
-	auth := base64.StdEncoding.EncodeToString([]byte(s.clientID + ":" + s.clientSecret))
-	req.Header.Set("Authorization", "Basic "+auth)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := s.DoRequest(context.Background(), req)
+	var tokenResp spotifyTokenResponse
+	err := s.Do(context.Background(), services.APIRequest{
+		Method: http.MethodPost,
+		URL:    s.AuthURL("/api/token"),
+		Form: url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		},
+		Headers: s.clientAuthHeader(),
+	}, &tokenResp)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token refresh failed (status %d): %s", resp.StatusCode, body)
-	}
-
-	var tokenResp struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token,omitempty"`
-		TokenType    string `json:"token_type"`
-		ExpiresIn    int    `json:"expires_in"`
-		Scope        string `json:"scope"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode refresh response: %w", err)
+		return nil, fmt.Errorf("token refresh failed: %w", err)
 	}
 
 	if tokenResp.RefreshToken == "" {
@@ -244,13 +243,32 @@ func (s *SpotifyService) RefreshTokens(refreshToken string) (*services.OAuthToke
 
 	s.LogInfo("Successfully refreshed tokens")
 
+	return tokenResp.tokens(), nil
+}
+
+// spotifyTokenResponse is the body of a successful token endpoint call
+type spotifyTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+func (r spotifyTokenResponse) tokens() *services.OAuthTokens {
 	return &services.OAuthTokens{
-		AccessToken:  tokenResp.AccessToken,
-		RefreshToken: tokenResp.RefreshToken,
-		TokenType:    tokenResp.TokenType,
-		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
-		Scope:        tokenResp.Scope,
-	}, nil
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+		TokenType:    r.TokenType,
+		ExpiresAt:    time.Now().Add(time.Duration(r.ExpiresIn) * time.Second),
+		Scope:        r.Scope,
+	}
+}
+
+// clientAuthHeader authenticates token endpoint calls with the app credentials
+func (s *SpotifyService) clientAuthHeader() http.Header {
+	auth := base64.StdEncoding.EncodeToString([]byte(s.clientID + ":" + s.clientSecret))
+	return http.Header{"Authorization": {"Basic " + auth}}
 }
 
 // GetUserProfile retrieves user profile information
@@ -260,22 +278,6 @@ func (s *SpotifyService) GetUserProfile(ctx context.Context, tokens *services.OA
 		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	req, err := s.CreateAuthenticatedRequest(ctx, "GET", "https://api.spotify.com/v1/me", tokens)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.DoRequest(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get user profile (status %d): %s", resp.StatusCode, body)
-	}
-
 	var spotifyUser struct {
 		ID          string `json:"id"`
 		DisplayName string `json:"display_name"`
@@ -285,8 +287,12 @@ func (s *SpotifyService) GetUserProfile(ctx context.Context, tokens *services.OA
 		} `json:"images"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&spotifyUser); err != nil {
-		return nil, fmt.Errorf("failed to decode user profile: %w", err)
+	err = s.Do(ctx, services.APIRequest{
+		URL:    s.APIURL("/me"),
+		Tokens: tokens,
+	}, &spotifyUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
 	}
 
 	profile := &services.UserProfile{
@@ -304,8 +310,8 @@ func (s *SpotifyService) GetUserProfile(ctx context.Context, tokens *services.OA
 	return profile, nil
 }
 
-// SyncUserData fetches user data for real-time cross-service sync
-func (s *SpotifyService) SyncUserData(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) (*services.UserDataResult, error) {
+// GetUserData fetches user data for real-time cross-service sync
+func (s *SpotifyService) GetUserData(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) (*services.UserDataResult, error) {
 	s.LogInfo("Starting Spotify sync for user")
 
 	valid, err := s.ValidateTokens(tokens)
@@ -355,9 +361,6 @@ func (s *SpotifyService) SyncUserData(ctx context.Context, tokens *services.OAut
 			"last_sync":    lastSync,
 		},
 	}
-	if err != nil {
-		return nil, err
-	}
 
 	// Convert SyncResult[SpotifyTrack] to SyncResult[any]
 	anyResult := &services.UserDataResult{
@@ -379,72 +382,61 @@ func (s *SpotifyService) SyncUserData(ctx context.Context, tokens *services.OAut
 	return anyResult, nil
 }
 
-// fetchSavedTracks retrieves user's liked songs
-func (s *SpotifyService) fetchSavedTracks(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
-	var items []services.SyncItem
-	offset := 0
-	limit := 50
-
-	for {
-		if err := s.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
+// spotifyPage is the paging object Spotify wraps listings in
+type spotifyPage[T any] struct {
+	Items []T     `json:"items"`
+	Total int     `json:"total"`
+	Next  *string `json:"next"`
+}
 
-		url := fmt.Sprintf("https://api.spotify.com/v1/me/tracks?offset=%d&limit=%d", offset, limit)
-		req, err := s.CreateAuthenticatedRequest(ctx, "GET", url, tokens)
-		if err != nil {
-			return nil, err
-		}
+// spotifySavedTrack is a track in the user's library or a playlist
+type spotifySavedTrack struct {
+	AddedAt time.Time    `json:"added_at"`
+	Track   SpotifyTrack `json:"track"`
+}
 
-		resp, err := s.DoRequest(ctx, req)
+// listAll collects every page of a Spotify listing endpoint
+func listAll[T any](ctx context.Context, s *SpotifyService, tokens *services.OAuthTokens, endpoint string, limit int) ([]T, error) {
+	return services.PaginateOffset(ctx, limit, func(ctx context.Context, offset, limit int) (services.Page[T], error) {
+		var page spotifyPage[T]
+		err := s.Do(ctx, services.APIRequest{
+			URL:    endpoint,
+			Query:  url.Values{"offset": {strconv.Itoa(offset)}, "limit": {strconv.Itoa(limit)}},
+			Tokens: tokens,
+		}, &page)
 		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("Spotify API error (status %d): %s", resp.StatusCode, body)
-		}
-
-		var result struct {
-			Items []struct {
-				AddedAt time.Time    `json:"added_at"`
-				Track   SpotifyTrack `json:"track"`
-			} `json:"items"`
-			Total int     `json:"total"`
-			Next  *string `json:"next"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode saved tracks response: %w", err)
+			return services.Page[T]{}, err
 		}
+		return services.Page[T]{Items: page.Items, Total: page.Total, HasMore: page.Next != nil}, nil
+	})
+}
 
-		for _, item := range result.Items {
-			if item.AddedAt.After(lastSync) {
-				item.Track.AddedAt = &item.AddedAt
-
-				syncItem := services.SyncItem{
-					ExternalID: item.Track.ID,
-					ItemType:   "saved_track",
-					Action:     services.ActionCreate,
-					Data:       item.Track,
-				}
-				items = append(items, syncItem)
-			}
-		}
+// savedTrackItems converts saved tracks added after lastSync into sync items
+func savedTrackItems(saved []spotifySavedTrack, itemType string, lastSync time.Time) []services.SyncItem {
+	var items []services.SyncItem
+	for _, item := range saved {
+		if item.AddedAt.After(lastSync) {
+			item.Track.AddedAt = &item.AddedAt
 
-		if result.Next == nil {
-			break
+			items = append(items, services.SyncItem{
+				ExternalID: item.Track.ID,
+				ItemType:   itemType,
+				Action:     services.ActionCreate,
+				Data:       item.Track,
+			})
 		}
-		offset += limit
+	}
+	return items
+}
 
-		if offset > result.Total {
-			break
-		}
+// fetchSavedTracks retrieves user's liked songs
+func (s *SpotifyService) fetchSavedTracks(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
+	saved, err := listAll[spotifySavedTrack](ctx, s, tokens, s.APIURL("/me/tracks"), 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved tracks: %w", err)
 	}
 
-	return items, nil
+	return savedTrackItems(saved, "saved_track", lastSync), nil
 }
 
 // fetchUserPlaylists retrieves tracks from user's playlists
@@ -470,152 +462,28 @@ func (s *SpotifyService) fetchUserPlaylists(ctx context.Context, tokens *service
 
 // getUserPlaylists gets user's playlists
 func (s *SpotifyService) getUserPlaylists(ctx context.Context, tokens *services.OAuthTokens) ([]SpotifyPlaylist, error) {
-	var playlists []SpotifyPlaylist
-	offset := 0
-	limit := 50
-
-	for {
-		if err := s.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
-
-		url := fmt.Sprintf("https://api.spotify.com/v1/me/playlists?offset=%d&limit=%d", offset, limit)
-		req, err := s.CreateAuthenticatedRequest(ctx, "GET", url, tokens)
-		if err != nil {
-			return nil, err
-		}
-
-		resp, err := s.DoRequest(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("failed to get playlists (status %d): %s", resp.StatusCode, body)
-		}
-
-		var result struct {
-			Items []SpotifyPlaylist `json:"items"`
-			Total int               `json:"total"`
-			Next  *string           `json:"next"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode playlists response: %w", err)
-		}
-
-		playlists = append(playlists, result.Items...)
-
-		if result.Next == nil {
-			break
-		}
-		offset += limit
-
-		if offset > result.Total {
-			break
-		}
+	playlists, err := listAll[SpotifyPlaylist](ctx, s, tokens, s.APIURL("/me/playlists"), 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlists: %w", err)
 	}
-
 	return playlists, nil
 }
 
 // getPlaylistTracks gets tracks from a specific playlist
 func (s *SpotifyService) getPlaylistTracks(ctx context.Context, tokens *services.OAuthTokens, playlistID string, lastSync time.Time) ([]services.SyncItem, error) {
-	var items []services.SyncItem
-	offset := 0
-	limit := 100
-
-	for {
-		if err := s.WaitForRateLimit(ctx); err != nil {
-			return nil, err
-		}
-
-		url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?offset=%d&limit=%d", playlistID, offset, limit)
-		req, err := s.CreateAuthenticatedRequest(ctx, "GET", url, tokens)
-		if err != nil {
-			return nil, err
-		}
-
-		resp, err := s.DoRequest(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("failed to get playlist tracks (status %d): %s", resp.StatusCode, body)
-		}
-
-		var result struct {
-			Items []struct {
-				AddedAt time.Time    `json:"added_at"`
-				Track   SpotifyTrack `json:"track"`
-			} `json:"items"`
-			Total int     `json:"total"`
-			Next  *string `json:"next"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode playlist tracks response: %w", err)
-		}
-
-		for _, item := range result.Items {
-			if item.AddedAt.After(lastSync) {
-				item.Track.AddedAt = &item.AddedAt
-
-				syncItem := services.SyncItem{
-					ExternalID: item.Track.ID,
-					ItemType:   "playlist_track",
-					Action:     services.ActionCreate,
-					Data:       item.Track,
-				}
-				items = append(items, syncItem)
-			}
-		}
-
-		if result.Next == nil {
-			break
-		}
-		offset += limit
-
-		if offset > result.Total {
-			break
-		}
+	endpoint := s.APIURL(fmt.Sprintf("/playlists/%s/tracks", url.PathEscape(playlistID)))
+	saved, err := listAll[spotifySavedTrack](ctx, s, tokens, endpoint, 100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist tracks: %w", err)
 	}
 
-	return items, nil
+	return savedTrackItems(saved, "playlist_track", lastSync), nil
 }
 
 // fetchRecentlyPlayed retrieves user's recently played tracks
 func (s *SpotifyService) fetchRecentlyPlayed(ctx context.Context, tokens *services.OAuthTokens, lastSync time.Time) ([]services.SyncItem, error) {
 	var items []services.SyncItem
 
-	if err := s.WaitForRateLimit(ctx); err != nil {
-		return nil, err
-	}
-
-	url := fmt.Sprintf("https://api.spotify.com/v1/me/player/recently-played?limit=50&after=%d",
-		lastSync.UnixNano()/1000000)
-
-	req, err := s.CreateAuthenticatedRequest(ctx, "GET", url, tokens)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := s.DoRequest(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get recently played (status %d): %s", resp.StatusCode, body)
-	}
-
 	var result struct {
 		Items []struct {
 			PlayedAt time.Time    `json:"played_at"`
@@ -623,8 +491,16 @@ func (s *SpotifyService) fetchRecentlyPlayed(ctx context.Context, tokens *servic
 		} `json:"items"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode recently played response: %w", err)
+	err := s.Do(ctx, services.APIRequest{
+		URL: s.APIURL("/me/player/recently-played"),
+		Query: url.Values{
+			"limit": {"50"},
+			"after": {strconv.FormatInt(lastSync.UnixMilli(), 10)},
+		},
+		Tokens: tokens,
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recently played: %w", err)
 	}
 
 	for _, item := range result.Items {
@@ -653,13 +529,13 @@ func (s *SpotifyService) generateTrackChecksum(track SpotifyTrack) string {
 }
 
 // HealthCheck performs a health check on the Spotify API
-func (s *SpotifyService) HealthCheck() error {
-	req, err := http.NewRequestWithContext(context.Background(), "GET", "https://api.spotify.com/v1/browse/featured-playlists?limit=1", nil)
+func (s *SpotifyService) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.APIURL("/browse/featured-playlists?limit=1"), nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := s.DoRequest(context.Background(), req)
+	resp, err := s.DoRequest(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -682,39 +558,47 @@ func (s *SpotifyService) AddTrack(ctx context.Context, tokens *services.OAuthTok
 	return fmt.Errorf("AddTrack not yet implemented - awaiting universal track type implementation")
 }
 
-// SearchTrack searches for a track on Spotify using universal track data
+// SearchTrack searches for a track on Spotify using universal track data. Results are cached
+// since catalog searches repeat across users and syncs.
 func (s *SpotifyService) SearchTrack(ctx context.Context, tokens *services.OAuthTokens, title, artist, album string) (*SpotifyTrack, error) {
 	valid, err := s.ValidateTokens(tokens)
 	if err != nil || !valid {
 		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	if err := s.WaitForRateLimit(ctx); err != nil {
-		return nil, err
-	}
-
 	query := fmt.Sprintf("track:\"%s\" artist:\"%s\"", title, artist)
 	if album != "" {
 		query += fmt.Sprintf(" album:\"%s\"", album)
 	}
 
-	url := fmt.Sprintf("https://api.spotify.com/v1/search?q=%s&type=track&limit=1",
-		url.QueryEscape(query))
+	var result struct {
+		Tracks struct {
+			Items []SpotifyTrack `json:"items"`
+		} `json:"tracks"`
+	}
 
-	req, err := s.CreateAuthenticatedRequest(ctx, "GET", url, tokens)
+	err = s.Do(ctx, services.APIRequest{
+		URL:    s.APIURL("/search"),
+		Query:  url.Values{"q": {query}, "type": {"track"}, "limit": {"1"}},
+		Tokens: tokens,
+		Cache:  true,
+	}, &result)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
-	resp, err := s.DoRequest(ctx, req)
-	if err != nil {
-		return nil, err
+	if len(result.Tracks.Items) == 0 {
+		return nil, fmt.Errorf("track not found on Spotify")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("search failed (status %d): %s", resp.StatusCode, body)
+	return &result.Tracks.Items[0], nil
+}
+
+// SearchTrackByISRC looks up a track by its ISRC, the most reliable cross-service match
+func (s *SpotifyService) SearchTrackByISRC(ctx context.Context, tokens *services.OAuthTokens, isrc string) (*SpotifyTrack, error) {
+	valid, err := s.ValidateTokens(tokens)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid tokens: %w", err)
 	}
 
 	var result struct {
@@ -723,12 +607,18 @@ func (s *SpotifyService) SearchTrack(ctx context.Context, tokens *services.OAuth
 		} `json:"tracks"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	err = s.Do(ctx, services.APIRequest{
+		URL:    s.APIURL("/search"),
+		Query:  url.Values{"q": {"isrc:" + isrc}, "type": {"track"}, "limit": {"1"}},
+		Tokens: tokens,
+		Cache:  true,
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("ISRC search failed: %w", err)
 	}
 
 	if len(result.Tracks.Items) == 0 {
-		return nil, fmt.Errorf("track not found on Spotify")
+		return nil, fmt.Errorf("track with ISRC %s not found on Spotify", isrc)
 	}
 
 	return &result.Tracks.Items[0], nil
@@ -741,25 +631,14 @@ func (s *SpotifyService) SaveTrack(ctx context.Context, tokens *services.OAuthTo
 		return fmt.Errorf("invalid tokens: %w", err)
 	}
 
-	if err := s.WaitForRateLimit(ctx); err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("https://api.spotify.com/v1/me/tracks?ids=%s", trackID)
-	req, err := s.CreateAuthenticatedRequest(ctx, "PUT", url, tokens)
-	if err != nil {
-		return err
-	}
-
-	resp, err := s.DoRequest(ctx, req)
+	err = s.Do(ctx, services.APIRequest{
+		Method: http.MethodPut,
+		URL:    s.APIURL("/me/tracks"),
+		Query:  url.Values{"ids": {trackID}},
+		Tokens: tokens,
+	}, nil)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to save track (status %d): %s", resp.StatusCode, body)
+		return fmt.Errorf("failed to save track: %w", err)
 	}
 
 	s.LogInfo("Successfully saved track %s to user's library", trackID)
@@ -0,0 +1,176 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"syncer.net/core/services"
+	"syncer.net/core/services/servicetest"
+)
+
+func newTestService(t *testing.T) (*SpotifyService, *servicetest.FakeMusicAPI) {
+	t.Helper()
+	t.Setenv("SPOTIFY_CLIENT_ID", "test-client")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "test-secret")
+
+	api := servicetest.NewFakeMusicAPI(t)
+	service := NewSpotifyService(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	service.SetTransport(api.Transport())
+	return service, api
+}
+
+func testTokens() *services.OAuthTokens {
+	return &services.OAuthTokens{AccessToken: "test-token", TokenType: "Bearer", ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+func TestTokenExchangeAndRefresh(t *testing.T) {
+	service, _ := newTestService(t)
+
+	tokens, err := service.ExchangeCode("code-1", "https://syncer.example/callback", "")
+	if err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+	if tokens.AccessToken != "spotify-access-code-1" || tokens.RefreshToken != "spotify-refresh-code-1" {
+		t.Errorf("ExchangeCode = %+v", tokens)
+	}
+
+	refreshed, err := service.RefreshTokens(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if refreshed.AccessToken == "" || refreshed.RefreshToken != tokens.RefreshToken {
+		t.Errorf("RefreshTokens = %+v, want a new access token and the original refresh token", refreshed)
+	}
+}
+
+func TestRefreshRevokedGrant(t *testing.T) {
+	service, api := newTestService(t)
+	api.RevokeRefreshToken("spotify-refresh-revoked")
+
+	_, err := service.RefreshTokens("spotify-refresh-revoked")
+	if !services.IsRevokedGrant(err) {
+		t.Errorf("RefreshTokens error = %v, want a revoked grant", err)
+	}
+
+	if _, err := service.RefreshTokens("spotify-refresh-valid"); err != nil {
+		t.Errorf("RefreshTokens with a valid grant: %v", err)
+	}
+}
+
+func TestPKCEExchange(t *testing.T) {
+	service, api := newTestService(t)
+
+	pkce, err := services.NewPKCE()
+	if err != nil {
+		t.Fatalf("NewPKCE: %v", err)
+	}
+
+	authURL, err := service.GetAuthURL("state-1", "https://syncer.example/callback", pkce.Challenge)
+	if err != nil {
+		t.Fatalf("GetAuthURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL %q: %v", authURL, err)
+	}
+	if query := parsed.Query(); query.Get("code_challenge") != pkce.Challenge || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("auth URL %q does not carry the S256 challenge", authURL)
+	}
+
+	api.IssueCode("code-1", pkce.Challenge)
+	if _, err := service.ExchangeCode("code-1", "https://syncer.example/callback", "wrong-verifier"); err == nil {
+		t.Error("ExchangeCode accepted a verifier that does not match the challenge")
+	}
+	if _, err := service.ExchangeCode("code-1", "https://syncer.example/callback", pkce.Verifier); err != nil {
+		t.Errorf("ExchangeCode with the matching verifier: %v", err)
+	}
+}
+
+func TestGetUserDataPaginates(t *testing.T) {
+	service, api := newTestService(t)
+
+	var library []servicetest.Track
+	for i := range 120 {
+		library = append(library, servicetest.Track{ID: fmt.Sprintf("track%d", i), Title: fmt.Sprintf("Song %d", i), Artist: "Band"})
+	}
+	api.SetLibrary(servicetest.Spotify, library)
+	api.AddPlaylist(servicetest.Spotify, "playlist1", "Mix", library[:3])
+	api.SetHistory(servicetest.Spotify, library[:2])
+
+	result, err := service.GetUserData(context.Background(), testTokens(), time.Time{})
+	if err != nil {
+		t.Fatalf("GetUserData: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("GetUserData reported errors: %+v", result.Errors)
+	}
+
+	counts := make(map[string]int)
+	for _, item := range result.Items {
+		counts[item.ItemType]++
+	}
+	if counts["saved_track"] != 120 || counts["playlist_track"] != 3 || counts["recently_played"] != 2 {
+		t.Errorf("item counts = %v", counts)
+	}
+}
+
+func TestSearchAndSave(t *testing.T) {
+	service, api := newTestService(t)
+	api.SetCatalog([]servicetest.Track{
+		{ID: "abc", Title: "Song", Artist: "Band", ISRC: "USRC17607839"},
+	})
+
+	track, err := service.SearchTrack(context.Background(), testTokens(), "Song", "Band", "")
+	if err != nil || track.ID != "abc" {
+		t.Fatalf("SearchTrack = %+v, %v", track, err)
+	}
+
+	track, err = service.SearchTrackByISRC(context.Background(), testTokens(), "USRC17607839")
+	if err != nil || track.ID != "abc" {
+		t.Fatalf("SearchTrackByISRC = %+v, %v", track, err)
+	}
+
+	if _, err := service.SearchTrackByISRC(context.Background(), testTokens(), "UNKNOWN"); err == nil {
+		t.Error("SearchTrackByISRC found an unknown ISRC")
+	}
+
+	if err := service.SaveTrack(context.Background(), testTokens(), "abc"); err != nil {
+		t.Fatalf("SaveTrack: %v", err)
+	}
+	if library := api.Library(servicetest.Spotify); len(library) != 1 || library[0].Title != "Song" {
+		t.Errorf("library after SaveTrack = %+v", library)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	service, api := newTestService(t)
+	api.FailNext("/v1/me", http.StatusBadGateway)
+
+	profile, err := service.GetUserProfile(context.Background(), testTokens())
+	if err != nil {
+		t.Fatalf("GetUserProfile: %v", err)
+	}
+	if profile.ExternalID != "fake-spotify-user" {
+		t.Errorf("profile = %+v", profile)
+	}
+}
+
+func TestHealthCheckIgnoresClientErrors(t *testing.T) {
+	service, api := newTestService(t)
+
+	// The health endpoint answers 401 without a token, which still proves the API is up
+	if err := service.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+
+	api.FailNext("/v1/browse/featured-playlists", http.StatusServiceUnavailable)
+	if err := service.HealthCheck(context.Background()); err == nil {
+		t.Error("HealthCheck passed on a 503")
+	}
+}
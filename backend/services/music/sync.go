@@ -2,6 +2,7 @@ package music
 
 import (
 	"fmt"
+	"log/slog"
 
 	"github.com/jmoiron/sqlx"
 	"syncer.net/core/services"
@@ -15,10 +16,10 @@ type MusicSyncComponents struct {
 }
 
 // NewMusicSyncComponents creates music-specific sync implementations
-func NewMusicSyncComponents() *MusicSyncComponents {
+func NewMusicSyncComponents(logger *slog.Logger) *MusicSyncComponents {
 	return &MusicSyncComponents{
-		Transformer: NewMusicTrackTransformer(),
-		Adder:       NewMusicCrossServiceAdder(),
+		Transformer: NewMusicTrackTransformer(logger),
+		Adder:       NewMusicCrossServiceAdder(logger),
 	}
 }
 
@@ -28,8 +29,9 @@ func CreateMusicSyncEngine(
 	oauth *services.OAuthManager,
 	db *sqlx.DB,
 	workers int,
+	logger *slog.Logger,
 ) (*sync.SyncEngine, error) {
-	components := NewMusicSyncComponents()
+	components := NewMusicSyncComponents(logger)
 
 	// Create the sync engine with music-specific components
 	engine := sync.NewSyncEngine(
@@ -38,6 +40,7 @@ func CreateMusicSyncEngine(
 		components.Adder,       // Implements CrossServiceAdder
 		db,
 		workers,
+		logger,
 	)
 
 	return engine, nil
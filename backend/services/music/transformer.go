@@ -1,46 +1,55 @@
 package music
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"syncer.net/core/logging"
+	"syncer.net/core/services"
 	"syncer.net/core/sync"
 )
 
 // MusicTrackTransformer handles conversion between different music service formats
 // Implements the DataTransformer interface from core/sync
 type MusicTrackTransformer struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewMusicTrackTransformer creates a new music track transformer
-func NewMusicTrackTransformer() *MusicTrackTransformer {
+func NewMusicTrackTransformer(logger *slog.Logger) *MusicTrackTransformer {
 	return &MusicTrackTransformer{
-		logger: log.New(log.Writer(), "[MusicTransformer] ", log.LstdFlags),
+		logger: logging.Component(logger, "music_transformer"),
 	}
 }
 
 // TransformToUniversal converts service-specific data to universal format
 func (t *MusicTrackTransformer) TransformToUniversal(serviceName string, data any) (sync.UniversalItem, error) {
+	var track UniversalTrack
 	switch serviceName {
 	case "spotify":
-		return t.SpotifyToUniversal(data), nil
+		track = t.SpotifyToUniversal(data)
 	case "deezer":
-		return t.DeezerToUniversal(data), nil
+		track = t.DeezerToUniversal(data)
 	default:
 		return UniversalTrack{}, fmt.Errorf("unsupported music service: %s", serviceName)
 	}
+
+	if track.Title == "" {
+		return UniversalTrack{}, fmt.Errorf("unrecognized %s track data of type %T", serviceName, data)
+	}
+	return track, nil
 }
 
 // FindBestMatch finds the best matching track using multiple strategies
 func (t *MusicTrackTransformer) FindBestMatch(sourceItem sync.UniversalItem, candidates []sync.UniversalItem, threshold float64) sync.UniversalMatch {
 	sourceTrack, ok := sourceItem.(UniversalTrack)
 	if !ok {
-		t.logger.Printf("Source item is not a UniversalTrack")
+		t.logger.Warn("Source item is not a UniversalTrack", "type", fmt.Sprintf("%T", sourceItem))
 		return sync.UniversalMatch{}
 	}
 
@@ -101,26 +110,42 @@ func (t *MusicTrackTransformer) AnalyzeMatches(matches []sync.UniversalMatch) ma
 
 // SpotifyToUniversal converts Spotify track data to universal format
 func (t *MusicTrackTransformer) SpotifyToUniversal(trackData any) UniversalTrack {
-	// Use reflection or map access for generic data conversion
-	if trackMap, ok := trackData.(map[string]any); ok {
+	if trackMap, ok := trackFields(trackData); ok {
 		return t.mapToUniversalTrack(trackMap, "spotify")
 	}
 
-	t.logger.Printf("Warning: Unexpected Spotify track data type: %T", trackData)
+	t.logger.Warn("Unexpected track data type", logging.KeyProvider, "spotify", "type", fmt.Sprintf("%T", trackData))
 	return UniversalTrack{}
 }
 
 // DeezerToUniversal converts Deezer track data to universal format
 func (t *MusicTrackTransformer) DeezerToUniversal(trackData any) UniversalTrack {
-	// Use reflection or map access for generic data conversion
-	if trackMap, ok := trackData.(map[string]any); ok {
+	if trackMap, ok := trackFields(trackData); ok {
 		return t.mapToUniversalTrack(trackMap, "deezer")
 	}
 
-	t.logger.Printf("Warning: Unexpected Deezer track data type: %T", trackData)
+	t.logger.Warn("Unexpected track data type", logging.KeyProvider, "deezer", "type", fmt.Sprintf("%T", trackData))
 	return UniversalTrack{}
 }
 
+// trackFields returns track data as a generic map. Providers hand over their typed API structs,
+// which are converted through their JSON representation.
+func trackFields(trackData any) (map[string]any, bool) {
+	if trackMap, ok := trackData.(map[string]any); ok {
+		return trackMap, true
+	}
+
+	encoded, err := json.Marshal(trackData)
+	if err != nil {
+		return nil, false
+	}
+	var trackMap map[string]any
+	if err := json.Unmarshal(encoded, &trackMap); err != nil {
+		return nil, false
+	}
+	return trackMap, true
+}
+
 // mapToUniversalTrack converts a generic track map to UniversalTrack based on service type
 func (t *MusicTrackTransformer) mapToUniversalTrack(trackMap map[string]any, serviceName string) UniversalTrack {
 	switch serviceName {
@@ -262,10 +287,10 @@ func (t *MusicTrackTransformer) calculateMatchScore(track1, track2 UniversalTrac
 	// ISRC matching - highest priority
 	if track1.ISRC != "" && track2.ISRC != "" {
 		if track1.ISRC == track2.ISRC {
-			t.logger.Printf("Perfect ISRC match: %s", track1.ISRC)
+			t.logger.Debug("Perfect ISRC match", "isrc", track1.ISRC)
 			return 1.0 // Perfect match
 		}
-		t.logger.Printf("ISRC mismatch: %s != %s", track1.ISRC, track2.ISRC)
+		t.logger.Debug("ISRC mismatch", "source_isrc", track1.ISRC, "candidate_isrc", track2.ISRC)
 		return 0.0 // ISRC mismatch means different tracks
 	}
 
@@ -297,8 +322,9 @@ func (t *MusicTrackTransformer) calculateMatchScore(track1, track2 UniversalTrac
 	// Weighted scoring: Title and Artist are most important
 	score := (titleScore * 0.4) + (artistScore * 0.4) + (albumScore * 0.15) + (durationScore * 0.05)
 
-	t.logger.Printf("Match score: '%s' - '%s' = %.3f (title:%.3f, artist:%.3f, album:%.3f, duration:%.3f)",
-		track1.Title, track2.Title, score, titleScore, artistScore, albumScore, durationScore)
+	t.logger.Debug("Match score",
+		"source_title", track1.Title, "candidate_title", track2.Title, "score", score,
+		"title_score", titleScore, "artist_score", artistScore, "album_score", albumScore, "duration_score", durationScore)
 
 	return score
 }
@@ -446,6 +472,30 @@ func levenshteinDistance(s1, s2 string) int {
 	return matrix[len(s1)][len(s2)]
 }
 
+// SyncTypeRequirements returns the provider capabilities a music sync type needs. Listening
+// history is replayed into the target's favorites.
+func (t *MusicTrackTransformer) SyncTypeRequirements(syncType string) (sync.SyncTypeRequirements, bool) {
+	switch syncType {
+	case string(MusicSyncTypeFavorites):
+		return sync.SyncTypeRequirements{
+			Read:  services.Capabilities{services.CapabilityReadFavorites},
+			Write: services.Capabilities{services.CapabilityWriteFavorites},
+		}, true
+	case string(MusicSyncTypePlaylists):
+		return sync.SyncTypeRequirements{
+			Read:  services.Capabilities{services.CapabilityReadPlaylists},
+			Write: services.Capabilities{services.CapabilityWritePlaylists},
+		}, true
+	case string(MusicSyncTypeRecentlyPlayed):
+		return sync.SyncTypeRequirements{
+			Read:  services.Capabilities{services.CapabilityReadHistory},
+			Write: services.Capabilities{services.CapabilityWriteFavorites},
+		}, true
+	default:
+		return sync.SyncTypeRequirements{}, false
+	}
+}
+
 // MatchesSyncType checks if an item type matches the requested sync type for music domain
 func (t *MusicTrackTransformer) MatchesSyncType(itemType string, syncType string) bool {
 	switch syncType {
@@ -456,7 +506,7 @@ func (t *MusicTrackTransformer) MatchesSyncType(itemType string, syncType string
 	case string(MusicSyncTypeRecentlyPlayed):
 		return itemType == "recently_played" || itemType == "flow_track"
 	default:
-		t.logger.Printf("Unknown music sync type '%s', including all items", syncType)
+		t.logger.Warn("Unknown music sync type, including all items", "sync_type", syncType)
 		return true
 	}
 }